@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+/*
+Authenticator obtains credentials for an API and adds them to requests. Credentials
+that expire are refreshed transparently by Apply.
+*/
+type Authenticator interface {
+	// Authenticate obtains credentials up front, so a broken auth setup fails early
+	Authenticate(ctx context.Context) error
+	// Apply adds credentials to a request, refreshing them first when they have expired
+	Apply(req *http.Request) error
+	// Invalidate drops cached credentials, e.g. after the API answered 401
+	Invalidate()
+}
+
+/*
+Valuer is implemented by authenticators that obtain values at runtime, like a
+session login response, which configs can reference as {{auth.<path>}}.
+*/
+type Valuer interface {
+	Values() map[string]any
+}
+
+// typeAliases maps the names the model tends to come up with onto the auth types
+var typeAliases = map[string]string{
+	"none":                      models.AuthNone,
+	"api_key":                   models.AuthAPIKey,
+	"apikey":                    models.AuthAPIKey,
+	"api-key":                   models.AuthAPIKey,
+	"header":                    models.AuthAPIKey,
+	"basic":                     models.AuthBasic,
+	"bearer":                    models.AuthBearer,
+	"client_credentials":        models.AuthOAuth2ClientCredentials,
+	"oauth2_client_credentials": models.AuthOAuth2ClientCredentials,
+	"authorization_code":        models.AuthOAuth2AuthorizationCode,
+	"oauth2_authorization_code": models.AuthOAuth2AuthorizationCode,
+	"session":                   models.AuthSession,
+	"login":                     models.AuthSession,
+	"token":                     models.AuthSession,
+}
+
+/*
+Type normalizes the auth type, falling back to what the config's fields imply,
+so configs written before the variants existed keep working: one with just a
+login endpoint is a session.
+*/
+func Type(config models.Auth) string {
+	name := strings.ToLower(config.Type)
+
+	if t, ok := typeAliases[name]; ok {
+		// Older configs call a login that hands out a bearer token "bearer".
+		if t == models.AuthBearer && config.Bearer == nil && config.Endpoint != "" {
+			return models.AuthSession
+		}
+		return t
+	}
+
+	switch {
+	case config.APIKey != nil:
+		return models.AuthAPIKey
+	case config.Basic != nil:
+		return models.AuthBasic
+	case config.Bearer != nil:
+		return models.AuthBearer
+	case config.OAuth2 != nil && (config.OAuth2.Code != "" || config.OAuth2.RefreshToken != ""):
+		return models.AuthOAuth2AuthorizationCode
+	case config.OAuth2 != nil:
+		return models.AuthOAuth2ClientCredentials
+	case config.Endpoint != "":
+		return models.AuthSession
+	case name == "":
+		return models.AuthNone
+	}
+
+	return config.Type
+}
+
+/*
+New creates the Authenticator for an APIConfig's auth block. Credentials are only
+ever sent to the host of baseURL, so a docs site or a third-party redirect never
+sees them. Token and login requests are sent with client.
+*/
+func New(config models.Auth, baseURL string, client *http.Client) (Authenticator, error) {
+	var (
+		authenticator Authenticator
+		err           error
+	)
+
+	switch t := Type(config); t {
+	case models.AuthNone:
+		authenticator = &none{}
+	case models.AuthAPIKey:
+		authenticator, err = newAPIKey(config)
+	case models.AuthBasic:
+		authenticator, err = newBasic(config)
+	case models.AuthBearer:
+		authenticator, err = newBearer(config)
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		authenticator, err = newOAuth2(config, t, client)
+	case models.AuthSession:
+		authenticator, err = newSession(config, baseURL, client)
+	default:
+		return nil, fmt.Errorf("unknown auth type: %s", config.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("auth needs an absolute base URL, got %q", baseURL)
+	}
+
+	return &scoped{Authenticator: authenticator, host: base.Host}, nil
+}
+
+// scoped only applies credentials to requests for the API's own host
+type scoped struct {
+	Authenticator
+	host string
+}
+
+func (s *scoped) Apply(req *http.Request) error {
+	if !strings.EqualFold(req.URL.Host, s.host) {
+		return nil
+	}
+	return s.Authenticator.Apply(req)
+}
+
+func (s *scoped) Values() map[string]any {
+	if valuer, ok := s.Authenticator.(Valuer); ok {
+		return valuer.Values()
+	}
+	return nil
+}
+
+/*
+place puts a credential where the API expects it. Both API keys and session
+tokens can live in a header, query parameter or cookie.
+*/
+func place(req *http.Request, in, name, value string) error {
+	switch strings.ToLower(in) {
+	case "", "header":
+		req.Header.Set(name, value)
+	case "query":
+		query := req.URL.Query()
+		query.Set(name, value)
+		req.URL.RawQuery = query.Encode()
+	case "cookie":
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	default:
+		return fmt.Errorf("unknown credential location: %s", in)
+	}
+	return nil
+}
+
+/*
+secret resolves {{env.NAME}} placeholders in a configured credential. A
+placeholder that doesn't resolve is an error, rather than being sent as the
+credential itself.
+*/
+func secret(value string) (string, error) {
+	if err := resolvable(value); err != nil {
+		return "", err
+	}
+	return utils.RenderString(value, nil), nil
+}
+
+// resolvable checks every placeholder in a configured value resolves, e.g. that its environment variable is set
+func resolvable(value any) error {
+	if missing := utils.Unresolved(value, nil); len(missing) > 0 {
+		return fmt.Errorf("error resolving credential: %s is not set", strings.Join(missing, ", "))
+	}
+	return nil
+}
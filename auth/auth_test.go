@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func TestNewResolvesSecrets(t *testing.T) {
+	t.Setenv("TEST_SECRET", "s3cret")
+
+	tests := []struct {
+		name   string
+		config models.Auth
+		want   string
+	}{
+		{"api key", models.Auth{APIKey: &models.APIKeyAuth{Name: "X-Api-Key", Value: "{{env.TEST_SECRET}}"}}, "s3cret"},
+		{"bearer", models.Auth{Bearer: &models.BearerAuth{Token: "{{ env.TEST_SECRET }}"}}, "Bearer s3cret"},
+		{"literal", models.Auth{Bearer: &models.BearerAuth{Token: "literal"}}, "Bearer literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator, err := New(tt.config, "https://api.example.com", http.DefaultClient)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/employees", nil)
+			if err := authenticator.Apply(req); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			got := req.Header.Get("X-Api-Key") + req.Header.Get("Authorization")
+			if got != tt.want {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFailsOnUnsetSecrets(t *testing.T) {
+	const unset = "{{env.TEST_UNSET_SECRET}}"
+
+	tests := []struct {
+		name   string
+		config models.Auth
+	}{
+		{"api key", models.Auth{APIKey: &models.APIKeyAuth{Name: "X-Api-Key", Value: unset}}},
+		{"basic username", models.Auth{Basic: &models.BasicAuth{Username: unset, Password: "hunter2"}}},
+		{"basic password", models.Auth{Basic: &models.BasicAuth{Username: "ada", Password: unset}}},
+		{"bearer", models.Auth{Bearer: &models.BearerAuth{Token: "prefix-" + unset}}},
+		{"client id", models.Auth{OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/token", ClientID: unset}}},
+		{"client secret", models.Auth{OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/token", ClientID: "app", ClientSecret: unset}}},
+		{"refresh token", models.Auth{OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/token", ClientID: "app", RefreshToken: unset}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.config, "https://api.example.com", http.DefaultClient)
+			if err == nil || !strings.Contains(err.Error(), "env.TEST_UNSET_SECRET") {
+				t.Errorf("New error = %v, want it to name the unset variable", err)
+			}
+		})
+	}
+}
+
+func TestSessionFailsOnUnsetSecrets(t *testing.T) {
+	tests := []struct {
+		name  string
+		input models.Input
+	}{
+		{"header", models.Input{Headers: models.Headers{"X-Api-Key": "{{env.TEST_UNSET_SECRET}}"}}},
+		{"body", models.Input{Body: map[string]any{"username": "ada", "password": "{{env.TEST_UNSET_SECRET}}"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newLoginServer(t)
+
+			authenticator, err := New(models.Auth{
+				Endpoint: "/login",
+				Inputs:   []models.Input{tt.input},
+				Session:  &models.SessionAuth{TokenPath: "$.data.token"},
+			}, server.URL, server.Client())
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			if err := authenticator.Authenticate(context.Background()); err == nil {
+				t.Error("logged in with an unset secret, want an error")
+			}
+			if server.logins != 0 {
+				t.Errorf("sent %d logins with the placeholder", server.logins)
+			}
+		})
+	}
+}
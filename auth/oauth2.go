@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+/*
+oauth2 implements the client credentials and authorization code flows. The
+authorization code flow exchanges its code once and then lives off refresh
+tokens, picking up rotated refresh tokens as the provider hands them out.
+*/
+type oauth2 struct {
+	flow   string
+	config models.OAuth2Auth
+	client *http.Client
+	cache  tokenCache
+
+	mu           sync.Mutex
+	code         string
+	refreshToken string
+	values       map[string]any
+}
+
+func newOAuth2(config models.Auth, flow string, client *http.Client) (*oauth2, error) {
+	if config.OAuth2 == nil || config.OAuth2.TokenURL == "" || config.OAuth2.ClientID == "" {
+		return nil, fmt.Errorf("%s auth requires oauth2.token_url and oauth2.client_id", flow)
+	}
+
+	o := &oauth2{
+		flow:   flow,
+		config: *config.OAuth2,
+		client: client,
+	}
+
+	// The config keeps the resolved credentials, so every grant sends the same ones.
+	for _, value := range []*string{&o.config.ClientID, &o.config.ClientSecret, &o.config.Code, &o.config.RefreshToken} {
+		resolved, err := secret(*value)
+		if err != nil {
+			return nil, err
+		}
+		*value = resolved
+	}
+	o.code, o.refreshToken = o.config.Code, o.config.RefreshToken
+
+	if flow == models.AuthOAuth2AuthorizationCode && o.code == "" && o.refreshToken == "" {
+		return nil, fmt.Errorf("%s auth requires oauth2.code or oauth2.refresh_token, obtain one at %s", flow, config.OAuth2.AuthorizationURL)
+	}
+
+	o.cache.fetch = o.fetch
+	return o, nil
+}
+
+func (o *oauth2) Authenticate(ctx context.Context) error {
+	_, err := o.cache.get(ctx)
+	return err
+}
+
+func (o *oauth2) Apply(req *http.Request) error {
+	token, err := o.cache.get(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (o *oauth2) Invalidate() {
+	o.cache.invalidate()
+}
+
+func (o *oauth2) Values() map[string]any {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.values
+}
+
+/*
+fetch picks the grant: a refresh token when there is one, then a pending code,
+then client credentials. When the refresh token is rejected, e.g. because it was
+revoked or expired, it falls back to the grant the flow started with.
+*/
+func (o *oauth2) fetch(ctx context.Context) (string, time.Time, error) {
+	o.mu.Lock()
+	refreshToken := o.refreshToken
+	o.mu.Unlock()
+
+	if refreshToken == "" {
+		form, ok := o.original("")
+		if !ok {
+			return "", time.Time{}, fmt.Errorf("authorization code was used and no refresh token was issued, obtain a new code")
+		}
+		return o.request(ctx, form)
+	}
+
+	token, expiry, err := o.request(ctx, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+	if err == nil {
+		return token, expiry, nil
+	}
+
+	o.mu.Lock()
+	if o.refreshToken == refreshToken {
+		o.refreshToken = ""
+	}
+	o.mu.Unlock()
+
+	form, ok := o.original(refreshToken)
+	if !ok {
+		return "", time.Time{}, err
+	}
+
+	log.Warn("Refreshing the OAuth2 token failed, falling back to the original grant", "grant", form.Get("grant_type"), "error", err)
+	return o.request(ctx, form)
+}
+
+/*
+original returns the grant the flow started with, when it can still be used: a
+code that wasn't exchanged yet, the client credentials, or the configured
+refresh token when it isn't the one that just failed.
+*/
+func (o *oauth2) original(failed string) (url.Values, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	switch {
+	case o.code != "":
+		form := url.Values{"grant_type": {"authorization_code"}, "code": {o.code}}
+		if o.config.RedirectURL != "" {
+			form.Set("redirect_uri", o.config.RedirectURL)
+		}
+		// Codes are single use, from now on we rely on the refresh token.
+		o.code = ""
+		return form, true
+	case o.flow == models.AuthOAuth2ClientCredentials:
+		return url.Values{"grant_type": {"client_credentials"}}, true
+	}
+
+	if configured := o.config.RefreshToken; configured != "" && configured != failed {
+		return url.Values{"grant_type": {"refresh_token"}, "refresh_token": {configured}}, true
+	}
+	return nil, false
+}
+
+// request sends a grant to the token endpoint, keeping any refresh token it hands out
+func (o *oauth2) request(ctx context.Context, form url.Values) (string, time.Time, error) {
+	if len(o.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.config.Scopes, " "))
+	}
+	if o.config.Audience != "" {
+		form.Set("audience", o.config.Audience)
+	}
+
+	clientID, clientSecret := o.config.ClientID, o.config.ClientSecret
+	if strings.EqualFold(o.config.ClientAuth, "body") {
+		form.Set("client_id", clientID)
+		if clientSecret != "" {
+			form.Set("client_secret", clientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error creating token request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !strings.EqualFold(o.config.ClientAuth, "body") {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	log.Info("Requesting OAuth2 token", "grant", form.Get("grant_type"), "url", o.config.TokenURL)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error requesting token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error reading token response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", time.Time{}, fmt.Errorf("token request failed with status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token struct {
+		AccessToken  string  `json:"access_token"`
+		TokenType    string  `json:"token_type"`
+		ExpiresIn    float64 `json:"expires_in"`
+		RefreshToken string  `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", time.Time{}, fmt.Errorf("error parsing token response: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token response contains no access_token")
+	}
+
+	o.mu.Lock()
+	if token.RefreshToken != "" {
+		o.refreshToken = token.RefreshToken
+	}
+	o.values = map[string]any{
+		"access_token": token.AccessToken,
+		"token_type":   token.TokenType,
+		"expires_in":   token.ExpiresIn,
+	}
+	o.mu.Unlock()
+
+	return token.AccessToken, expiresIn(token.ExpiresIn), nil
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+/*
+tokenServer is a stand-in OAuth2 provider. It hands out numbered access tokens,
+rotates refresh tokens, and refuses the refresh tokens listed in revoked.
+*/
+type tokenServer struct {
+	*httptest.Server
+	expiresIn int
+	revoked   []string
+
+	mu      sync.Mutex
+	issued  int
+	grants  []form
+	clients []string
+}
+
+// form is what the token endpoint received for a grant
+type form map[string]string
+
+func newTokenServer(t *testing.T) *tokenServer {
+	t.Helper()
+
+	ts := &tokenServer{expiresIn: 3600}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		grant := form{}
+		for key := range r.PostForm {
+			grant[key] = r.PostForm.Get(key)
+		}
+
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+
+		ts.grants = append(ts.grants, grant)
+		if id, secret, ok := r.BasicAuth(); ok {
+			ts.clients = append(ts.clients, id+":"+secret)
+		} else {
+			ts.clients = append(ts.clients, grant["client_id"]+":"+grant["client_secret"])
+		}
+
+		if grant["grant_type"] == "refresh_token" && slices.Contains(ts.revoked, grant["refresh_token"]) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "invalid_grant"}`)
+			return
+		}
+
+		ts.issued++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", ts.issued),
+			"token_type":    "Bearer",
+			"expires_in":    ts.expiresIn,
+			"refresh_token": fmt.Sprintf("refresh-%d", ts.issued),
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// grantTypes returns the grant types the server received, in order
+func (ts *tokenServer) grantTypes() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	var out []string
+	for _, grant := range ts.grants {
+		out = append(out, grant["grant_type"])
+	}
+	return out
+}
+
+// authorization returns the Authorization header an authenticator adds to a request for the API
+func authorization(t *testing.T, authenticator Authenticator) string {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/employees", nil)
+	if err := authenticator.Apply(req); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	return req.Header.Get("Authorization")
+}
+
+func newTestOAuth2(t *testing.T, ts *tokenServer, config models.OAuth2Auth) Authenticator {
+	t.Helper()
+
+	config.TokenURL = ts.URL + "/token"
+	authenticator, err := New(models.Auth{OAuth2: &config}, "https://api.example.com", ts.Client())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return authenticator
+}
+
+func TestOAuth2Grants(t *testing.T) {
+	t.Setenv("TEST_CLIENT_SECRET", "s3cret")
+
+	tests := []struct {
+		name   string
+		config models.OAuth2Auth
+		grant  form
+		client string
+	}{
+		{
+			name:   "client credentials",
+			config: models.OAuth2Auth{ClientID: "client", ClientSecret: "{{env.TEST_CLIENT_SECRET}}", Scopes: []string{"read", "write"}},
+			grant:  form{"grant_type": "client_credentials", "scope": "read write"},
+			client: "client:s3cret",
+		},
+		{
+			name:   "client credentials in the body",
+			config: models.OAuth2Auth{ClientID: "client", ClientSecret: "{{env.TEST_CLIENT_SECRET}}", ClientAuth: "body", Audience: "api"},
+			grant:  form{"grant_type": "client_credentials", "audience": "api", "client_id": "client", "client_secret": "s3cret"},
+			client: "client:s3cret",
+		},
+		{
+			name:   "authorization code",
+			config: models.OAuth2Auth{ClientID: "client", Code: "code-1", RedirectURL: "https://app.example.com/callback"},
+			grant:  form{"grant_type": "authorization_code", "code": "code-1", "redirect_uri": "https://app.example.com/callback"},
+			client: "client:",
+		},
+		{
+			name:   "authorization code from a refresh token",
+			config: models.OAuth2Auth{ClientID: "client", RefreshToken: "refresh-0"},
+			grant:  form{"grant_type": "refresh_token", "refresh_token": "refresh-0"},
+			client: "client:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTokenServer(t)
+			authenticator := newTestOAuth2(t, ts, tt.config)
+
+			if err := authenticator.Authenticate(context.Background()); err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if got := authorization(t, authenticator); got != "Bearer access-1" {
+				t.Errorf("Authorization = %q, want the fetched token", got)
+			}
+
+			if len(ts.grants) != 1 {
+				t.Fatalf("sent %d grants, want the token to be cached after the first", len(ts.grants))
+			}
+			if fmt.Sprint(ts.grants[0]) != fmt.Sprint(tt.grant) {
+				t.Errorf("grant = %v, want %v", ts.grants[0], tt.grant)
+			}
+			if ts.clients[0] != tt.client {
+				t.Errorf("client = %q, want %q", ts.clients[0], tt.client)
+			}
+		})
+	}
+}
+
+func TestOAuth2RefreshesExpiredTokens(t *testing.T) {
+	ts := newTokenServer(t)
+	// Anything within the expiry margin counts as expired already.
+	ts.expiresIn = 1
+
+	authenticator := newTestOAuth2(t, ts, models.OAuth2Auth{ClientID: "client", Code: "code-1"})
+
+	for i := 1; i <= 3; i++ {
+		if got, want := authorization(t, authenticator), fmt.Sprintf("Bearer access-%d", i); got != want {
+			t.Errorf("request %d: Authorization = %q, want %q", i, got, want)
+		}
+	}
+
+	want := []string{"authorization_code", "refresh_token", "refresh_token"}
+	if got := ts.grantTypes(); !slices.Equal(got, want) {
+		t.Errorf("grants = %v, want %v", got, want)
+	}
+	if got := ts.grants[2]["refresh_token"]; got != "refresh-2" {
+		t.Errorf("refreshed with %q, want the rotated refresh-2", got)
+	}
+}
+
+func TestOAuth2InvalidateFetchesNewToken(t *testing.T) {
+	ts := newTokenServer(t)
+
+	// The API only accepts the latest token, as if the first one was revoked.
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		issued := ts.issued
+		ts.mu.Unlock()
+
+		if issued < 2 || r.Header.Get("Authorization") != fmt.Sprintf("Bearer access-%d", issued) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	config := models.OAuth2Auth{ClientID: "client", TokenURL: ts.URL + "/token"}
+	authenticator, err := New(models.Auth{Type: "client_credentials", OAuth2: &config}, api.URL, ts.Client())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	send := func() int {
+		req, _ := http.NewRequest(http.MethodGet, api.URL+"/employees", nil)
+		if err := authenticator.Apply(req); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		resp, err := api.Client().Do(req)
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := send(); status != http.StatusUnauthorized {
+		t.Fatalf("first request got %d, want 401", status)
+	}
+
+	authenticator.Invalidate()
+
+	if status := send(); status != http.StatusOK {
+		t.Errorf("request after Invalidate got %d, want 200", status)
+	}
+	if got := ts.grantTypes(); len(got) != 2 {
+		t.Errorf("grants = %v, want a new token after Invalidate", got)
+	}
+}
+
+func TestOAuth2FallsBackWhenRefreshFails(t *testing.T) {
+	tests := []struct {
+		name   string
+		config models.OAuth2Auth
+		grants []string
+	}{
+		{
+			name:   "client credentials",
+			config: models.OAuth2Auth{ClientID: "client", ClientSecret: "secret"},
+			grants: []string{"client_credentials", "refresh_token", "client_credentials"},
+		},
+		{
+			name:   "configured refresh token",
+			config: models.OAuth2Auth{ClientID: "client", RefreshToken: "refresh-0"},
+			grants: []string{"refresh_token", "refresh_token", "refresh_token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTokenServer(t)
+			ts.revoked = []string{"refresh-1"}
+
+			authenticator := newTestOAuth2(t, ts, tt.config)
+
+			if got := authorization(t, authenticator); got != "Bearer access-1" {
+				t.Fatalf("Authorization = %q, want the first token", got)
+			}
+
+			authenticator.Invalidate()
+
+			if got := authorization(t, authenticator); got != "Bearer access-2" {
+				t.Errorf("Authorization = %q, want a token from the original grant", got)
+			}
+			if got := ts.grantTypes(); !slices.Equal(got, tt.grants) {
+				t.Errorf("grants = %v, want %v", got, tt.grants)
+			}
+		})
+	}
+}
+
+func TestOAuth2FailsWithoutGrantToFallBackOn(t *testing.T) {
+	ts := newTokenServer(t)
+	ts.revoked = []string{"refresh-1"}
+
+	authenticator := newTestOAuth2(t, ts, models.OAuth2Auth{ClientID: "client", Code: "code-1"})
+	authorization(t, authenticator)
+	authenticator.Invalidate()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/employees", nil)
+	if err := authenticator.Apply(req); err == nil {
+		t.Fatal("Apply succeeded, but the code was used and the refresh token revoked")
+	}
+}
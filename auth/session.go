@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+/*
+session logs in with the config's login request and extracts a token from the
+response. It logs in again when the token expires or gets invalidated. Without a
+token path, cookies set by the login are sent instead, and the response values
+are still available to configs as {{auth.<path>}}.
+*/
+type session struct {
+	config  models.Auth
+	login   *url.URL
+	client  *http.Client
+	cache   tokenCache
+	mu      sync.Mutex
+	values  map[string]any
+	cookies []*http.Cookie
+}
+
+func newSession(config models.Auth, baseURL string, client *http.Client) (*session, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("session auth requires a login endpoint")
+	}
+
+	login, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid login endpoint %q: %w", config.Endpoint, err)
+	}
+
+	if !login.IsAbs() {
+		base, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+		}
+		login = base.JoinPath(login.Path).ResolveReference(&url.URL{RawQuery: login.RawQuery})
+	}
+
+	s := &session{config: config, login: login, client: client}
+	s.cache.fetch = s.fetch
+
+	return s, nil
+}
+
+func (s *session) Authenticate(ctx context.Context) error {
+	_, err := s.cache.get(ctx)
+	return err
+}
+
+func (s *session) Apply(req *http.Request) error {
+	token, err := s.cache.get(req.Context())
+	if err != nil {
+		return err
+	}
+
+	if s.config.Session == nil || s.config.Session.TokenPath == "" {
+		s.mu.Lock()
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+		s.mu.Unlock()
+		return nil
+	}
+
+	name, prefix := s.config.Session.Name, s.config.Session.Prefix
+	if name == "" {
+		name = "Authorization"
+	}
+	if prefix == "" && strings.EqualFold(name, "Authorization") {
+		prefix = "Bearer "
+	}
+
+	return place(req, s.config.Session.In, name, prefix+token)
+}
+
+func (s *session) Invalidate() {
+	s.cache.invalidate()
+}
+
+func (s *session) Values() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values
+}
+
+func (s *session) fetch(ctx context.Context) (string, time.Time, error) {
+	method := strings.ToUpper(s.config.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	var input models.Input
+	if len(s.config.Inputs) > 0 {
+		input = s.config.Inputs[0]
+	}
+
+	headers := http.Header{}
+	for key, value := range input.Headers {
+		rendered, err := secret(value)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		headers.Set(key, rendered)
+	}
+
+	if err := resolvable(input.Body); err != nil {
+		return "", time.Time{}, err
+	}
+
+	var reader io.Reader
+	if body, _ := utils.Render(input.Body, nil).(map[string]any); len(body) > 0 {
+		if strings.Contains(headers.Get("Content-Type"), "x-www-form-urlencoded") {
+			form := url.Values{}
+			for key, value := range body {
+				form.Set(key, utils.Stringify(value))
+			}
+			reader = strings.NewReader(form.Encode())
+		} else {
+			data, err := json.Marshal(body)
+			if err != nil {
+				return "", time.Time{}, fmt.Errorf("error encoding login body: %w", err)
+			}
+			reader = bytes.NewReader(data)
+			if headers.Get("Content-Type") == "" {
+				headers.Set("Content-Type", "application/json")
+			}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.login.String(), reader)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error creating login request: %w", err)
+	}
+	req.Header = headers
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	log.Info("Logging in", "url", s.login)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error logging in: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error reading login response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", time.Time{}, fmt.Errorf("login failed with status code %d: %s", resp.StatusCode, string(data))
+	}
+
+	var response any
+	if err := json.Unmarshal(data, &response); err != nil {
+		response = map[string]any{"body": string(data)}
+	}
+
+	values, _ := response.(map[string]any)
+	if len(s.config.Outputs) > 0 {
+		values = map[string]any{}
+		for _, output := range s.config.Outputs {
+			for name, path := range output {
+				if value, ok := utils.Lookup(response, path); ok {
+					values[name] = value
+				}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.values = values
+	s.cookies = resp.Cookies()
+	s.mu.Unlock()
+
+	if s.config.Session == nil || s.config.Session.TokenPath == "" {
+		// Nothing to extract, the login itself is the credential.
+		return "session", s.expiry(response), nil
+	}
+
+	token, ok := utils.Lookup(response, s.config.Session.TokenPath)
+	if !ok || utils.Stringify(token) == "" {
+		return "", time.Time{}, fmt.Errorf("login response has no token at %s", s.config.Session.TokenPath)
+	}
+
+	return utils.Stringify(token), s.expiry(response), nil
+}
+
+func (s *session) expiry(response any) time.Time {
+	if s.config.Session == nil {
+		return time.Time{}
+	}
+
+	if s.config.Session.ExpiresInPath != "" {
+		if value, ok := utils.Lookup(response, s.config.Session.ExpiresInPath); ok {
+			if seconds, ok := value.(float64); ok {
+				return expiresIn(seconds)
+			}
+		}
+	}
+
+	return expiresIn(float64(s.config.Session.TTL))
+}
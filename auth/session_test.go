@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// loginServer is a stand-in login endpoint that hands out a new token and cookie on every login
+type loginServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	logins int
+	bodies []map[string]any
+}
+
+func newLoginServer(t *testing.T) *loginServer {
+	t.Helper()
+
+	ls := &loginServer{}
+	ls.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+
+		ls.mu.Lock()
+		ls.logins++
+		ls.bodies = append(ls.bodies, body)
+		n := ls.logins
+		ls.mu.Unlock()
+
+		token := "token-" + string(rune('0'+n))
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: token})
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"token": token, "expires_in": 3600, "user_id": 42},
+		})
+	}))
+	t.Cleanup(ls.Close)
+
+	return ls
+}
+
+func TestSession(t *testing.T) {
+	t.Setenv("TEST_PASSWORD", "hunter2")
+
+	tests := []struct {
+		name    string
+		session *models.SessionAuth
+		apply   func(req *http.Request) string
+		want    string
+	}{
+		{
+			name:    "token in the Authorization header",
+			session: &models.SessionAuth{TokenPath: "$.data.token", ExpiresInPath: "$.data.expires_in"},
+			apply:   func(req *http.Request) string { return req.Header.Get("Authorization") },
+			want:    "Bearer token-1",
+		},
+		{
+			name:    "token in a query parameter",
+			session: &models.SessionAuth{TokenPath: "$.data.token", In: "query", Name: "access_token"},
+			apply:   func(req *http.Request) string { return req.URL.Query().Get("access_token") },
+			want:    "token-1",
+		},
+		{
+			name:    "token in a custom header",
+			session: &models.SessionAuth{TokenPath: "$.data.token", Name: "X-Session", Prefix: "Token "},
+			apply:   func(req *http.Request) string { return req.Header.Get("X-Session") },
+			want:    "Token token-1",
+		},
+		{
+			name: "cookies without a token path",
+			apply: func(req *http.Request) string {
+				cookie, err := req.Cookie("session")
+				if err != nil {
+					return ""
+				}
+				return cookie.Value
+			},
+			want: "token-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := newLoginServer(t)
+
+			config := models.Auth{
+				Type:     "session",
+				Endpoint: "/login",
+				Inputs:   []models.Input{{Body: map[string]any{"username": "ada", "password": "{{env.TEST_PASSWORD}}"}}},
+				Outputs:  []models.Output{{"user": "$.data.user_id"}},
+				Session:  tt.session,
+			}
+			authenticator, err := New(config, ls.URL, ls.Client())
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			if err := authenticator.Authenticate(context.Background()); err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, ls.URL+"/employees", nil)
+			if err := authenticator.Apply(req); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got := tt.apply(req); got != tt.want {
+				t.Errorf("credential = %q, want %q", got, tt.want)
+			}
+
+			if want := map[string]any{"username": "ada", "password": "hunter2"}; !reflect.DeepEqual(ls.bodies[0], want) {
+				t.Errorf("login body = %v, want %v", ls.bodies[0], want)
+			}
+			if got := authenticator.(Valuer).Values(); !reflect.DeepEqual(got, map[string]any{"user": 42.0}) {
+				t.Errorf("Values = %v, want the declared outputs", got)
+			}
+			if ls.logins != 1 {
+				t.Errorf("logged in %d times, want the session to be reused", ls.logins)
+			}
+		})
+	}
+}
+
+func TestSessionLogsInAgainWhenInvalidated(t *testing.T) {
+	ls := newLoginServer(t)
+
+	config := models.Auth{Type: "session", Endpoint: ls.URL + "/login", Session: &models.SessionAuth{TokenPath: "$.data.token"}}
+	authenticator, err := New(config, ls.URL, ls.Client())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if got := authorization(t, scopedTo(authenticator, "api.example.com")); got != "Bearer token-1" {
+		t.Fatalf("Authorization = %q, want the first token", got)
+	}
+
+	authenticator.Invalidate()
+
+	if got := authorization(t, scopedTo(authenticator, "api.example.com")); got != "Bearer token-2" {
+		t.Errorf("Authorization = %q, want a token from a new login", got)
+	}
+}
+
+// scopedTo rescopes an authenticator to another host, so its credentials can be checked on any request
+func scopedTo(authenticator Authenticator, host string) Authenticator {
+	return &scoped{Authenticator: authenticator.(*scoped).Authenticator, host: host}
+}
+
+func TestScopedOnlySendsCredentialsToTheAPI(t *testing.T) {
+	authenticator, err := New(models.Auth{Type: "bearer", Bearer: &models.BearerAuth{Token: "secret"}}, "https://api.example.com/v3", nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.example.com/v3/employees", "Bearer secret"},
+		{"https://API.example.com/other", "Bearer secret"},
+		{"https://docs.example.com/v3/employees", ""},
+		{"https://api.example.com.evil.test/v3/employees", ""},
+		{"https://api.example.com:8443/v3/employees", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err := authenticator.Apply(req); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// none is used for public APIs
+type none struct{}
+
+func (n *none) Authenticate(context.Context) error { return nil }
+func (n *none) Apply(*http.Request) error          { return nil }
+func (n *none) Invalidate()                        {}
+
+// apiKey sends a static key in a header, query parameter or cookie
+type apiKey struct {
+	in, name, value string
+}
+
+func newAPIKey(config models.Auth) (*apiKey, error) {
+	if config.APIKey == nil || config.APIKey.Name == "" || config.APIKey.Value == "" {
+		return nil, fmt.Errorf("api_key auth requires api_key.name and api_key.value")
+	}
+
+	value, err := secret(config.APIKey.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiKey{
+		in:    config.APIKey.In,
+		name:  config.APIKey.Name,
+		value: config.APIKey.Prefix + value,
+	}, nil
+}
+
+func (a *apiKey) Authenticate(context.Context) error { return nil }
+func (a *apiKey) Invalidate()                        {}
+
+func (a *apiKey) Apply(req *http.Request) error {
+	return place(req, a.in, a.name, a.value)
+}
+
+// basic sends a username and password using HTTP basic authentication
+type basic struct {
+	username, password string
+}
+
+func newBasic(config models.Auth) (*basic, error) {
+	if config.Basic == nil || config.Basic.Username == "" {
+		return nil, fmt.Errorf("basic auth requires basic.username")
+	}
+
+	username, err := secret(config.Basic.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := secret(config.Basic.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &basic{username: username, password: password}, nil
+}
+
+func (b *basic) Authenticate(context.Context) error { return nil }
+func (b *basic) Invalidate()                        {}
+
+func (b *basic) Apply(req *http.Request) error {
+	req.SetBasicAuth(b.username, b.password)
+	return nil
+}
+
+// bearer sends a static token in the Authorization header
+type bearer struct {
+	token string
+}
+
+func newBearer(config models.Auth) (*bearer, error) {
+	if config.Bearer == nil || config.Bearer.Token == "" {
+		return nil, fmt.Errorf("bearer auth requires bearer.token")
+	}
+
+	token, err := secret(config.Bearer.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bearer{token: token}, nil
+}
+
+func (b *bearer) Authenticate(context.Context) error { return nil }
+func (b *bearer) Invalidate()                        {}
+
+func (b *bearer) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// expiryMargin renews tokens a little before they expire, so requests in flight don't race the expiry
+const expiryMargin = 30 * time.Second
+
+/*
+tokenCache holds a token that expires, fetching a new one through fetch whenever
+it is missing or about to expire. Concurrent callers share a single fetch.
+*/
+type tokenCache struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	fetch  func(ctx context.Context) (string, time.Time, error)
+}
+
+func (tc *tokenCache) get(ctx context.Context) (string, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if tc.token != "" && (tc.expiry.IsZero() || time.Now().Add(expiryMargin).Before(tc.expiry)) {
+		return tc.token, nil
+	}
+
+	token, expiry, err := tc.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tc.token, tc.expiry = token, expiry
+	return token, nil
+}
+
+func (tc *tokenCache) invalidate() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.token = ""
+	tc.expiry = time.Time{}
+}
+
+// expiresIn turns a lifetime in seconds into an expiry time, zero meaning it doesn't expire
+func expiresIn(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(seconds * float64(time.Second)))
+}
@@ -2,6 +2,8 @@ package browser
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
 	"github.com/charmbracelet/log"
@@ -13,20 +15,30 @@ import (
 // Global page instance to be reused across browser operations
 var page *rod.Page
 var browser *rod.Browser
-
-func init() {
-	log.Info("Initializing browser")
-	u := launcher.New().
-		Set("user-data-dir", "path").
-		Delete("--headless").
-		MustLaunch()
-
-	browser = rod.New().ControlURL(u).MustConnect()
-	page = browser.MustPage("")
-	log.Info("Browser initialized successfully")
+var launch sync.Once
+
+/*
+currentPage launches the browser the first time a browser tool needs it, so
+commands that never touch the browser don't start Chrome.
+*/
+func currentPage() *rod.Page {
+	launch.Do(func() {
+		log.Info("Initializing browser")
+		u := launcher.New().
+			Set("user-data-dir", "path").
+			Delete("--headless").
+			MustLaunch()
+
+		browser = rod.New().ControlURL(u).MustConnect()
+		page = browser.MustPage("")
+		log.Info("Browser initialized successfully")
+	})
+
+	return page
 }
 
 type BrowserExtractor struct {
+	tabbed
 	ToolName        string           `json:"name" jsonschema:"description=The name of the tool,required"`
 	ToolDescription string           `json:"description" jsonschema:"description=The description of the tool,required"`
 	ToolParameters  models.Parameter `json:"parameters" jsonschema:"description=The parameters of the tool,required"`
@@ -70,7 +82,7 @@ func (be *BrowserExtractor) Execute(args map[string]any) (string, error) {
 	}
 
 	log.Info("Finding element in page")
-	element := page.MustElement(selector)
+	element := be.page().MustElement(selector)
 	if element == nil {
 		log.Error("Element not found", "selector", selector)
 		return "", fmt.Errorf("element not found: %s", selector)
@@ -108,6 +120,7 @@ func (be *BrowserExtractor) Schema() any {
 }
 
 type BrowserNavigator struct {
+	tabbed
 	ToolName        string           `json:"name" jsonschema:"description=The name of the tool,required"`
 	ToolDescription string           `json:"description" jsonschema:"description=The description of the tool,required"`
 	ToolParameters  models.Parameter `json:"parameters" jsonschema:"description=The parameters of the tool,required"`
@@ -153,7 +166,7 @@ func (bn *BrowserNavigator) Execute(args map[string]any) (string, error) {
 	}
 
 	log.Info("Navigating browser to URL", "url", url)
-	page.MustNavigate(url).MustWaitStable()
+	bn.page().MustNavigate(url).MustWaitStable()
 	log.Info("Successfully navigated to URL and page is stable", "url")
 	return "Navigated to " + url, nil
 }
@@ -172,6 +185,7 @@ func (bn *BrowserNavigator) Schema() any {
 }
 
 type BrowserClicker struct {
+	tabbed
 	ToolName        string           `json:"name" jsonschema:"description=The name of the tool,required"`
 	ToolDescription string           `json:"description" jsonschema:"description=The description of the tool,required"`
 	ToolParameters  models.Parameter `json:"parameters" jsonschema:"description=The parameters of the tool,required"`
@@ -217,7 +231,7 @@ func (bc *BrowserClicker) Execute(args map[string]any) (string, error) {
 	}
 
 	log.Info("Clicking element with selector", "selector", selector)
-	page.MustElement(selector).MustClick()
+	bc.page().MustElement(selector).MustClick()
 	log.Info("Successfully clicked element", "selector", selector)
 	return "clicked " + selector, nil
 }
@@ -236,6 +250,7 @@ func (bc *BrowserClicker) Schema() any {
 }
 
 type BrowserJavaScriptExecutor struct {
+	tabbed
 	ToolName        string           `json:"name" jsonschema:"description=The name of the tool,required"`
 	ToolDescription string           `json:"description" jsonschema:"description=The description of the tool,required"`
 	ToolParameters  models.Parameter `json:"parameters" jsonschema:"description=The parameters of the tool,required"`
@@ -281,7 +296,7 @@ func (bje *BrowserJavaScriptExecutor) Execute(args map[string]any) (string, erro
 	}
 
 	log.Info("Executing JavaScript in browser", "scriptLength", len(script))
-	out := page.MustEval(script).Str()
+	out := bje.page().MustEval(script).Str()
 	log.Info("JavaScript execution successful", "outputLength", len(out))
 
 	return out, nil
@@ -315,3 +330,124 @@ func ExtractPageContent(browser *rod.Browser, selector string) (string, error) {
 	log.Info("Successfully extracted and converted content", "markdownSize", len(markdown))
 	return markdown, nil
 }
+
+/*
+Visit navigates to a URL and returns the page's content as markdown, with the
+absolute URLs of the links on it, for crawlers that follow documentation around
+without going through the model.
+*/
+func Visit(url string) (string, []string, error) {
+	log.Info("Visiting page", "url", url)
+
+	p := currentPage()
+	if err := p.Navigate(url); err != nil {
+		return "", nil, fmt.Errorf("error navigating to %s: %w", url, err)
+	}
+	if err := p.WaitStable(time.Second); err != nil {
+		return "", nil, fmt.Errorf("error waiting for %s to load: %w", url, err)
+	}
+
+	body, err := p.Element("body")
+	if err != nil {
+		return "", nil, fmt.Errorf("error finding the body of %s: %w", url, err)
+	}
+
+	html, err := body.HTML()
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting HTML: %w", err)
+	}
+
+	markdown, err := htmltomarkdown.ConvertString(html)
+	if err != nil {
+		return "", nil, fmt.Errorf("error converting HTML to markdown: %w", err)
+	}
+
+	result, err := p.Eval(`() => Array.from(document.querySelectorAll("a[href]"), a => a.href)`)
+	if err != nil {
+		return "", nil, fmt.Errorf("error collecting links: %w", err)
+	}
+
+	var links []string
+	for _, link := range result.Value.Arr() {
+		links = append(links, link.Str())
+	}
+
+	return markdown, links, nil
+}
+
+/*
+Tab is a browser tab of its own, for agents that browse at the same time as
+others and would otherwise navigate each other's page away.
+*/
+type Tab struct {
+	page *rod.Page
+}
+
+// NewTab opens a new tab in the shared browser, launching it if needed
+func NewTab() *Tab {
+	currentPage()
+	return &Tab{page: browser.MustPage("")}
+}
+
+// Close closes the tab
+func (t *Tab) Close() error {
+	return t.page.Close()
+}
+
+// Tools returns the browser tools, working in this tab
+func (t *Tab) Tools() []models.ToolType {
+	return tools(t)
+}
+
+// Tools returns the browser tools, working in the shared page
+func Tools() []models.ToolType {
+	return tools(nil)
+}
+
+func tools(tab *Tab) []models.ToolType {
+	extractor := NewBrowserExtractor().(*BrowserExtractor)
+	navigator := NewBrowserNavigator().(*BrowserNavigator)
+	executor := NewBrowserJavaScriptExecutor().(*BrowserJavaScriptExecutor)
+	clicker := NewBrowserClicker().(*BrowserClicker)
+
+	extractor.tab, navigator.tab, executor.tab, clicker.tab = tab, tab, tab, tab
+	return []models.ToolType{extractor, navigator, executor, clicker}
+}
+
+// tabbed is what the browser tools share: the tab they work in, the shared page when there is none
+type tabbed struct {
+	tab *Tab
+}
+
+func (t tabbed) page() *rod.Page {
+	if t.tab != nil {
+		return t.tab.page
+	}
+	return currentPage()
+}
+
+// Sequence keys the tool's calls by tab, since what they do depends on what earlier calls did in it
+func (t tabbed) Sequence() string {
+	if t.tab != nil {
+		return "tab " + string(t.tab.page.TargetID)
+	}
+	return "page"
+}
+
+// URL returns the address of the page the tool works in, empty when the browser never started
+func (t tabbed) URL() string {
+	p := page
+	if t.tab != nil {
+		p = t.tab.page
+	}
+	if p == nil {
+		return ""
+	}
+
+	info, err := p.Info()
+	if err != nil {
+		log.Warn("Could not get the page URL", "error", err)
+		return ""
+	}
+	return info.URL
+}
@@ -0,0 +1,702 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// envPlaceholder matches the {{env.NAME}} placeholders credentials are written as
+var envPlaceholder = regexp.MustCompile(`\{\{\s*env\.([A-Za-z0-9_]+)\s*\}\}`)
+
+// tokenCacheSource caches the tokens of OAuth2 and session logins until they expire
+const tokenCacheSource = `
+// expiryMargin renews tokens a little before they expire, so requests in flight don't race the expiry
+const expiryMargin = 30 * time.Second
+
+// tokenCache holds a token until it expires, fetching a new one when it is missing or about to expire
+type tokenCache struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (tc *tokenCache) get(ctx context.Context, fetch func(ctx context.Context) (string, time.Time, error)) (string, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if tc.token != "" && (tc.expiry.IsZero() || time.Now().Add(expiryMargin).Before(tc.expiry)) {
+		return tc.token, nil
+	}
+
+	token, expiry, err := fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tc.token, tc.expiry = token, expiry
+	return token, nil
+}
+
+func (tc *tokenCache) clear() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.token = ""
+	tc.expiry = time.Time{}
+}
+
+// expiresIn turns a lifetime in seconds into an expiry time, zero meaning it doesn't expire
+func expiresIn(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(seconds * float64(time.Second)))
+}
+`
+
+// oauth2Source implements both OAuth2 flows, with the settings of the config as constants
+const oauth2Source = `
+// oauth2 fetches access tokens from the token endpoint, living off refresh tokens once it has one
+type oauth2 struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	cache        tokenCache
+
+	mu           sync.Mutex
+	code         string
+	refreshToken string
+}
+
+func (o *oauth2) apply(ctx context.Context, c *Client, req *http.Request) error {
+	token, err := o.cache.get(ctx, func(ctx context.Context) (string, time.Time, error) {
+		return o.fetch(ctx, c)
+	})
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (o *oauth2) invalidate() bool {
+	o.cache.clear()
+	return true
+}
+
+// fetch picks the grant: a refresh token when there is one, then a pending code, then client credentials
+func (o *oauth2) fetch(ctx context.Context, c *Client) (string, time.Time, error) {
+	o.mu.Lock()
+	form := url.Values{}
+
+	switch {
+	case o.refreshToken != "":
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", o.refreshToken)
+	case o.code != "":
+		form.Set("grant_type", "authorization_code")
+		form.Set("code", o.code)
+		if oauth2RedirectURL != "" {
+			form.Set("redirect_uri", oauth2RedirectURL)
+		}
+		// Codes are single use, from now on we rely on the refresh token.
+		o.code = ""
+	case oauth2ClientCredentials:
+		form.Set("grant_type", "client_credentials")
+	default:
+		o.mu.Unlock()
+		return "", time.Time{}, fmt.Errorf("authorization code was used and no refresh token was issued, obtain a new code")
+	}
+	o.mu.Unlock()
+
+	if oauth2Scope != "" {
+		form.Set("scope", oauth2Scope)
+	}
+	if oauth2Audience != "" {
+		form.Set("audience", oauth2Audience)
+	}
+	if oauth2ClientAuthInBody {
+		form.Set("client_id", o.clientID)
+		if o.clientSecret != "" {
+			form.Set("client_secret", o.clientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error creating token request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !oauth2ClientAuthInBody {
+		req.SetBasicAuth(url.QueryEscape(o.clientID), url.QueryEscape(o.clientSecret))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error requesting token: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error reading token response: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", time.Time{}, fmt.Errorf("token request failed with status code %d: %s", res.StatusCode, body)
+	}
+
+	var token map[string]any
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", time.Time{}, fmt.Errorf("error parsing token response: %w", err)
+	}
+
+	accessToken, _ := token["access_token"].(string)
+	if accessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token response contains no access_token")
+	}
+
+	if refreshToken, _ := token["refresh_token"].(string); refreshToken != "" {
+		o.mu.Lock()
+		o.refreshToken = refreshToken
+		o.mu.Unlock()
+	}
+
+	seconds, _ := token["expires_in"].(float64)
+	return accessToken, expiresIn(seconds), nil
+}
+`
+
+// sessionSource logs in with the login request of the config, filling in the credentials the client was given
+const sessionSource = `
+// session logs in with the login request and sends what the login hands out with every request
+type session struct {
+	loginURL    string
+	credentials map[string]string
+	cache       tokenCache
+
+	mu      sync.Mutex
+	cookies []*http.Cookie
+}
+
+func (s *session) invalidate() bool {
+	s.cache.clear()
+	return true
+}
+
+// loginPlaceholder matches the {{env.NAME}} placeholders the credentials fill in
+var loginPlaceholder = regexp.MustCompile("\\{\\{\\s*env\\.([A-Za-z0-9_]+)\\s*\\}\\}")
+
+// fill replaces the placeholders in the strings of a decoded JSON value with the credentials
+func (s *session) fill(value any) any {
+	switch v := value.(type) {
+	case string:
+		return loginPlaceholder.ReplaceAllStringFunc(v, func(match string) string {
+			return s.credentials[loginPlaceholder.FindStringSubmatch(match)[1]]
+		})
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = s.fill(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = s.fill(item)
+		}
+		return out
+	}
+	return value
+}
+
+func (s *session) login(ctx context.Context, c *Client) (string, time.Time, error) {
+	target, err := c.resolve(s.loginURL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	header := http.Header{}
+	for key, value := range loginHeader {
+		header.Set(key, s.fill(value).(string))
+	}
+
+	var reader io.Reader
+	if loginBody != "" {
+		var body map[string]any
+		if err := json.Unmarshal([]byte(loginBody), &body); err != nil {
+			return "", time.Time{}, fmt.Errorf("error decoding login body: %w", err)
+		}
+		body = s.fill(body).(map[string]any)
+
+		if strings.Contains(header.Get("Content-Type"), "x-www-form-urlencoded") {
+			form := url.Values{}
+			for key, value := range body {
+				form.Set(key, fmt.Sprint(value))
+			}
+			reader = strings.NewReader(form.Encode())
+		} else {
+			data, err := json.Marshal(body)
+			if err != nil {
+				return "", time.Time{}, fmt.Errorf("error encoding login body: %w", err)
+			}
+			reader = bytes.NewReader(data)
+			if header.Get("Content-Type") == "" {
+				header.Set("Content-Type", "application/json")
+			}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, loginMethod, target.String(), reader)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error creating login request: %w", err)
+	}
+	req.Header = header
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error logging in: %w", err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error reading login response: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", time.Time{}, fmt.Errorf("login failed with status code %d: %s", res.StatusCode, data)
+	}
+
+	s.mu.Lock()
+	s.cookies = res.Cookies()
+	s.mu.Unlock()
+
+	var doc any
+	_ = json.Unmarshal(data, &doc)
+
+	return s.extract(doc)
+}
+`
+
+/*
+authFile writes the auth of the config: an option taking the credentials, one
+reading them from the environment variables the config names, and whatever login
+the option needs. Configs without auth get no file.
+*/
+func (g *generator) authFile() string {
+	a := g.config.Auth
+	var b strings.Builder
+
+	switch g.authType() {
+	case models.AuthAPIKey:
+		b.WriteString(`
+// apiKey sends the API key with every request
+type apiKey struct {
+	key string
+}
+
+// WithAPIKey authenticates requests with an API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.auth = apiKey{key: key}
+	}
+}
+
+func (a apiKey) apply(ctx context.Context, c *Client, req *http.Request) error {
+`)
+		b.WriteString(place(a.APIKey.In, a.APIKey.Name, a.APIKey.Prefix, "a.key"))
+		b.WriteString(`	return nil
+}
+
+func (apiKey) invalidate() bool {
+	return false
+}
+`)
+		g.fromEnv(&b, "WithAPIKey", a.APIKey.Value)
+
+	case models.AuthBasic:
+		b.WriteString(`
+// basic sends a username and password with every request
+type basic struct {
+	username, password string
+}
+
+// WithBasicAuth authenticates requests with a username and password
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.auth = basic{username: username, password: password}
+	}
+}
+
+func (a basic) apply(ctx context.Context, c *Client, req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+func (basic) invalidate() bool {
+	return false
+}
+`)
+		g.fromEnv(&b, "WithBasicAuth", a.Basic.Username, a.Basic.Password)
+
+	case models.AuthBearer:
+		b.WriteString(`
+// bearer sends a token with every request
+type bearer struct {
+	token string
+}
+
+// WithToken authenticates requests with a bearer token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.auth = bearer{token: token}
+	}
+}
+
+func (a bearer) apply(ctx context.Context, c *Client, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (bearer) invalidate() bool {
+	return false
+}
+`)
+		g.fromEnv(&b, "WithToken", a.Bearer.Token)
+
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		o := a.OAuth2
+		clientCredentials := g.authType() == models.AuthOAuth2ClientCredentials
+
+		fmt.Fprintf(&b, "\n// The OAuth2 settings of the API\nconst (\n")
+		fmt.Fprintf(&b, "\toauth2TokenURL = %s\n", strconv.Quote(o.TokenURL))
+		fmt.Fprintf(&b, "\toauth2Scope = %s\n", strconv.Quote(strings.Join(o.Scopes, " ")))
+		fmt.Fprintf(&b, "\toauth2Audience = %s\n", strconv.Quote(o.Audience))
+		fmt.Fprintf(&b, "\toauth2RedirectURL = %s\n", strconv.Quote(o.RedirectURL))
+		fmt.Fprintf(&b, "\toauth2ClientAuthInBody = %t\n", strings.EqualFold(o.ClientAuth, "body"))
+		fmt.Fprintf(&b, "\toauth2ClientCredentials = %t\n", clientCredentials)
+		b.WriteString(")\n")
+
+		if clientCredentials {
+			b.WriteString(`
+// WithClientCredentials authenticates with the OAuth2 client credentials flow
+func WithClientCredentials(clientID, clientSecret string) Option {
+	return func(c *Client) {
+		c.auth = &oauth2{tokenURL: oauth2TokenURL, clientID: clientID, clientSecret: clientSecret}
+	}
+}
+`)
+			g.fromEnv(&b, "WithClientCredentials", o.ClientID, o.ClientSecret)
+		} else {
+			fmt.Fprintf(&b, `
+// WithAuthorizationCode exchanges an authorization code for tokens and refreshes them from then on. Codes are obtained at %s.
+func WithAuthorizationCode(clientID, clientSecret, code string) Option {
+	return func(c *Client) {
+		c.auth = &oauth2{tokenURL: oauth2TokenURL, clientID: clientID, clientSecret: clientSecret, code: code}
+	}
+}
+
+// WithRefreshToken authenticates with a refresh token, for when the authorization code was exchanged before
+func WithRefreshToken(clientID, clientSecret, refreshToken string) Option {
+	return func(c *Client) {
+		c.auth = &oauth2{tokenURL: oauth2TokenURL, clientID: clientID, clientSecret: clientSecret, refreshToken: refreshToken}
+	}
+}
+`, defaultString(o.AuthorizationURL, "the provider's authorization endpoint"))
+			if o.RefreshToken != "" {
+				g.fromEnv(&b, "WithRefreshToken", o.ClientID, o.ClientSecret, o.RefreshToken)
+			} else {
+				g.fromEnv(&b, "WithAuthorizationCode", o.ClientID, o.ClientSecret, o.Code)
+			}
+		}
+
+		b.WriteString(oauth2Source)
+		b.WriteString(tokenCacheSource)
+
+	case models.AuthSession:
+		g.sessionAuth(&b)
+		b.WriteString(sessionSource)
+		b.WriteString(tokenCacheSource)
+
+	default:
+		return ""
+	}
+
+	return b.String()
+}
+
+// authType is the normalized auth type of the config, none when the settings it needs are missing
+func (g *generator) authType() string {
+	a := g.config.Auth
+
+	switch t := auth.Type(a); t {
+	case models.AuthAPIKey:
+		if a.APIKey != nil {
+			return t
+		}
+	case models.AuthBasic:
+		if a.Basic != nil {
+			return t
+		}
+	case models.AuthBearer:
+		if a.Bearer != nil {
+			return t
+		}
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		if a.OAuth2 != nil {
+			return t
+		}
+	case models.AuthSession:
+		return t
+	}
+
+	return models.AuthNone
+}
+
+// sessionAuth writes the login settings, the option taking the credentials of the login request and how the session is sent
+func (g *generator) sessionAuth(b *strings.Builder) {
+	a := g.config.Auth
+
+	loginMethod := strings.ToUpper(a.Method)
+	if loginMethod == "" {
+		loginMethod = "POST"
+	}
+
+	var input models.Input
+	if len(a.Inputs) > 0 {
+		input = a.Inputs[0]
+	}
+
+	var body string
+	if len(input.Body) > 0 {
+		data, _ := json.Marshal(input.Body)
+		body = string(data)
+	}
+
+	fmt.Fprintf(b, "\n// The login request of the API, with {{env.NAME}} placeholders for the credentials\n")
+	fmt.Fprintf(b, "const (\n\tloginURL = %s\n\tloginMethod = %s\n\tloginBody = %s\n)\n", strconv.Quote(a.Endpoint), strconv.Quote(loginMethod), strconv.Quote(body))
+
+	fmt.Fprintf(b, "\n// loginHeader holds the headers of the login request\nvar loginHeader = map[string]string{\n")
+	for _, key := range slices.Sorted(maps.Keys(input.Headers)) {
+		fmt.Fprintf(b, "\t%s: %s,\n", strconv.Quote(key), strconv.Quote(input.Headers[key]))
+	}
+	b.WriteString("}\n")
+
+	credentials := g.loginCredentials()
+	params := make([]string, len(credentials))
+	entries := make([]string, len(credentials))
+	taken := names{}
+	for i, name := range credentials {
+		params[i] = argument(strings.ToLower(name), taken)
+		entries[i] = fmt.Sprintf("%s: %s", strconv.Quote(name), params[i])
+	}
+
+	doc := "WithLogin authenticates by logging in"
+	signature := ""
+	if len(credentials) > 0 {
+		doc += ", with the values of " + list(credentials) + " in the login request"
+		signature = strings.Join(params, ", ") + " string"
+	}
+
+	fmt.Fprintf(b, `
+// %s
+func WithLogin(%s) Option {
+	return func(c *Client) {
+		c.auth = &session{loginURL: loginURL, credentials: map[string]string{%s}}
+	}
+}
+`, doc, signature, strings.Join(entries, ", "))
+
+	getenv := make([]string, len(credentials))
+	for i, name := range credentials {
+		getenv[i] = fmt.Sprintf("os.Getenv(%s)", strconv.Quote(name))
+	}
+	fmt.Fprintf(b, `
+// WithCredentialsFromEnv logs in with the credentials in the environment variables the config names
+func WithCredentialsFromEnv() Option {
+	return WithLogin(%s)
+}
+`, strings.Join(getenv, ", "))
+
+	login := `s.cache.get(ctx, func(ctx context.Context) (string, time.Time, error) {
+		return s.login(ctx, c)
+	})`
+
+	if a.Session == nil || a.Session.TokenPath == "" {
+		fmt.Fprintf(b, `
+func (s *session) apply(ctx context.Context, c *Client, req *http.Request) error {
+	if _, err := %s; err != nil {
+		return err
+	}
+
+	// The login itself is the credential, its cookies go along with every request.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cookie := range s.cookies {
+		req.AddCookie(cookie)
+	}
+	return nil
+}
+`, login)
+	} else {
+		name, prefix := a.Session.Name, a.Session.Prefix
+		if name == "" {
+			name = "Authorization"
+		}
+		if prefix == "" && strings.EqualFold(name, "Authorization") {
+			prefix = "Bearer "
+		}
+
+		fmt.Fprintf(b, `
+func (s *session) apply(ctx context.Context, c *Client, req *http.Request) error {
+	token, err := %s
+	if err != nil {
+		return err
+	}
+
+%s	return nil
+}
+`, login, place(a.Session.In, name, prefix, "token"))
+	}
+
+	var tokenPath, expiresInPath []string
+	var ttl int
+	if a.Session != nil {
+		tokenPath, _ = utils.ParsePath(a.Session.TokenPath)
+		expiresInPath, _ = utils.ParsePath(a.Session.ExpiresInPath)
+		ttl = a.Session.TTL
+	}
+
+	fmt.Fprintf(b, `
+// extract takes the token and its lifetime from the login response
+func (s *session) extract(doc any) (string, time.Time, error) {
+	expiry := expiresIn(%d)
+`, ttl)
+
+	if len(expiresInPath) > 0 {
+		fmt.Fprintf(b, `	if value, ok := lookup(doc, %#v); ok {
+		if seconds, ok := value.(float64); ok {
+			expiry = expiresIn(seconds)
+		}
+	}
+`, expiresInPath)
+	}
+
+	if len(tokenPath) == 0 {
+		b.WriteString("\n\treturn \"session\", expiry, nil\n}\n")
+		return
+	}
+
+	fmt.Fprintf(b, `
+	token, ok := lookup(doc, %#v)
+	if !ok || token == nil || fmt.Sprint(token) == "" {
+		return "", time.Time{}, fmt.Errorf("login response has no token at %%s", %s)
+	}
+	return fmt.Sprint(token), expiry, nil
+}
+`, tokenPath, strconv.Quote(a.Session.TokenPath))
+}
+
+// sessionPaths reports whether the session login takes anything from the response by path
+func (g *generator) sessionPaths() bool {
+	return g.authType() == models.AuthSession && g.config.Auth.Session != nil &&
+		(g.config.Auth.Session.TokenPath != "" || g.config.Auth.Session.ExpiresInPath != "")
+}
+
+// loginCredentials returns the environment variables the login request references, sorted
+func (g *generator) loginCredentials() []string {
+	found := map[string]bool{}
+	for _, input := range g.config.Auth.Inputs[:min(1, len(g.config.Auth.Inputs))] {
+		var texts []string
+		for _, value := range input.Headers {
+			texts = append(texts, value)
+		}
+		data, _ := json.Marshal(input.Body)
+		texts = append(texts, string(data))
+
+		for _, text := range texts {
+			for _, match := range envPlaceholder.FindAllStringSubmatch(text, -1) {
+				found[match[1]] = true
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(found))
+}
+
+// fromEnv writes WithCredentialsFromEnv, passing the credentials of the config to option with their placeholders read from the environment
+func (g *generator) fromEnv(b *strings.Builder, option string, values ...string) {
+	args := make([]string, len(values))
+	for i, value := range values {
+		args[i] = envExpression(value)
+	}
+
+	fmt.Fprintf(b, `
+// WithCredentialsFromEnv authenticates with the credentials in the environment variables the config names
+func WithCredentialsFromEnv() Option {
+	return %s(%s)
+}
+`, option, strings.Join(args, ", "))
+}
+
+// envExpression turns a value with {{env.NAME}} placeholders into a Go expression reading them from the environment
+func envExpression(value string) string {
+	var parts []string
+	last := 0
+	for _, loc := range envPlaceholder.FindAllStringSubmatchIndex(value, -1) {
+		if loc[0] > last {
+			parts = append(parts, strconv.Quote(value[last:loc[0]]))
+		}
+		parts = append(parts, fmt.Sprintf("os.Getenv(%s)", strconv.Quote(value[loc[2]:loc[3]])))
+		last = loc[1]
+	}
+	if last < len(value) || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(value[last:]))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// place writes the statements that send a credential in a header, query parameter or cookie
+func place(in, name, prefix, value string) string {
+	if prefix != "" {
+		value = strconv.Quote(prefix) + " + " + value
+	}
+
+	switch in {
+	case "query":
+		return fmt.Sprintf("\tquery := req.URL.Query()\n\tquery.Set(%s, %s)\n\treq.URL.RawQuery = query.Encode()\n", strconv.Quote(name), value)
+	case "cookie":
+		return fmt.Sprintf("\treq.AddCookie(&http.Cookie{Name: %s, Value: %s})\n", strconv.Quote(name), value)
+	}
+	return fmt.Sprintf("\treq.Header.Set(%s, %s)\n", strconv.Quote(name), value)
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// list joins names the way a sentence does, e.g. A, B and C
+func list(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
@@ -0,0 +1,212 @@
+package codegen
+
+import (
+	"strconv"
+	"strings"
+)
+
+// clientSource is the core of every generated client, with the name and base URL of the API filled in
+const clientSource = `
+// DefaultBaseURL is where the API lives, as found during extraction
+const DefaultBaseURL = BASE_URL
+
+// Client calls the INTEGRATION API. Create one with New.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	auth       authenticator
+}
+
+// authenticator adds credentials to requests, and forgets cached tokens the API no longer accepts
+type authenticator interface {
+	apply(ctx context.Context, c *Client, req *http.Request) error
+	invalidate() bool
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// New creates a client for the API at DefaultBaseURL
+func New(options ...Option) *Client {
+	c := &Client{baseURL: DefaultBaseURL, httpClient: http.DefaultClient}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithBaseURL points the client at another deployment of the API, e.g. a sandbox or a mock server
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sends requests with the given HTTP client instead of http.DefaultClient
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// Error is returned when the API answers with a status code outside the 2xx range
+type Error struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("API responded with status code %d: %s", e.StatusCode, e.Body)
+}
+
+// response is what the API answered to a successful request
+type response struct {
+	header http.Header
+	body   []byte
+}
+
+// resolve turns a path into a URL on the base URL, leaving absolute URLs as they are
+func (c *Client) resolve(path string) (*url.URL, error) {
+	if target, err := url.Parse(path); err == nil && target.IsAbs() {
+		return target, nil
+	}
+
+	target, err := url.Parse(c.baseURL + "/" + strings.TrimLeft(path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL for %q: %w", path, err)
+	}
+	return target, nil
+}
+
+/*
+do sends a request and returns the response when it is successful. Credentials are
+only sent to the host of the base URL, and a request the API rejects with 401 is
+retried once after forgetting the cached token.
+*/
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body any) (*response, error) {
+	target, err := c.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(query) > 0 {
+		values := target.Query()
+		for key, value := range query {
+			values[key] = value
+		}
+		target.RawQuery = values.Encode()
+	}
+
+	var data []byte
+	if body != nil {
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("error encoding request body: %w", err)
+		}
+	}
+
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", c.baseURL, err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if data != nil {
+			reader = bytes.NewReader(data)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), reader)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if req.Header.Get("Accept") == "" {
+			req.Header.Set("Accept", "application/json")
+		}
+		if data != nil && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		if c.auth != nil && strings.EqualFold(target.Host, base.Host) {
+			if err := c.auth.apply(ctx, c, req); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error calling %s %s: %w", method, target.Redacted(), err)
+		}
+
+		payload, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading response of %s %s: %w", method, target.Redacted(), err)
+		}
+
+		if res.StatusCode == http.StatusUnauthorized && attempt == 0 && c.auth != nil && c.auth.invalidate() {
+			continue
+		}
+
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return nil, &Error{StatusCode: res.StatusCode, Body: payload}
+		}
+
+		return &response{header: res.Header, body: payload}, nil
+	}
+}
+
+// decode decodes a response body into out, leaving out alone when the body is empty
+func decode(name string, res *response, out any) error {
+	if len(bytes.TrimSpace(res.body)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(res.body, out); err != nil {
+		return fmt.Errorf("error decoding %s response: %w", name, err)
+	}
+	return nil
+}
+`
+
+// lookupSource resolves the paths of tokens and pages in decoded JSON, for clients that need it
+const lookupSource = `
+// lookup follows a path of keys and indexes through decoded JSON
+func lookup(value any, path []string) (any, bool) {
+	for _, segment := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			item, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = item
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+`
+
+// clientFile writes the client, with the lookup helper when pagination or a session login needs it
+func (g *generator) clientFile() string {
+	source := strings.NewReplacer(
+		"BASE_URL", strconv.Quote(strings.TrimRight(g.config.BaseURL, "/")),
+		"INTEGRATION", oneLine(g.config.Integration),
+	).Replace(clientSource)
+
+	if g.paginates() || g.sessionPaths() {
+		source += lookupSource
+	}
+
+	return source
+}
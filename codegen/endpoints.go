@@ -0,0 +1,454 @@
+package codegen
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/export"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// pathParameter matches the {name} parameters of an endpoint path
+var pathParameter = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// httpMethods maps the methods net/http has constants for onto their names
+var httpMethods = map[string]string{
+	http.MethodGet:     "http.MethodGet",
+	http.MethodHead:    "http.MethodHead",
+	http.MethodPost:    "http.MethodPost",
+	http.MethodPut:     "http.MethodPut",
+	http.MethodPatch:   "http.MethodPatch",
+	http.MethodDelete:  "http.MethodDelete",
+	http.MethodOptions: "http.MethodOptions",
+}
+
+// method is a client method for an endpoint, with the Go types of everything it takes and returns
+type method struct {
+	name     string
+	endpoint models.Endpoint
+	verb     string
+	path     string
+	args     []arg
+	params   string
+	fields   []paramField
+	body     string
+	result   string
+	all      string
+	pages    string
+	item     string
+}
+
+// arg is a path parameter, which methods take as an argument
+type arg struct {
+	name, goType, param string
+	schema              *models.Schema
+}
+
+// paramField is a query or header parameter, which methods take in their params struct
+type paramField struct {
+	name, goType, in, param, doc string
+	required                     bool
+}
+
+// declareMethods declares a method for every endpoint, along with the types of its parameters, body and result
+func (g *generator) declareMethods() {
+	methodNames := names{}
+
+	for _, endpoint := range export.Endpoints(g.config) {
+		m := &method{endpoint: endpoint, verb: strings.ToUpper(endpoint.Method), path: endpoint.Path}
+		if m.verb == "" {
+			m.verb = http.MethodGet
+		}
+		m.name = methodNames.claim(exported(endpoint.Name))
+
+		taken := names{}
+		for _, match := range pathParameter.FindAllStringSubmatch(m.route(), -1) {
+			s := g.parameterSchema(endpoint, "path", match[1])
+			goType := g.goType(s, m.name+exported(match[1]))
+			if resolved, err := g.config.ResolveSchema(s); err != nil || resolved == nil || !scalar(resolved) {
+				goType, s = "string", nil
+			}
+			m.args = append(m.args, arg{argument(match[1], taken), goType, match[1], s})
+		}
+
+		fieldNames := names{}
+		for _, parameter := range endpoint.Parameters {
+			if parameter.In != "query" && parameter.In != "header" {
+				continue
+			}
+			if m.params == "" {
+				m.params = g.idents.claim(m.name + "Params")
+			}
+
+			f := paramField{
+				name:     fieldNames.claim(exported(parameter.Name)),
+				in:       parameter.In,
+				param:    parameter.Name,
+				doc:      parameter.Description,
+				required: parameter.Required,
+			}
+			f.goType = g.goType(parameter.Schema, m.params+f.name)
+			if !f.required && g.pointerable(f.goType) {
+				f.goType = "*" + f.goType
+			}
+			m.fields = append(m.fields, f)
+		}
+
+		if endpoint.Request != nil && m.verb != http.MethodGet && m.verb != http.MethodHead {
+			m.body = g.goType(endpoint.Request, m.name+"Request")
+		}
+
+		if endpoint.Response != nil {
+			m.result = g.goType(endpoint.Response, m.name+"Response")
+		}
+
+		if endpoint.Pagination != nil && g.knownPagination(endpoint.Pagination) {
+			m.all = methodNames.claim(m.name + "All")
+			m.pages = unexported(m.name) + "Pages"
+			m.item = g.itemType(m)
+		}
+
+		g.methods = append(g.methods, m)
+	}
+}
+
+// route is the path of the endpoint without any query string
+func (m *method) route() string {
+	path, _, _ := strings.Cut(m.path, "?")
+	return path
+}
+
+// absolute reports whether the endpoint lives outside the base URL
+func (m *method) absolute() bool {
+	u, err := url.Parse(m.path)
+	return err == nil && u.IsAbs()
+}
+
+func (g *generator) parameterSchema(endpoint models.Endpoint, in, name string) *models.Schema {
+	for _, parameter := range endpoint.Parameters {
+		if parameter.In == in && parameter.Name == name {
+			return parameter.Schema
+		}
+	}
+	return nil
+}
+
+func (g *generator) knownPagination(p *models.Pagination) bool {
+	switch p.Type {
+	case models.PaginationPage, models.PaginationOffset, models.PaginationLinkHeader:
+		return true
+	case models.PaginationCursor:
+		return p.CursorParam != "" && p.CursorPath != ""
+	case models.PaginationNextURL:
+		return p.NextURLPath != ""
+	}
+	return false
+}
+
+// itemSchema finds the schema of the items of a page, following the items path through the response schema
+func (g *generator) itemSchema(endpoint models.Endpoint) *models.Schema {
+	s, _ := g.config.ResolveSchema(endpoint.Response)
+
+	segments, err := utils.ParsePath(endpoint.Pagination.ItemsPath)
+	if err != nil {
+		return nil
+	}
+
+	for _, segment := range segments {
+		switch {
+		case s == nil:
+			return nil
+		case isObject(s):
+			s = s.Properties[segment]
+		case s.Type == "array":
+			s = s.Items
+		default:
+			return nil
+		}
+		s, _ = g.config.ResolveSchema(s)
+	}
+
+	if s == nil || s.Type != "array" {
+		return nil
+	}
+	return s.Items
+}
+
+func (g *generator) itemType(m *method) string {
+	if s := g.itemSchema(m.endpoint); s != nil {
+		return g.goType(s, m.name+"Item")
+	}
+	return "any"
+}
+
+// paginates reports whether any endpoint gets a helper that collects all its pages
+func (g *generator) paginates() bool {
+	for _, m := range g.methods {
+		if m.all != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointsFile writes the methods of the client, with their params structs and pagination helpers
+func (g *generator) endpointsFile() string {
+	var b strings.Builder
+
+	for _, m := range g.methods {
+		if m.params != "" {
+			fmt.Fprintf(&b, "\n// %s holds the query and header parameters of %s, which are only sent when set\ntype %s struct {\n", m.params, m.name, m.params)
+			for _, f := range m.fields {
+				if f.doc != "" {
+					fmt.Fprintf(&b, "\t// %s\n", oneLine(f.doc))
+				}
+				fmt.Fprintf(&b, "\t%s %s\n", f.name, f.goType)
+			}
+			b.WriteString("}\n")
+		}
+
+		fmt.Fprintf(&b, "\n// %s calls %s %s.\n", m.name, m.verb, m.path)
+		if description := oneLine(m.endpoint.Description); description != "" {
+			fmt.Fprintf(&b, "//\n// %s\n", description)
+		}
+
+		returns := "error"
+		if m.result != "" {
+			returns = "(" + g.resultType(m) + ", error)"
+		}
+
+		fmt.Fprintf(&b, "func (c *Client) %s(%s) %s {\n", m.name, m.signature(), returns)
+		g.writeRequest(&b, m)
+
+		switch {
+		case m.result == "":
+			fmt.Fprintf(&b, "\t_, err := c.do(ctx, %s, path, %s)\n\treturn err\n}\n", verb(m.verb), m.arguments())
+		case g.resultType(m) != m.result:
+			fmt.Fprintf(&b, `	res, err := c.do(ctx, %s, path, %s)
+	if err != nil {
+		return nil, err
+	}
+
+	var out %s
+	if err := decode(%q, res, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+`, verb(m.verb), m.arguments(), m.result, m.name)
+		default:
+			fmt.Fprintf(&b, `	var out %s
+	res, err := c.do(ctx, %s, path, %s)
+	if err != nil {
+		return out, err
+	}
+
+	err = decode(%q, res, &out)
+	return out, err
+}
+`, m.result, verb(m.verb), m.arguments(), m.name)
+		}
+
+		if m.all != "" {
+			g.writeAll(&b, m)
+		}
+	}
+
+	return b.String()
+}
+
+// resultType is what a method returns, a pointer for structs and plain values for everything else
+func (g *generator) resultType(m *method) string {
+	if g.pointerable(m.result) && !g.isScalar(m.result) {
+		return "*" + m.result
+	}
+	return m.result
+}
+
+// isScalar reports whether a Go type is one of the basic types, or a data model defined as one
+func (g *generator) isScalar(goType string) bool {
+	switch goType {
+	case "string", "int64", "float64", "bool":
+		return true
+	}
+	for _, d := range g.decls {
+		if d.name == goType && !d.isStruct {
+			return g.isScalar(d.underlying)
+		}
+	}
+	return false
+}
+
+// signature lists the arguments of a method: the context, path parameters, params and body
+func (m *method) signature() string {
+	parts := []string{"ctx context.Context"}
+	for _, a := range m.args {
+		parts = append(parts, a.name+" "+a.goType)
+	}
+	if m.params != "" {
+		parts = append(parts, "params *"+m.params)
+	}
+	if m.body != "" {
+		parts = append(parts, "body "+bodyType(m.body))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// arguments are what a method passes on to do after the path
+func (m *method) arguments() string {
+	query, header := "nil", "nil"
+	if m.params != "" {
+		query, header = "query", "header"
+	}
+
+	payload := "nil"
+	if m.body != "" {
+		payload = "payload"
+	}
+
+	return strings.Join([]string{query, header, payload}, ", ")
+}
+
+// bodyType takes structs by pointer, so callers can leave the body out with nil
+func bodyType(goType string) string {
+	switch {
+	case strings.HasPrefix(goType, "[]"), strings.HasPrefix(goType, "map["), goType == "any":
+		return goType
+	case goType == "string", goType == "int64", goType == "float64", goType == "bool":
+		return goType
+	}
+	return "*" + goType
+}
+
+// writeRequest writes the statements that build the path, query, headers and payload of a call
+func (g *generator) writeRequest(b *strings.Builder, m *method) {
+	fmt.Fprintf(b, "\tpath := %s\n", m.pathExpression())
+
+	if m.params != "" {
+		b.WriteString("\tquery, header := url.Values{}, http.Header{}\n\tif params != nil {\n")
+		for _, f := range m.fields {
+			target := "query"
+			if f.in == "header" {
+				target = "header"
+			}
+
+			value := "params." + f.name
+			switch {
+			case strings.HasPrefix(f.goType, "[]"):
+				fmt.Fprintf(b, "\t\tfor _, value := range %s {\n\t\t\t%s.Add(%q, fmt.Sprint(value))\n\t\t}\n", value, target, f.param)
+			case strings.HasPrefix(f.goType, "*"):
+				fmt.Fprintf(b, "\t\tif %s != nil {\n\t\t\t%s.Set(%q, fmt.Sprint(*%s))\n\t\t}\n", value, target, f.param, value)
+			case strings.HasPrefix(f.goType, "map[") || f.goType == "any":
+				fmt.Fprintf(b, "\t\tif %s != nil {\n\t\t\t%s.Set(%q, fmt.Sprint(%s))\n\t\t}\n", value, target, f.param, value)
+			default:
+				fmt.Fprintf(b, "\t\t%s.Set(%q, fmt.Sprint(%s))\n", target, f.param, value)
+			}
+		}
+		b.WriteString("\t}\n")
+	}
+
+	if m.body != "" {
+		if bodyType(m.body) == m.body && g.isScalar(m.body) {
+			b.WriteString("\tpayload := body\n")
+		} else {
+			// A nil body is left out, rather than sent as null.
+			b.WriteString("\tvar payload any\n\tif body != nil {\n\t\tpayload = body\n\t}\n")
+		}
+	}
+
+	b.WriteString("\n")
+}
+
+// pathExpression builds the path of a call from its literal parts and escaped path parameters
+func (m *method) pathExpression() string {
+	var parts []string
+	last := 0
+
+	for _, loc := range pathParameter.FindAllStringSubmatchIndex(m.path, -1) {
+		if loc[0] > last {
+			parts = append(parts, strconv.Quote(m.path[last:loc[0]]))
+		}
+
+		value := m.path[loc[0]:loc[1]]
+		for _, a := range m.args {
+			if a.param != m.path[loc[2]:loc[3]] {
+				continue
+			}
+			if a.goType == "string" {
+				value = "url.PathEscape(" + a.name + ")"
+			} else {
+				value = "url.PathEscape(fmt.Sprint(" + a.name + "))"
+			}
+		}
+		parts = append(parts, value)
+
+		last = loc[1]
+	}
+
+	if last < len(m.path) || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(m.path[last:]))
+	}
+
+	return strings.Join(parts, " + ")
+}
+
+// writeAll writes the helper that fetches every page of a paginated endpoint
+func (g *generator) writeAll(b *strings.Builder, m *method) {
+	p := m.endpoint.Pagination
+	path := func(value string) string {
+		segments, _ := utils.ParsePath(value)
+		if len(segments) == 0 {
+			return "nil"
+		}
+		return fmt.Sprintf("%#v", segments)
+	}
+
+	maxPages := p.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+	startPage := p.StartPage
+	if p.Type == models.PaginationPage && startPage == 0 {
+		startPage = 1
+	}
+
+	fmt.Fprintf(b, "\n// %s is how %s pages its results\nvar %s = pagination{\n\tkind: %q,\n", m.pages, m.name, m.pages, p.Type)
+	if p.ItemsPath != "" {
+		fmt.Fprintf(b, "\titemsPath: %s,\n", path(p.ItemsPath))
+	}
+	switch p.Type {
+	case models.PaginationPage:
+		fmt.Fprintf(b, "\tpageParam: %q,\n\tstartPage: %d,\n", defaultString(p.PageParam, "page"), startPage)
+	case models.PaginationOffset:
+		fmt.Fprintf(b, "\toffsetParam: %q,\n", defaultString(p.OffsetParam, "offset"))
+	case models.PaginationCursor:
+		fmt.Fprintf(b, "\tcursorParam: %q,\n\tcursorPath: %s,\n", p.CursorParam, path(p.CursorPath))
+	case models.PaginationNextURL:
+		fmt.Fprintf(b, "\tnextURLPath: %s,\n", path(p.NextURLPath))
+	}
+	if p.LimitParam != "" && p.Limit > 0 {
+		fmt.Fprintf(b, "\tlimitParam: %q,\n\tlimit: %d,\n", p.LimitParam, p.Limit)
+	}
+	if p.TotalPath != "" {
+		fmt.Fprintf(b, "\ttotalPath: %s,\n", path(p.TotalPath))
+	}
+	fmt.Fprintf(b, "\tmaxPages: %d,\n}\n", maxPages)
+
+	fmt.Fprintf(b, "\n// %s calls %s for every page and returns the items of all pages\n", m.all, m.name)
+	fmt.Fprintf(b, "func (c *Client) %s(%s) ([]%s, error) {\n", m.all, m.signature(), m.item)
+	g.writeRequest(b, m)
+	fmt.Fprintf(b, "\treturn collect[%s](ctx, c, %s, %s, path, %s)\n}\n", m.item, m.pages, verb(m.verb), m.arguments())
+}
+
+// verb writes an HTTP method, as a net/http constant where there is one
+func verb(m string) string {
+	if constant, ok := httpMethods[m]; ok {
+		return constant
+	}
+	return strconv.Quote(m)
+}
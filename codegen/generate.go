@@ -0,0 +1,171 @@
+package codegen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// Options tune the generated package
+type Options struct {
+	// Package is the name of the generated package, derived from the integration when empty
+	Package string
+}
+
+// stdlib maps the package names generated code may use onto their import paths
+var stdlib = map[string]string{
+	"bytes":    "bytes",
+	"context":  "context",
+	"errors":   "errors",
+	"fmt":      "fmt",
+	"http":     "net/http",
+	"httptest": "net/http/httptest",
+	"io":       "io",
+	"json":     "encoding/json",
+	"os":       "os",
+	"regexp":   "regexp",
+	"strconv":  "strconv",
+	"strings":  "strings",
+	"sync":     "sync",
+	"testing":  "testing",
+	"time":     "time",
+	"url":      "net/url",
+}
+
+// reserved are the exported names of the generated runtime, which models can't take
+var reserved = []string{
+	"Client", "DefaultBaseURL", "Error", "New", "Option", "WithAPIKey", "WithAuthorizationCode", "WithBaseURL",
+	"WithBasicAuth", "WithClientCredentials", "WithCredentialsFromEnv", "WithHTTPClient", "WithLogin",
+	"WithRefreshToken", "WithToken",
+}
+
+// nonAlphanumeric matches what can't be part of a package name
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+type generator struct {
+	config    *models.APIConfig
+	pkg       string
+	idents    names
+	refs      map[string]string
+	declared  map[*models.Schema]string
+	noPointer map[string]bool
+	decls     []*decl
+	methods   []*method
+}
+
+/*
+Generate turns an APIConfig into the files of a Go client package, keyed by file
+name: a struct per data model, a Client with a method per endpoint, the auth of
+the config as options, helpers that collect every page of paginated endpoints,
+and tests that run all of it against a mock server. The output is gofmt'd and
+only depends on the standard library.
+*/
+func Generate(config *models.APIConfig, options Options) (map[string][]byte, error) {
+	g := &generator{
+		config:    config,
+		pkg:       options.Package,
+		idents:    names{},
+		refs:      map[string]string{},
+		declared:  map[*models.Schema]string{},
+		noPointer: map[string]bool{},
+	}
+
+	if g.pkg == "" {
+		g.pkg = PackageName(config.Integration)
+	}
+	if !token.IsIdentifier(g.pkg) {
+		return nil, fmt.Errorf("invalid package name %q", g.pkg)
+	}
+
+	for _, name := range reserved {
+		g.idents.claim(name)
+	}
+
+	g.declareSchemas()
+	g.declareMethods()
+
+	sources := map[string]string{
+		"client.go":      g.clientFile(),
+		"endpoints.go":   g.endpointsFile(),
+		"models.go":      g.modelsFile(),
+		"client_test.go": g.testFile(),
+	}
+	if auth := g.authFile(); auth != "" {
+		sources["auth.go"] = auth
+	}
+	if g.paginates() {
+		sources["pagination.go"] = paginationSource
+	}
+
+	files := map[string][]byte{}
+	for _, name := range slices.Sorted(maps.Keys(sources)) {
+		data, err := g.source(name, sources[name])
+		if err != nil {
+			return nil, err
+		}
+		files[name] = data
+	}
+
+	return files, nil
+}
+
+// PackageName derives a package name from the integration, e.g. my-api becomes myapi
+func PackageName(integration string) string {
+	name := nonAlphanumeric.ReplaceAllString(strings.ToLower(integration), "")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || token.IsKeyword(name) {
+		name = "api" + name
+	}
+	return name
+}
+
+/*
+source completes a generated file with its header and the imports it uses, and
+formats it. Imports are found by parsing the file, so they never drift from what
+the generators write.
+*/
+func (g *generator) source(name, body string) ([]byte, error) {
+	header := fmt.Sprintf("// Code generated by milkshake from the %s config. DO NOT EDIT.\n\npackage %s\n", oneLine(g.config.Integration), g.pkg)
+
+	file, err := parser.ParseFile(token.NewFileSet(), name, header+body, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing generated %s: %w\n%s", name, err, header+body)
+	}
+
+	// Locals never shadow these package names, so every selector on one is a package use.
+	used := map[string]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok {
+				if path, ok := stdlib[ident.Name]; ok {
+					used[path] = true
+				}
+			}
+		}
+		return true
+	})
+
+	var imports strings.Builder
+	if len(used) > 0 {
+		imports.WriteString("\nimport (\n")
+		for _, path := range slices.Sorted(maps.Keys(used)) {
+			fmt.Fprintf(&imports, "\t%s\n", strconv.Quote(path))
+		}
+		imports.WriteString(")\n")
+	}
+
+	data, err := format.Source([]byte(header + imports.String() + body))
+	if err != nil {
+		return nil, fmt.Errorf("error formatting generated %s: %w", name, err)
+	}
+
+	return data, nil
+}
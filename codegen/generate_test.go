@@ -0,0 +1,145 @@
+package codegen
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// employee is a self-referencing schema, every employee has a manager
+var employee = &models.Schema{
+	Type:     "object",
+	Required: []string{"id", "name"},
+	Properties: map[string]*models.Schema{
+		"id":         {Type: "integer"},
+		"name":       {Type: "string"},
+		"email":      {Type: "string", Format: "email"},
+		"hired_at":   {Type: "string", Format: "date-time"},
+		"manager":    models.SchemaRef("Employee"),
+		"reports":    {Type: "array", Items: models.SchemaRef("Employee")},
+		"status":     {Type: "string", Enum: []any{"active", "inactive"}},
+		"nickname":   {Type: "string", Nullable: true},
+		"type":       {Type: "string"},
+		"client":     models.SchemaRef("Client"),
+		"attributes": {Type: "object"},
+	},
+}
+
+// fixture covers the auth flows and pagination styles, with names that collide with each other and the runtime
+func fixture(auth models.Auth) *models.APIConfig {
+	list := func(name, path string, pagination models.Pagination, response *models.Schema) models.Endpoint {
+		return models.Endpoint{
+			Name:       name,
+			Method:     "GET",
+			Path:       path,
+			Parameters: []models.EndpointParameter{{Name: "status", In: "query", Schema: &models.Schema{Type: "string"}}},
+			Response:   response,
+			Pagination: &pagination,
+		}
+	}
+
+	return &models.APIConfig{
+		Integration: "Test-API",
+		BaseURL:     "https://api.example.com/v1",
+		Auth:        auth,
+		Schemas: map[string]*models.Schema{
+			"Employee": employee,
+			"employee": {Type: "object", Properties: map[string]*models.Schema{"id": {Type: "string"}}},
+			"Client":   {Type: "object", Required: []string{"id"}, Properties: map[string]*models.Schema{"id": {Type: "string", Format: "uuid"}}},
+		},
+		Endpoints: []models.Endpoint{
+			list("list_employees", "/employees", models.Pagination{Type: models.PaginationCursor, ItemsPath: "$.data", CursorParam: "after", CursorPath: "$.next"},
+				&models.Schema{Type: "object", Properties: map[string]*models.Schema{"data": {Type: "array", Items: models.SchemaRef("Employee")}, "next": {Type: "string"}}}),
+			list("list-employees", "/v2/employees", models.Pagination{Type: models.PaginationPage, ItemsPath: "$.items", PageParam: "page", LimitParam: "per_page", Limit: 50},
+				&models.Schema{Type: "object", Properties: map[string]*models.Schema{"items": {Type: "array", Items: models.SchemaRef("employee")}}}),
+			list("list_clients", "/clients", models.Pagination{Type: models.PaginationLinkHeader},
+				&models.Schema{Type: "array", Items: models.SchemaRef("Client")}),
+			list("list_teams", "/teams", models.Pagination{Type: models.PaginationOffset, ItemsPath: "$.teams", OffsetParam: "offset", LimitParam: "limit", Limit: 10, TotalPath: "$.total"},
+				&models.Schema{Type: "object", Properties: map[string]*models.Schema{"teams": {Type: "array", Items: &models.Schema{Type: "object", Properties: map[string]*models.Schema{"name": {Type: "string"}}}}, "total": {Type: "integer"}}}),
+			list("list_shifts", "/shifts", models.Pagination{Type: models.PaginationNextURL, ItemsPath: "$.results", NextURLPath: "$.next"},
+				&models.Schema{Type: "object", Properties: map[string]*models.Schema{"results": {Type: "array", Items: &models.Schema{Type: "object"}}, "next": {Type: "string", Nullable: true}}}),
+			{
+				Name:       "get_employee",
+				Method:     "GET",
+				Path:       "/employees/{id}",
+				Parameters: []models.EndpointParameter{{Name: "id", In: "path", Required: true, Schema: &models.Schema{Type: "integer"}}},
+				Response:   models.SchemaRef("Employee"),
+			},
+			{Name: "new", Method: "POST", Path: "/employees", Request: models.SchemaRef("Employee"), Response: models.SchemaRef("Employee")},
+			{Name: "client", Method: "DELETE", Path: "/clients/{client-id}"},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and tests the generated packages with the go tool")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go tool is not available")
+	}
+
+	tests := []struct {
+		name string
+		auth models.Auth
+	}{
+		{"oauth2 client credentials", models.Auth{Type: models.AuthOAuth2ClientCredentials, OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/oauth/token", ClientID: "{{env.CLIENT_ID}}", ClientSecret: "{{env.CLIENT_SECRET}}", Scopes: []string{"read"}}}},
+		{"oauth2 authorization code", models.Auth{Type: models.AuthOAuth2AuthorizationCode, OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/oauth/token", ClientID: "{{env.CLIENT_ID}}", ClientSecret: "{{env.CLIENT_SECRET}}", RefreshToken: "{{env.REFRESH_TOKEN}}", ClientAuth: "body"}}},
+		{"api key in the query", models.Auth{Type: models.AuthAPIKey, APIKey: &models.APIKeyAuth{In: "query", Name: "api_key", Value: "{{env.API_KEY}}"}}},
+		{"session", models.Auth{
+			Type:     models.AuthSession,
+			Endpoint: "/login",
+			Inputs:   []models.Input{{Body: map[string]any{"username": "{{env.USERNAME}}", "password": "{{env.PASSWORD}}"}}},
+			Session:  &models.SessionAuth{TokenPath: "$.data.token", ExpiresInPath: "$.data.expires_in"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Generate(fixture(tt.auth), Options{})
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+
+			dir := t.TempDir()
+			files["go.mod"] = []byte("module example.com/testapi\n\ngo 1.24\n")
+			for name, data := range files {
+				if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+
+			for _, args := range [][]string{{"vet", "./..."}, {"test", "./..."}} {
+				cmd := exec.Command(gobin, args...)
+				cmd.Dir = dir
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Errorf("go %s on the generated package: %v\n%s", args[0], err, out)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateNames(t *testing.T) {
+	files, err := Generate(fixture(models.Auth{}), Options{})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	for _, name := range []string{"client.go", "endpoints.go", "models.go", "pagination.go", "client_test.go"} {
+		if files[name] == nil {
+			t.Errorf("missing %s", name)
+		}
+	}
+	if files["auth.go"] != nil {
+		t.Error("generated auth.go for an API without auth")
+	}
+
+	if PackageName("Test-API") != "testapi" || PackageName("2fa") != "api2fa" || PackageName("func") != "apifunc" {
+		t.Errorf("unexpected package names %s, %s, %s", PackageName("Test-API"), PackageName("2fa"), PackageName("func"))
+	}
+}
@@ -0,0 +1,120 @@
+package codegen
+
+import (
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// initialisms are the words Go spells in capitals, so generated names read like hand-written ones
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "https": true, "id": true, "ip": true, "json": true,
+	"sql": true, "ttl": true, "uri": true, "url": true, "utc": true, "uuid": true, "xml": true,
+}
+
+// words splits a name at separators and case changes, e.g. get_employeeID into get, employee and ID
+func words(s string) []string {
+	var (
+		out     []string
+		current []rune
+	)
+
+	flush := func() {
+		if len(current) > 0 {
+			out = append(out, string(current))
+			current = nil
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+
+		if unicode.IsUpper(r) && len(current) > 0 {
+			previous := current[len(current)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				flush()
+			}
+		}
+
+		current = append(current, r)
+	}
+	flush()
+
+	return out
+}
+
+// exported turns a name from the config into an exported Go identifier
+func exported(s string) string {
+	var b strings.Builder
+	for _, word := range words(s) {
+		lower := strings.ToLower(word)
+		if initialisms[lower] {
+			b.WriteString(strings.ToUpper(lower))
+			continue
+		}
+		runes := []rune(lower)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	name := b.String()
+	if name == "" {
+		return "Value"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "N" + name
+	}
+	return name
+}
+
+// unexported turns a name from the config into an unexported Go identifier, for parameters
+func unexported(s string) string {
+	name := exported(s)
+
+	// Lower the leading word, which may be an initialism like ID.
+	runes := []rune(name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	name = string(runes)
+	if token.IsKeyword(name) {
+		name += "Value"
+	}
+	return name
+}
+
+// names hands out identifiers that are unique within a scope
+type names map[string]bool
+
+// claim returns name, or name with a number appended when it is already taken
+func (n names) claim(name string) string {
+	unique := name
+	for i := 2; n[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	n[unique] = true
+	return unique
+}
+
+// locals are the names generated functions use for their own variables, which arguments can't take
+var locals = []string{"body", "c", "ctx", "err", "header", "out", "params", "path", "payload", "query", "res"}
+
+// argument turns a name from the config into a function argument, clear of packages, locals and the other arguments
+func argument(s string, taken names) string {
+	name := unexported(s)
+	if _, ok := stdlib[name]; ok || slices.Contains(locals, name) {
+		name += "Value"
+	}
+	return taken.claim(name)
+}
@@ -0,0 +1,198 @@
+package codegen
+
+// defaultMaxPages matches the runner, stopping runaway pagination when an API never signals the last page
+const defaultMaxPages = 100
+
+// paginationSource follows the pagination of endpoints the way the runner does, for the All helpers
+const paginationSource = `
+// pagination describes how an endpoint spreads its results over pages
+type pagination struct {
+	kind        string
+	itemsPath   []string
+	pageParam   string
+	startPage   int
+	limitParam  string
+	limit       int
+	offsetParam string
+	cursorParam string
+	cursorPath  []string
+	nextURLPath []string
+	totalPath   []string
+	maxPages    int
+}
+
+/*
+collect sends a call page by page and decodes the items of all pages. It stops on
+an empty or short page, when the API stops handing out a cursor or next link,
+when the reported total is reached, or after maxPages pages.
+*/
+func collect[T any](ctx context.Context, c *Client, p pagination, method, path string, query url.Values, header http.Header, body any) ([]T, error) {
+	var (
+		items  []T
+		count  int
+		page   = p.startPage
+		cursor string
+		target = path
+		seen   = map[string]bool{}
+	)
+
+	for n := 0; n < p.maxPages; n++ {
+		values := url.Values{}
+		if target == path {
+			// Next links carry the query parameters themselves.
+			for key, value := range query {
+				values[key] = append([]string(nil), value...)
+			}
+		}
+
+		// Pagination parameters go in the body of calls that have one, and in the query otherwise.
+		var fields map[string]any
+		if body != nil && method != http.MethodGet && method != http.MethodHead {
+			data, err := json.Marshal(body)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding request body: %w", err)
+			}
+			_ = json.Unmarshal(data, &fields)
+		}
+
+		set := func(name string, value any) {
+			if fields != nil {
+				fields[name] = value
+			} else {
+				values.Set(name, fmt.Sprint(value))
+			}
+		}
+
+		if p.limit > 0 && p.limitParam != "" {
+			set(p.limitParam, p.limit)
+		}
+
+		switch p.kind {
+		case "page":
+			set(p.pageParam, page)
+		case "offset":
+			set(p.offsetParam, count)
+		case "cursor":
+			if cursor != "" {
+				set(p.cursorParam, cursor)
+			}
+		}
+
+		payload := body
+		if fields != nil {
+			payload = fields
+		}
+
+		res, err := c.do(ctx, method, target, values, header, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(res.body))
+		decoder.UseNumber()
+
+		var doc any
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("error decoding page: %w", err)
+		}
+
+		found, _ := lookup(doc, p.itemsPath)
+		list, ok := found.([]any)
+		if found != nil && !ok {
+			return nil, fmt.Errorf("page has no list of items at %v", p.itemsPath)
+		}
+
+		data, err := json.Marshal(list)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding items: %w", err)
+		}
+
+		var decoded []T
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, fmt.Errorf("error decoding items: %w", err)
+		}
+
+		items = append(items, decoded...)
+		count += len(list)
+
+		if len(list) == 0 || (p.limit > 0 && len(list) < p.limit) {
+			break
+		}
+
+		if len(p.totalPath) > 0 {
+			if total, ok := lookup(doc, p.totalPath); ok {
+				if want, err := strconv.Atoi(fmt.Sprint(total)); err == nil && count >= want {
+					break
+				}
+			}
+		}
+
+		var next string
+		switch p.kind {
+		case "page":
+			page++
+			continue
+		case "offset":
+			continue
+		case "cursor":
+			value, _ := lookup(doc, p.cursorPath)
+			if value == nil || fmt.Sprint(value) == "" || fmt.Sprint(value) == cursor {
+				return items, nil
+			}
+			cursor = fmt.Sprint(value)
+			continue
+		case "link_header":
+			next = nextLink(res.header.Values("Link"))
+		case "next_url":
+			if value, ok := lookup(doc, p.nextURLPath); ok && value != nil {
+				next = fmt.Sprint(value)
+			}
+		}
+
+		if next == "" {
+			return items, nil
+		}
+
+		current, err := c.resolve(target)
+		if err != nil {
+			return nil, err
+		}
+		resolved, err := current.Parse(next)
+		if err != nil {
+			return nil, fmt.Errorf("invalid next page URL %q: %w", next, err)
+		}
+		if seen[resolved.String()] {
+			return items, nil
+		}
+		seen[resolved.String()] = true
+		target = resolved.String()
+	}
+
+	return items, nil
+}
+
+// nextLink finds the rel="next" URL in RFC 8288 Link headers
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") {
+					continue
+				}
+
+				for _, rel := range strings.Fields(strings.Trim(value, "\"")) {
+					if strings.EqualFold(rel, "next") {
+						return target
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
+`
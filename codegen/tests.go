@@ -0,0 +1,351 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/schema"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// The paths the mock server answers token and login requests on
+const (
+	testTokenPath = "/oauth/token"
+	testLoginPath = "/login"
+)
+
+/*
+testFile writes tests that call every method against a mock server. The server
+answers each endpoint with an example response generated from its schema, checks
+the credentials of every request, and ends pagination after the first page, so
+the tests cover decoding, auth and the All helpers without a live API.
+*/
+func (g *generator) testFile() string {
+	var b strings.Builder
+
+	var testable []*method
+	for _, m := range g.methods {
+		if !m.absolute() {
+			testable = append(testable, m)
+		}
+	}
+
+	b.WriteString(`
+// route is a canned answer of the mock server
+type route struct {
+	method  string
+	pattern *regexp.Regexp
+	first   string
+	rest    string
+	calls   int
+}
+
+// newTestClient starts a mock server that answers every endpoint with an example response, and returns a client for it
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	routes := []*route{
+`)
+
+	for _, m := range testable {
+		first, rest := g.exampleResponses(m)
+		fmt.Fprintf(&b, "\t\t{method: %q, pattern: regexp.MustCompile(%s), first: %s, rest: %s},\n",
+			m.verb, strconv.Quote(m.pattern()), strconv.Quote(first), strconv.Quote(rest))
+	}
+
+	b.WriteString(`	}
+
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+`)
+	b.WriteString(g.testAuthRoute())
+	if check := g.testAuthCheck(); check != "" {
+		fmt.Fprintf(&b, "\n\t\tif !(%s) {\n\t\t\tw.WriteHeader(http.StatusUnauthorized)\n\t\t\treturn\n\t\t}\n", check)
+	}
+	b.WriteString(`
+		for _, route := range routes {
+			if route.method != r.Method || !route.pattern.MatchString(r.URL.Path) {
+				continue
+			}
+
+			body := route.first
+			if route.calls > 0 {
+				body = route.rest
+			}
+			route.calls++
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, body)
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+`)
+	b.WriteString(g.testClient())
+	b.WriteString("\treturn client\n}\n")
+
+	for _, m := range testable {
+		fmt.Fprintf(&b, "\nfunc Test%s(t *testing.T) {\n\tclient := newTestClient(t)\n\n", m.name)
+		call := fmt.Sprintf("client.%s(%s)", m.name, g.testArguments(m))
+		if m.result == "" {
+			fmt.Fprintf(&b, "\tif err := %s; err != nil {\n\t\tt.Fatalf(\"%s failed: %%v\", err)\n\t}\n}\n", call, m.name)
+		} else {
+			fmt.Fprintf(&b, "\tif _, err := %s; err != nil {\n\t\tt.Fatalf(\"%s failed: %%v\", err)\n\t}\n}\n", call, m.name)
+		}
+
+		if m.all != "" {
+			want := g.examplePageSize(m)
+			fmt.Fprintf(&b, `
+func Test%s(t *testing.T) {
+	client := newTestClient(t)
+
+	items, err := client.%s(%s)
+	if err != nil {
+		t.Fatalf("%s failed: %%v", err)
+	}
+	if len(items) != %d {
+		t.Fatalf("%s returned %%d items, want %d", len(items))
+	}
+}
+`, m.all, m.all, g.testArguments(m), m.all, want, m.all, want)
+		}
+	}
+
+	if g.authType() != models.AuthNone && len(testable) > 0 {
+		m := testable[0]
+		assign := "_, err :="
+		if m.result == "" {
+			assign = "err :="
+		}
+		fmt.Fprintf(&b, `
+func TestUnauthorized(t *testing.T) {
+	client := newTestClient(t)
+	client.auth = nil
+
+	%s client.%s(%s)
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("%s without credentials returned %%v, want a 401 error", err)
+	}
+}
+`, assign, m.name, g.testArguments(m), m.name)
+	}
+
+	return b.String()
+}
+
+// pattern matches the paths of an endpoint, with anything in place of its path parameters
+func (m *method) pattern() string {
+	var b strings.Builder
+	b.WriteString("^")
+
+	route := "/" + strings.TrimLeft(m.route(), "/")
+	last := 0
+	for _, loc := range pathParameter.FindAllStringIndex(route, -1) {
+		b.WriteString(regexp.QuoteMeta(route[last:loc[0]]))
+		b.WriteString("[^/]+")
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(route[last:]))
+
+	b.WriteString("$")
+	return b.String()
+}
+
+/*
+exampleResponses returns the mock server's first and later answers to a method.
+For paginated endpoints later pages are empty, and the first page links to the
+next one where the pagination follows links in the body.
+*/
+func (g *generator) exampleResponses(m *method) (string, string) {
+	if m.endpoint.Response == nil {
+		return "", ""
+	}
+
+	example := schema.Example(g.config, m.endpoint.Response)
+	first, _ := json.Marshal(example)
+	if m.all == "" {
+		return string(first), string(first)
+	}
+
+	p := m.endpoint.Pagination
+	if p.Type == models.PaginationNextURL {
+		example = setPath(example, p.NextURLPath, "/"+strings.TrimLeft(m.route(), "/"))
+		first, _ = json.Marshal(example)
+	}
+
+	empty := setPath(schema.Example(g.config, m.endpoint.Response), p.ItemsPath, []any{})
+	rest, _ := json.Marshal(empty)
+
+	return string(first), string(rest)
+}
+
+// examplePageSize is the number of items on the mock server's first page
+func (g *generator) examplePageSize(m *method) int {
+	example := schema.Example(g.config, m.endpoint.Response)
+	items, _ := utils.Lookup(example, m.endpoint.Pagination.ItemsPath)
+	if m.endpoint.Pagination.ItemsPath == "" {
+		items = example
+	}
+	list, _ := items.([]any)
+	return len(list)
+}
+
+// setPath sets the value at a path in decoded JSON, creating objects along the way
+func setPath(doc any, path string, value any) any {
+	segments, err := utils.ParsePath(path)
+	if err != nil || len(segments) == 0 {
+		return value
+	}
+
+	object, ok := doc.(map[string]any)
+	if !ok {
+		object = map[string]any{}
+	}
+	object[segments[0]] = setPath(object[segments[0]], strings.Join(segments[1:], "."), value)
+	return object
+}
+
+// testArguments are what the tests call a method with: a path parameter of the right type, and no params or body
+func (g *generator) testArguments(m *method) string {
+	args := []string{"context.Background()"}
+	for _, a := range m.args {
+		switch a.goType {
+		case "string":
+			args = append(args, `"1"`)
+		case "bool":
+			args = append(args, "true")
+		default:
+			if resolved, err := g.config.ResolveSchema(a.schema); err == nil && resolved != nil && resolved.Type == "string" {
+				args = append(args, `"1"`)
+			} else if resolved != nil && resolved.Type == "boolean" {
+				args = append(args, "true")
+			} else {
+				args = append(args, "1")
+			}
+		}
+	}
+	if m.params != "" {
+		args = append(args, "nil")
+	}
+	if m.body != "" {
+		switch bodyType(m.body) {
+		case "string":
+			args = append(args, `""`)
+		case "int64", "float64":
+			args = append(args, "0")
+		case "bool":
+			args = append(args, "false")
+		default:
+			args = append(args, "nil")
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+// testAuthRoute answers the token or login request of the mock server
+func (g *generator) testAuthRoute() string {
+	switch g.authType() {
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		token := `{"access_token":"test-access-token","token_type":"Bearer","expires_in":3600}`
+		return fmt.Sprintf(`
+		if r.URL.Path == %q {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, %s)
+			return
+		}
+`, testTokenPath, strconv.Quote(token))
+
+	case models.AuthSession:
+		var login any = map[string]any{}
+		if s := g.config.Auth.Session; s != nil && s.TokenPath != "" {
+			login = setPath(login, s.TokenPath, "test-session-token")
+		}
+		data, _ := json.Marshal(login)
+		return fmt.Sprintf(`
+		if r.URL.Path == %q {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "test-session"})
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, %s)
+			return
+		}
+`, testLoginPath, strconv.Quote(string(data)))
+	}
+
+	return ""
+}
+
+// testAuthCheck is the condition under which the mock server accepts a request's credentials
+func (g *generator) testAuthCheck() string {
+	a := g.config.Auth
+
+	switch g.authType() {
+	case models.AuthAPIKey:
+		return received(a.APIKey.In, a.APIKey.Name, a.APIKey.Prefix+"test-key")
+	case models.AuthBasic:
+		return `func() bool { user, password, ok := r.BasicAuth(); return ok && user == "test-user" && password == "test-password" }()`
+	case models.AuthBearer:
+		return `r.Header.Get("Authorization") == "Bearer test-token"`
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		return `r.Header.Get("Authorization") == "Bearer test-access-token"`
+	case models.AuthSession:
+		if a.Session == nil || a.Session.TokenPath == "" {
+			return received("cookie", "session", "test-session")
+		}
+		name, prefix := a.Session.Name, a.Session.Prefix
+		if name == "" {
+			name = "Authorization"
+		}
+		if prefix == "" && strings.EqualFold(name, "Authorization") {
+			prefix = "Bearer "
+		}
+		return received(a.Session.In, name, prefix+"test-session-token")
+	}
+
+	return ""
+}
+
+// received is the condition that a request carries a credential in a header, query parameter or cookie
+func received(in, name, value string) string {
+	switch in {
+	case "query":
+		return fmt.Sprintf("r.URL.Query().Get(%q) == %q", name, value)
+	case "cookie":
+		return fmt.Sprintf("func() bool { cookie, err := r.Cookie(%q); return err == nil && cookie.Value == %q }()", name, value)
+	}
+	return fmt.Sprintf("r.Header.Get(%q) == %q", name, value)
+}
+
+// testClient creates the client of the tests, with test credentials and its logins pointed at the mock server
+func (g *generator) testClient() string {
+	switch g.authType() {
+	case models.AuthAPIKey:
+		return "\tclient := New(WithBaseURL(server.URL), WithAPIKey(\"test-key\"))\n"
+	case models.AuthBasic:
+		return "\tclient := New(WithBaseURL(server.URL), WithBasicAuth(\"test-user\", \"test-password\"))\n"
+	case models.AuthBearer:
+		return "\tclient := New(WithBaseURL(server.URL), WithToken(\"test-token\"))\n"
+	case models.AuthOAuth2ClientCredentials:
+		return fmt.Sprintf("\tclient := New(WithBaseURL(server.URL), WithClientCredentials(\"test-client\", \"test-secret\"))\n\tclient.auth.(*oauth2).tokenURL = server.URL + %q\n", testTokenPath)
+	case models.AuthOAuth2AuthorizationCode:
+		return fmt.Sprintf("\tclient := New(WithBaseURL(server.URL), WithAuthorizationCode(\"test-client\", \"test-secret\", \"test-code\"))\n\tclient.auth.(*oauth2).tokenURL = server.URL + %q\n", testTokenPath)
+	case models.AuthSession:
+		credentials := make([]string, len(g.loginCredentials()))
+		for i := range credentials {
+			credentials[i] = `"test"`
+		}
+		return fmt.Sprintf("\tclient := New(WithBaseURL(server.URL), WithLogin(%s))\n\tclient.auth.(*session).loginURL = server.URL + %q\n", strings.Join(credentials, ", "), testLoginPath)
+	}
+
+	return "\tclient := New(WithBaseURL(server.URL))\n"
+}
@@ -0,0 +1,232 @@
+package codegen
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// modelDoc documents data models without a description
+const modelDoc = "is a data model of the API."
+
+// decl is a type of the generated package, with its fields when it is a struct
+type decl struct {
+	name       string
+	doc        string
+	fallback   string
+	isStruct   bool
+	fields     []field
+	underlying string
+	alias      bool
+	enum       []enumValue
+}
+
+type field struct {
+	name, goType, tag, doc string
+}
+
+type enumValue struct {
+	name, value string
+}
+
+/*
+declareSchemas declares a type for every data model of the config. All names are
+claimed up front, so models can reference each other in any order.
+*/
+func (g *generator) declareSchemas() {
+	schemaNames := slices.Sorted(maps.Keys(g.config.Schemas))
+
+	for _, name := range schemaNames {
+		g.refs[name] = g.idents.claim(exported(name))
+
+		// Models that end up as slices, maps or any are never pointed to.
+		resolved, err := g.config.ResolveSchema(g.config.Schemas[name])
+		if err != nil || resolved == nil || !scalar(resolved) && (!isObject(resolved) || len(resolved.Properties) == 0) {
+			g.noPointer[g.refs[name]] = true
+		}
+	}
+
+	for _, name := range schemaNames {
+		s, typeName := g.config.Schemas[name], g.refs[name]
+
+		switch {
+		case s == nil:
+			g.decls = append(g.decls, &decl{name: typeName, fallback: modelDoc, underlying: "any", alias: true})
+		case s.Ref != "":
+			d := &decl{name: typeName, fallback: modelDoc, alias: true}
+			g.decls = append(g.decls, d)
+			d.underlying = g.goType(s, typeName)
+		case isObject(s) && len(s.Properties) > 0:
+			g.declareStruct(typeName, s, modelDoc)
+		default:
+			d := &decl{name: typeName, doc: s.Description, fallback: modelDoc}
+			g.decls = append(g.decls, d)
+			d.underlying = g.goType(s, typeName)
+
+			if s.Type == "string" {
+				for _, value := range s.Enum {
+					if text, ok := value.(string); ok && text != "" {
+						d.enum = append(d.enum, enumValue{g.idents.claim(typeName + exported(text)), text})
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+goType returns the Go type for a schema, declaring structs for inline objects
+under a name derived from hint. Formats don't change the type, dates and the like
+stay strings, since APIs rarely agree on how they write them.
+*/
+func (g *generator) goType(s *models.Schema, hint string) string {
+	if s == nil {
+		return "any"
+	}
+
+	if s.Ref != "" {
+		if name, ok := g.refs[models.RefName(s.Ref)]; ok {
+			return name
+		}
+		return "any"
+	}
+
+	if name, ok := g.declared[s]; ok {
+		return name
+	}
+
+	switch {
+	case isObject(s):
+		if len(s.Properties) == 0 {
+			return "map[string]any"
+		}
+		return g.declareStruct(g.idents.claim(hint), s, "is part of a request or response of the API.")
+	case s.Type == "array":
+		return "[]" + g.goType(s.Items, hint+"Item")
+	case s.Type == "string":
+		return "string"
+	case s.Type == "integer":
+		return "int64"
+	case s.Type == "number":
+		return "float64"
+	case s.Type == "boolean":
+		return "bool"
+	}
+
+	return "any"
+}
+
+func (g *generator) declareStruct(name string, s *models.Schema, fallback string) string {
+	d := &decl{name: name, doc: s.Description, fallback: fallback, isStruct: true}
+	g.declared[s] = name
+	g.decls = append(g.decls, d)
+
+	fieldNames := names{}
+	for _, property := range slices.Sorted(maps.Keys(s.Properties)) {
+		p := s.Properties[property]
+		fieldName := fieldNames.claim(exported(property))
+		fieldType := g.goType(p, name+fieldName)
+
+		optional := !slices.Contains(s.Required, property)
+		if (optional || g.nullable(p)) && g.pointerable(fieldType) {
+			fieldType = "*" + fieldType
+		}
+
+		tag := property
+		if optional {
+			tag += ",omitempty"
+		}
+
+		var doc string
+		if p != nil {
+			doc = p.Description
+		}
+
+		d.fields = append(d.fields, field{fieldName, fieldType, "`json:" + strconv.Quote(tag) + "`", doc})
+	}
+
+	return name
+}
+
+// nullable reports whether a schema, or the data model it references, allows null
+func (g *generator) nullable(s *models.Schema) bool {
+	resolved, err := g.config.ResolveSchema(s)
+	return err == nil && resolved != nil && resolved.Nullable
+}
+
+// pointerable reports whether optional values of a type are better off as pointers, which nil slices, maps and interfaces are not
+func (g *generator) pointerable(goType string) bool {
+	return !strings.HasPrefix(goType, "[]") && !strings.HasPrefix(goType, "map[") && goType != "any" && !g.noPointer[goType]
+}
+
+func scalar(s *models.Schema) bool {
+	return s.Type == "string" || s.Type == "integer" || s.Type == "number" || s.Type == "boolean"
+}
+
+func isObject(s *models.Schema) bool {
+	return s.Type == "object" || (s.Type == "" && len(s.Properties) > 0)
+}
+
+// modelsFile writes the declared types
+func (g *generator) modelsFile() string {
+	var b strings.Builder
+
+	for _, d := range g.decls {
+		b.WriteString("\n")
+		comment(&b, "", d.name, d.doc, d.fallback)
+
+		switch {
+		case d.isStruct:
+			fmt.Fprintf(&b, "type %s struct {\n", d.name)
+			for _, f := range d.fields {
+				if f.doc != "" {
+					fmt.Fprintf(&b, "\t// %s\n", oneLine(f.doc))
+				}
+				fmt.Fprintf(&b, "\t%s %s %s\n", f.name, f.goType, f.tag)
+			}
+			b.WriteString("}\n")
+		case d.alias:
+			fmt.Fprintf(&b, "type %s = %s\n", d.name, d.underlying)
+		default:
+			fmt.Fprintf(&b, "type %s %s\n", d.name, d.underlying)
+		}
+
+		if len(d.enum) > 0 {
+			fmt.Fprintf(&b, "\n// The values of %s\nconst (\n", d.name)
+			for _, value := range d.enum {
+				fmt.Fprintf(&b, "\t%s %s = %s\n", value.name, d.name, strconv.Quote(value.value))
+			}
+			b.WriteString(")\n")
+		}
+	}
+
+	return b.String()
+}
+
+/*
+comment writes a doc comment for name, built from a description when there is one
+and from fallback otherwise, so it starts with the name the way Go expects.
+*/
+func comment(b *strings.Builder, indent, name, description, fallback string) {
+	text := oneLine(description)
+	if text == "" {
+		fmt.Fprintf(b, "%s// %s %s\n", indent, name, fallback)
+		return
+	}
+
+	runes := []rune(text)
+	if len(runes) > 1 && unicode.IsUpper(runes[0]) && (unicode.IsLower(runes[1]) || runes[1] == ' ') {
+		runes[0] = unicode.ToLower(runes[0])
+	}
+	fmt.Fprintf(b, "%s// %s is %s\n", indent, name, strings.TrimSuffix(string(runes), "."))
+}
+
+// oneLine collapses a description onto a single line
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
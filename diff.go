@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/theapemachine/idrinkyourmilkshake/diff"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// diffCommand compares two versions of an APIConfig and reports what changed
+func diffCommand(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := flags.String("format", "text", "Output format: text or json")
+	out := flags.String("out", "", "Write the report to this file instead of stdout")
+	failOnBreaking := flags.Bool("fail-on-breaking", false, "Exit with an error when there are breaking changes")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: milkshake diff [flags] old.json new.json")
+	}
+
+	old, err := models.LoadAPIConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	new, err := models.LoadAPIConfig(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	report := diff.Compare(old, new)
+
+	var data []byte
+	switch *format {
+	case "text":
+		data = []byte(report.Text())
+	case "json":
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			return fmt.Errorf("error encoding report: %w", err)
+		}
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unknown format %q, use text or json", *format)
+	}
+
+	if err := write(*out, data); err != nil {
+		return err
+	}
+
+	if *failOnBreaking && report.Breaking() {
+		return fmt.Errorf("%d breaking changes", report.Summary.Breaking)
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/export"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// pathParameter matches OpenAPI style path parameters such as {id}
+var pathParameter = regexp.MustCompile(`\{[^{}/]+\}`)
+
+// differ collects the changes between two versions of a config
+type differ struct {
+	old, new *models.APIConfig
+	usage    map[string]direction
+	changes  []Change
+}
+
+/*
+Compare structurally compares two versions of a config, e.g. before and after a
+vendor changed its docs, and classifies every change as breaking or not for an
+integration built against the old version. Endpoints are matched by method and
+path, with parameter names ignored, and then by name, so renames by a new
+extraction don't show up as changes. Schema changes are judged by whether the
+schema is sent in requests, read from responses, or both.
+*/
+func Compare(old, new *models.APIConfig) *Report {
+	d := &differ{old: old, new: new}
+	d.usage = usage(old)
+	for name, dir := range usage(new) {
+		d.usage[name] |= dir
+	}
+
+	if old.BaseURL != new.BaseURL {
+		d.add("base_url", Changed, "base URL changed", old.BaseURL, new.BaseURL, true)
+	}
+
+	d.auth()
+	d.endpoints()
+	d.schemas()
+
+	report := &Report{Integration: new.Integration, Changes: d.changes}
+	if report.Integration == "" {
+		report.Integration = old.Integration
+	}
+	if report.Changes == nil {
+		report.Changes = []Change{}
+	}
+	report.summarize()
+
+	return report
+}
+
+func (d *differ) add(path, kind, message string, old, new any, breaking bool) {
+	d.changes = append(d.changes, Change{Path: path, Kind: kind, Breaking: breaking, Message: message, Old: old, New: new})
+}
+
+/*
+auth compares the auth blocks. A different auth type, or any setting of it that
+changed, means integrations have to be reconfigured, so it is always breaking.
+*/
+func (d *differ) auth() {
+	oldType, newType := auth.Type(d.old.Auth), auth.Type(d.new.Auth)
+	if oldType != newType {
+		d.add("auth.type", Changed, "auth type changed", oldType, newType, true)
+		return
+	}
+
+	d.settings("auth", "auth setting", flatten(d.old.Auth), flatten(d.new.Auth), func(string) bool { return true })
+}
+
+/*
+endpoints matches the endpoints of both versions and compares each pair. Removed
+endpoints break integrations that call them, new ones don't.
+*/
+func (d *differ) endpoints() {
+	oldEndpoints, newEndpoints := export.Endpoints(d.old), export.Endpoints(d.new)
+	matched := map[int]int{}
+	taken := map[int]bool{}
+
+	// First by method and path, then whatever is left by name.
+	for _, byName := range []bool{false, true} {
+		for i, o := range oldEndpoints {
+			if _, ok := matched[i]; ok {
+				continue
+			}
+			for j, n := range newEndpoints {
+				if taken[j] {
+					continue
+				}
+				if (!byName && signature(o) == signature(n)) || (byName && o.Name == n.Name) {
+					matched[i], taken[j] = j, true
+					break
+				}
+			}
+		}
+	}
+
+	for j, n := range newEndpoints {
+		if !taken[j] {
+			d.add("endpoints."+n.Name, Added, fmt.Sprintf("%s %s added", method(n), n.Path), nil, nil, false)
+			continue
+		}
+
+		for i, o := range oldEndpoints {
+			if k, ok := matched[i]; ok && k == j {
+				d.endpoint(o, n)
+			}
+		}
+	}
+
+	for i, o := range oldEndpoints {
+		if _, ok := matched[i]; !ok {
+			d.add("endpoints."+o.Name, Removed, fmt.Sprintf("%s %s removed", method(o), o.Path), nil, nil, true)
+		}
+	}
+}
+
+func (d *differ) endpoint(old, new models.Endpoint) {
+	path := "endpoints." + new.Name
+
+	if method(old) != method(new) {
+		d.add(path+".method", Changed, "method changed", method(old), method(new), true)
+	}
+	if normalize(old.Path) != normalize(new.Path) {
+		d.add(path+".path", Changed, "path changed", old.Path, new.Path, true)
+	}
+
+	d.parameters(path, old, new)
+	d.schema(path+".request", old.Request, new.Request, request)
+	d.schema(path+".response", old.Response, new.Response, response)
+
+	switch {
+	case old.Pagination == nil && new.Pagination != nil:
+		d.add(path+".pagination", Added, "pagination added", nil, new.Pagination, true)
+	case old.Pagination != nil && new.Pagination == nil:
+		d.add(path+".pagination", Removed, "pagination removed", old.Pagination, nil, true)
+	case old.Pagination != nil:
+		// The page size and page limit are what the runner asks for, not how the API behaves.
+		d.settings(path+".pagination", "pagination setting", flatten(old.Pagination), flatten(new.Pagination), func(key string) bool {
+			return key != "limit" && key != "max_pages"
+		})
+	}
+}
+
+/*
+parameters compares the path, query and header parameters of an endpoint. New
+required parameters and parameters that became required break callers, as do
+removed parameters and type changes. New optional parameters don't.
+*/
+func (d *differ) parameters(path string, old, new models.Endpoint) {
+	key := func(p models.EndpointParameter) string {
+		if p.In == "header" {
+			return p.In + "." + strings.ToLower(p.Name)
+		}
+		return p.In + "." + p.Name
+	}
+
+	oldParams := map[string]models.EndpointParameter{}
+	for _, p := range old.Parameters {
+		oldParams[key(p)] = p
+	}
+	newParams := map[string]models.EndpointParameter{}
+	for _, p := range new.Parameters {
+		newParams[key(p)] = p
+	}
+
+	// Path parameters are positional, a renamed one is still the same parameter.
+	oldPath, newPath := pathParameter.FindAllString(old.Path, -1), pathParameter.FindAllString(new.Path, -1)
+	for i := range min(len(oldPath), len(newPath)) {
+		oldName, newName := strings.Trim(oldPath[i], "{}"), strings.Trim(newPath[i], "{}")
+		if oldName == newName {
+			continue
+		}
+		if p, ok := oldParams["path."+oldName]; ok {
+			delete(oldParams, "path."+oldName)
+			oldParams["path."+newName] = p
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(newParams)) {
+		n := newParams[k]
+		o, ok := oldParams[k]
+		at := path + ".parameters." + k
+
+		switch {
+		case !ok && n.In == "path":
+			// Added path parameters show up as a path change already.
+		case !ok:
+			message := fmt.Sprintf("optional %s parameter %s added", n.In, n.Name)
+			if n.Required {
+				message = fmt.Sprintf("required %s parameter %s added", n.In, n.Name)
+			}
+			d.add(at, Added, message, nil, nil, n.Required)
+		default:
+			if !o.Required && n.Required {
+				d.add(at+".required", Changed, fmt.Sprintf("%s parameter %s became required", n.In, n.Name), false, true, true)
+			} else if o.Required && !n.Required {
+				d.add(at+".required", Changed, fmt.Sprintf("%s parameter %s became optional", n.In, n.Name), true, false, false)
+			}
+			// A schema appearing or disappearing only says how much the docs tell about the parameter.
+			if o.Schema != nil && n.Schema != nil {
+				d.compare(at+".schema", o.Schema, n.Schema, request)
+			}
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(oldParams)) {
+		o := oldParams[k]
+		if _, ok := newParams[k]; !ok && o.In != "path" {
+			d.add(path+".parameters."+k, Removed, fmt.Sprintf("%s parameter %s removed", o.In, o.Name), nil, nil, true)
+		}
+	}
+}
+
+// settings compares two flattened blocks of settings key by key
+func (d *differ) settings(path, what string, old, new map[string]any, breaking func(key string) bool) {
+	keys := slices.Sorted(maps.Keys(old))
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		o, inOld := old[key]
+		n, inNew := new[key]
+
+		switch {
+		case !inOld:
+			d.add(path+"."+key, Added, what+" added", nil, n, breaking(key))
+		case !inNew:
+			d.add(path+"."+key, Removed, what+" removed", o, nil, breaking(key))
+		case value(o) != value(n):
+			d.add(path+"."+key, Changed, what+" changed", o, n, breaking(key))
+		}
+	}
+}
+
+/*
+flatten turns a settings block into a map of dotted paths to its values, the way
+they are written in JSON, so blocks compare key by key. Lists are kept whole.
+*/
+func flatten(v any) map[string]any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+
+	out := map[string]any{}
+	var walk func(prefix string, value any)
+	walk = func(prefix string, value any) {
+		object, ok := value.(map[string]any)
+		if !ok {
+			if prefix != "" {
+				out[prefix] = value
+			}
+			return
+		}
+		for key, val := range object {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			walk(key, val)
+		}
+	}
+	walk("", doc)
+
+	return out
+}
+
+// signature identifies an endpoint by its method and path, whatever its path parameters are called
+func signature(endpoint models.Endpoint) string {
+	return method(endpoint) + " " + normalize(endpoint.Path)
+}
+
+func normalize(path string) string {
+	return strings.TrimRight(pathParameter.ReplaceAllString(path, "{}"), "/")
+}
+
+func method(endpoint models.Endpoint) string {
+	if endpoint.Method == "" {
+		return "GET"
+	}
+	return strings.ToUpper(endpoint.Method)
+}
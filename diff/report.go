@@ -0,0 +1,118 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The kinds of change a diff reports
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Change is a single difference between two versions of a config
+type Change struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Breaking bool   `json:"breaking"`
+	Message  string `json:"message"`
+	Old      any    `json:"old,omitempty"`
+	New      any    `json:"new,omitempty"`
+}
+
+// Summary counts the changes of a diff
+type Summary struct {
+	Changes     int `json:"changes"`
+	Breaking    int `json:"breaking"`
+	NonBreaking int `json:"non_breaking"`
+}
+
+// Report lists everything that changed between two versions of a config
+type Report struct {
+	Integration string   `json:"integration"`
+	Summary     Summary  `json:"summary"`
+	Changes     []Change `json:"changes"`
+}
+
+// Breaking reports whether any change breaks integrations built against the old version
+func (report *Report) Breaking() bool {
+	return report.Summary.Breaking > 0
+}
+
+func (report *Report) summarize() {
+	report.Summary = Summary{Changes: len(report.Changes)}
+
+	for _, change := range report.Changes {
+		if change.Breaking {
+			report.Summary.Breaking++
+		} else {
+			report.Summary.NonBreaking++
+		}
+	}
+}
+
+/*
+Text renders the report for people: a summary line, then the breaking changes
+and the other changes, each marked + when added, - when removed and ~ when
+changed, with the old and new values where they say more than the message.
+*/
+func (report *Report) Text() string {
+	var b strings.Builder
+
+	if report.Summary.Changes == 0 {
+		fmt.Fprintf(&b, "%s: no changes\n", report.Integration)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "%s: %d breaking, %d non-breaking changes\n", report.Integration, report.Summary.Breaking, report.Summary.NonBreaking)
+
+	for _, section := range []struct {
+		title    string
+		breaking bool
+	}{{"Breaking changes", true}, {"Non-breaking changes", false}} {
+		var lines []string
+		for _, change := range report.Changes {
+			if change.Breaking != section.breaking {
+				continue
+			}
+
+			line := fmt.Sprintf("  %s %s: %s", marker(change.Kind), change.Path, change.Message)
+			if change.Kind == Changed && (change.Old != nil || change.New != nil) {
+				line += fmt.Sprintf(" (%s -> %s)", value(change.Old), value(change.New))
+			}
+			lines = append(lines, line)
+		}
+
+		if len(lines) > 0 {
+			fmt.Fprintf(&b, "\n%s:\n%s\n", section.title, strings.Join(lines, "\n"))
+		}
+	}
+
+	return b.String()
+}
+
+func marker(kind string) string {
+	switch kind {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	}
+	return "~"
+}
+
+// value formats an old or new value compactly, as JSON
+func value(v any) string {
+	if v == nil {
+		return "none"
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/theapemachine/idrinkyourmilkshake/export"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// direction says whether a schema is sent in requests, read from responses, or both
+type direction int
+
+const (
+	request direction = 1 << iota
+	response
+)
+
+/*
+usage finds the direction every named schema is used in, following references
+from the endpoints' parameters, bodies and responses through other schemas.
+*/
+func usage(config *models.APIConfig) map[string]direction {
+	out := map[string]direction{}
+
+	var mark func(s *models.Schema, dir direction)
+	mark = func(s *models.Schema, dir direction) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			name := models.RefName(s.Ref)
+			if out[name]&dir == dir {
+				return
+			}
+			out[name] |= dir
+			mark(config.Schemas[name], dir)
+			return
+		}
+		for _, property := range s.Properties {
+			mark(property, dir)
+		}
+		mark(s.Items, dir)
+	}
+
+	for _, endpoint := range export.Endpoints(config) {
+		for _, parameter := range endpoint.Parameters {
+			mark(parameter.Schema, request)
+		}
+		mark(endpoint.Request, request)
+		mark(endpoint.Response, response)
+	}
+
+	return out
+}
+
+/*
+schemas compares the named data models. Models no endpoint uses are judged as
+responses, since they describe the data integrations read from the API.
+*/
+func (d *differ) schemas() {
+	names := slices.Sorted(maps.Keys(d.old.Schemas))
+	for name := range d.new.Schemas {
+		if _, ok := d.old.Schemas[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		old, new := d.old.Schemas[name], d.new.Schemas[name]
+		path := "schemas." + name
+
+		switch {
+		case old == nil:
+			d.add(path, Added, "schema added", nil, nil, false)
+		case new == nil:
+			d.add(path, Removed, "schema removed", nil, nil, true)
+		default:
+			dir := d.usage[name]
+			if dir == 0 {
+				dir = response
+			}
+			d.compare(path, old, new, dir)
+		}
+	}
+}
+
+// schema compares the schema of a body or parameter, which is sent or read in the given direction
+func (d *differ) schema(path string, old, new *models.Schema, dir direction) {
+	switch {
+	case old == nil && new == nil:
+	case old == nil:
+		// An endpoint that starts taking a body breaks callers that don't send one.
+		d.add(path, Added, "schema added", nil, nil, dir&request != 0)
+	case new == nil:
+		d.add(path, Removed, "schema removed", nil, nil, dir&response != 0)
+	default:
+		d.compare(path, old, new, dir)
+	}
+}
+
+/*
+compare walks two versions of a schema. What breaks depends on the direction:
+callers can't send what a request no longer accepts, and can't read what a
+response no longer holds. References to the same named schema stop the walk,
+since the named schemas are compared on their own.
+*/
+func (d *differ) compare(path string, old, new *models.Schema, dir direction) {
+	breaks := func(forRequests, forResponses bool) bool {
+		return (dir&request != 0 && forRequests) || (dir&response != 0 && forResponses)
+	}
+
+	if old.Ref != "" || new.Ref != "" {
+		if models.RefName(old.Ref) != models.RefName(new.Ref) {
+			d.add(path, Changed, "type changed", typeName(old), typeName(new), true)
+		}
+		return
+	}
+
+	if typeName(old) != typeName(new) {
+		d.add(path, Changed, "type changed", typeName(old), typeName(new), true)
+		return
+	}
+
+	if old.Format != new.Format {
+		d.add(path+".format", Changed, "format changed", old.Format, new.Format, old.Format != "" && new.Format != "")
+	}
+
+	if !old.Nullable && new.Nullable {
+		d.add(path+".nullable", Changed, "became nullable", false, true, breaks(false, true))
+	} else if old.Nullable && !new.Nullable {
+		d.add(path+".nullable", Changed, "no longer nullable", true, false, breaks(true, false))
+	}
+
+	if len(old.Enum) > 0 || len(new.Enum) > 0 {
+		added, removed := enumChanges(old.Enum, new.Enum)
+		if len(old.Enum) == 0 {
+			d.add(path+".enum", Added, "values restricted to an enum", nil, new.Enum, breaks(true, false))
+		} else if len(new.Enum) == 0 {
+			d.add(path+".enum", Removed, "enum restriction removed", old.Enum, nil, breaks(false, true))
+		} else {
+			// Clients may not handle values they have never seen in responses.
+			if len(added) > 0 {
+				d.add(path+".enum", Added, fmt.Sprintf("enum values %s added", value(added)), nil, added, breaks(false, true))
+			}
+			if len(removed) > 0 {
+				d.add(path+".enum", Removed, fmt.Sprintf("enum values %s removed", value(removed)), removed, nil, breaks(true, false))
+			}
+		}
+	}
+
+	d.properties(path, old, new, dir, breaks)
+
+	if old.Items != nil || new.Items != nil {
+		d.schema(path+"[]", old.Items, new.Items, dir)
+	}
+}
+
+/*
+properties compares the fields of two versions of an object. Removed fields
+break readers of responses, new required fields and fields that became required
+break senders of requests, and fields that became optional break readers.
+*/
+func (d *differ) properties(path string, old, new *models.Schema, dir direction, breaks func(forRequests, forResponses bool) bool) {
+	names := slices.Sorted(maps.Keys(old.Properties))
+	for name := range new.Properties {
+		if _, ok := old.Properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		o, n := old.Properties[name], new.Properties[name]
+		at := path + "." + name
+		wasRequired, isRequired := slices.Contains(old.Required, name), slices.Contains(new.Required, name)
+
+		switch {
+		case o == nil:
+			message := "optional field added"
+			if isRequired {
+				message = "required field added"
+			}
+			d.add(at, Added, message, nil, typeName(n), breaks(isRequired, false))
+		case n == nil:
+			d.add(at, Removed, "field removed", typeName(o), nil, breaks(false, true))
+		default:
+			if !wasRequired && isRequired {
+				d.add(at, Changed, "field became required", "optional", "required", breaks(true, false))
+			} else if wasRequired && !isRequired {
+				d.add(at, Changed, "field became optional", "required", "optional", breaks(false, true))
+			}
+			d.compare(at, o, n, dir)
+		}
+	}
+}
+
+// typeName describes the type of a schema for messages, the referenced schema's name for references
+func typeName(s *models.Schema) string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Ref != "":
+		return models.RefName(s.Ref)
+	case s.Type == "" && len(s.Properties) > 0:
+		return "object"
+	case s.Type == "":
+		return "any"
+	}
+	return s.Type
+}
+
+// enumChanges returns the values only the new enum has, and the values only the old one has
+func enumChanges(old, new []any) ([]any, []any) {
+	var added, removed []any
+	for _, v := range new {
+		if !slices.ContainsFunc(old, func(o any) bool { return value(o) == value(v) }) {
+			added = append(added, v)
+		}
+	}
+	for _, v := range old {
+		if !slices.ContainsFunc(new, func(n any) bool { return value(n) == value(v) }) {
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,166 @@
+package diff
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// employee is an object schema with the given fields, all strings
+func employee(required []string, fields ...string) *models.Schema {
+	s := &models.Schema{Type: "object", Properties: map[string]*models.Schema{}, Required: required}
+	for _, field := range fields {
+		s.Properties[field] = &models.Schema{Type: "string"}
+	}
+	return s
+}
+
+// usedAs builds a config whose endpoints send the Employee schema, read it, or both
+func usedAs(dir direction, s *models.Schema) *models.APIConfig {
+	ref := &models.Schema{Ref: models.SchemaRefPrefix + "Employee"}
+	config := &models.APIConfig{Schemas: map[string]*models.Schema{"Employee": s}}
+
+	if dir&response != 0 {
+		config.Endpoints = append(config.Endpoints, models.Endpoint{Name: "get_employee", Method: "GET", Path: "/employees/{id}", Response: ref})
+	}
+	if dir&request != 0 {
+		config.Endpoints = append(config.Endpoints, models.Endpoint{Name: "create_employee", Method: "POST", Path: "/employees", Request: ref})
+	}
+
+	return config
+}
+
+// breaking maps the paths of a report's changes to whether they break integrations
+func breaking(report *Report) map[string]bool {
+	out := map[string]bool{}
+	for _, change := range report.Changes {
+		out[change.Path] = change.Breaking
+	}
+	return out
+}
+
+func TestCompareSchemaDirections(t *testing.T) {
+	tests := []struct {
+		name     string
+		dir      direction
+		old, new *models.Schema
+		want     map[string]bool
+	}{
+		{
+			name: "field removed from a response",
+			dir:  response,
+			old:  employee(nil, "name", "email"),
+			new:  employee(nil, "name"),
+			want: map[string]bool{"schemas.Employee.email": true},
+		},
+		{
+			name: "field removed from a request",
+			dir:  request,
+			old:  employee(nil, "name", "email"),
+			new:  employee(nil, "name"),
+			want: map[string]bool{"schemas.Employee.email": false},
+		},
+		{
+			name: "new required request field",
+			dir:  request,
+			old:  employee([]string{"name"}, "name"),
+			new:  employee([]string{"name", "role"}, "name", "role"),
+			want: map[string]bool{"schemas.Employee.role": true},
+		},
+		{
+			name: "new optional request field",
+			dir:  request,
+			old:  employee([]string{"name"}, "name"),
+			new:  employee([]string{"name"}, "name", "role"),
+			want: map[string]bool{"schemas.Employee.role": false},
+		},
+		{
+			name: "new required response field",
+			dir:  response,
+			old:  employee([]string{"name"}, "name"),
+			new:  employee([]string{"name", "role"}, "name", "role"),
+			want: map[string]bool{"schemas.Employee.role": false},
+		},
+		{
+			name: "field became optional in a request",
+			dir:  request,
+			old:  employee([]string{"name"}, "name"),
+			new:  employee(nil, "name"),
+			want: map[string]bool{"schemas.Employee.name": false},
+		},
+		{
+			name: "field became optional in a response",
+			dir:  response,
+			old:  employee([]string{"name"}, "name"),
+			new:  employee(nil, "name"),
+			want: map[string]bool{"schemas.Employee.name": true},
+		},
+		{
+			name: "field removed from a schema used in both directions",
+			dir:  request | response,
+			old:  employee(nil, "name", "email"),
+			new:  employee(nil, "name"),
+			want: map[string]bool{"schemas.Employee.email": true},
+		},
+		{
+			name: "new required field in a schema used in both directions",
+			dir:  request | response,
+			old:  employee(nil, "name"),
+			new:  employee([]string{"role"}, "name", "role"),
+			want: map[string]bool{"schemas.Employee.role": true},
+		},
+		{
+			name: "new optional field in a schema used in both directions",
+			dir:  request | response,
+			old:  employee(nil, "name"),
+			new:  employee(nil, "name", "role"),
+			want: map[string]bool{"schemas.Employee.role": false},
+		},
+		{
+			name: "field removed from a schema no endpoint uses",
+			old:  employee(nil, "name", "email"),
+			new:  employee(nil, "name"),
+			want: map[string]bool{"schemas.Employee.email": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Compare(usedAs(tt.dir, tt.old), usedAs(tt.dir, tt.new))
+			if got := breaking(report); !maps.Equal(got, tt.want) {
+				t.Errorf("changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareInlineSchemas(t *testing.T) {
+	old := &models.APIConfig{Endpoints: []models.Endpoint{{
+		Name: "create_employee", Method: "POST", Path: "/employees",
+		Request:  employee([]string{"name"}, "name"),
+		Response: employee(nil, "id", "name"),
+	}}}
+	new := &models.APIConfig{Endpoints: []models.Endpoint{{
+		Name: "create_employee", Method: "POST", Path: "/employees",
+		Request:  employee([]string{"name", "role"}, "name", "role"),
+		Response: employee(nil, "name"),
+	}}}
+
+	want := map[string]bool{
+		"endpoints.create_employee.request.role": true,
+		"endpoints.create_employee.response.id":  true,
+	}
+	if got := breaking(Compare(old, new)); !maps.Equal(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+
+	// Undone, they drop a request field and add a response field, which breaks nothing.
+	want = map[string]bool{
+		"endpoints.create_employee.request.role": false,
+		"endpoints.create_employee.response.id":  false,
+	}
+	if got := breaking(Compare(new, old)); !maps.Equal(got, want) {
+		t.Errorf("reversed changes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/theapemachine/idrinkyourmilkshake/export"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// exportCommand converts an APIConfig into a format other tools understand
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "openapi", "The format to export to: openapi, postman, http or curl")
+	out := flags.String("out", "", "Write the export to this file instead of stdout")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: milkshake export [flags] config.json")
+	}
+
+	config, err := models.LoadAPIConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+
+	switch *format {
+	case "openapi":
+		if data, err = json.MarshalIndent(export.ToOpenAPI(config), "", "  "); err != nil {
+			return fmt.Errorf("error encoding OpenAPI document: %w", err)
+		}
+	case "postman":
+		if data, err = json.MarshalIndent(export.ToPostman(config), "", "  "); err != nil {
+			return fmt.Errorf("error encoding Postman collection: %w", err)
+		}
+	case "http":
+		data = []byte(export.ToHTTPFile(config))
+	case "curl":
+		data = []byte(export.ToCurl(config))
+	default:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+
+	return write(*out, data)
+}
+
+// importCommand converts an OpenAPI document into an APIConfig
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	out := flags.String("out", "", "Write the config to this file instead of stdout")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: milkshake import [flags] openapi.json")
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error reading OpenAPI document: %w", err)
+	}
+
+	config, err := export.FromOpenAPI(data)
+	if err != nil {
+		return err
+	}
+
+	if *out != "" {
+		return config.Save(*out)
+	}
+
+	if data, err = json.MarshalIndent(config, "", "  "); err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	return write("", append(data, '\n'))
+}
+
+// write writes a command's output to a file, or to stdout when no path is given
+func write(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/schema"
+)
+
+// placeholder matches the {{ path }} references the runner resolves in step values
+var placeholder = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// pathParameter matches OpenAPI style path parameters such as {id}
+var pathParameter = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// nonIdentifier matches what can't be part of a parameter or operation name
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// call is an endpoint exports describe, with the job it comes from when it was derived from a step
+type call struct {
+	models.Endpoint
+	Job string
+}
+
+/*
+calls returns the config's endpoints, followed by its HTTP steps that call
+something the endpoints don't already describe, so exports cover everything the
+runner would call. Placeholders in step paths become path parameters.
+*/
+func calls(config *models.APIConfig) []call {
+	var out []call
+	for _, endpoint := range config.Endpoints {
+		out = append(out, call{Endpoint: endpoint})
+	}
+
+	seen := map[string]bool{}
+	for _, endpoint := range config.Endpoints {
+		seen[method(endpoint.Method)+" "+endpoint.Path] = true
+	}
+
+	for _, job := range config.Jobs {
+		for _, step := range job.Steps {
+			if step.Endpoint == "" || step.GraphQL != nil || step.Collection != "" {
+				continue
+			}
+
+			m := method(step.Method)
+			if seen[m+" "+step.Endpoint] {
+				continue
+			}
+			seen[m+" "+step.Endpoint] = true
+
+			path, parameters := templatePath(step.Endpoint)
+			endpoint := models.Endpoint{
+				Name:        identifier(job.Name + "_" + step.Name),
+				Method:      m,
+				Path:        path,
+				Description: fmt.Sprintf("Step %s of job %s", step.Name, job.Name),
+				Parameters:  parameters,
+				Pagination:  step.Pagination,
+			}
+
+			for _, name := range slices.Sorted(maps.Keys(step.Inputs.Headers)) {
+				endpoint.Parameters = append(endpoint.Parameters, models.EndpointParameter{
+					Name: name, In: "header", Schema: &models.Schema{Type: "string", Example: step.Inputs.Headers[name]},
+				})
+			}
+
+			if len(step.Inputs.Body) > 0 {
+				if m == http.MethodGet || m == http.MethodDelete {
+					// The runner sends the body of these methods as query parameters.
+					for _, name := range slices.Sorted(maps.Keys(step.Inputs.Body)) {
+						value := step.Inputs.Body[name]
+						parameter := models.EndpointParameter{Name: name, In: "query", Schema: schema.Infer(value)}
+						parameter.Schema.Example = value
+						endpoint.Parameters = append(endpoint.Parameters, parameter)
+					}
+				} else {
+					endpoint.Request = schema.Infer(step.Inputs.Body)
+					endpoint.Request.Example = step.Inputs.Body
+				}
+			}
+
+			out = append(out, call{Endpoint: endpoint, Job: job.Name})
+		}
+	}
+
+	return out
+}
+
+/*
+Endpoints returns the endpoints exports describe: the config's own, followed by
+those derived from its HTTP steps, for generators that build on the same view.
+*/
+func Endpoints(config *models.APIConfig) []models.Endpoint {
+	var out []models.Endpoint
+	for _, c := range calls(config) {
+		out = append(out, c.Endpoint)
+	}
+	return out
+}
+
+// templatePath turns placeholders in a step's path into OpenAPI style path parameters
+func templatePath(path string) (string, []models.EndpointParameter) {
+	var parameters []models.EndpointParameter
+	seen := map[string]bool{}
+
+	path = placeholder.ReplaceAllStringFunc(path, func(match string) string {
+		segments := strings.FieldsFunc(placeholder.FindStringSubmatch(match)[1], func(r rune) bool {
+			return r == '.' || r == '[' || r == ']'
+		})
+
+		name := identifier(segments[len(segments)-1])
+		if seen[name] {
+			return "{" + name + "}"
+		}
+		seen[name] = true
+
+		parameters = append(parameters, models.EndpointParameter{
+			Name:        name,
+			In:          "path",
+			Required:    true,
+			Description: "Resolved from " + strings.TrimSpace(match),
+			Schema:      &models.Schema{Type: "string"},
+		})
+
+		return "{" + name + "}"
+	})
+
+	return path, parameters
+}
+
+// pathParameters returns the names of the {name} parameters in an OpenAPI style path
+func pathParameters(path string) []string {
+	var names []string
+	for _, match := range pathParameter.FindAllStringSubmatch(path, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+func method(m string) string {
+	if m == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(m)
+}
+
+func identifier(s string) string {
+	return strings.Trim(nonIdentifier.ReplaceAllString(s, "_"), "_")
+}
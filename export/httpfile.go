@@ -0,0 +1,414 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// httpVariable writes placeholders as .http file variables, environment variables by their bare name
+func httpVariable(path string) string {
+	if strings.Contains(path, ".response.") {
+		// A request variable of the token or login request.
+		return "{{" + path + "}}"
+	}
+	if name, ok := strings.CutPrefix(path, "env."); ok {
+		return "{{" + name + "}}"
+	}
+	return "{{" + identifier(path) + "}}"
+}
+
+/*
+ToHTTPFile converts an APIConfig into a .http file, as used by the REST Client
+extension of VS Code and by JetBrains IDEs. Environment variables are read from
+the process environment, and OAuth2 and session logins are requests of their own
+whose response the other requests take their token from.
+*/
+func ToHTTPFile(config *models.APIConfig) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", config.Integration)
+	fmt.Fprintf(&b, "@baseUrl = %s\n", strings.TrimRight(config.BaseURL, "/"))
+	for _, name := range envVars(config) {
+		fmt.Fprintf(&b, "@%s = {{$processEnv %s}}\n", name, name)
+	}
+
+	headers, query := httpAuth(&b, config)
+
+	for _, r := range requests(config) {
+		fmt.Fprintf(&b, "\n### %s\n", r.name)
+		if r.description != "" {
+			fmt.Fprintf(&b, "# %s\n", r.description)
+		}
+
+		path := r.path
+		for _, p := range r.pathParams {
+			value := rewrite(p.value, httpVariable)
+			if value == "" {
+				value = "{{" + p.name + "}}"
+			}
+			path = strings.ReplaceAll(path, "{"+p.name+"}", value)
+		}
+
+		fmt.Fprintf(&b, "%s {{baseUrl}}%s%s\n", r.method, rewrite(path, httpVariable), queryString(append(r.query, query...), httpVariable))
+
+		for _, header := range append(append([]param{{"Accept", "application/json"}}, headers...), r.headers...) {
+			fmt.Fprintf(&b, "%s: %s\n", header.name, rewrite(header.value, httpVariable))
+		}
+
+		if r.body != nil {
+			data, _ := json.MarshalIndent(rewriteValue(r.body, httpVariable), "", "  ")
+			fmt.Fprintf(&b, "\n%s\n", data)
+		}
+	}
+
+	return b.String()
+}
+
+// httpAuth writes the login request of the config's auth, if it has one, and returns the credentials every request sends
+func httpAuth(b *strings.Builder, config *models.APIConfig) ([]param, []param) {
+	a := redactAuth(config.Auth)
+	value := func(s string) string { return rewrite(s, httpVariable) }
+
+	switch auth.Type(a) {
+	case models.AuthAPIKey:
+		if a.APIKey != nil {
+			return place(a.APIKey.In, a.APIKey.Name, a.APIKey.Prefix+a.APIKey.Value)
+		}
+
+	case models.AuthBasic:
+		if a.Basic != nil {
+			return []param{{"Authorization", "Basic " + a.Basic.Username + " " + a.Basic.Password}}, nil
+		}
+
+	case models.AuthBearer:
+		if a.Bearer != nil {
+			return []param{{"Authorization", "Bearer " + a.Bearer.Token}}, nil
+		}
+
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		if a.OAuth2 == nil {
+			break
+		}
+
+		form := oauth2Form(a)
+		fmt.Fprintf(b, "\n### token\n# @name token\nPOST %s\nContent-Type: application/x-www-form-urlencoded\n", value(a.OAuth2.TokenURL))
+		if a.OAuth2.ClientAuth != "body" {
+			fmt.Fprintf(b, "Authorization: Basic %s %s\n", value(a.OAuth2.ClientID), value(a.OAuth2.ClientSecret))
+		}
+		fmt.Fprintf(b, "\n%s\n", rewrite(formString(form), httpVariable))
+
+		return []param{{"Authorization", "Bearer {{token.response.body.$.access_token}}"}}, nil
+
+	case models.AuthSession:
+		login := method(a.Method)
+		if a.Method == "" {
+			login = http.MethodPost
+		}
+
+		endpoint := value(a.Endpoint)
+		if u, err := url.Parse(a.Endpoint); err != nil || !u.IsAbs() {
+			endpoint = "{{baseUrl}}/" + strings.TrimLeft(endpoint, "/")
+		}
+
+		fmt.Fprintf(b, "\n### login\n# @name login\n%s %s\n", login, endpoint)
+		if len(a.Inputs) > 0 {
+			input := a.Inputs[0]
+			for _, key := range slices.Sorted(maps.Keys(input.Headers)) {
+				fmt.Fprintf(b, "%s: %s\n", key, value(input.Headers[key]))
+			}
+			if len(input.Body) > 0 {
+				if _, ok := input.Headers["Content-Type"]; !ok {
+					fmt.Fprintf(b, "Content-Type: application/json\n")
+				}
+				data, _ := json.MarshalIndent(rewriteValue(input.Body, httpVariable), "", "  ")
+				fmt.Fprintf(b, "\n%s\n", data)
+			}
+		}
+
+		if a.Session == nil || a.Session.TokenPath == "" {
+			// The login's cookies are sent along with the other requests.
+			return nil, nil
+		}
+
+		name, prefix := sessionPlacement(a.Session)
+		path := a.Session.TokenPath
+		if !strings.HasPrefix(path, "$") {
+			path = "$." + path
+		}
+		return place(a.Session.In, name, prefix+"{{login.response.body."+path+"}}")
+	}
+
+	return nil, nil
+}
+
+/*
+ToCurl converts an APIConfig into a shell script with a curl command for every
+request. Environment variables and values from earlier steps are read from shell
+variables, and tokens are obtained up front with curl and jq.
+*/
+func ToCurl(config *models.APIConfig) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "#!/bin/sh\n# %s\nset -e\n\n", config.Integration)
+	fmt.Fprintf(&b, "BASE_URL=%s\n", shellQuote(strings.TrimRight(config.BaseURL, "/")))
+	if names := envVars(config); len(names) > 0 {
+		fmt.Fprintf(&b, "# Requires %s in the environment\n", strings.Join(names, ", "))
+	}
+
+	headers, query := curlAuth(&b, config)
+
+	for _, r := range requests(config) {
+		fmt.Fprintf(&b, "\n# %s\n", r.name)
+		if r.description != "" {
+			fmt.Fprintf(&b, "# %s\n", r.description)
+		}
+
+		path := r.path
+		for _, p := range r.pathParams {
+			value := p.value
+			if value == "" {
+				value = "{{" + p.name + "}}"
+			}
+			path = strings.ReplaceAll(path, "{"+p.name+"}", value)
+		}
+
+		target := "{{BASE_URL}}" + path + queryString(append(r.query, query...), func(path string) string { return "{{" + path + "}}" })
+		fmt.Fprintf(&b, "curl -sS -X %s %s", r.method, shellString(target))
+
+		for _, header := range append(append([]param{{"Accept", "application/json"}}, headers...), r.headers...) {
+			fmt.Fprintf(&b, " \\\n  -H %s", shellString(header.name+": "+header.value))
+		}
+
+		if r.body != nil {
+			data, _ := json.Marshal(r.body)
+			fmt.Fprintf(&b, " \\\n  --data %s", shellString(string(data)))
+		}
+		if auth.Type(config.Auth) == models.AuthSession {
+			b.WriteString(" \\\n  -b cookies.txt")
+		}
+
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// curlAuth writes the commands that obtain the config's credentials, and returns the credentials every request sends
+func curlAuth(b *strings.Builder, config *models.APIConfig) ([]param, []param) {
+	a := redactAuth(config.Auth)
+
+	switch auth.Type(a) {
+	case models.AuthAPIKey:
+		if a.APIKey != nil {
+			return place(a.APIKey.In, a.APIKey.Name, a.APIKey.Prefix+a.APIKey.Value)
+		}
+
+	case models.AuthBasic:
+		if a.Basic != nil {
+			fmt.Fprintf(b, "BASIC=$(printf '%%s:%%s' %s %s | base64)\n", shellString(a.Basic.Username), shellString(a.Basic.Password))
+			return []param{{"Authorization", "Basic {{BASIC}}"}}, nil
+		}
+
+	case models.AuthBearer:
+		if a.Bearer != nil {
+			return []param{{"Authorization", "Bearer " + a.Bearer.Token}}, nil
+		}
+
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		if a.OAuth2 == nil {
+			break
+		}
+
+		fmt.Fprintf(b, "\nACCESS_TOKEN=$(curl -sS -X POST %s", shellString(a.OAuth2.TokenURL))
+		if a.OAuth2.ClientAuth != "body" {
+			fmt.Fprintf(b, " \\\n  -u %s", shellString(a.OAuth2.ClientID+":"+a.OAuth2.ClientSecret))
+		}
+		fmt.Fprintf(b, " \\\n  --data %s | jq -r .access_token)\n", shellString(formString(oauth2Form(a))))
+
+		return []param{{"Authorization", "Bearer {{ACCESS_TOKEN}}"}}, nil
+
+	case models.AuthSession:
+		login := method(a.Method)
+		if a.Method == "" {
+			login = http.MethodPost
+		}
+
+		endpoint := a.Endpoint
+		if u, err := url.Parse(a.Endpoint); err != nil || !u.IsAbs() {
+			endpoint = "{{BASE_URL}}/" + strings.TrimLeft(endpoint, "/")
+		}
+
+		fmt.Fprintf(b, "\nLOGIN=$(curl -sS -X %s %s -c cookies.txt", login, shellString(endpoint))
+		if len(a.Inputs) > 0 {
+			input := a.Inputs[0]
+			for _, key := range slices.Sorted(maps.Keys(input.Headers)) {
+				fmt.Fprintf(b, " \\\n  -H %s", shellString(key+": "+input.Headers[key]))
+			}
+			if len(input.Body) > 0 {
+				if _, ok := input.Headers["Content-Type"]; !ok {
+					fmt.Fprintf(b, " \\\n  -H 'Content-Type: application/json'")
+				}
+				data, _ := json.Marshal(input.Body)
+				fmt.Fprintf(b, " \\\n  --data %s", shellString(string(data)))
+			}
+		}
+		b.WriteString(")\n")
+
+		if a.Session == nil || a.Session.TokenPath == "" {
+			// The requests send the cookies the login left in cookies.txt.
+			return nil, nil
+		}
+
+		fmt.Fprintf(b, "SESSION_TOKEN=$(echo \"$LOGIN\" | jq -r %s)\n", shellQuote(jqPath(a.Session.TokenPath)))
+		name, prefix := sessionPlacement(a.Session)
+		return place(a.Session.In, name, prefix+"{{SESSION_TOKEN}}")
+	}
+
+	return nil, nil
+}
+
+// place returns a credential as a header or query parameter, the way the auth package sends it
+func place(in, name, value string) ([]param, []param) {
+	switch in {
+	case "query":
+		return nil, []param{{name, value}}
+	case "cookie":
+		return []param{{"Cookie", name + "=" + value}}, nil
+	}
+	return []param{{name, value}}, nil
+}
+
+// sessionPlacement returns the header and prefix a session token is sent with, with the auth package's defaults
+func sessionPlacement(session *models.SessionAuth) (string, string) {
+	name, prefix := session.Name, session.Prefix
+	if name == "" {
+		name = "Authorization"
+	}
+	if prefix == "" && strings.EqualFold(name, "Authorization") {
+		prefix = "Bearer "
+	}
+	return name, prefix
+}
+
+func oauth2Form(a models.Auth) []param {
+	form := []param{{"grant_type", "client_credentials"}}
+	if auth.Type(a) == models.AuthOAuth2AuthorizationCode {
+		form = []param{{"grant_type", "authorization_code"}, {"code", a.OAuth2.Code}}
+		if a.OAuth2.RedirectURL != "" {
+			form = append(form, param{"redirect_uri", a.OAuth2.RedirectURL})
+		}
+	}
+	if a.OAuth2.ClientAuth == "body" {
+		form = append(form, param{"client_id", a.OAuth2.ClientID}, param{"client_secret", a.OAuth2.ClientSecret})
+	}
+	if len(a.OAuth2.Scopes) > 0 {
+		form = append(form, param{"scope", strings.Join(a.OAuth2.Scopes, " ")})
+	}
+	if a.OAuth2.Audience != "" {
+		form = append(form, param{"audience", a.OAuth2.Audience})
+	}
+	return form
+}
+
+// formString joins parameters into a form encoded string, leaving placeholders unescaped
+func formString(params []param) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.name + "=" + escapeLiterals(p.value, url.QueryEscape)
+	}
+	return strings.Join(parts, "&")
+}
+
+// queryString writes query parameters, rewriting placeholders with fn and escaping everything else
+func queryString(params []param, fn func(path string) string) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = url.QueryEscape(p.name) + "=" + rewrite(escapeLiterals(p.value, url.QueryEscape), fn)
+	}
+	return "?" + strings.Join(parts, "&")
+}
+
+// escapeLiterals escapes the text around placeholders, leaving the placeholders themselves intact
+func escapeLiterals(s string, escape func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range placeholder.FindAllStringIndex(s, -1) {
+		b.WriteString(escape(s[last:loc[0]]))
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(escape(s[last:]))
+	return b.String()
+}
+
+/*
+shellString quotes a value for the shell in double quotes, so placeholders can
+become variable references while everything else is taken literally. Environment
+variables are referenced by their name, other placeholders by an upper case form
+of their path.
+*/
+func shellString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(escape(s[last:loc[0]]))
+
+		path := s[loc[2]:loc[3]]
+		name, ok := strings.CutPrefix(path, "env.")
+		if !ok {
+			name = strings.ToUpper(identifier(path))
+			if path == identifier(path) && strings.ToLower(path) == path {
+				// Path parameters keep their name, so they read naturally.
+				name = path
+			}
+		}
+		b.WriteString("${" + name + "}")
+
+		last = loc[1]
+	}
+	b.WriteString(escape(s[last:]))
+
+	b.WriteByte('"')
+	return b.String()
+}
+
+// shellQuote quotes a value for the shell in single quotes, taking it literally
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// jqPath turns a JSONPath into a jq filter, e.g. .["data"]["token"]
+func jqPath(path string) string {
+	segments, err := utils.ParsePath(path)
+	if err != nil || len(segments) == 0 {
+		return "."
+	}
+
+	var b strings.Builder
+	b.WriteString(".")
+	for _, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			fmt.Fprintf(&b, "[%s]", segment)
+		} else {
+			fmt.Fprintf(&b, "[%q]", segment)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,305 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// openAPIDocument is how FromOpenAPI reads documents, leaving path items raw since they mix operations and other fields
+type openAPIDocument struct {
+	OpenAPI
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+/*
+FromOpenAPI converts an OpenAPI 3.x document in JSON into an APIConfig. Documents
+exported by ToOpenAPI come back with their jobs and auth settings intact. For
+other documents the auth is derived from the first security scheme, with secrets
+left as {{env.NAME}} placeholders to fill in.
+*/
+func FromOpenAPI(data []byte) (*models.APIConfig, error) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing OpenAPI document: %w", err)
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, only 3.x documents can be imported", doc.OpenAPI.OpenAPI)
+	}
+
+	config := &models.APIConfig{
+		Integration: doc.Info.Title,
+		AccountID:   doc.AccountID,
+		Jobs:        doc.Jobs,
+	}
+
+	if len(doc.Servers) > 0 {
+		config.BaseURL = doc.Servers[0].URL
+	}
+
+	if doc.Auth != nil {
+		config.Auth = *doc.Auth
+	} else {
+		config.Auth = authFromSchemes(doc.Components.SecuritySchemes)
+	}
+
+	if len(doc.Components.Schemas) > 0 {
+		config.Schemas = map[string]*models.Schema{}
+		for name, s := range doc.Components.Schemas {
+			config.Schemas[name] = fromJSONSchema(s)
+		}
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := doc.Paths[path]
+
+		var shared []OpenAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("error parsing parameters of %s: %w", path, err)
+			}
+		}
+
+		for _, m := range methods {
+			raw, ok := item[strings.ToLower(m)]
+			if !ok {
+				continue
+			}
+
+			var op Operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("error parsing %s %s: %w", m, path, err)
+			}
+
+			if op.FromStep && len(config.Jobs) > 0 {
+				// Exported from a step, which the jobs already describe.
+				continue
+			}
+
+			config.Endpoints = append(config.Endpoints, endpoint(m, path, shared, op))
+		}
+	}
+
+	return config, nil
+}
+
+func endpoint(m, path string, shared []OpenAPIParameter, op Operation) models.Endpoint {
+	endpoint := models.Endpoint{
+		Name:        op.OperationID,
+		Method:      m,
+		Path:        path,
+		Description: op.Description,
+		Pagination:  op.Pagination,
+	}
+
+	if endpoint.Name == "" {
+		endpoint.Name = identifier(strings.ToLower(m) + "_" + path)
+	}
+	if endpoint.Description == "" {
+		endpoint.Description = op.Summary
+	}
+
+	// Operation parameters override the path item's ones with the same name and location.
+	parameters := slices.Clone(op.Parameters)
+	for _, parameter := range shared {
+		if !slices.ContainsFunc(parameters, func(p OpenAPIParameter) bool { return p.Name == parameter.Name && p.In == parameter.In }) {
+			parameters = append(parameters, parameter)
+		}
+	}
+
+	for _, parameter := range parameters {
+		if parameter.Name == "" || parameter.In == "cookie" {
+			// Unresolved $refs and cookies have no place in an endpoint.
+			continue
+		}
+		endpoint.Parameters = append(endpoint.Parameters, models.EndpointParameter{
+			Name:        parameter.Name,
+			In:          parameter.In,
+			Required:    parameter.Required,
+			Description: parameter.Description,
+			Schema:      fromJSONSchema(parameter.Schema),
+		})
+	}
+
+	if op.RequestBody != nil {
+		endpoint.Request = contentSchema(op.RequestBody.Content)
+	}
+
+	if response, ok := successStatus(op.Responses); ok {
+		endpoint.Response = contentSchema(response.Content)
+	}
+
+	return endpoint
+}
+
+// contentSchema picks the schema of the JSON content, or of the first content type otherwise
+func contentSchema(content map[string]MediaType) *models.Schema {
+	types := make([]string, 0, len(content))
+	for contentType := range content {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+
+	for _, contentType := range types {
+		if strings.Contains(contentType, "json") {
+			return fromJSONSchema(content[contentType].Schema)
+		}
+	}
+
+	if len(types) > 0 {
+		return fromJSONSchema(content[types[0]].Schema)
+	}
+
+	return nil
+}
+
+/*
+fromJSONSchema converts a decoded JSON Schema into a Schema. Both the 3.1 style of
+a null entry in the type and the 3.0 nullable keyword are understood, and allOf
+compositions are flattened, since Schema has no composition of its own.
+*/
+func fromJSONSchema(value any) *models.Schema {
+	raw, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	s := &models.Schema{}
+
+	if ref, ok := raw["$ref"].(string); ok {
+		s.Ref = models.SchemaRefPrefix + models.RefName(ref)
+		return s
+	}
+
+	switch t := raw["type"].(type) {
+	case string:
+		s.Type = t
+	case []any:
+		for _, entry := range t {
+			if entry == "null" {
+				s.Nullable = true
+			} else if name, ok := entry.(string); ok && s.Type == "" {
+				s.Type = name
+			}
+		}
+	}
+
+	if nullable, ok := raw["nullable"].(bool); ok && nullable {
+		s.Nullable = true
+	}
+
+	s.Format, _ = raw["format"].(string)
+	s.Description, _ = raw["description"].(string)
+	s.Enum, _ = raw["enum"].([]any)
+	s.Example = raw["example"]
+	if examples, ok := raw["examples"].([]any); ok && len(examples) > 0 {
+		s.Example = examples[0]
+	}
+
+	if properties, ok := raw["properties"].(map[string]any); ok {
+		s.Properties = map[string]*models.Schema{}
+		for name, property := range properties {
+			s.Properties[name] = fromJSONSchema(property)
+		}
+	}
+
+	if required, ok := raw["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+
+	s.Items = fromJSONSchema(raw["items"])
+
+	if all, ok := raw["allOf"].([]any); ok {
+		for _, part := range all {
+			flatten(s, fromJSONSchema(part))
+		}
+	}
+
+	return s
+}
+
+// flatten folds one part of an allOf into the schema
+func flatten(s, part *models.Schema) {
+	if part == nil {
+		return
+	}
+
+	if part.Ref != "" && s.Type == "" && len(s.Properties) == 0 && s.Ref == "" {
+		s.Ref = part.Ref
+		return
+	}
+
+	if s.Type == "" {
+		s.Type = part.Type
+	}
+	for name, property := range part.Properties {
+		if s.Properties == nil {
+			s.Properties = map[string]*models.Schema{}
+		}
+		s.Properties[name] = property
+	}
+	s.Required = append(s.Required, part.Required...)
+}
+
+// authFromSchemes derives the auth block from the first security scheme of a foreign document
+func authFromSchemes(schemes map[string]SecurityScheme) models.Auth {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return models.Auth{Type: models.AuthNone}
+	}
+
+	scheme := schemes[names[0]]
+	env := "{{env." + strings.ToUpper(identifier(names[0]))
+
+	switch {
+	case scheme.Type == "apiKey":
+		return models.Auth{Type: models.AuthAPIKey, APIKey: &models.APIKeyAuth{In: scheme.In, Name: scheme.Name, Value: env + "}}"}}
+
+	case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic"):
+		return models.Auth{Type: models.AuthBasic, Basic: &models.BasicAuth{Username: env + "_USERNAME}}", Password: env + "_PASSWORD}}"}}
+
+	case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"):
+		return models.Auth{Type: models.AuthBearer, Bearer: &models.BearerAuth{Token: env + "_TOKEN}}"}}
+
+	case scheme.Type == "oauth2" && scheme.Flows != nil:
+		settings := &models.OAuth2Auth{ClientID: env + "_CLIENT_ID}}", ClientSecret: env + "_CLIENT_SECRET}}"}
+		flow, authType := scheme.Flows.ClientCredentials, models.AuthOAuth2ClientCredentials
+		if flow == nil {
+			flow, authType = scheme.Flows.AuthorizationCode, models.AuthOAuth2AuthorizationCode
+			settings.Code = env + "_CODE}}"
+		}
+		if flow == nil {
+			break
+		}
+
+		settings.TokenURL, settings.AuthorizationURL = flow.TokenURL, flow.AuthorizationURL
+		for scope := range flow.Scopes {
+			settings.Scopes = append(settings.Scopes, scope)
+		}
+		sort.Strings(settings.Scopes)
+
+		return models.Auth{Type: authType, OAuth2: settings}
+	}
+
+	return models.Auth{Type: models.AuthNone}
+}
@@ -0,0 +1,357 @@
+package export
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification documents are exported as
+const OpenAPIVersion = "3.1.0"
+
+/*
+OpenAPI is an OpenAPI 3.1 document, limited to what an APIConfig can describe.
+What OpenAPI has no place for, like the jobs and the exact auth settings, travels
+along in x-milkshake extensions, so importing an export gives back the config.
+Literal credentials are redacted, only {{env.NAME}} placeholders are kept.
+*/
+type OpenAPI struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       OpenAPIInfo           `json:"info"`
+	Servers    []OpenAPIServer       `json:"servers,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components OpenAPIComponents     `json:"components,omitzero"`
+	Security   []map[string][]string `json:"security,omitempty"`
+	AccountID  string                `json:"x-milkshake-account-id,omitempty"`
+	Auth       *models.Auth          `json:"x-milkshake-auth,omitempty"`
+	Jobs       []models.Job          `json:"x-milkshake-jobs,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// PathItem holds the operations on a path, keyed by lower case HTTP method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  []OpenAPIParameter  `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	Pagination  *models.Pagination  `json:"x-milkshake-pagination,omitempty"`
+	FromStep    bool                `json:"x-milkshake-step,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+	Schema      any    `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema  any `json:"schema,omitempty"`
+	Example any `json:"example,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]any            `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string      `json:"type"`
+	Description  string      `json:"description,omitempty"`
+	Scheme       string      `json:"scheme,omitempty"`
+	BearerFormat string      `json:"bearerFormat,omitempty"`
+	In           string      `json:"in,omitempty"`
+	Name         string      `json:"name,omitempty"`
+	Flows        *OAuthFlows `json:"flows,omitempty"`
+}
+
+type OAuthFlows struct {
+	ClientCredentials *OAuthFlow `json:"clientCredentials,omitempty"`
+	AuthorizationCode *OAuthFlow `json:"authorizationCode,omitempty"`
+}
+
+type OAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl"`
+	RefreshURL       string            `json:"refreshUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+// ToOpenAPI converts an APIConfig into an OpenAPI 3.1 document
+func ToOpenAPI(config *models.APIConfig) *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI:   OpenAPIVersion,
+		Info:      OpenAPIInfo{Title: config.Integration, Version: "1.0.0"},
+		Paths:     map[string]PathItem{},
+		AccountID: config.AccountID,
+		Jobs:      redactJobs(config.Jobs),
+	}
+
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+
+	if config.BaseURL != "" {
+		doc.Servers = []OpenAPIServer{{URL: config.BaseURL}}
+	}
+
+	if len(config.Schemas) > 0 {
+		doc.Components.Schemas = map[string]any{}
+		for name, s := range config.Schemas {
+			doc.Components.Schemas[name] = toJSONSchema(s)
+		}
+	}
+
+	if name, scheme, scopes, ok := securityScheme(config.Auth); ok {
+		auth := redactAuth(config.Auth)
+		doc.Auth = &auth
+		doc.Components.SecuritySchemes = map[string]SecurityScheme{name: scheme}
+		doc.Security = []map[string][]string{{name: scopes}}
+	}
+
+	for _, c := range calls(config) {
+		path := "/" + strings.TrimLeft(pathOnly(c.Path), "/")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+
+		op := operation(c.Endpoint)
+		op.FromStep = c.Job != ""
+		doc.Paths[path][strings.ToLower(method(c.Method))] = op
+	}
+
+	return doc
+}
+
+func operation(endpoint models.Endpoint) *Operation {
+	op := &Operation{
+		OperationID: endpoint.Name,
+		Description: endpoint.Description,
+		Pagination:  endpoint.Pagination,
+		Responses:   map[string]Response{"200": {Description: "Successful response"}},
+	}
+
+	declared := map[string]bool{}
+	for _, parameter := range endpoint.Parameters {
+		declared[parameter.Name] = true
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name:        parameter.Name,
+			In:          parameter.In,
+			Required:    parameter.Required || parameter.In == "path",
+			Description: parameter.Description,
+			Schema:      toJSONSchema(redactExample(parameter)),
+		})
+	}
+
+	// OpenAPI requires every path parameter to be declared.
+	for _, name := range pathParameters(endpoint.Path) {
+		if !declared[name] {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{Name: name, In: "path", Required: true, Schema: map[string]any{"type": "string"}})
+		}
+	}
+
+	if endpoint.Request != nil {
+		request := *endpoint.Request
+		if body, ok := request.Example.(map[string]any); ok {
+			request.Example = redactFields(body)
+		}
+
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: toJSONSchema(&request)}},
+		}
+	}
+
+	if endpoint.Response != nil {
+		op.Responses["200"] = Response{
+			Description: "Successful response",
+			Content:     map[string]MediaType{"application/json": {Schema: toJSONSchema(endpoint.Response)}},
+		}
+	}
+
+	return op
+}
+
+/*
+toJSONSchema converts a schema to the JSON Schema dialect of OpenAPI 3.1. References
+point into the components, and nullable becomes a null entry in the type.
+*/
+func toJSONSchema(s *models.Schema) any {
+	if s == nil {
+		return nil
+	}
+
+	out := map[string]any{}
+
+	if s.Ref != "" {
+		out["$ref"] = "#/components/schemas/" + models.RefName(s.Ref)
+		return out
+	}
+
+	switch {
+	case s.Type != "" && s.Nullable:
+		out["type"] = []string{s.Type, "null"}
+	case s.Type != "":
+		out["type"] = s.Type
+	}
+
+	if s.Format != "" {
+		out["format"] = s.Format
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if len(s.Properties) > 0 {
+		properties := map[string]any{}
+		for name, property := range s.Properties {
+			properties[name] = toJSONSchema(property)
+		}
+		out["properties"] = properties
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	if s.Items != nil {
+		out["items"] = toJSONSchema(s.Items)
+	}
+	if s.Example != nil {
+		out["examples"] = []any{s.Example}
+	}
+
+	return out
+}
+
+/*
+securityScheme describes the config's auth as an OpenAPI security scheme, named
+after the auth type. Session logins have no OpenAPI equivalent, so they are
+described by how the obtained token or cookie is sent.
+*/
+func securityScheme(config models.Auth) (string, SecurityScheme, []string, bool) {
+	authType := auth.Type(config)
+
+	switch authType {
+	case models.AuthAPIKey:
+		scheme := SecurityScheme{Type: "apiKey"}
+		if config.APIKey != nil {
+			scheme.In, scheme.Name = config.APIKey.In, config.APIKey.Name
+		}
+		if scheme.In == "" {
+			scheme.In = "header"
+		}
+		return authType, scheme, []string{}, true
+
+	case models.AuthBasic:
+		return authType, SecurityScheme{Type: "http", Scheme: "basic"}, []string{}, true
+
+	case models.AuthBearer:
+		return authType, SecurityScheme{Type: "http", Scheme: "bearer"}, []string{}, true
+
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		var settings models.OAuth2Auth
+		if config.OAuth2 != nil {
+			settings = *config.OAuth2
+		}
+
+		scopes := map[string]string{}
+		for _, scope := range settings.Scopes {
+			scopes[scope] = ""
+		}
+
+		flow := &OAuthFlow{TokenURL: settings.TokenURL, RefreshURL: settings.TokenURL, Scopes: scopes}
+		flows := &OAuthFlows{ClientCredentials: flow}
+		if authType == models.AuthOAuth2AuthorizationCode {
+			flow.AuthorizationURL = settings.AuthorizationURL
+			flows = &OAuthFlows{AuthorizationCode: flow}
+		}
+
+		return authType, SecurityScheme{Type: "oauth2", Flows: flows}, slices.Clone(settings.Scopes), true
+
+	case models.AuthSession:
+		scheme := SecurityScheme{
+			Type:        "apiKey",
+			In:          "cookie",
+			Name:        "session",
+			Description: "Obtained by logging in at " + config.Endpoint,
+		}
+
+		if config.Session != nil && config.Session.TokenPath != "" {
+			scheme.In, scheme.Name = config.Session.In, config.Session.Name
+			if scheme.In == "" {
+				scheme.In = "header"
+			}
+			if scheme.Name == "" {
+				scheme.Name = "Authorization"
+			}
+		}
+
+		return authType, scheme, []string{}, true
+	}
+
+	return "", SecurityScheme{}, nil, false
+}
+
+// pathOnly strips the scheme and host from endpoints that were given as full URLs
+func pathOnly(path string) string {
+	if u, err := url.Parse(path); err == nil && u.IsAbs() {
+		return u.Path
+	}
+	if before, _, ok := strings.Cut(path, "?"); ok {
+		return before
+	}
+	return path
+}
+
+// successStatus picks the response describing success, preferring the lowest 2xx code
+func successStatus(responses map[string]Response) (Response, bool) {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+
+	if len(codes) > 0 {
+		return responses[codes[0]], true
+	}
+
+	response, ok := responses["default"]
+	return response, ok
+}
+
+// methods are the HTTP methods OpenAPI path items can hold operations for
+var methods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+}
@@ -0,0 +1,50 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func TestToOpenAPIRedactsSecrets(t *testing.T) {
+	config := &models.APIConfig{
+		BaseURL: "https://api.example.com",
+		Auth: models.Auth{
+			Type:   models.AuthOAuth2ClientCredentials,
+			OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/token", ClientID: "client", ClientSecret: "s3cret-client", RefreshToken: "{{env.REFRESH_TOKEN}}"},
+			Inputs: []models.Input{{Headers: models.Headers{"X-Api-Key": "s3cret-key"}, Body: map[string]any{"username": "ada", "password": "s3cret-password"}}},
+		},
+		Jobs: []models.Job{{Name: "sync", Steps: []models.Step{
+			{
+				Type: "http_request", Name: "list", Endpoint: "/employees",
+				Inputs: models.Input{Headers: models.Headers{"Authorization": "Bearer s3cret-token", "X-Token": "Bearer {{env.TOKEN}}", "Accept": "application/json"}},
+			},
+			{
+				Type: "http_request", Name: "login", Endpoint: "/login", Method: "POST",
+				Inputs: models.Input{Body: map[string]any{"user": "ada", "api_key": "s3cret-body"}},
+			},
+		}}},
+	}
+
+	data, err := json.Marshal(ToOpenAPI(config))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	doc := string(data)
+
+	if strings.Contains(doc, "s3cret") {
+		t.Errorf("export holds a literal secret: %s", doc)
+	}
+
+	for _, kept := range []string{`"client_id":"client"`, "{{env.REFRESH_TOKEN}}", "Bearer {{env.TOKEN}}", "application/json", `"username":"ada"`, `"user":"ada"`} {
+		if !strings.Contains(doc, kept) {
+			t.Errorf("export lost %s: %s", kept, doc)
+		}
+	}
+
+	if config.Auth.OAuth2.ClientSecret != "s3cret-client" || config.Jobs[0].Steps[0].Inputs.Headers["Authorization"] != "Bearer s3cret-token" {
+		t.Error("redacting the export changed the config")
+	}
+}
@@ -0,0 +1,327 @@
+package export
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// PostmanSchema identifies collections in the Postman v2.1 format
+const PostmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+/*
+PostmanCollection is a Postman v2.1 collection. Requests are grouped in a folder
+for the config's endpoints and a folder per job, the base URL and environment
+variables become collection variables, and the auth is set on the collection.
+*/
+type PostmanCollection struct {
+	Info     PostmanInfo       `json:"info"`
+	Auth     *PostmanAuth      `json:"auth,omitempty"`
+	Variable []PostmanVariable `json:"variable,omitempty"`
+	Item     []PostmanItem     `json:"item"`
+}
+
+type PostmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+type PostmanVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+type PostmanItem struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Item        []PostmanItem   `json:"item,omitempty"`
+	Request     *PostmanRequest `json:"request,omitempty"`
+	Event       []PostmanEvent  `json:"event,omitempty"`
+}
+
+type PostmanRequest struct {
+	Method string            `json:"method"`
+	Header []PostmanKeyValue `json:"header"`
+	URL    PostmanURL        `json:"url"`
+	Body   *PostmanBody      `json:"body,omitempty"`
+}
+
+type PostmanKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+type PostmanURL struct {
+	Raw      string            `json:"raw"`
+	Host     []string          `json:"host,omitempty"`
+	Path     []string          `json:"path,omitempty"`
+	Query    []PostmanKeyValue `json:"query,omitempty"`
+	Variable []PostmanKeyValue `json:"variable,omitempty"`
+}
+
+type PostmanBody struct {
+	Mode    string         `json:"mode"`
+	Raw     string         `json:"raw"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+type PostmanAuth struct {
+	Type   string            `json:"type"`
+	APIKey []PostmanKeyValue `json:"apikey,omitempty"`
+	Basic  []PostmanKeyValue `json:"basic,omitempty"`
+	Bearer []PostmanKeyValue `json:"bearer,omitempty"`
+	OAuth2 []PostmanKeyValue `json:"oauth2,omitempty"`
+}
+
+type PostmanEvent struct {
+	Listen string        `json:"listen"`
+	Script PostmanScript `json:"script"`
+}
+
+type PostmanScript struct {
+	Type string   `json:"type"`
+	Exec []string `json:"exec"`
+}
+
+// postmanVariable writes placeholders as Postman variables, environment variables by their bare name
+func postmanVariable(path string) string {
+	if name, ok := strings.CutPrefix(path, "env."); ok {
+		return "{{" + name + "}}"
+	}
+	return "{{" + path + "}}"
+}
+
+// ToPostman converts an APIConfig into a Postman v2.1 collection
+func ToPostman(config *models.APIConfig) *PostmanCollection {
+	collection := &PostmanCollection{
+		Info:     PostmanInfo{Name: config.Integration, Schema: PostmanSchema},
+		Variable: []PostmanVariable{{Key: "baseUrl", Value: strings.TrimRight(config.BaseURL, "/"), Type: "string"}},
+	}
+
+	for _, name := range envVars(config) {
+		collection.Variable = append(collection.Variable, PostmanVariable{Key: name, Type: "secret"})
+	}
+
+	collection.Auth, collection.Item = postmanAuth(config)
+
+	folders := map[string]int{}
+	for _, r := range requests(config) {
+		index, ok := folders[r.folder]
+		if !ok {
+			index = len(collection.Item)
+			folders[r.folder] = index
+			collection.Item = append(collection.Item, PostmanItem{Name: r.folder})
+		}
+
+		folder := &collection.Item[index]
+		folder.Item = append(folder.Item, PostmanItem{
+			Name:        r.name,
+			Description: r.description,
+			Request:     postmanRequest(r),
+		})
+	}
+
+	return collection
+}
+
+func postmanRequest(r request) *PostmanRequest {
+	req := &PostmanRequest{Method: r.method, Header: []PostmanKeyValue{}}
+
+	for _, header := range r.headers {
+		req.Header = append(req.Header, PostmanKeyValue{Key: header.name, Value: rewrite(header.value, postmanVariable)})
+	}
+
+	req.URL = postmanURL("{{baseUrl}}", postmanPath(r.path))
+
+	for _, p := range r.pathParams {
+		value := rewrite(p.value, postmanVariable)
+		if value == "" {
+			value = "{{" + p.name + "}}"
+		}
+		req.URL.Variable = append(req.URL.Variable, PostmanKeyValue{Key: p.name, Value: value})
+	}
+
+	for _, q := range r.query {
+		req.URL.Query = append(req.URL.Query, PostmanKeyValue{Key: q.name, Value: rewrite(q.value, postmanVariable)})
+	}
+	if len(req.URL.Query) > 0 {
+		query := make([]string, len(req.URL.Query))
+		for i, q := range req.URL.Query {
+			query[i] = q.Key + "=" + q.Value
+		}
+		req.URL.Raw += "?" + strings.Join(query, "&")
+	}
+
+	if r.body != nil {
+		data, _ := json.MarshalIndent(rewriteValue(r.body, postmanVariable), "", "  ")
+		req.Body = &PostmanBody{
+			Mode:    "raw",
+			Raw:     string(data),
+			Options: map[string]any{"raw": map[string]any{"language": "json"}},
+		}
+	}
+
+	return req
+}
+
+// postmanPath writes path parameters as Postman's :name, segment by segment so placeholders stay intact
+func postmanPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.Contains(segment, "{{") {
+			segments[i] = rewrite(segment, postmanVariable)
+		} else {
+			segments[i] = pathParameter.ReplaceAllString(segment, ":$1")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// postmanURL splits a URL into the parts Postman expects, keeping absolute URLs as they are
+func postmanURL(host, path string) PostmanURL {
+	if u, err := url.Parse(path); err == nil && u.IsAbs() {
+		return PostmanURL{Raw: path}
+	}
+
+	return PostmanURL{
+		Raw:  host + "/" + strings.TrimLeft(path, "/"),
+		Host: []string{host},
+		Path: strings.Split(strings.Trim(path, "/"), "/"),
+	}
+}
+
+/*
+postmanAuth describes the config's auth in Postman's terms. Session logins have no
+Postman equivalent, so they get a login request that stores the token in the
+sessionToken variable for the collection's auth to send, or leaves the cookies it
+receives in Postman's cookie jar.
+*/
+func postmanAuth(config *models.APIConfig) (*PostmanAuth, []PostmanItem) {
+	a := redactAuth(config.Auth)
+	value := func(s string) string { return rewrite(s, postmanVariable) }
+
+	switch auth.Type(a) {
+	case models.AuthAPIKey:
+		if a.APIKey == nil {
+			break
+		}
+		return postmanAPIKey(a.APIKey.In, a.APIKey.Name, a.APIKey.Prefix+value(a.APIKey.Value)), nil
+
+	case models.AuthBasic:
+		if a.Basic == nil {
+			break
+		}
+		return &PostmanAuth{Type: "basic", Basic: []PostmanKeyValue{
+			{Key: "username", Value: value(a.Basic.Username)},
+			{Key: "password", Value: value(a.Basic.Password)},
+		}}, nil
+
+	case models.AuthBearer:
+		if a.Bearer == nil {
+			break
+		}
+		return &PostmanAuth{Type: "bearer", Bearer: []PostmanKeyValue{{Key: "token", Value: value(a.Bearer.Token)}}}, nil
+
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		if a.OAuth2 == nil {
+			break
+		}
+		grant := "client_credentials"
+		if auth.Type(a) == models.AuthOAuth2AuthorizationCode {
+			grant = "authorization_code"
+		}
+		clientAuth := "header"
+		if a.OAuth2.ClientAuth == "body" {
+			clientAuth = "body"
+		}
+		return &PostmanAuth{Type: "oauth2", OAuth2: []PostmanKeyValue{
+			{Key: "grant_type", Value: grant},
+			{Key: "accessTokenUrl", Value: value(a.OAuth2.TokenURL)},
+			{Key: "authUrl", Value: value(a.OAuth2.AuthorizationURL)},
+			{Key: "redirect_uri", Value: value(a.OAuth2.RedirectURL)},
+			{Key: "clientId", Value: value(a.OAuth2.ClientID)},
+			{Key: "clientSecret", Value: value(a.OAuth2.ClientSecret)},
+			{Key: "scope", Value: strings.Join(a.OAuth2.Scopes, " ")},
+			{Key: "client_authentication", Value: clientAuth},
+			{Key: "addTokenTo", Value: "header"},
+		}}, nil
+
+	case models.AuthSession:
+		return postmanSession(config)
+	}
+
+	return &PostmanAuth{Type: "noauth"}, nil
+}
+
+func postmanSession(config *models.APIConfig) (*PostmanAuth, []PostmanItem) {
+	a := redactAuth(config.Auth)
+	login := &PostmanRequest{Method: method(a.Method), Header: []PostmanKeyValue{}}
+	if a.Method == "" {
+		login.Method = http.MethodPost
+	}
+	login.URL = postmanURL("{{baseUrl}}", postmanPath(a.Endpoint))
+
+	if len(a.Inputs) > 0 {
+		input := a.Inputs[0]
+		for _, key := range slices.Sorted(maps.Keys(input.Headers)) {
+			login.Header = append(login.Header, PostmanKeyValue{Key: key, Value: rewrite(input.Headers[key], postmanVariable)})
+		}
+		if len(input.Body) > 0 {
+			data, _ := json.MarshalIndent(rewriteValue(input.Body, postmanVariable), "", "  ")
+			login.Body = &PostmanBody{Mode: "raw", Raw: string(data), Options: map[string]any{"raw": map[string]any{"language": "json"}}}
+		}
+	}
+
+	item := PostmanItem{Name: "Login", Description: "Logs in and keeps the session for the other requests", Request: login}
+	folder := []PostmanItem{{Name: "Auth", Item: []PostmanItem{item}}}
+
+	if a.Session == nil || a.Session.TokenPath == "" {
+		return &PostmanAuth{Type: "noauth"}, folder
+	}
+
+	folder[0].Item[0].Event = []PostmanEvent{{
+		Listen: "test",
+		Script: PostmanScript{Type: "text/javascript", Exec: []string{
+			`pm.collectionVariables.set("sessionToken", ` + tokenExpression("pm.response.json()", a.Session.TokenPath) + `);`,
+		}},
+	}}
+
+	name, prefix := a.Session.Name, a.Session.Prefix
+	if name == "" {
+		name = "Authorization"
+	}
+	if strings.EqualFold(name, "Authorization") && (prefix == "" || strings.EqualFold(strings.TrimSpace(prefix), "Bearer")) {
+		return &PostmanAuth{Type: "bearer", Bearer: []PostmanKeyValue{{Key: "token", Value: "{{sessionToken}}"}}}, folder
+	}
+
+	return postmanAPIKey(a.Session.In, name, prefix+"{{sessionToken}}"), folder
+}
+
+/*
+postmanAPIKey describes a key sent in a header, query parameter or cookie. Postman's
+apikey auth only knows headers and query parameters, so a cookie is sent as a
+Cookie header holding name=value.
+*/
+func postmanAPIKey(in, name, value string) *PostmanAuth {
+	switch in {
+	case "query":
+	case "cookie":
+		in, name, value = "header", "Cookie", name+"="+value
+	default:
+		in = "header"
+	}
+
+	return &PostmanAuth{Type: "apikey", APIKey: []PostmanKeyValue{
+		{Key: "key", Value: name},
+		{Key: "value", Value: value},
+		{Key: "in", Value: in},
+	}}
+}
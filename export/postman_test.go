@@ -0,0 +1,49 @@
+package export
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func TestPostmanAPIKey(t *testing.T) {
+	tests := []struct {
+		name string
+		auth models.Auth
+		want string
+	}{
+		{
+			name: "header",
+			auth: models.Auth{Type: models.AuthAPIKey, APIKey: &models.APIKeyAuth{In: "header", Name: "X-Api-Key", Value: "{{env.API_KEY}}"}},
+			want: "[{key X-Api-Key } {value {{API_KEY}} } {in header }]",
+		},
+		{
+			name: "query",
+			auth: models.Auth{Type: models.AuthAPIKey, APIKey: &models.APIKeyAuth{In: "query", Name: "api_key", Value: "{{env.API_KEY}}"}},
+			want: "[{key api_key } {value {{API_KEY}} } {in query }]",
+		},
+		{
+			name: "cookie",
+			auth: models.Auth{Type: models.AuthAPIKey, APIKey: &models.APIKeyAuth{In: "cookie", Name: "sid", Value: "{{env.API_KEY}}"}},
+			want: "[{key Cookie } {value sid={{API_KEY}} } {in header }]",
+		},
+		{
+			name: "session cookie",
+			auth: models.Auth{Type: models.AuthSession, Endpoint: "/login", Session: &models.SessionAuth{TokenPath: "$.token", In: "cookie", Name: "session"}},
+			want: "[{key Cookie } {value session={{sessionToken}} } {in header }]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := ToPostman(&models.APIConfig{BaseURL: "https://api.example.com", Auth: tt.auth})
+			if collection.Auth.Type != "apikey" {
+				t.Fatalf("auth type = %q, want apikey", collection.Auth.Type)
+			}
+			if got := fmt.Sprint(collection.Auth.APIKey); got != tt.want {
+				t.Errorf("apikey = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/schema"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// param is a named value of a request, which may still hold {{ path }} placeholders
+type param struct {
+	name, value string
+}
+
+/*
+request is a call as a concrete request someone can send by hand. Exporters for
+click-through formats share it, and only differ in how they write placeholders,
+path parameters and auth.
+*/
+type request struct {
+	name        string
+	folder      string
+	description string
+	method      string
+	path        string
+	pathParams  []param
+	query       []param
+	headers     []param
+	body        any
+}
+
+/*
+requests turns the calls of a config into requests, with example values wherever
+the config has them. Literal credentials among those are redacted, keeping the
+{{env.NAME}} placeholders.
+*/
+func requests(config *models.APIConfig) []request {
+	var out []request
+
+	for _, c := range calls(config) {
+		r := request{
+			name:        c.Name,
+			folder:      c.Job,
+			description: c.Description,
+			method:      method(c.Method),
+			path:        "/" + strings.TrimLeft(c.Path, "/"),
+		}
+
+		if r.folder == "" {
+			r.folder = "Endpoints"
+		}
+
+		for _, parameter := range c.Parameters {
+			value := parameterExample(config, parameter)
+			switch parameter.In {
+			case "path":
+				r.pathParams = append(r.pathParams, param{parameter.Name, value})
+			case "query":
+				r.query = append(r.query, param{parameter.Name, value})
+			case "header":
+				r.headers = append(r.headers, param{parameter.Name, value})
+			}
+		}
+
+		for _, name := range pathParameters(r.path) {
+			if !hasParam(r.pathParams, name) {
+				r.pathParams = append(r.pathParams, param{name: name})
+			}
+		}
+
+		if c.Request != nil && r.method != http.MethodGet && r.method != http.MethodHead {
+			r.body = schema.Example(config, c.Request)
+			if body, ok := r.body.(map[string]any); ok {
+				r.body = redactFields(body)
+			}
+			if !hasParam(r.headers, "Content-Type") {
+				r.headers = append(r.headers, param{"Content-Type", "application/json"})
+			}
+		}
+
+		out = append(out, r)
+	}
+
+	return out
+}
+
+// parameterExample returns the value to send for a parameter, redacted when it's a literal credential
+func parameterExample(config *models.APIConfig, parameter models.EndpointParameter) string {
+	parameter.Schema = redactExample(parameter)
+	if parameter.Schema == nil {
+		return ""
+	}
+	if parameter.In == "path" && parameter.Schema.Example == nil && len(parameter.Schema.Enum) == 0 {
+		// A made up ID only leads to a 404, so the parameter stays a variable to fill in.
+		return ""
+	}
+	if value := schema.Example(config, parameter.Schema); value != nil {
+		return utils.Stringify(value)
+	}
+	return ""
+}
+
+func hasParam(params []param, name string) bool {
+	for _, p := range params {
+		if strings.EqualFold(p.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+rewrite replaces the {{ path }} placeholders in a string with what fn makes of
+their path, so every format can write variables its own way.
+*/
+func rewrite(s string, fn func(path string) string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(match string) string {
+		return fn(placeholder.FindStringSubmatch(match)[1])
+	})
+}
+
+// rewriteValue rewrites the placeholders in every string of a decoded JSON value
+func rewriteValue(value any, fn func(path string) string) any {
+	switch v := value.(type) {
+	case string:
+		return rewrite(v, fn)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, val := range v {
+			out[key] = rewriteValue(val, fn)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = rewriteValue(val, fn)
+		}
+		return out
+	}
+	return value
+}
+
+// envVars collects the names of the environment variables a config references, sorted
+func envVars(config *models.APIConfig) []string {
+	found := map[string]bool{}
+	collect := func(path string) string {
+		if name, ok := strings.CutPrefix(path, "env."); ok {
+			found[name] = true
+		}
+		return path
+	}
+
+	rewriteValue(toValue(config), collect)
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// toValue turns any value into its decoded JSON form, so placeholders can be found in every string of it
+func toValue(value any) any {
+	var out any
+	if data, err := json.Marshal(value); err == nil {
+		_ = json.Unmarshal(data, &out)
+	}
+	return out
+}
+
+// tokenExpression turns a JSONPath into a JavaScript property access on root, e.g. root["data"]["token"]
+func tokenExpression(root, path string) string {
+	segments, err := utils.ParsePath(path)
+	if err != nil {
+		return root
+	}
+
+	var b strings.Builder
+	b.WriteString(root)
+	for _, segment := range segments {
+		fmt.Fprintf(&b, "[%q]", segment)
+	}
+	return b.String()
+}
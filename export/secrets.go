@@ -0,0 +1,152 @@
+package export
+
+import (
+	"maps"
+	"regexp"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// redacted replaces literal secrets in documents meant for review
+const redacted = "[REDACTED]"
+
+/*
+reference matches values that only say where a secret comes from: placeholders,
+optionally behind a scheme such as "Bearer ". Those are safe to export.
+*/
+var reference = regexp.MustCompile(`^(?:[A-Za-z]+ )?(?:\{\{\s*[^{}]+?\s*\}\})+$`)
+
+// sensitiveNames are parts of header and field names that hold credentials
+var sensitiveNames = []string{"auth", "token", "key", "secret", "password", "cookie", "session", "signature"}
+
+// redact returns the value, or a marker when it is a literal secret rather than a placeholder
+func redact(value string) string {
+	if value == "" || reference.MatchString(value) {
+		return value
+	}
+	return redacted
+}
+
+// sensitive reports whether a header or field name looks like it holds a credential
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveNames {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+redactAuth returns a copy of the auth settings with every literal credential
+replaced, keeping the {{env.NAME}} placeholders. Login inputs keep their values,
+except for the headers and fields whose name says they hold a credential.
+*/
+func redactAuth(config models.Auth) models.Auth {
+	out := config
+
+	if config.APIKey != nil {
+		key := *config.APIKey
+		key.Value = redact(key.Value)
+		out.APIKey = &key
+	}
+
+	if config.Basic != nil {
+		basic := *config.Basic
+		basic.Password = redact(basic.Password)
+		out.Basic = &basic
+	}
+
+	if config.Bearer != nil {
+		bearer := *config.Bearer
+		bearer.Token = redact(bearer.Token)
+		out.Bearer = &bearer
+	}
+
+	if config.OAuth2 != nil {
+		oauth2 := *config.OAuth2
+		oauth2.ClientSecret = redact(oauth2.ClientSecret)
+		oauth2.Code = redact(oauth2.Code)
+		oauth2.RefreshToken = redact(oauth2.RefreshToken)
+		out.OAuth2 = &oauth2
+	}
+
+	out.Inputs = nil
+	for _, input := range config.Inputs {
+		out.Inputs = append(out.Inputs, models.Input{
+			Headers: redactHeaders(input.Headers),
+			Body:    redactFields(input.Body),
+		})
+	}
+
+	return out
+}
+
+// redactHeaders copies headers, redacting the values of those that hold credentials
+func redactHeaders(headers models.Headers) models.Headers {
+	if headers == nil {
+		return nil
+	}
+
+	out := maps.Clone(headers)
+	for name, value := range out {
+		if sensitive(name) {
+			out[name] = redact(value)
+		}
+	}
+	return out
+}
+
+// redactFields copies a body, redacting the string values of the fields that hold credentials
+func redactFields(body map[string]any) map[string]any {
+	if body == nil {
+		return nil
+	}
+
+	out := make(map[string]any, len(body))
+	for name, value := range body {
+		switch v := value.(type) {
+		case string:
+			if sensitive(name) {
+				v = redact(v)
+			}
+			out[name] = v
+		case map[string]any:
+			out[name] = redactFields(v)
+		default:
+			out[name] = value
+		}
+	}
+	return out
+}
+
+// redactJobs copies jobs, redacting the credentials in the inputs of their steps
+func redactJobs(jobs []models.Job) []models.Job {
+	var out []models.Job
+	for _, job := range jobs {
+		steps := make([]models.Step, len(job.Steps))
+		for i, step := range job.Steps {
+			step.Inputs = models.Input{Headers: redactHeaders(step.Inputs.Headers), Body: redactFields(step.Inputs.Body)}
+			steps[i] = step
+		}
+		out = append(out, models.Job{Name: job.Name, Steps: steps})
+	}
+	return out
+}
+
+// redactExample returns a copy of a parameter's schema whose example holds no credential
+func redactExample(parameter models.EndpointParameter) *models.Schema {
+	if parameter.Schema == nil || !sensitive(parameter.Name) {
+		return parameter.Schema
+	}
+
+	s := *parameter.Schema
+	if value, ok := s.Example.(string); ok {
+		s.Example = redact(value)
+	} else if s.Example != nil {
+		s.Example = redacted
+	}
+	return &s
+}
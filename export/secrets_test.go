@@ -0,0 +1,61 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func TestExportsRedactSecrets(t *testing.T) {
+	auths := map[string]models.Auth{
+		"api key":         {Type: models.AuthAPIKey, APIKey: &models.APIKeyAuth{In: "query", Name: "api_key", Value: "s3cret-key"}},
+		"basic":           {Type: models.AuthBasic, Basic: &models.BasicAuth{Username: "ada", Password: "s3cret-password"}},
+		"bearer":          {Type: models.AuthBearer, Bearer: &models.BearerAuth{Token: "s3cret-token"}},
+		"client secret":   {Type: models.AuthOAuth2ClientCredentials, OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/token", ClientID: "client", ClientSecret: "s3cret-client"}},
+		"client in body":  {Type: models.AuthOAuth2ClientCredentials, OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/token", ClientID: "client", ClientSecret: "s3cret-client", ClientAuth: "body"}},
+		"code":            {Type: models.AuthOAuth2AuthorizationCode, OAuth2: &models.OAuth2Auth{TokenURL: "https://api.example.com/token", ClientID: "client", ClientSecret: "{{env.CLIENT_SECRET}}", Code: "s3cret-code"}},
+		"session headers": {Type: models.AuthSession, Endpoint: "/login", Inputs: []models.Input{{Headers: models.Headers{"X-Api-Key": "s3cret-key"}, Body: map[string]any{"username": "ada", "password": "s3cret-password"}}}},
+	}
+
+	exporters := map[string]func(*models.APIConfig) string{
+		"http": ToHTTPFile,
+		"curl": ToCurl,
+		"postman": func(config *models.APIConfig) string {
+			data, _ := json.Marshal(ToPostman(config))
+			return string(data)
+		},
+	}
+
+	for name, a := range auths {
+		config := &models.APIConfig{
+			Integration: "test",
+			BaseURL:     "https://api.example.com",
+			Auth:        a,
+			Jobs: []models.Job{{Name: "sync", Steps: []models.Step{
+				{
+					Type: "http_request", Name: "list", Endpoint: "/employees",
+					Inputs: models.Input{Headers: models.Headers{"X-Token": "s3cret-step", "X-Env-Token": "Bearer {{env.TOKEN}}"}},
+				},
+				{
+					Type: "http_request", Name: "create", Endpoint: "/employees", Method: "POST",
+					Inputs: models.Input{Body: map[string]any{"name": "ada", "api_key": "s3cret-body"}},
+				},
+			}}},
+		}
+
+		for format, export := range exporters {
+			t.Run(name+"/"+format, func(t *testing.T) {
+				out := export(config)
+
+				if strings.Contains(out, "s3cret") {
+					t.Errorf("export holds a literal secret:\n%s", out)
+				}
+				if !strings.Contains(out, "ada") || !strings.Contains(out, "TOKEN") {
+					t.Errorf("export lost values that aren't secret:\n%s", out)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/codegen"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// generateCommand writes a typed Go client package for an APIConfig
+func generateCommand(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	pkg := flags.String("package", "", "Name of the generated package, derived from the integration by default")
+	out := flags.String("out", "", "Directory to write the package to, named after the package by default")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: milkshake generate [flags] config.json")
+	}
+
+	config, err := models.LoadAPIConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	name := *pkg
+	if name == "" {
+		name = codegen.PackageName(config.Integration)
+	}
+
+	files, err := codegen.Generate(config, codegen.Options{Package: name})
+	if err != nil {
+		return err
+	}
+
+	dir := *out
+	if dir == "" {
+		dir = name
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %w", dir, err)
+	}
+
+	for name, data := range files {
+		if err := write(filepath.Join(dir, name), data); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Generated client", "dir", dir, "files", len(files))
+	return nil
+}
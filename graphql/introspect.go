@@ -0,0 +1,207 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+)
+
+// introspectionQuery is the standard introspection query, trimmed of the parts
+// that don't help describe an API, like directives and deprecation reasons.
+const introspectionQuery = `
+query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: false) {
+    name
+    description
+    args { ...InputValue }
+    type { ...TypeRef }
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: false) { name }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+        }
+      }
+    }
+  }
+}
+`
+
+type GraphQLIntrospector struct {
+	ToolName        string           `json:"name" jsonschema:"description=The name of the tool,required"`
+	ToolDescription string           `json:"description" jsonschema:"description=The description of the tool,required"`
+	ToolParameters  models.Parameter `json:"parameters" jsonschema:"description=The parameters of the tool,required"`
+	Required        []string         `json:"required" jsonschema:"description=The required parameters of the tool,required"`
+}
+
+func NewGraphQLIntrospector() models.ToolType {
+	return &GraphQLIntrospector{
+		ToolName:        "graphql_introspect",
+		ToolDescription: "Runs the introspection query against a GraphQL endpoint and returns a compact SDL summary of its schema",
+		ToolParameters: models.Parameter{
+			Type: "object",
+			Properties: []models.Property{
+				{
+					Name:        "url",
+					Type:        "string",
+					Description: "The URL of the GraphQL endpoint",
+				},
+				{
+					Name:        "headers",
+					Type:        "object",
+					Description: "The headers of the request, for example to authenticate",
+				},
+				{
+					Name:        "types",
+					Type:        "array",
+					Description: "Only describe these types, to keep the summary of large schemas short",
+				},
+			},
+			Required: true,
+		},
+		Required: []string{"url"},
+	}
+}
+
+func (gi *GraphQLIntrospector) Name() string {
+	return gi.ToolName
+}
+
+func (gi *GraphQLIntrospector) Description() string {
+	return gi.ToolDescription
+}
+
+func (gi *GraphQLIntrospector) Execute(args map[string]any) (string, error) {
+	url, ok := args["url"].(string)
+	if !ok {
+		log.Error("URL is required but not provided")
+		return "", fmt.Errorf("url is required")
+	}
+
+	var only []string
+	if types, ok := args["types"].([]any); ok {
+		for _, t := range types {
+			if name, ok := t.(string); ok {
+				only = append(only, name)
+			}
+		}
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"query":         introspectionQuery,
+		"operationName": "IntrospectionQuery",
+	})
+	if err != nil {
+		return "", fmt.Errorf("error encoding introspection query: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(string(body)))
+	if err != nil {
+		log.Error("Error creating introspection request", "error", err)
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	if headers, ok := args["headers"].(map[string]any); ok {
+		for key, value := range headers {
+			if str, ok := value.(string); ok {
+				req.Header.Set(key, str)
+			}
+		}
+	}
+
+	log.Info("Sending introspection query", "url", url)
+	resp, err := request.Client().Do(req)
+	if err != nil {
+		log.Error("Error executing introspection query", "error", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading introspection response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Error("Introspection failed with non-success status code", "statusCode", resp.StatusCode)
+		return "", fmt.Errorf("introspection failed with status code %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	schema, err := ParseIntrospection(respBody)
+	if err != nil {
+		return "", err
+	}
+
+	sdl := schema.SDL(only...)
+	log.Info("Introspection completed", "types", len(schema.Types), "sdlSize", len(sdl))
+
+	return sdl, nil
+}
+
+func (gi *GraphQLIntrospector) Schema() any {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"url": map[string]interface{}{
+				"type":        "string",
+				"description": "The URL of the GraphQL endpoint",
+			},
+			"headers": map[string]interface{}{
+				"type":        "object",
+				"description": "The headers of the request, for example to authenticate",
+			},
+			"types": map[string]interface{}{
+				"type":        "array",
+				"description": "Only describe these types, to keep the summary of large schemas short",
+				"items": map[string]interface{}{
+					"type": "string",
+				},
+			},
+		},
+		"required": []string{"url"},
+	}
+}
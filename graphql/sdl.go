@@ -0,0 +1,215 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// TypeRef is a reference to a type, wrapped in NON_NULL and LIST as needed
+type TypeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *TypeRef `json:"ofType"`
+}
+
+// InputValue is an argument or an input object field
+type InputValue struct {
+	Name         string  `json:"name"`
+	Type         TypeRef `json:"type"`
+	DefaultValue *string `json:"defaultValue"`
+}
+
+// Field is a field of an object or interface type
+type Field struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Args        []InputValue `json:"args"`
+	Type        TypeRef      `json:"type"`
+}
+
+// EnumValue is a single value of an enum type
+type EnumValue struct {
+	Name string `json:"name"`
+}
+
+// Type is a named type in the schema
+type Type struct {
+	Kind          string       `json:"kind"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	Fields        []Field      `json:"fields"`
+	InputFields   []InputValue `json:"inputFields"`
+	Interfaces    []TypeRef    `json:"interfaces"`
+	EnumValues    []EnumValue  `json:"enumValues"`
+	PossibleTypes []TypeRef    `json:"possibleTypes"`
+}
+
+// Schema is the result of an introspection query
+type Schema struct {
+	QueryType        *TypeRef `json:"queryType"`
+	MutationType     *TypeRef `json:"mutationType"`
+	SubscriptionType *TypeRef `json:"subscriptionType"`
+	Types            []Type   `json:"types"`
+}
+
+var builtinScalars = []string{"String", "Int", "Float", "Boolean", "ID"}
+
+// ParseIntrospection parses the response to an introspection query
+func ParseIntrospection(body []byte) (*Schema, error) {
+	var response struct {
+		Data struct {
+			Schema *Schema `json:"__schema"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error parsing introspection response: %w", err)
+	}
+
+	if len(response.Errors) > 0 {
+		messages := make([]string, 0, len(response.Errors))
+		for _, e := range response.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("introspection failed: %s", strings.Join(messages, "; "))
+	}
+
+	if response.Data.Schema == nil {
+		return nil, fmt.Errorf("introspection response contains no schema, introspection may be disabled")
+	}
+
+	return response.Data.Schema, nil
+}
+
+/*
+SDL renders the schema as compact SDL, skipping introspection and built-in types.
+Descriptions are shortened to their first line, so the summary fits in a prompt.
+When only is given, just those types are rendered.
+*/
+func (s *Schema) SDL(only ...string) string {
+	var b strings.Builder
+
+	b.WriteString("schema {\n")
+	for _, root := range []struct {
+		name string
+		ref  *TypeRef
+	}{
+		{"query", s.QueryType},
+		{"mutation", s.MutationType},
+		{"subscription", s.SubscriptionType},
+	} {
+		if root.ref != nil && root.ref.Name != "" {
+			fmt.Fprintf(&b, "  %s: %s\n", root.name, root.ref.Name)
+		}
+	}
+	b.WriteString("}\n")
+
+	for _, t := range s.Types {
+		if strings.HasPrefix(t.Name, "__") || slices.Contains(builtinScalars, t.Name) {
+			continue
+		}
+
+		if len(only) > 0 && !slices.Contains(only, t.Name) {
+			continue
+		}
+
+		b.WriteString("\n")
+		writeDescription(&b, "", t.Description)
+
+		switch t.Kind {
+		case "SCALAR":
+			fmt.Fprintf(&b, "scalar %s\n", t.Name)
+		case "ENUM":
+			values := make([]string, 0, len(t.EnumValues))
+			for _, v := range t.EnumValues {
+				values = append(values, v.Name)
+			}
+			fmt.Fprintf(&b, "enum %s { %s }\n", t.Name, strings.Join(values, " "))
+		case "UNION":
+			members := make([]string, 0, len(t.PossibleTypes))
+			for _, p := range t.PossibleTypes {
+				members = append(members, p.Name)
+			}
+			fmt.Fprintf(&b, "union %s = %s\n", t.Name, strings.Join(members, " | "))
+		case "INPUT_OBJECT":
+			fmt.Fprintf(&b, "input %s {\n", t.Name)
+			for _, f := range t.InputFields {
+				fmt.Fprintf(&b, "  %s\n", f.String())
+			}
+			b.WriteString("}\n")
+		case "OBJECT", "INTERFACE":
+			keyword := "type"
+			if t.Kind == "INTERFACE" {
+				keyword = "interface"
+			}
+
+			fmt.Fprintf(&b, "%s %s", keyword, t.Name)
+			if len(t.Interfaces) > 0 {
+				names := make([]string, 0, len(t.Interfaces))
+				for _, i := range t.Interfaces {
+					names = append(names, i.Name)
+				}
+				fmt.Fprintf(&b, " implements %s", strings.Join(names, " & "))
+			}
+			b.WriteString(" {\n")
+
+			for _, f := range t.Fields {
+				writeDescription(&b, "  ", f.Description)
+				fmt.Fprintf(&b, "  %s", f.Name)
+				if len(f.Args) > 0 {
+					args := make([]string, 0, len(f.Args))
+					for _, a := range f.Args {
+						args = append(args, a.String())
+					}
+					fmt.Fprintf(&b, "(%s)", strings.Join(args, ", "))
+				}
+				fmt.Fprintf(&b, ": %s\n", f.Type.String())
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	return b.String()
+}
+
+// String renders the reference the way it is written in SDL, e.g. [User!]!
+func (t TypeRef) String() string {
+	switch t.Kind {
+	case "NON_NULL":
+		if t.OfType != nil {
+			return t.OfType.String() + "!"
+		}
+	case "LIST":
+		if t.OfType != nil {
+			return "[" + t.OfType.String() + "]"
+		}
+	}
+	return t.Name
+}
+
+// String renders the input value the way it is written in SDL, e.g. first: Int = 10
+func (v InputValue) String() string {
+	str := v.Name + ": " + v.Type.String()
+	if v.DefaultValue != nil {
+		str += " = " + *v.DefaultValue
+	}
+	return str
+}
+
+func writeDescription(b *strings.Builder, indent, description string) {
+	description, _, _ = strings.Cut(strings.TrimSpace(description), "\n")
+	if description == "" {
+		return
+	}
+
+	description = utils.Truncate(description, 120)
+
+	fmt.Fprintf(b, "%s# %s\n", indent, description)
+}
@@ -2,27 +2,225 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/merge"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
 	"github.com/theapemachine/idrinkyourmilkshake/openai"
+	"github.com/theapemachine/idrinkyourmilkshake/pipeline"
+	"github.com/theapemachine/idrinkyourmilkshake/provenance"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+	"github.com/theapemachine/idrinkyourmilkshake/review"
 )
 
+// docsURL is the documentation the extraction starts from
+const docsURL = "https://developer.dyflexis.com/v3"
+
+// commands are the subcommands next to the default extraction, keyed by name
+var commands = map[string]func(args []string) error{
+	"diff":     diffCommand,
+	"export":   exportCommand,
+	"generate": generateCommand,
+	"import":   importCommand,
+	"mock":     mockCommand,
+	"review":   reviewCommand,
+	"run":      runCommand,
+	"store":    storeCommand,
+	"verify":   verifyCommand,
+	"watch":    watchCommand,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal("Command failed", "command", os.Args[1], "error", err)
+			}
+			return
+		}
+	}
+
+	if err := extract(os.Args[1:]); err != nil {
+		log.Fatal("Error executing OpenAI client", "error", err)
+	}
+}
+
+func extract(args []string) error {
+	flags := flag.NewFlagSet("milkshake", flag.ExitOnError)
+	out := flags.String("out", "", "Write the extracted config to this file instead of stdout")
+	authConfig := flags.String("auth", "", "Authenticate http_request calls with the auth block of this config")
+	reviewFirst := flags.Bool("review", false, "Go through the fields that need a closer look before the config is written")
+	seedPath := flags.String("seed", "", "Only extract what this earlier config is missing or gets wrong, and merge the result into it")
+	usePipeline := flags.Bool("pipeline", false, "Extract with a planner, parallel explorers and a writer instead of a single agent, for large docs")
+	explorers := flags.Int("explorers", 4, "How many explorers of the pipeline run at the same time")
+	toolWorkers := flags.Int("tool-workers", 4, "How many tool calls of a turn run at the same time")
+	maxIterations := flags.Int("max-iterations", 20, "How many turns each agent gets before it has to answer with what it found, 0 for no limit")
+	maxTime := flags.Duration("max-time", 0, "Stop the run after this long with an answer from what was found, 0 for no limit")
+	budget := flags.Float64("budget", 0, "Stop the run once the model calls cost more than this many US dollars, with an answer from what was found, 0 for no limit")
+	tokenBudget := flags.Int64("token-budget", 0, "Stop the run once the model calls took more than this many tokens, with an answer from what was found, 0 for no limit")
+	pricesPath := flags.String("prices", "", "Price the model calls with the per-million-token prices in this JSON file")
+	transcriptPath := flags.String("transcript", "", "Write the events, tool calls, token usage and cost of the run to this JSON file")
+	configureHTTP := httpFlags(flags)
+	progress := progressFlag(flags)
+	flags.Parse(args)
+
+	if *reviewFirst && *out == "" {
+		return fmt.Errorf("-review needs -out, since the review takes over the terminal")
+	}
+
 	log.Info("Starting application")
 
+	handler, err := progress()
+	if err != nil {
+		return err
+	}
+
+	if err := configureHTTP(); err != nil {
+		return err
+	}
+
+	if *authConfig != "" {
+		config, err := models.LoadAPIConfig(*authConfig)
+		if err != nil {
+			return err
+		}
+
+		authenticator, err := auth.New(config.Auth, config.BaseURL, request.Client())
+		if err != nil {
+			return err
+		}
+		request.UseAuthenticator(authenticator)
+	}
+
+	var (
+		seed  *models.APIConfig
+		brief string
+	)
+	if *seedPath != "" {
+		var err error
+		if seed, err = models.LoadAPIConfig(*seedPath); err != nil {
+			return err
+		}
+		if brief, err = seedPrompt(seed); err != nil {
+			return err
+		}
+	}
+
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is not set")
+		return fmt.Errorf("OPENAI_API_KEY environment variable is not set")
 	}
 
 	log.Info("Initializing OpenAI client")
-	client := openai.NewClient(apiKey)
+	prices := openai.DefaultPrices
+	if *pricesPath != "" {
+		if prices, err = openai.LoadPrices(*pricesPath); err != nil {
+			return err
+		}
+	}
+
+	var transcript *openai.Transcript
+	if *transcriptPath != "" {
+		transcript = openai.NewTranscript()
+		handler = openai.Handlers(handler, transcript.Record)
+	}
+
+	client := openai.NewClient(apiKey).
+		WithToolWorkers(*toolWorkers).
+		WithPrices(prices).
+		WithBudget(*budget).
+		WithTokenBudget(*tokenBudget).
+		WithEvents(handler)
+
+	if *maxTime > 0 {
+		client = client.WithDeadline(time.Now().Add(*maxTime))
+	}
+
+	// Whatever the outcome, the calls have been paid for.
+	defer func() {
+		usage := client.Usage()
+		log.Info("Token usage",
+			"prompt", usage.PromptTokens,
+			"cached", usage.CachedTokens,
+			"completion", usage.CompletionTokens,
+			"cost", fmt.Sprintf("$%.4f", usage.Cost),
+		)
+
+		if transcript != nil {
+			if err := transcript.Save(*transcriptPath, usage); err != nil {
+				log.Error("Could not save the transcript", "error", err)
+			}
+		}
+	}()
 
 	log.Info("Creating background context")
 	ctx := context.Background()
 	client = client.WithContext(ctx)
 
+	var (
+		config *models.APIConfig
+		calls  []openai.ToolCall
+	)
+
+	if *usePipeline {
+		p := pipeline.New(client, docsURL).WithBrief(brief).WithExplorers(*explorers).WithIterations(*maxIterations)
+		config, err = p.Run(ctx)
+		calls = p.ToolCalls()
+	} else {
+		config, err = execute(client, brief, *maxIterations)
+		calls = client.ToolCalls()
+	}
+	if err != nil {
+		return err
+	}
+
+	provenance.Resolve(config, calls)
+	if provenance.VerifyAuth(ctx, config, calls, request.Client()) {
+		log.Info("The extracted auth works against the live API")
+	}
+
+	if seed != nil {
+		config = merge.Merge(seed, config)
+	}
+
+	if config.Partial {
+		log.Warn("The extraction was stopped early, so the config is partial", "reasons", strings.Join(config.Stopped, "; "))
+	}
+
+	if flagged := review.NewFlagger().Flag(config); len(flagged) > 0 {
+		if *reviewFirst {
+			reviewed := walk(config, flagged)
+			log.Info("Reviewed the fields that needed a closer look", "reviewed", reviewed, "flagged", len(flagged))
+		} else {
+			log.Warn("Some extracted fields need a closer look, go through them with -review or the review command", "count", len(flagged))
+		}
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	if *out == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	return os.WriteFile(*out, append(data, '\n'), 0o644)
+}
+
+/*
+execute extracts the config with a single agent that navigates, reads and writes
+it all. When the agent had to stop early, the config is marked partial.
+*/
+func execute(client *openai.Client, brief string, maxIterations int) (*models.APIConfig, error) {
 	log.Info("Creating conversation buffer with system and user prompts")
 	buffer := openai.NewBuffer(
 		`
@@ -31,17 +229,94 @@ func main() {
 		You will be given a URL to a page of API documentation and your job is to extract the API endpoints and data models from the documentation and generate a configuration object.
 		You have access to a full Chrome browser as a tool, so you can navigate the documentation and do whatever is needed to extract the information.
 		You also have access to an HTTP request tool, so you can interact with APIs when needed.
+		Describe every data model as a JSON Schema under schemas, and list the endpoints with their request and response schemas as $ref references such as #/schemas/Employee.
+		When the documentation is vague about a response, fetch real samples with the HTTP request tool and pass them to the schema inference tool, together with the documented schema, to find out what the API really returns.
+		For GraphQL APIs, use the GraphQL introspection tool to discover the schema, and express operations as steps of type graphql.
+		Every tool output starts with its tool_call_id. Under citations, cite where you found the account ID, the base URL, each endpoint, the auth and each schema field, with the tool_call_id of the output and a short quote copied verbatim from it.
+		Give every citation a confidence from 0 to 1, and be honest: anything you inferred or made up, such as an account ID the documentation doesn't give, gets a low confidence and no tool_call_id.
 		`,
-		`
-		Here is the documentation URL for the API: https://developer.dyflexis.com/v3
-		`,
+		fmt.Sprintf("Here is the documentation URL for the API: %s\n%s", docsURL, brief),
 	)
 
-	log.Info("Starting OpenAI client execution with max iterations", "maxIterations", 20)
-	result, err := client.Execute(buffer, 20)
+	log.Info("Starting OpenAI client execution with max iterations", "maxIterations", maxIterations)
+	result, err := client.Execute(buffer, maxIterations)
 	if err != nil {
-		log.Fatal("Error executing OpenAI client", "error", err)
+		return nil, err
 	}
 
 	log.Info("Execution completed successfully", "resultLength", len(result))
+
+	config, err := models.ParseAPIConfig([]byte(result))
+	if err != nil {
+		return nil, err
+	}
+
+	config.MarkPartial(client.Stopped())
+	return config, nil
+}
+
+/*
+seedPrompt tells the model what an earlier extraction already found, so it only
+goes after what is missing or changed. Locked fields were edited by hand and are
+kept by the merge regardless, so the model is told not to bother with them.
+*/
+func seedPrompt(seed *models.APIConfig) (string, error) {
+	known := *seed
+	known.Provenance = nil
+
+	config, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding seed config: %w", err)
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "\nAn earlier extraction already produced this configuration:\n\n%s\n", config)
+	prompt.WriteString("\nOnly extract what it is missing, such as undocumented endpoints or incomplete data models, and what the documentation now describes differently.\n")
+	prompt.WriteString("Return just those endpoints, data models and settings, under the names the configuration already uses. Whatever you leave out is kept as it is.\n")
+	prompt.WriteString("The integration, account ID, base URL and auth only replace what the configuration has when you cite where you read them.\n")
+
+	if len(seed.Locked) > 0 {
+		prompt.WriteString("\nThese fields were edited by hand and are kept whatever you return, so don't spend time on them:\n")
+		for _, path := range seed.Locked {
+			fmt.Fprintf(&prompt, "- %s\n", path)
+		}
+	}
+
+	return prompt.String(), nil
+}
+
+/*
+httpFlags registers the flags for the shared HTTP client on a command's flag set.
+The returned function applies them once the flags have been parsed.
+*/
+func httpFlags(flags *flag.FlagSet) func() error {
+	defaults := request.DefaultClientConfig()
+	timeout := flags.Duration("timeout", defaults.Timeout, "Timeout for outgoing HTTP requests, including retries")
+	maxRetries := flags.Int("max-retries", defaults.MaxRetries, "Maximum number of retries for rate limited or failed HTTP requests")
+	rateLimit := flags.Float64("rate-limit", defaults.RateLimit, "Maximum HTTP requests per second per host, 0 to disable")
+	cassettePath := flags.String("cassette", "", "Record and replay HTTP requests using the cassette at this path")
+	cassetteMode := flags.String("cassette-mode", string(request.ModeAuto), "Cassette mode: record, replay or auto")
+
+	return func() error {
+		clientConfig := request.DefaultClientConfig()
+		clientConfig.Timeout = *timeout
+		clientConfig.MaxRetries = *maxRetries
+		clientConfig.RateLimit = *rateLimit
+		request.Configure(clientConfig)
+
+		if *cassettePath == "" {
+			return nil
+		}
+
+		cassette, err := request.NewCassette(*cassettePath, request.CassetteMode(*cassetteMode))
+		if err != nil {
+			return fmt.Errorf("error loading cassette: %w", err)
+		}
+
+		// The cassette sits in front of the retrying client, so only final responses are recorded.
+		cassette.Transport = request.Transport()
+		request.UseTransport(cassette)
+
+		return nil
+	}
 }
@@ -0,0 +1,263 @@
+package mapping
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// commonLayouts are tried in order when a date field has no explicit format
+var commonLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02-01-2006",
+	"01/02/2006",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+/*
+Apply evaluates a mapping against a single source record. For every field the
+value is read from its source, or joined from its concat sources, then falls
+back to the default, goes through the lookup table and is finally coerced to
+the field's type.
+*/
+func Apply(m models.Map, record any) (map[string]any, error) {
+	out := map[string]any{}
+
+	for _, field := range m {
+		value, err := Field(field, record)
+		if err != nil {
+			return nil, err
+		}
+
+		if value == nil {
+			continue
+		}
+
+		set(out, field.Target, value)
+	}
+
+	return out, nil
+}
+
+// Field evaluates a single field mapping against a source record
+func Field(field models.FieldMap, record any) (any, error) {
+	value := source(field, record)
+
+	if field.Lookup != nil && value != nil {
+		translated, ok := field.Lookup[fmt.Sprint(value)]
+		if ok {
+			value = translated
+		} else {
+			value = nil
+		}
+	}
+
+	if isEmpty(value) {
+		value = field.Default
+	}
+
+	if value == nil {
+		if field.Required {
+			return nil, fmt.Errorf("field %s is required but has no value", field.Target)
+		}
+		return nil, nil
+	}
+
+	coerced, err := coerce(value, field)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.Target, err)
+	}
+
+	return coerced, nil
+}
+
+func source(field models.FieldMap, record any) any {
+	if len(field.Concat) == 0 {
+		if field.Source == "" {
+			return nil
+		}
+		value, _ := utils.Lookup(record, field.Source)
+		return value
+	}
+
+	separator := field.Separator
+	if separator == "" {
+		separator = " "
+	}
+
+	parts := []string{}
+	for _, path := range field.Concat {
+		if value, ok := utils.Lookup(record, path); ok && !isEmpty(value) {
+			parts = append(parts, toString(value))
+		}
+	}
+
+	if len(parts) == 0 {
+		return nil
+	}
+
+	return strings.Join(parts, separator)
+}
+
+func coerce(value any, field models.FieldMap) (any, error) {
+	switch strings.ToLower(field.Type) {
+	case "":
+		return value, nil
+
+	case "string":
+		return toString(value), nil
+
+	case "integer", "int":
+		number, err := toNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		return int64(math.Round(number)), nil
+
+	case "number", "float":
+		return toNumber(value)
+
+	case "boolean", "bool":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case float64:
+			return v != 0, nil
+		}
+		switch strings.ToLower(strings.TrimSpace(toString(value))) {
+		case "true", "yes", "y", "1", "on":
+			return true, nil
+		case "false", "no", "n", "0", "off", "":
+			return false, nil
+		}
+		return nil, fmt.Errorf("cannot convert %v to a boolean", value)
+
+	case "date", "datetime", "date-time":
+		date, err := parseDate(value, field.DateFormat)
+		if err != nil {
+			return nil, err
+		}
+		format := field.Format
+		if format == "" {
+			format = time.RFC3339
+		}
+		return date.Format(layout(format)), nil
+	}
+
+	return nil, fmt.Errorf("unknown type %s", field.Type)
+}
+
+func parseDate(value any, format string) (time.Time, error) {
+	switch name := strings.ToLower(format); name {
+	case "unix", "unix_ms":
+		number, err := toNumber(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if name == "unix_ms" {
+			return time.UnixMilli(int64(number)).UTC(), nil
+		}
+		return time.Unix(int64(number), 0).UTC(), nil
+	}
+
+	str := strings.TrimSpace(toString(value))
+
+	if format != "" {
+		date, err := time.Parse(layout(format), str)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse date %q with layout %s", str, format)
+		}
+		return date, nil
+	}
+
+	for _, candidate := range commonLayouts {
+		if date, err := time.Parse(candidate, str); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("cannot parse date %q", str)
+}
+
+// layout translates the names models tend to use for layouts into Go layouts
+func layout(format string) string {
+	switch strings.ToUpper(format) {
+	case "RFC3339", "ISO8601":
+		return time.RFC3339
+	case "RFC1123":
+		return time.RFC1123
+	case "DATE":
+		return time.DateOnly
+	}
+	return format
+}
+
+func toNumber(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to a number", v)
+		}
+		return number, nil
+	}
+	return 0, fmt.Errorf("cannot convert %v to a number", value)
+}
+
+func toString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func isEmpty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	}
+	return false
+}
+
+// set writes value into out at a dotted target path, creating nested objects as needed
+func set(out map[string]any, target string, value any) {
+	keys := strings.Split(target, ".")
+	current := out
+
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			current[key] = next
+		}
+		current = next
+	}
+
+	current[keys[len(keys)-1]] = value
+}
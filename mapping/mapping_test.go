@@ -0,0 +1,35 @@
+package mapping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  any
+		format string
+	}{
+		{float64(want.Unix()), "unix"},
+		{float64(want.UnixMilli()), "unix_ms"},
+		{float64(want.UnixMilli()), "UNIX_MS"},
+		{"1709296200", "Unix"},
+		{"2024-03-01T12:30:00Z", ""},
+		{"2024-03-01T12:30:00Z", "ISO8601"},
+		{"2024-03-01 12:30:00", "2006-01-02 15:04:05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := parseDate(tt.value, tt.format)
+			if err != nil {
+				t.Fatalf("parseDate(%v, %q): %v", tt.value, tt.format, err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("parseDate(%v, %q) = %s, want %s", tt.value, tt.format, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package merge
+
+import (
+	"cmp"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// pathParameter matches OpenAPI style path parameters such as {id}
+var pathParameter = regexp.MustCompile(`\{[^{}/]+\}`)
+
+// merger combines a seed config with a new extraction, recording where every field came from
+type merger struct {
+	seed, extracted *models.APIConfig
+	provenance      map[string]models.Provenance
+	// renamed maps the paths of matched endpoints to the names the extraction gave them
+	renamed map[string]string
+}
+
+/*
+Merge combines a seed config with what an extraction returned on top of it. The
+merge goes field by field: top level settings, the auth block, every field of
+an endpoint, every property of a schema, and every job. A field the extraction
+filled in replaces the seed's, one it left empty keeps the seed's, and a field
+that is locked in the seed is kept whatever the extraction says. Nothing is ever
+removed, since an extraction that only looked at part of the docs can't tell.
+
+Endpoints are matched by method and path, with parameter names ignored, and then
+by name, and keep the seed's name so jobs referring to them still work. The
+result's provenance says for every field whether it came from the seed or the
+extraction, with the extraction's citations for the fields it filled in. Fields
+kept from the seed keep the provenance they had there. The outcome only depends
+on the two configs, so merging twice gives the same.
+
+The model has to answer the top level settings and the auth block whatever it
+found, so those only replace the seed's when the extraction cites where it read
+them, and an extracted auth of type none counts as not found at all.
+*/
+func Merge(seed, extracted *models.APIConfig) *models.APIConfig {
+	if seed == nil {
+		seed = &models.APIConfig{}
+	}
+	if extracted == nil {
+		extracted = &models.APIConfig{}
+	}
+
+	m := &merger{seed: seed, extracted: extracted, provenance: map[string]models.Provenance{}, renamed: map[string]string{}}
+	// Only the extraction can be cut short, the seed was complete enough to build on.
+	out := &models.APIConfig{Locked: slices.Clone(seed.Locked), Partial: extracted.Partial, Stopped: extracted.Stopped}
+
+	out.Integration = pick(m, "integration", seed.Integration, claim(m, "integration", seed.Integration, extracted.Integration))
+	out.AccountID = pick(m, "account_id", seed.AccountID, claim(m, "account_id", seed.AccountID, extracted.AccountID))
+	out.BaseURL = pick(m, "base_url", seed.BaseURL, claim(m, "base_url", seed.BaseURL, extracted.BaseURL))
+
+	// No auth is what the model answers when it found nothing, not a finding.
+	seedAuth, extractedAuth := seed.Auth, extracted.Auth
+	if auth.Type(seedAuth) == models.AuthNone {
+		seedAuth = models.Auth{}
+	}
+	if auth.Type(extractedAuth) == models.AuthNone {
+		extractedAuth = models.Auth{}
+	}
+	if out.Auth = pick(m, "auth", seedAuth, claim(m, "auth", seedAuth, extractedAuth)); isZero(out.Auth) {
+		out.Auth = models.Auth{Type: models.AuthNone}
+	}
+
+	out.Endpoints = m.endpoints()
+	out.Schemas = m.schemas()
+	out.Jobs = m.jobs()
+
+	if len(m.provenance) > 0 {
+		out.Provenance = m.provenance
+	}
+
+	return out
+}
+
+/*
+pick decides a single field. Blocks the merge doesn't look into, like auth or an
+endpoint's pagination, are picked whole, so a lock on anything inside them locks
+the whole block.
+*/
+func pick[T any](m *merger, path string, seed, extracted T) T {
+	return decide(m, path, m.locked(path), seed, extracted)
+}
+
+/*
+claim returns the extracted value of a setting the model always has to answer.
+It only counts when the seed has no value yet, or the extraction cites a tool
+call for it, since the model repeats or makes up the rest.
+*/
+func claim[T any](m *merger, path string, seed, extracted T) T {
+	if isZero(seed) || m.cites(path) {
+		return extracted
+	}
+
+	var zero T
+	return zero
+}
+
+// cites reports whether the extraction cites a tool call for the field at path, or for a field inside it
+func (m *merger) cites(path string) bool {
+	for at, provenance := range m.extracted.Provenance {
+		if provenance.ToolCallID != "" && (at == path || strings.HasPrefix(at, path+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+func decide[T any](m *merger, path string, locked bool, seed, extracted T) T {
+	value, source := seed, models.SourceSeed
+
+	switch {
+	case locked:
+	case isZero(extracted):
+	case isZero(seed) || !reflect.DeepEqual(seed, extracted):
+		value, source = extracted, models.SourceExtracted
+	}
+
+	if isZero(value) {
+		return value
+	}
+
+	config, at := m.seed, path
+	if source == models.SourceExtracted {
+		config, at = m.extracted, m.extractedPath(path)
+	}
+
+	provenance, _ := config.ProvenanceOf(at)
+	provenance.Source = cmp.Or(provenance.Source, source)
+	provenance.Locked = locked
+	m.provenance[path] = provenance
+
+	return value
+}
+
+// extractedPath returns the path a field has in the extraction, which may have named its endpoint differently
+func (m *merger) extractedPath(path string) string {
+	for seed, extracted := range m.renamed {
+		if path == seed || strings.HasPrefix(path, seed+".") {
+			return extracted + strings.TrimPrefix(path, seed)
+		}
+	}
+	return path
+}
+
+/*
+locked reports whether the seed locks the field at path, or a field inside it.
+Locks under one of the nested prefixes don't count, since the merge decides
+those fields on their own.
+*/
+func (m *merger) locked(path string, nested ...string) bool {
+	if m.seed.IsLocked(path) {
+		return true
+	}
+
+	for _, locked := range m.seed.Locked {
+		if !strings.HasPrefix(locked, path+".") {
+			continue
+		}
+		if !slices.ContainsFunc(nested, func(prefix string) bool { return strings.HasPrefix(locked, prefix) }) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+endpoints merges the endpoints, in the seed's order followed by the ones only the
+extraction found.
+*/
+func (m *merger) endpoints() []models.Endpoint {
+	seed, extracted := m.seed.Endpoints, m.extracted.Endpoints
+	matched := map[int]int{}
+	taken := map[int]bool{}
+
+	// First by method and path, then whatever is left by name.
+	for _, byName := range []bool{false, true} {
+		for i, s := range seed {
+			if _, ok := matched[i]; ok {
+				continue
+			}
+			for j, e := range extracted {
+				if taken[j] {
+					continue
+				}
+				if (!byName && signature(s) == signature(e)) || (byName && s.Name == e.Name) {
+					matched[i], taken[j] = j, true
+					break
+				}
+			}
+		}
+	}
+
+	var out []models.Endpoint
+	for i, s := range seed {
+		e := models.Endpoint{}
+		if j, ok := matched[i]; ok {
+			e = extracted[j]
+			if e.Name != s.Name {
+				m.renamed["endpoints."+s.Name] = "endpoints." + e.Name
+			}
+		}
+		out = append(out, m.endpoint(s.Name, s, e))
+	}
+
+	for j, e := range extracted {
+		if taken[j] || m.seed.IsLocked("endpoints."+e.Name) {
+			continue
+		}
+		out = append(out, m.endpoint(e.Name, models.Endpoint{}, e))
+	}
+
+	return out
+}
+
+func (m *merger) endpoint(name string, seed, extracted models.Endpoint) models.Endpoint {
+	path := "endpoints." + name
+
+	return models.Endpoint{
+		Name:        name,
+		Method:      pick(m, path+".method", seed.Method, extracted.Method),
+		Path:        pick(m, path+".path", seed.Path, extracted.Path),
+		Description: pick(m, path+".description", seed.Description, extracted.Description),
+		Parameters:  pick(m, path+".parameters", seed.Parameters, extracted.Parameters),
+		Request:     m.schema(path+".request", seed.Request, extracted.Request),
+		Response:    m.schema(path+".response", seed.Response, extracted.Response),
+		Pagination:  pick(m, path+".pagination", seed.Pagination, extracted.Pagination),
+	}
+}
+
+func (m *merger) schemas() map[string]*models.Schema {
+	names := slices.Collect(maps.Keys(m.seed.Schemas))
+	for name := range m.extracted.Schemas {
+		if _, ok := m.seed.Schemas[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	out := map[string]*models.Schema{}
+	for _, name := range names {
+		if schema := m.schema("schemas."+name, m.seed.Schemas[name], m.extracted.Schemas[name]); schema != nil {
+			out[name] = schema
+		}
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+/*
+schema merges a schema property by property, so a model the extraction only
+partly described keeps the properties the seed knew about. Everything but the
+properties is decided as a whole.
+*/
+func (m *merger) schema(path string, seed, extracted *models.Schema) *models.Schema {
+	// A lock on the schema's properties doesn't lock the rest of it.
+	properties := path + ".properties."
+	out := decide(m, path, m.locked(path, properties), shell(seed), shell(extracted))
+	if out == nil {
+		return nil
+	}
+
+	var names []string
+	if seed != nil {
+		names = slices.Collect(maps.Keys(seed.Properties))
+	}
+	if extracted != nil {
+		for name := range extracted.Properties {
+			if seed == nil || seed.Properties[name] == nil {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		var s, e *models.Schema
+		if seed != nil {
+			s = seed.Properties[name]
+		}
+		if extracted != nil {
+			e = extracted.Properties[name]
+		}
+
+		if property := m.schema(properties+name, s, e); property != nil {
+			if out.Properties == nil {
+				out.Properties = map[string]*models.Schema{}
+			}
+			out.Properties[name] = property
+		}
+	}
+
+	return out
+}
+
+func (m *merger) jobs() []models.Job {
+	var out []models.Job
+	seen := map[string]bool{}
+
+	for _, s := range m.seed.Jobs {
+		seen[s.Name] = true
+		e := models.Job{}
+		for _, job := range m.extracted.Jobs {
+			if job.Name == s.Name {
+				e = job
+				break
+			}
+		}
+		out = append(out, pick(m, "jobs."+s.Name, s, e))
+	}
+
+	for _, e := range m.extracted.Jobs {
+		if !seen[e.Name] && !m.seed.IsLocked("jobs."+e.Name) {
+			out = append(out, pick(m, "jobs."+e.Name, models.Job{}, e))
+		}
+	}
+
+	if out == nil {
+		return []models.Job{}
+	}
+	return out
+}
+
+// shell returns a copy of a schema without its properties
+func shell(schema *models.Schema) *models.Schema {
+	if schema == nil {
+		return nil
+	}
+	c := *schema
+	c.Properties = nil
+	return &c
+}
+
+func isZero(v any) bool {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() || value.IsZero() {
+		return true
+	}
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return false
+}
+
+// signature identifies an endpoint by its method and path, whatever its path parameters are called
+func signature(endpoint models.Endpoint) string {
+	method := strings.ToUpper(endpoint.Method)
+	if method == "" {
+		method = "GET"
+	}
+	return method + " " + strings.TrimRight(pathParameter.ReplaceAllString(endpoint.Path, "{}"), "/")
+}
@@ -0,0 +1,98 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func bearer(token string) models.Auth {
+	return models.Auth{Type: models.AuthBearer, Bearer: &models.BearerAuth{Token: token}}
+}
+
+func TestMergeKeepsSeedSettingsTheExtractionDoesntCite(t *testing.T) {
+	seed := &models.APIConfig{
+		Integration: "dyflexis",
+		AccountID:   "acme",
+		BaseURL:     "https://api.example.com/v3",
+		Auth:        bearer("{{env.TOKEN}}"),
+	}
+	cited := models.Provenance{Source: models.SourceExtracted, URL: "https://docs.example.com/auth", ToolCallID: "call_1"}
+
+	tests := []struct {
+		name      string
+		extracted *models.APIConfig
+		want      *models.APIConfig
+		sources   map[string]string
+	}{
+		{
+			name: "no auth and uncited settings",
+			extracted: &models.APIConfig{
+				Integration: "Dyflexis API",
+				AccountID:   "example",
+				BaseURL:     "https://example.com",
+				Auth:        models.Auth{Type: models.AuthNone},
+				Provenance:  map[string]models.Provenance{"base_url": {Source: models.SourceExtracted}},
+			},
+			want:    seed,
+			sources: map[string]string{"integration": models.SourceSeed, "account_id": models.SourceSeed, "base_url": models.SourceSeed, "auth": models.SourceSeed},
+		},
+		{
+			name:      "empty auth",
+			extracted: &models.APIConfig{},
+			want:      seed,
+			sources:   map[string]string{"auth": models.SourceSeed},
+		},
+		{
+			name:      "uncited auth",
+			extracted: &models.APIConfig{Auth: bearer("made-up")},
+			want:      seed,
+			sources:   map[string]string{"auth": models.SourceSeed},
+		},
+		{
+			name: "cited settings",
+			extracted: &models.APIConfig{
+				BaseURL:    "https://api.example.com/v4",
+				Auth:       models.Auth{Type: models.AuthAPIKey, APIKey: &models.APIKeyAuth{In: "header", Name: "X-Api-Key", Value: "{{env.API_KEY}}"}},
+				Provenance: map[string]models.Provenance{"base_url": cited, "auth.api_key": cited},
+			},
+			want: &models.APIConfig{
+				Integration: seed.Integration,
+				AccountID:   seed.AccountID,
+				BaseURL:     "https://api.example.com/v4",
+				Auth:        models.Auth{Type: models.AuthAPIKey, APIKey: &models.APIKeyAuth{In: "header", Name: "X-Api-Key", Value: "{{env.API_KEY}}"}},
+			},
+			sources: map[string]string{"integration": models.SourceSeed, "base_url": models.SourceExtracted, "auth": models.SourceExtracted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := Merge(seed, tt.extracted)
+
+			if out.Integration != tt.want.Integration || out.AccountID != tt.want.AccountID || out.BaseURL != tt.want.BaseURL {
+				t.Errorf("settings = %q %q %q, want %q %q %q", out.Integration, out.AccountID, out.BaseURL, tt.want.Integration, tt.want.AccountID, tt.want.BaseURL)
+			}
+			if out.Auth.Type != tt.want.Auth.Type || (out.Auth.Bearer == nil) != (tt.want.Auth.Bearer == nil) || (out.Auth.Bearer != nil && *out.Auth.Bearer != *tt.want.Auth.Bearer) {
+				t.Errorf("auth = %+v, want %+v", out.Auth, tt.want.Auth)
+			}
+			for path, source := range tt.sources {
+				if got := out.Provenance[path].Source; got != source {
+					t.Errorf("provenance of %s = %q, want %q", path, got, source)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeFillsInWhatTheSeedLacks(t *testing.T) {
+	out := Merge(&models.APIConfig{Auth: models.Auth{Type: models.AuthNone}}, &models.APIConfig{BaseURL: "https://api.example.com", Auth: bearer("{{env.TOKEN}}")})
+
+	if out.BaseURL != "https://api.example.com" || out.Auth.Type != models.AuthBearer {
+		t.Errorf("got base URL %q and auth %q, want the extraction's", out.BaseURL, out.Auth.Type)
+	}
+
+	if out = Merge(&models.APIConfig{}, &models.APIConfig{Auth: models.Auth{Type: models.AuthNone}}); out.Auth.Type != models.AuthNone {
+		t.Errorf("auth type = %q, want none when neither has auth", out.Auth.Type)
+	}
+}
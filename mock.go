@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/mock"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+)
+
+// mockCommand serves an APIConfig's endpoints from a local mock server
+func mockCommand(args []string) error {
+	flags := flag.NewFlagSet("mock", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8080", "Address to listen on")
+	samples := flags.String("samples", "", "Serve the responses recorded on this cassette instead of fake data where they match")
+	items := flags.Int("items", 25, "Number of items paginated endpoints serve without samples")
+	seed := flags.Uint64("seed", 1, "Seed of the fake data")
+	configOut := flags.String("config", "", "Write a copy of the config pointed at the mock server to this file")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: milkshake mock [flags] config.json")
+	}
+
+	config, err := models.LoadAPIConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	server, err := mock.New(config)
+	if err != nil {
+		return err
+	}
+	server.WithItems(*items).WithSeed(*seed)
+
+	if *samples != "" {
+		cassette, err := request.NewCassette(*samples, request.ModeReplay)
+		if err != nil {
+			return fmt.Errorf("error loading samples: %w", err)
+		}
+		server.WithSamples(cassette.Interactions)
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", *addr, err)
+	}
+
+	serverURL := "http://" + listener.Addr().String()
+
+	if *configOut != "" {
+		mocked, err := server.Config(serverURL)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(mocked, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding config: %w", err)
+		}
+		if err := write(*configOut, append(data, '\n')); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range server.Routes() {
+		log.Info("Serving endpoint", "route", route)
+	}
+	log.Info("Mock server listening", "url", serverURL, "integration", config.Integration)
+
+	return http.Serve(listener, server)
+}
@@ -0,0 +1,294 @@
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// tokenLifetime is the lifetime in seconds the server reports for the tokens it issues
+const tokenLifetime = 3600
+
+// sessionCookie is the cookie session logins without a token path get
+const sessionCookie = "session"
+
+/*
+authenticator enforces the auth of a config. Static credentials must match the
+values their {{env.NAME}} placeholders resolve to, or be present at all when the
+variable isn't set. OAuth2 tokens and session logins are served by the server
+itself, and only the tokens it issued are accepted afterwards.
+*/
+type authenticator struct {
+	config      models.Auth
+	kind        string
+	tokenPath   string
+	loginPath   string
+	loginMethod string
+	mu          sync.Mutex
+	tokens      map[string]bool
+	refresh     map[string]bool
+}
+
+func newAuthenticator(config *models.APIConfig, localPath func(string) string) (*authenticator, error) {
+	a := &authenticator{
+		config:  config.Auth,
+		kind:    auth.Type(config.Auth),
+		tokens:  map[string]bool{},
+		refresh: map[string]bool{},
+	}
+
+	switch a.kind {
+	case models.AuthNone:
+	case models.AuthAPIKey:
+		if config.Auth.APIKey == nil || config.Auth.APIKey.Name == "" {
+			return nil, fmt.Errorf("api_key auth requires api_key.name")
+		}
+	case models.AuthBasic:
+		if config.Auth.Basic == nil {
+			return nil, fmt.Errorf("basic auth requires basic.username")
+		}
+	case models.AuthBearer:
+		if config.Auth.Bearer == nil {
+			return nil, fmt.Errorf("bearer auth requires bearer.token")
+		}
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		if config.Auth.OAuth2 == nil || config.Auth.OAuth2.TokenURL == "" {
+			return nil, fmt.Errorf("%s auth requires oauth2.token_url", a.kind)
+		}
+		a.tokenPath = localPath(config.Auth.OAuth2.TokenURL)
+	case models.AuthSession:
+		if config.Auth.Endpoint == "" {
+			return nil, fmt.Errorf("session auth requires a login endpoint")
+		}
+		a.loginPath = localPath(config.Auth.Endpoint)
+		a.loginMethod = strings.ToUpper(defaultString(config.Auth.Method, http.MethodPost))
+	default:
+		return nil, fmt.Errorf("unknown auth type: %s", config.Auth.Type)
+	}
+
+	return a, nil
+}
+
+// serve answers token and login requests, reporting whether the request was one
+func (a *authenticator) serve(w http.ResponseWriter, r *http.Request) bool {
+	switch {
+	case a.tokenPath != "" && r.URL.Path == a.tokenPath && r.Method == http.MethodPost:
+		a.token(w, r)
+	case a.loginPath != "" && r.URL.Path == a.loginPath && r.Method == a.loginMethod:
+		a.login(w, r)
+	default:
+		return false
+	}
+	return true
+}
+
+// check returns why a request's credentials are rejected, nil when they are accepted
+func (a *authenticator) check(r *http.Request) error {
+	switch a.kind {
+	case models.AuthAPIKey:
+		key := a.config.APIKey
+		got, ok := strings.CutPrefix(credential(r, key.In, key.Name), key.Prefix)
+		if !ok || !matches(got, key.Value) {
+			return fmt.Errorf("missing or invalid API key in %s %s", defaultString(key.In, "header"), key.Name)
+		}
+	case models.AuthBasic:
+		username, password, ok := r.BasicAuth()
+		if !ok || !matches(username, a.config.Basic.Username) || !matches(password, a.config.Basic.Password) {
+			return fmt.Errorf("missing or invalid basic credentials")
+		}
+	case models.AuthBearer:
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !matches(token, a.config.Bearer.Token) {
+			return fmt.Errorf("missing or invalid bearer token")
+		}
+	case models.AuthOAuth2ClientCredentials, models.AuthOAuth2AuthorizationCode:
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !a.issued(a.tokens, token) {
+			return fmt.Errorf("missing or invalid access token, request one at %s", a.tokenPath)
+		}
+	case models.AuthSession:
+		if !a.issued(a.tokens, a.sessionToken(r)) {
+			return fmt.Errorf("missing or invalid session, log in at %s %s", a.loginMethod, a.loginPath)
+		}
+	}
+	return nil
+}
+
+/*
+token answers an OAuth2 token request for the flow of the config. The client has
+to authenticate the way client_auth says, and codes and refresh tokens have to be
+the configured ones or ones the server issued.
+*/
+func (a *authenticator) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		fail(w, http.StatusBadRequest, fmt.Sprintf("invalid token request: %v", err))
+		return
+	}
+
+	o := a.config.OAuth2
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if !matches(clientID, o.ClientID) || !matches(clientSecret, o.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+		return
+	}
+
+	grant := r.PostForm.Get("grant_type")
+	switch {
+	case grant == "client_credentials" && a.kind == models.AuthOAuth2ClientCredentials:
+	case grant == "authorization_code" && a.kind == models.AuthOAuth2AuthorizationCode:
+		if !matches(r.PostForm.Get("code"), o.Code) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+			return
+		}
+	case grant == "refresh_token" && a.kind == models.AuthOAuth2AuthorizationCode:
+		if token := r.PostForm.Get("refresh_token"); !a.issued(a.refresh, token) && !matches(token, o.RefreshToken) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "unsupported_grant_type"})
+		return
+	}
+
+	response := map[string]any{
+		"access_token": a.issue(a.tokens),
+		"token_type":   "Bearer",
+		"expires_in":   tokenLifetime,
+	}
+	if len(o.Scopes) > 0 {
+		response["scope"] = strings.Join(o.Scopes, " ")
+	}
+	if a.kind == models.AuthOAuth2AuthorizationCode {
+		response["refresh_token"] = a.issue(a.refresh)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+/*
+login answers the session login request. The fields of the configured login body
+that resolve to a value have to be sent with that value. The response carries the
+new token at the token path, and sets it as a cookie for configs without one.
+*/
+func (a *authenticator) login(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(a.config.Inputs) > 0 {
+		input := a.config.Inputs[0]
+		for name, value := range input.Body {
+			want, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if !hasField(body, name) || !matches(field(body, name), want) {
+				fail(w, http.StatusUnauthorized, fmt.Sprintf("missing or invalid login field %s", name))
+				return
+			}
+		}
+	}
+
+	token := a.issue(a.tokens)
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", HttpOnly: true})
+
+	var doc any = map[string]any{}
+	if s := a.config.Session; s != nil {
+		if s.TokenPath != "" {
+			doc = setPath(doc, s.TokenPath, token)
+		}
+		if s.ExpiresInPath != "" {
+			doc = setPath(doc, s.ExpiresInPath, tokenLifetime)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// sessionToken finds the session token of a request where the config says it is sent, or in the session cookie
+func (a *authenticator) sessionToken(r *http.Request) string {
+	s := a.config.Session
+	if s == nil || s.TokenPath == "" {
+		return credential(r, "cookie", sessionCookie)
+	}
+
+	name, prefix := s.Name, s.Prefix
+	if name == "" {
+		name = "Authorization"
+	}
+	if prefix == "" && strings.EqualFold(name, "Authorization") {
+		prefix = "Bearer "
+	}
+
+	token, _ := strings.CutPrefix(credential(r, s.In, name), prefix)
+	return token
+}
+
+// issue creates a random token and remembers it in tokens
+func (a *authenticator) issue(tokens map[string]bool) string {
+	data := make([]byte, 16)
+	rand.Read(data)
+	token := hex.EncodeToString(data)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	tokens[token] = true
+
+	return token
+}
+
+func (a *authenticator) issued(tokens map[string]bool, token string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return token != "" && tokens[token]
+}
+
+// credential reads a credential from a header, query parameter or cookie
+func credential(r *http.Request, in, name string) string {
+	switch strings.ToLower(in) {
+	case "query":
+		return r.URL.Query().Get(name)
+	case "cookie":
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+	return r.Header.Get(name)
+}
+
+/*
+matches reports whether a credential is acceptable for the configured value. The
+value's placeholders are resolved against the environment, and when some can't be
+resolved any non-empty credential will do, so the server runs without secrets.
+Nothing configured accepts anything.
+*/
+func matches(got, configured string) bool {
+	if configured == "" {
+		return true
+	}
+
+	want := utils.RenderString(configured, nil)
+	if strings.Contains(want, "{{") {
+		return got != ""
+	}
+	return got == want
+}
@@ -0,0 +1,262 @@
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+const (
+	// defaultItems is how many items a paginated endpoint serves in total without samples
+	defaultItems = 25
+	// defaultPageSize is the page size when neither the request nor the pagination sets one
+	defaultPageSize = 10
+)
+
+/*
+page serves one page of a paginated endpoint. The page to serve is read from the
+request's query or body the way the pagination says it is sent, and the response
+hands out what the client needs for the next page: a cursor, a next URL or a Link
+header, which are left out once the last page is reached.
+*/
+func (s *Server) page(w http.ResponseWriter, r *http.Request, route *route, body any) {
+	p := *route.endpoint.Pagination
+	template, pool := s.pageData(route)
+
+	param := func(name string) string {
+		if value := r.URL.Query().Get(name); value != "" {
+			return value
+		}
+		return field(body, name)
+	}
+
+	size := p.Limit
+	if n, err := strconv.Atoi(param(p.LimitParam)); err == nil && n > 0 && p.LimitParam != "" {
+		size = n
+	}
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	pageParam := defaultString(p.PageParam, "page")
+	startPage := p.StartPage
+	if startPage == 0 {
+		startPage = 1
+	}
+
+	page := startPage
+	if n, err := strconv.Atoi(param(pageParam)); err == nil {
+		page = n
+	}
+
+	var offset int
+	switch p.Type {
+	case models.PaginationPage, models.PaginationLinkHeader, models.PaginationNextURL:
+		offset = (page - startPage) * size
+	case models.PaginationOffset:
+		offset, _ = strconv.Atoi(param(defaultString(p.OffsetParam, "offset")))
+	case models.PaginationCursor:
+		var err error
+		if offset, err = decodeCursor(param(p.CursorParam)); err != nil {
+			fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		fail(w, http.StatusInternalServerError, fmt.Sprintf("unknown pagination type %q", p.Type))
+		return
+	}
+
+	offset = max(0, min(offset, len(pool)))
+	end := min(offset+size, len(pool))
+	items := append([]any{}, pool[offset:end]...)
+	more := end < len(pool)
+
+	var doc any = items
+	if p.ItemsPath != "" {
+		doc = setPath(clone(template), p.ItemsPath, items)
+	}
+
+	if p.TotalPath != "" {
+		doc = setPath(doc, p.TotalPath, len(pool))
+	}
+
+	switch p.Type {
+	case models.PaginationCursor:
+		var cursor any
+		if more {
+			cursor = encodeCursor(end)
+		}
+		doc = setPath(doc, p.CursorPath, cursor)
+	case models.PaginationNextURL:
+		var next any
+		if more {
+			next = nextURL(r, pageParam, page+1)
+		}
+		doc = setPath(doc, p.NextURLPath, next)
+	case models.PaginationLinkHeader:
+		if more {
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextURL(r, pageParam, page+1)))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, doc)
+}
+
+/*
+pageData returns the response every page is built on, and all items there are to
+page through. Recorded samples provide both when there are any, the items of
+all recorded pages together. Otherwise they are faked, each item with its own
+seed so pages always hold the same items.
+*/
+func (s *Server) pageData(route *route) (any, []any) {
+	p := route.endpoint.Pagination
+
+	var (
+		template any
+		pool     []any
+	)
+
+	for _, sample := range route.samples {
+		if sample.doc == nil {
+			continue
+		}
+		if template == nil {
+			template = sample.doc
+		}
+		pool = append(pool, pageItems(sample.doc, p.ItemsPath)...)
+	}
+	if template != nil {
+		return template, pool
+	}
+
+	if route.endpoint.Response != nil {
+		template = s.faker(route.endpoint.Name).Fake(route.endpoint.Response)
+	}
+
+	item := s.itemSchema(route.endpoint)
+	for i := range s.items {
+		if item == nil {
+			pool = append(pool, map[string]any{"id": i + 1})
+			continue
+		}
+		pool = append(pool, s.faker(route.endpoint.Name, strconv.Itoa(i)).Fake(item))
+	}
+
+	return template, pool
+}
+
+// itemSchema finds the schema of the items of a page, following the items path through the response schema
+func (s *Server) itemSchema(endpoint models.Endpoint) *models.Schema {
+	current, _ := s.config.ResolveSchema(endpoint.Response)
+
+	segments, err := utils.ParsePath(endpoint.Pagination.ItemsPath)
+	if err != nil {
+		return nil
+	}
+
+	for _, segment := range segments {
+		switch {
+		case current == nil:
+			return nil
+		case current.Type == "array":
+			current = current.Items
+		default:
+			current = current.Properties[segment]
+		}
+		current, _ = s.config.ResolveSchema(current)
+	}
+
+	if current == nil || current.Type != "array" {
+		return nil
+	}
+	return current.Items
+}
+
+// pageItems returns the items in a page, found at the items path or as the page itself
+func pageItems(doc any, path string) []any {
+	if path != "" {
+		doc, _ = utils.Lookup(doc, path)
+	}
+	items, _ := doc.([]any)
+	return items
+}
+
+// setPath sets the value at a path in decoded JSON, creating objects along the way
+func setPath(doc any, path string, value any) any {
+	segments, err := utils.ParsePath(path)
+	if err != nil || len(segments) == 0 {
+		return value
+	}
+
+	object, ok := doc.(map[string]any)
+	if !ok {
+		object = map[string]any{}
+	}
+	object[segments[0]] = setPath(object[segments[0]], strings.Join(segments[1:], "."), value)
+	return object
+}
+
+// clone deep copies decoded JSON, so pages never change the response they are built on
+func clone(doc any) any {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil
+	}
+
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// nextURL is the absolute URL of the request with the page parameter set to the next page
+func nextURL(r *http.Request, param string, page int) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	query := r.URL.Query()
+	query.Set(param, strconv.Itoa(page))
+
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// encodeCursor turns an offset into an opaque cursor, so clients can't rely on what's in it
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset a cursor stands for, 0 for no cursor
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if value, ok := strings.CutPrefix(string(data), "offset:"); ok {
+			if offset, err := strconv.Atoi(value); err == nil {
+				return offset, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
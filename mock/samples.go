@@ -0,0 +1,41 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+)
+
+// sample is a recorded response the server serves in place of fake data
+type sample struct {
+	path        string
+	status      int
+	contentType string
+	body        string
+	doc         any
+}
+
+func newSample(path string, response request.RecordedResponse) sample {
+	s := sample{
+		path:        path,
+		status:      response.StatusCode,
+		contentType: response.Headers.Get("Content-Type"),
+		body:        response.Body,
+	}
+
+	if err := json.Unmarshal([]byte(response.Body), &s.doc); err != nil {
+		s.doc = nil
+	}
+
+	return s
+}
+
+// write serves the sample as it was recorded
+func (s sample) write(w http.ResponseWriter) {
+	if s.contentType != "" {
+		w.Header().Set("Content-Type", s.contentType)
+	}
+	w.WriteHeader(s.status)
+	w.Write([]byte(s.body))
+}
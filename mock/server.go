@@ -0,0 +1,449 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/export"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+	"github.com/theapemachine/idrinkyourmilkshake/schema"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// pathParameter matches OpenAPI style path parameters such as {id}
+var pathParameter = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+/*
+Server is an http.Handler that stands in for the API an APIConfig describes. It
+serves every endpoint with fake data that conforms to the endpoint's response
+schema, or with recorded samples, enforces the config's auth, and pages results
+the way the endpoint's pagination says, so integrations can be developed and
+tested against it before there are credentials for the real API.
+*/
+type Server struct {
+	config *models.APIConfig
+	base   *url.URL
+	routes []*route
+	auth   *authenticator
+	items  int
+	seed   uint64
+}
+
+// route is an endpoint the server answers, with the path it lives at on the server
+type route struct {
+	endpoint models.Endpoint
+	method   string
+	path     string
+	pattern  *regexp.Regexp
+	samples  []sample
+}
+
+// New creates a Server for the given config
+func New(config *models.APIConfig) (*Server, error) {
+	base, err := url.Parse(config.BaseURL)
+	if err != nil || !base.IsAbs() {
+		return nil, fmt.Errorf("mock server needs an absolute base URL, got %q", config.BaseURL)
+	}
+
+	s := &Server{config: config, base: base, items: defaultItems, seed: 1}
+
+	for _, endpoint := range export.Endpoints(config) {
+		path, ok := s.localPath(endpoint.Path)
+		if !ok {
+			log.Warn("Not serving endpoint on another host", "endpoint", endpoint.Name, "path", endpoint.Path)
+			continue
+		}
+
+		method := strings.ToUpper(endpoint.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		s.routes = append(s.routes, &route{endpoint: endpoint, method: method, path: path, pattern: pattern(path)})
+	}
+
+	// Literal paths such as /employees/me go before the parameters that would match them.
+	slices.SortStableFunc(s.routes, func(a, b *route) int {
+		return strings.Count(a.path, "{") - strings.Count(b.path, "{")
+	})
+
+	if s.auth, err = newAuthenticator(config, s.authPath); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// WithItems sets how many items paginated endpoints serve in total, when there are no samples for them
+func (s *Server) WithItems(items int) *Server {
+	s.items = items
+	return s
+}
+
+// WithSeed sets the seed of the fake data, so different seeds serve different data
+func (s *Server) WithSeed(seed uint64) *Server {
+	s.seed = seed
+	return s
+}
+
+/*
+WithSamples serves the successful responses of recorded interactions, e.g. from a
+cassette, instead of fake data for the endpoints they match. The items of all
+recorded pages of a paginated endpoint are paged anew.
+*/
+func (s *Server) WithSamples(interactions []*request.Interaction) *Server {
+	for _, interaction := range interactions {
+		if interaction.Response.StatusCode < 200 || interaction.Response.StatusCode >= 300 {
+			continue
+		}
+
+		u, err := url.Parse(interaction.Request.URL)
+		if err != nil {
+			continue
+		}
+
+		if r := s.match(interaction.Request.Method, u.Path); r != nil {
+			r.samples = append(r.samples, newSample(u.Path, interaction.Response))
+		}
+	}
+
+	for _, r := range s.routes {
+		if len(r.samples) > 0 {
+			log.Info("Serving recorded samples", "endpoint", r.endpoint.Name, "samples", len(r.samples))
+		}
+	}
+
+	return s
+}
+
+/*
+Config returns a copy of the config pointed at the server running at serverURL:
+the base URL, the token and login endpoints, and steps calling the API by its
+absolute URL all move to the server, so the runner can use it as the API.
+*/
+func (s *Server) Config(serverURL string) (*models.APIConfig, error) {
+	server, err := url.Parse(strings.TrimRight(serverURL, "/"))
+	if err != nil || !server.IsAbs() {
+		return nil, fmt.Errorf("invalid server URL %q", serverURL)
+	}
+
+	data, err := json.Marshal(s.config)
+	if err != nil {
+		return nil, fmt.Errorf("error copying config: %w", err)
+	}
+
+	var config models.APIConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error copying config: %w", err)
+	}
+
+	move := func(value string) string {
+		u, err := url.Parse(value)
+		if err != nil || !u.IsAbs() {
+			return value
+		}
+		u.Scheme, u.Host = server.Scheme, server.Host
+		return u.String()
+	}
+
+	config.BaseURL = move(config.BaseURL)
+	config.Auth.Endpoint = move(config.Auth.Endpoint)
+	if config.Auth.OAuth2 != nil {
+		config.Auth.OAuth2.TokenURL = move(config.Auth.OAuth2.TokenURL)
+	}
+
+	for i, endpoint := range config.Endpoints {
+		if u, err := url.Parse(endpoint.Path); err == nil && strings.EqualFold(u.Host, s.base.Host) {
+			config.Endpoints[i].Path = move(endpoint.Path)
+		}
+	}
+	for _, job := range config.Jobs {
+		for i, step := range job.Steps {
+			if u, err := url.Parse(step.Endpoint); err == nil && strings.EqualFold(u.Host, s.base.Host) {
+				job.Steps[i].Endpoint = move(step.Endpoint)
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+// Routes lists the method and path of every endpoint the server answers
+func (s *Server) Routes() []string {
+	var out []string
+	for _, r := range s.routes {
+		out = append(out, r.method+" "+r.path)
+	}
+	return out
+}
+
+/*
+ServeHTTP answers token and login requests, then finds the endpoint a request is
+for and checks its credentials, required parameters and body before serving a
+response. Whatever the server rejects gets a JSON error saying why.
+*/
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		log.Info("Mock request", "method", r.Method, "path", r.URL.Path, "status", rec.status)
+	}()
+
+	if s.auth.serve(rec, r) {
+		return
+	}
+
+	route := s.match(r.Method, r.URL.Path)
+	if route == nil {
+		s.notFound(rec, r)
+		return
+	}
+
+	if err := s.auth.check(r); err != nil {
+		fail(rec, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		fail(rec, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if problems := s.validate(route, r, body); len(problems) > 0 {
+		fail(rec, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+
+	if route.endpoint.Pagination != nil {
+		s.page(rec, r, route, body)
+		return
+	}
+
+	s.respond(rec, r, route)
+}
+
+// match finds the route for a request, nil when no endpoint lives at the path with that method
+func (s *Server) match(method, path string) *route {
+	for _, r := range s.routes {
+		if strings.EqualFold(r.method, method) && r.pattern.MatchString(path) {
+			return r
+		}
+	}
+	return nil
+}
+
+// notFound answers 405 when the path exists with other methods, and 404 otherwise
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, route := range s.routes {
+		if route.pattern.MatchString(r.URL.Path) && !slices.Contains(allowed, route.method) {
+			allowed = append(allowed, route.method)
+		}
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		fail(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+
+	fail(w, http.StatusNotFound, fmt.Sprintf("no endpoint at %s", r.URL.Path))
+}
+
+// validate checks that a request has the required query and header parameters, and a body that matches the request schema
+func (s *Server) validate(route *route, r *http.Request, body any) []string {
+	var problems []string
+
+	for _, parameter := range route.endpoint.Parameters {
+		if !parameter.Required {
+			continue
+		}
+
+		switch parameter.In {
+		case "query":
+			if !r.URL.Query().Has(parameter.Name) && !hasField(body, parameter.Name) {
+				problems = append(problems, fmt.Sprintf("missing query parameter %s", parameter.Name))
+			}
+		case "header":
+			if r.Header.Get(parameter.Name) == "" {
+				problems = append(problems, fmt.Sprintf("missing header %s", parameter.Name))
+			}
+		}
+	}
+
+	if body != nil && route.endpoint.Request != nil {
+		problems = append(problems, schema.Validate(s.config, route.endpoint.Request, body)...)
+	}
+
+	return problems
+}
+
+/*
+respond serves a response for an endpoint that doesn't paginate. A sample
+recorded for the same path wins over one recorded for another path, and fake
+data is seeded with the path, so every resource looks the same on every call.
+*/
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, route *route) {
+	if len(route.samples) > 0 {
+		chosen := route.samples[0]
+		for _, sample := range route.samples {
+			if sample.path == r.URL.Path {
+				chosen = sample
+				break
+			}
+		}
+		chosen.write(w)
+		return
+	}
+
+	if route.endpoint.Response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.faker(route.endpoint.Name, r.URL.Path).Fake(route.endpoint.Response))
+}
+
+// faker creates a Faker whose data only depends on the server's seed and the given keys
+func (s *Server) faker(keys ...string) *schema.Faker {
+	hash := fnv.New64a()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+	}
+	return schema.NewFaker(s.config, s.seed^hash.Sum64())
+}
+
+/*
+localPath is where an endpoint lives on the server: relative paths are joined to
+the path of the base URL, and absolute URLs keep their path as long as they point
+at the API's own host.
+*/
+func (s *Server) localPath(path string) (string, bool) {
+	path, _, _ = strings.Cut(path, "?")
+
+	u, err := url.Parse(path)
+	if err == nil && u.IsAbs() {
+		if !strings.EqualFold(u.Host, s.base.Host) {
+			return "", false
+		}
+		return "/" + strings.TrimLeft(u.Path, "/"), true
+	}
+
+	return strings.TrimRight(s.base.Path, "/") + "/" + strings.TrimLeft(path, "/"), true
+}
+
+// authPath is where a token URL or login lives on the server, which serves them whatever host they are on
+func (s *Server) authPath(path string) string {
+	if u, err := url.Parse(path); err == nil && u.IsAbs() {
+		return "/" + strings.TrimLeft(u.Path, "/")
+	}
+
+	local, _ := s.localPath(path)
+	return local
+}
+
+// pattern matches the paths of a route, with anything but a slash in place of its path parameters
+func pattern(path string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+
+	last := 0
+	for _, loc := range pathParameter.FindAllStringIndex(path, -1) {
+		b.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		b.WriteString("[^/]+")
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(path[last:]))
+
+	b.WriteString("/?$")
+	return regexp.MustCompile(b.String())
+}
+
+// readBody decodes a JSON or form body, returning nil when the request has none
+func readBody(r *http.Request) (any, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, nil
+	}
+
+	if strings.Contains(r.Header.Get("Content-Type"), "x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid form body: %w", err)
+		}
+
+		out := map[string]any{}
+		for key := range form {
+			out[key] = form.Get(key)
+		}
+		return out, nil
+	}
+
+	var body any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return body, nil
+}
+
+// hasField reports whether a decoded body is an object with the given field
+func hasField(body any, name string) bool {
+	object, ok := body.(map[string]any)
+	if !ok {
+		return false
+	}
+	_, ok = object[name]
+	return ok
+}
+
+// field returns a field of a decoded body as a string, the way a query parameter would hold it
+func field(body any, name string) string {
+	object, _ := body.(map[string]any)
+	return utils.Stringify(object[name])
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		fail(w, http.StatusInternalServerError, fmt.Sprintf("error encoding response: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// fail answers with a JSON error
+func fail(w http.ResponseWriter, status int, message string) {
+	data, _ := json.Marshal(map[string]any{"error": http.StatusText(status), "message": message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// recorder remembers the status of a response, for the request log
+type recorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
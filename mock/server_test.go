@@ -0,0 +1,348 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+	"github.com/theapemachine/idrinkyourmilkshake/schema"
+)
+
+// employee has an optional manager of its own type, so fake data has to stop somewhere
+var employee = &models.Schema{
+	Type:     "object",
+	Required: []string{"id", "name"},
+	Properties: map[string]*models.Schema{
+		"id":      {Type: "integer"},
+		"name":    {Type: "string"},
+		"email":   {Type: "string", Format: "email"},
+		"manager": models.SchemaRef("Employee"),
+	},
+}
+
+func newTestServer(t *testing.T, config *models.APIConfig) (*Server, *httptest.Server) {
+	t.Helper()
+
+	if config.BaseURL == "" {
+		config.BaseURL = "https://api.example.com/v1"
+	}
+	if config.Schemas == nil {
+		config.Schemas = map[string]*models.Schema{"Employee": employee}
+	}
+
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return s, server
+}
+
+// get sends a request to the mock and decodes its JSON response
+func get(t *testing.T, req *http.Request) (*http.Response, any) {
+	t.Helper()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	var doc any
+	data, _ := io.ReadAll(resp.Body)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("decoding %s: %v", data, err)
+		}
+	}
+	return resp, doc
+}
+
+func TestServerServesConformingData(t *testing.T) {
+	config := &models.APIConfig{Endpoints: []models.Endpoint{
+		{Name: "get_employee", Method: "GET", Path: "/employees/{id}", Response: models.SchemaRef("Employee")},
+	}}
+	_, server := newTestServer(t, config)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/employees/42", nil)
+	resp, doc := get(t, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if mismatches := schema.Validate(config, models.SchemaRef("Employee"), doc); len(mismatches) > 0 {
+		t.Errorf("response doesn't conform to the schema: %v", mismatches)
+	}
+
+	// The same resource looks the same on every call.
+	if _, again := get(t, req.Clone(req.Context())); fmt.Sprint(again) != fmt.Sprint(doc) {
+		t.Errorf("got %v, then %v", doc, again)
+	}
+}
+
+func TestServerEnforcesAuth(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "s3cret")
+
+	tests := []struct {
+		name string
+		auth models.Auth
+		// good adds working credentials to a request, logging in first where needed, bad adds wrong ones
+		good func(t *testing.T, server string, req *http.Request)
+		bad  func(req *http.Request)
+	}{
+		{
+			name: "api key",
+			auth: models.Auth{APIKey: &models.APIKeyAuth{In: "query", Name: "api_key", Value: "{{env.TEST_API_KEY}}"}},
+			good: func(t *testing.T, server string, req *http.Request) { req.URL.RawQuery = "api_key=s3cret" },
+			bad:  func(req *http.Request) { req.URL.RawQuery = "api_key=wrong" },
+		},
+		{
+			name: "api key from an unset variable",
+			auth: models.Auth{APIKey: &models.APIKeyAuth{Name: "X-Api-Key", Value: "{{env.TEST_UNSET_KEY}}"}},
+			good: func(t *testing.T, server string, req *http.Request) { req.Header.Set("X-Api-Key", "anything") },
+			bad:  func(req *http.Request) { req.Header.Set("X-Other", "anything") },
+		},
+		{
+			name: "basic",
+			auth: models.Auth{Basic: &models.BasicAuth{Username: "ada", Password: "{{env.TEST_API_KEY}}"}},
+			good: func(t *testing.T, server string, req *http.Request) { req.SetBasicAuth("ada", "s3cret") },
+			bad:  func(req *http.Request) { req.SetBasicAuth("ada", "wrong") },
+		},
+		{
+			name: "bearer",
+			auth: models.Auth{Bearer: &models.BearerAuth{Token: "{{env.TEST_API_KEY}}"}},
+			good: func(t *testing.T, server string, req *http.Request) { req.Header.Set("Authorization", "Bearer s3cret") },
+			bad:  func(req *http.Request) { req.Header.Set("Authorization", "Bearer wrong") },
+		},
+		{
+			name: "oauth2 client credentials",
+			auth: models.Auth{OAuth2: &models.OAuth2Auth{TokenURL: "https://auth.example.com/oauth/token", ClientID: "app", ClientSecret: "{{env.TEST_API_KEY}}"}},
+			good: func(t *testing.T, server string, req *http.Request) {
+				token, _ := http.NewRequest(http.MethodPost, server+"/oauth/token", strings.NewReader("grant_type=client_credentials"))
+				token.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				token.SetBasicAuth("app", "s3cret")
+				resp, doc := get(t, token)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("token request got %d: %v", resp.StatusCode, doc)
+				}
+				req.Header.Set("Authorization", fmt.Sprint("Bearer ", doc.(map[string]any)["access_token"]))
+			},
+			bad: func(req *http.Request) { req.Header.Set("Authorization", "Bearer s3cret") },
+		},
+		{
+			name: "session",
+			auth: models.Auth{
+				Endpoint: "/login",
+				Inputs:   []models.Input{{Body: map[string]any{"username": "ada", "password": "{{env.TEST_API_KEY}}"}}},
+				Session:  &models.SessionAuth{TokenPath: "$.data.token"},
+			},
+			good: func(t *testing.T, server string, req *http.Request) {
+				login, _ := http.NewRequest(http.MethodPost, server+"/v1/login", strings.NewReader(`{"username": "ada", "password": "s3cret"}`))
+				resp, doc := get(t, login)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("login got %d: %v", resp.StatusCode, doc)
+				}
+				req.Header.Set("Authorization", fmt.Sprint("Bearer ", doc.(map[string]any)["data"].(map[string]any)["token"]))
+			},
+			bad: func(req *http.Request) { req.Header.Set("Authorization", "Bearer s3cret") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, server := newTestServer(t, &models.APIConfig{
+				Auth:      tt.auth,
+				Endpoints: []models.Endpoint{{Name: "get_employee", Method: "GET", Path: "/employees/{id}", Response: models.SchemaRef("Employee")}},
+			})
+
+			for _, c := range []struct {
+				name   string
+				apply  func(req *http.Request)
+				status int
+			}{
+				{"without credentials", func(*http.Request) {}, http.StatusUnauthorized},
+				{"with wrong credentials", tt.bad, http.StatusUnauthorized},
+				{"with credentials", func(req *http.Request) { tt.good(t, server.URL, req) }, http.StatusOK},
+			} {
+				req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/employees/1", nil)
+				c.apply(req)
+				if resp, doc := get(t, req); resp.StatusCode != c.status {
+					t.Errorf("%s got %d, want %d: %v", c.name, resp.StatusCode, c.status, doc)
+				}
+			}
+		})
+	}
+}
+
+func TestServerPaginates(t *testing.T) {
+	list := &models.Schema{Type: "array", Items: models.SchemaRef("Employee")}
+	envelope := func(items, extra string) *models.Schema {
+		s := &models.Schema{Type: "object", Properties: map[string]*models.Schema{items: list}}
+		if extra != "" {
+			s.Properties[extra] = &models.Schema{Type: "string", Nullable: true}
+		}
+		return s
+	}
+
+	tests := []struct {
+		pagination models.Pagination
+		response   *models.Schema
+		// next returns the URL of the next page, "" when this was the last one
+		next func(resp *http.Response, doc any, current *url.URL, page int) string
+	}{
+		{
+			pagination: models.Pagination{Type: models.PaginationPage, ItemsPath: "$.data", LimitParam: "per_page", Limit: 10},
+			response:   envelope("data", ""),
+			next: func(resp *http.Response, doc any, current *url.URL, page int) string {
+				return withQuery(current, "page", fmt.Sprint(page+1))
+			},
+		},
+		{
+			pagination: models.Pagination{Type: models.PaginationOffset, ItemsPath: "$.data", Limit: 10, TotalPath: "$.total"},
+			response:   envelope("data", ""),
+			next: func(resp *http.Response, doc any, current *url.URL, page int) string {
+				return withQuery(current, "offset", fmt.Sprint(page*10))
+			},
+		},
+		{
+			pagination: models.Pagination{Type: models.PaginationCursor, ItemsPath: "$.data", CursorParam: "after", CursorPath: "$.next", Limit: 10},
+			response:   envelope("data", "next"),
+			next: func(resp *http.Response, doc any, current *url.URL, page int) string {
+				if cursor, ok := doc.(map[string]any)["next"].(string); ok {
+					return withQuery(current, "after", cursor)
+				}
+				return ""
+			},
+		},
+		{
+			pagination: models.Pagination{Type: models.PaginationLinkHeader, Limit: 10},
+			response:   list,
+			next: func(resp *http.Response, doc any, current *url.URL, page int) string {
+				link, _, _ := strings.Cut(resp.Header.Get("Link"), ">")
+				return strings.TrimPrefix(link, "<")
+			},
+		},
+		{
+			pagination: models.Pagination{Type: models.PaginationNextURL, ItemsPath: "$.results", NextURLPath: "$.next", Limit: 10},
+			response:   envelope("results", "next"),
+			next: func(resp *http.Response, doc any, current *url.URL, page int) string {
+				next, _ := doc.(map[string]any)["next"].(string)
+				return next
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pagination.Type, func(t *testing.T) {
+			config := &models.APIConfig{Endpoints: []models.Endpoint{
+				{Name: "list_employees", Method: "GET", Path: "/employees", Response: tt.response, Pagination: &tt.pagination},
+			}}
+			s, server := newTestServer(t, config)
+			s.WithItems(25)
+
+			seen := map[string]bool{}
+			pages := 0
+			for next := server.URL + "/v1/employees"; next != "" && pages < 10; pages++ {
+				req, _ := http.NewRequest(http.MethodGet, next, nil)
+				resp, doc := get(t, req)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("page %d got %d: %v", pages+1, resp.StatusCode, doc)
+				}
+
+				items := doc
+				if tt.pagination.ItemsPath != "" {
+					items = doc.(map[string]any)[strings.TrimPrefix(tt.pagination.ItemsPath, "$.")]
+				}
+				if len(items.([]any)) == 0 {
+					break
+				}
+				for _, item := range items.([]any) {
+					if mismatches := schema.Validate(config, models.SchemaRef("Employee"), item); len(mismatches) > 0 {
+						t.Errorf("item doesn't conform to the schema: %v", mismatches)
+					}
+					seen[fmt.Sprint(item)] = true
+				}
+
+				if tt.pagination.TotalPath != "" && doc.(map[string]any)["total"] != float64(25) {
+					t.Errorf("total = %v, want 25", doc.(map[string]any)["total"])
+				}
+
+				next = tt.next(resp, doc, req.URL, pages+1)
+			}
+
+			if len(seen) != 25 || pages != 3 {
+				t.Errorf("got %d distinct items in %d pages, want 25 in 3", len(seen), pages)
+			}
+		})
+	}
+}
+
+func TestServerReplaysSamples(t *testing.T) {
+	recorded := func(method, u string, status int, body string) *request.Interaction {
+		return &request.Interaction{
+			Request:  request.RecordedRequest{Method: method, URL: u},
+			Response: request.RecordedResponse{StatusCode: status, Headers: http.Header{"Content-Type": {"application/json"}}, Body: body},
+		}
+	}
+
+	s, server := newTestServer(t, &models.APIConfig{Endpoints: []models.Endpoint{
+		{Name: "get_employee", Method: "GET", Path: "/employees/{id}", Response: models.SchemaRef("Employee")},
+		{
+			Name: "list_employees", Method: "GET", Path: "/employees",
+			Response:   &models.Schema{Type: "object", Properties: map[string]*models.Schema{"data": {Type: "array", Items: models.SchemaRef("Employee")}}},
+			Pagination: &models.Pagination{Type: models.PaginationPage, ItemsPath: "$.data", LimitParam: "per_page", Limit: 2},
+		},
+	}})
+	s.WithSamples([]*request.Interaction{
+		recorded("GET", "https://api.example.com/v1/employees/1", 200, `{"id": 1, "name": "Ada"}`),
+		recorded("GET", "https://api.example.com/v1/employees/2", 200, `{"id": 2, "name": "Grace"}`),
+		recorded("GET", "https://api.example.com/v1/employees/3", 500, `{"error": "oops"}`),
+		recorded("GET", "https://api.example.com/v1/employees?page=1", 200, `{"data": [{"id": 1, "name": "Ada"}, {"id": 2, "name": "Grace"}], "meta": {"source": "recorded"}}`),
+		recorded("GET", "https://api.example.com/v1/employees?page=2", 200, `{"data": [{"id": 3, "name": "Edsger"}], "meta": {"source": "recorded"}}`),
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/v1/employees/1", `map[id:1 name:Ada]`},
+		{"/v1/employees/2", `map[id:2 name:Grace]`},
+		// Failed responses aren't served, another recording of the endpoint is.
+		{"/v1/employees/3", `map[id:1 name:Ada]`},
+		// Pages hold the recorded items anew.
+		{"/v1/employees?page=1&per_page=3", `map[data:[map[id:1 name:Ada] map[id:2 name:Grace] map[id:3 name:Edsger]] meta:map[source:recorded]]`},
+		{"/v1/employees?page=2&per_page=2", `map[data:[map[id:3 name:Edsger]] meta:map[source:recorded]]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			resp, doc := get(t, req)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if got := fmt.Sprint(doc); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// withQuery returns the URL with a query parameter set
+func withQuery(u *url.URL, name, value string) string {
+	next := *u
+	query := next.Query()
+	query.Set(name, value)
+	next.RawQuery = query.Encode()
+	return next.String()
+}
@@ -0,0 +1,78 @@
+package models
+
+// The authentication types an APIConfig can describe
+const (
+	AuthNone                    = "none"
+	AuthAPIKey                  = "api_key"
+	AuthBasic                   = "basic"
+	AuthBearer                  = "bearer"
+	AuthOAuth2ClientCredentials = "oauth2_client_credentials"
+	AuthOAuth2AuthorizationCode = "oauth2_authorization_code"
+	AuthSession                 = "session"
+)
+
+/*
+Auth represents authentication details for an API. Type selects the variant and
+the matching block holds its settings. Session logins are described by the
+endpoint, method, inputs and outputs of the login request itself. Secrets should
+be written as {{env.NAME}} placeholders rather than literal values.
+*/
+type Auth struct {
+	Type     string       `json:"type" jsonschema:"description=The authentication scheme,enum=none,enum=api_key,enum=basic,enum=bearer,enum=oauth2_client_credentials,enum=oauth2_authorization_code,enum=session"`
+	Endpoint string       `json:"endpoint,omitempty" jsonschema:"description=Login endpoint for session authentication"`
+	Method   string       `json:"method,omitempty" jsonschema:"description=HTTP method of the login request"`
+	Inputs   []Input      `json:"inputs,omitempty" jsonschema:"description=Headers and body of the login request"`
+	Outputs  []Output     `json:"outputs,omitempty" jsonschema:"description=Values to keep from the login response"`
+	APIKey   *APIKeyAuth  `json:"api_key,omitempty" jsonschema:"description=Settings for api_key authentication"`
+	Basic    *BasicAuth   `json:"basic,omitempty" jsonschema:"description=Settings for basic authentication"`
+	Bearer   *BearerAuth  `json:"bearer,omitempty" jsonschema:"description=Settings for bearer authentication"`
+	OAuth2   *OAuth2Auth  `json:"oauth2,omitempty" jsonschema:"description=Settings for both OAuth2 flows"`
+	Session  *SessionAuth `json:"session,omitempty" jsonschema:"description=How to use the token obtained by a session login"`
+}
+
+// APIKeyAuth sends a static key in a header, query parameter or cookie
+type APIKeyAuth struct {
+	In     string `json:"in" jsonschema:"description=Where the key is sent,enum=header,enum=query,enum=cookie,required"`
+	Name   string `json:"name" jsonschema:"description=Name of the header\\, query parameter or cookie,required"`
+	Value  string `json:"value" jsonschema:"description=The key\\, usually an {{env.NAME}} placeholder,required"`
+	Prefix string `json:"prefix,omitempty" jsonschema:"description=Prefix for the value\\, e.g. Token followed by a space"`
+}
+
+// BasicAuth sends a username and password using HTTP basic authentication
+type BasicAuth struct {
+	Username string `json:"username" jsonschema:"description=The username\\, usually an {{env.NAME}} placeholder,required"`
+	Password string `json:"password" jsonschema:"description=The password\\, usually an {{env.NAME}} placeholder,required"`
+}
+
+// BearerAuth sends a static token in the Authorization header
+type BearerAuth struct {
+	Token string `json:"token" jsonschema:"description=The token\\, usually an {{env.NAME}} placeholder,required"`
+}
+
+/*
+OAuth2Auth holds the settings for both OAuth2 flows. The client credentials flow
+only needs the token URL and client. The authorization code flow exchanges a code
+obtained out of band, or starts from a refresh token, and refreshes from then on.
+*/
+type OAuth2Auth struct {
+	TokenURL         string   `json:"token_url" jsonschema:"description=The token endpoint,required"`
+	AuthorizationURL string   `json:"authorization_url,omitempty" jsonschema:"description=The authorization endpoint users are sent to in the authorization code flow"`
+	ClientID         string   `json:"client_id" jsonschema:"description=The client ID\\, usually an {{env.NAME}} placeholder,required"`
+	ClientSecret     string   `json:"client_secret,omitempty" jsonschema:"description=The client secret\\, usually an {{env.NAME}} placeholder"`
+	ClientAuth       string   `json:"client_auth,omitempty" jsonschema:"description=How the client authenticates to the token endpoint\\, defaults to header,enum=header,enum=body"`
+	Scopes           []string `json:"scopes,omitempty" jsonschema:"description=Scopes to request"`
+	Audience         string   `json:"audience,omitempty" jsonschema:"description=Audience to request\\, for providers that need one"`
+	RedirectURL      string   `json:"redirect_url,omitempty" jsonschema:"description=Redirect URL registered for the authorization code flow"`
+	Code             string   `json:"code,omitempty" jsonschema:"description=Authorization code to exchange\\, usually an {{env.NAME}} placeholder"`
+	RefreshToken     string   `json:"refresh_token,omitempty" jsonschema:"description=Refresh token to start from\\, usually an {{env.NAME}} placeholder"`
+}
+
+// SessionAuth describes how the token returned by a session login is extracted and sent
+type SessionAuth struct {
+	TokenPath     string `json:"token_path" jsonschema:"description=JSONPath to the token in the login response\\, e.g. $.data.token,required"`
+	ExpiresInPath string `json:"expires_in_path,omitempty" jsonschema:"description=JSONPath to the token lifetime in seconds in the login response"`
+	TTL           int    `json:"ttl,omitempty" jsonschema:"description=Token lifetime in seconds when the response does not say"`
+	In            string `json:"in,omitempty" jsonschema:"description=Where the token is sent\\, defaults to header,enum=header,enum=query,enum=cookie"`
+	Name          string `json:"name,omitempty" jsonschema:"description=Name of the header\\, query parameter or cookie\\, defaults to Authorization"`
+	Prefix        string `json:"prefix,omitempty" jsonschema:"description=Prefix for the token\\, defaults to Bearer followed by a space for the Authorization header"`
+}
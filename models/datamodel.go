@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// SchemaRefPrefix is how endpoints reference the config's schemas, e.g. #/schemas/Employee
+const SchemaRefPrefix = "#/schemas/"
+
+/*
+Schema is the subset of JSON Schema used to describe the data models of an API.
+Nullable is shorthand for allowing null next to Type, which keeps the model's
+output simple, and exporters expand it into whatever their format expects.
+*/
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	Example     any                `json:"example,omitempty"`
+}
+
+/*
+JSONSchema describes Schema to the model as a free-form JSON Schema object, since
+reflecting the recursive type would never end.
+*/
+func (Schema) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type:        "object",
+		Description: "A JSON Schema with type, format, description, enum, properties, required, items and nullable, or a $ref such as #/schemas/Employee",
+	}
+}
+
+// SchemaRef returns a schema that references one of the config's schemas by name
+func SchemaRef(name string) *Schema {
+	return &Schema{Ref: SchemaRefPrefix + name}
+}
+
+// RefName returns the name of the schema a reference points to, accepting OpenAPI style references too
+func RefName(ref string) string {
+	for _, prefix := range []string{SchemaRefPrefix, "#/components/schemas/", "#/$defs/", "#/definitions/"} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			return name
+		}
+	}
+	return ref
+}
+
+// EndpointParameter represents a path, query or header parameter of an endpoint
+type EndpointParameter struct {
+	Name        string  `json:"name" jsonschema:"description=Name of the parameter,required"`
+	In          string  `json:"in" jsonschema:"description=Where the parameter is sent,enum=path,enum=query,enum=header,required"`
+	Required    bool    `json:"required,omitempty" jsonschema:"description=Whether the parameter must be sent"`
+	Description string  `json:"description,omitempty" jsonschema:"description=What the parameter does"`
+	Schema      *Schema `json:"schema,omitempty" jsonschema:"description=Schema of the parameter's value"`
+}
+
+// Endpoint represents an operation of the API, with the shapes of its request and response
+type Endpoint struct {
+	Name        string              `json:"name" jsonschema:"description=Unique name of the endpoint\\, e.g. list_employees,required"`
+	Method      string              `json:"method" jsonschema:"description=HTTP method,required"`
+	Path        string              `json:"path" jsonschema:"description=Path relative to the base URL\\, with parameters as {name},required"`
+	Description string              `json:"description,omitempty" jsonschema:"description=What the endpoint does"`
+	Parameters  []EndpointParameter `json:"parameters,omitempty" jsonschema:"description=Path\\, query and header parameters"`
+	Request     *Schema             `json:"request,omitempty" jsonschema:"description=Schema of the request body\\, usually a $ref to one of the schemas"`
+	Response    *Schema             `json:"response,omitempty" jsonschema:"description=Schema of a successful response body\\, usually a $ref to one of the schemas or an array of them"`
+	Pagination  *Pagination         `json:"pagination,omitempty" jsonschema:"description=How the endpoint pages its results"`
+}
+
+// Endpoint returns the endpoint with the given name
+func (config *APIConfig) Endpoint(name string) (Endpoint, bool) {
+	for _, endpoint := range config.Endpoints {
+		if endpoint.Name == name {
+			return endpoint, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+/*
+ResolveSchema follows a schema's reference to the config's schemas, so callers
+always get the definition itself. Schemas that aren't references come back as is.
+*/
+func (config *APIConfig) ResolveSchema(schema *Schema) (*Schema, error) {
+	seen := map[string]bool{}
+
+	for schema != nil && schema.Ref != "" {
+		name := RefName(schema.Ref)
+		if seen[name] {
+			return nil, fmt.Errorf("schema %q references itself", name)
+		}
+		seen[name] = true
+
+		definition, ok := config.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %q", schema.Ref)
+		}
+		schema = definition
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadAPIConfig reads an APIConfig from a JSON file
+func LoadAPIConfig(path string) (*APIConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+
+	return ParseAPIConfig(data)
+}
+
+// ParseAPIConfig decodes an APIConfig from JSON, as produced by the extraction
+func ParseAPIConfig(data []byte) (*APIConfig, error) {
+	var config APIConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// Save writes the APIConfig to a JSON file
+func (config *APIConfig) Save(path string) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing config: %w", err)
+	}
+
+	return nil
+}
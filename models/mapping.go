@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// FieldMap describes how a single target field is computed from a source record
+type FieldMap struct {
+	Target     string         `json:"target" jsonschema:"description=Field to write in the mapped record\\, dots create nested objects,required"`
+	Source     string         `json:"source,omitempty" jsonschema:"description=JSONPath expression into the source record\\, e.g. $.contact.email"`
+	Concat     []string       `json:"concat,omitempty" jsonschema:"description=JSONPath expressions whose values are joined with the separator\\, instead of a single source"`
+	Separator  string         `json:"separator,omitempty" jsonschema:"description=Separator for concat\\, defaults to a single space"`
+	Type       string         `json:"type,omitempty" jsonschema:"description=Type to coerce the value to,enum=string,enum=integer,enum=number,enum=boolean,enum=date"`
+	DateFormat string         `json:"date_format,omitempty" jsonschema:"description=Layout of source dates: a Go time layout\\, RFC3339\\, unix or unix_ms. Common layouts are detected when empty"`
+	Format     string         `json:"format,omitempty" jsonschema:"description=Go time layout for the mapped date\\, defaults to RFC3339"`
+	Lookup     map[string]any `json:"lookup,omitempty" jsonschema:"description=Table translating source values into target values\\, e.g. {\"F\": \"female\"}"`
+	Default    any            `json:"default,omitempty" jsonschema:"description=Value used when the source is missing or not in the lookup table"`
+	Required   bool           `json:"required,omitempty" jsonschema:"description=Fail the mapping when the field ends up without a value"`
+}
+
+/*
+Map represents a data mapping configuration as a list of field mappings. It also
+accepts the shorthand object form {"target": "source path"}, which is what older
+configs contain.
+*/
+type Map []FieldMap
+
+// UnmarshalJSON accepts both the list form and the shorthand object form
+func (m *Map) UnmarshalJSON(data []byte) error {
+	var fields []FieldMap
+	if err := json.Unmarshal(data, &fields); err == nil {
+		*m = fields
+		return nil
+	}
+
+	var shorthand map[string]string
+	if err := json.Unmarshal(data, &shorthand); err != nil {
+		return fmt.Errorf("map must be a list of field mappings or an object of target to source: %w", err)
+	}
+
+	targets := make([]string, 0, len(shorthand))
+	for target := range shorthand {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	*m = make(Map, 0, len(targets))
+	for _, target := range targets {
+		if shorthand[target] == "" {
+			continue
+		}
+		*m = append(*m, FieldMap{Target: target, Source: shorthand[target]})
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+// The pagination styles the runner can follow
+const (
+	PaginationPage       = "page"
+	PaginationOffset     = "offset"
+	PaginationCursor     = "cursor"
+	PaginationLinkHeader = "link_header"
+	PaginationNextURL    = "next_url"
+)
+
+/*
+Pagination describes how an endpoint spreads its results over several pages, so
+the runner can fetch all of them and hand later steps the combined items.
+*/
+type Pagination struct {
+	Type        string `json:"type" jsonschema:"description=Pagination style,enum=page,enum=offset,enum=cursor,enum=link_header,enum=next_url,required"`
+	ItemsPath   string `json:"items_path,omitempty" jsonschema:"description=JSONPath to the items in each page\\, e.g. $.data\\, when the response is not a plain array"`
+	PageParam   string `json:"page_param,omitempty" jsonschema:"description=Parameter holding the page number for page pagination\\, defaults to page"`
+	StartPage   int    `json:"start_page,omitempty" jsonschema:"description=Number of the first page for page pagination\\, defaults to 1"`
+	LimitParam  string `json:"limit_param,omitempty" jsonschema:"description=Parameter holding the page size\\, e.g. per_page or limit"`
+	Limit       int    `json:"limit,omitempty" jsonschema:"description=Page size to request"`
+	OffsetParam string `json:"offset_param,omitempty" jsonschema:"description=Parameter holding the offset for offset pagination\\, defaults to offset"`
+	CursorParam string `json:"cursor_param,omitempty" jsonschema:"description=Parameter to send the cursor in for cursor pagination"`
+	CursorPath  string `json:"cursor_path,omitempty" jsonschema:"description=JSONPath to the next cursor in each page for cursor pagination"`
+	NextURLPath string `json:"next_url_path,omitempty" jsonschema:"description=JSONPath to the URL of the next page for next_url pagination"`
+	TotalPath   string `json:"total_path,omitempty" jsonschema:"description=JSONPath to the total number of items\\, when the API reports it"`
+	MaxPages    int    `json:"max_pages,omitempty" jsonschema:"description=Maximum number of pages to fetch\\, defaults to 100"`
+}
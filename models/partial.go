@@ -0,0 +1,15 @@
+package models
+
+/*
+MarkPartial marks a config as the best the model could do before it was stopped
+by one of the run's limits, keeping why for whoever picks it up. Empty reasons
+are skipped, so without any the config is left as it is.
+*/
+func (config *APIConfig) MarkPartial(reasons ...string) {
+	for _, reason := range reasons {
+		if reason != "" {
+			config.Partial = true
+			config.Stopped = append(config.Stopped, reason)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import "strings"
+
+// Where the value of a field came from
+const (
+	SourceSeed      = "seed"
+	SourceExtracted = "extracted"
+	SourceReview    = "review"
+)
+
+/*
+Provenance records where the value of a field of a config came from. Fields are
+identified by dotted paths such as endpoints.list_employees.path, and Locked
+marks values kept from the seed because they were edited by hand. For extracted
+fields, URL, Snippet and ToolCallID say where the model read them, and Verified
+that a live request confirmed them. An extracted field without a URL is one the
+model didn't cite, so it may well be a guess. Confidence is how sure the model
+says it is, from 0 to 1, and is missing when it didn't say. Reviewed marks
+fields a person accepted or edited.
+*/
+type Provenance struct {
+	Source     string   `json:"source"`
+	Locked     bool     `json:"locked,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	Snippet    string   `json:"snippet,omitempty"`
+	ToolCallID string   `json:"tool_call_id,omitempty"`
+	Verified   bool     `json:"verified,omitempty"`
+	Confidence *float64 `json:"confidence,omitempty"`
+	Reviewed   bool     `json:"reviewed,omitempty"`
+}
+
+// Citation is the model's account of where it found part of a config, resolved into provenance afterwards
+type Citation struct {
+	Field      string  `json:"field" jsonschema:"description=Dotted path of what is cited\\, e.g. endpoints.list_employees\\, auth\\, account_id or schemas.Employee.properties.email,required"`
+	ToolCallID string  `json:"tool_call_id,omitempty" jsonschema:"description=The tool_call_id that labels the tool output it was read from\\, empty when it wasn't read anywhere"`
+	Snippet    string  `json:"snippet,omitempty" jsonschema:"description=A short verbatim quote from that output backing it up"`
+	Confidence float64 `json:"confidence" jsonschema:"description=How sure you are of the value\\, from 0 for a guess to 1 for certain,minimum=0,maximum=1,required"`
+}
+
+/*
+IsLocked reports whether the field at path was edited by hand, either because it
+is locked itself or because a block containing it is, e.g. auth or
+endpoints.list_employees.
+*/
+func (config *APIConfig) IsLocked(path string) bool {
+	for _, locked := range config.Locked {
+		if path == locked || strings.HasPrefix(path, locked+".") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ProvenanceOf returns the provenance recorded for the field at path, or for the
+closest block containing it, since a citation of a whole endpoint covers its
+fields too.
+*/
+func (config *APIConfig) ProvenanceOf(path string) (Provenance, bool) {
+	for {
+		if provenance, ok := config.Provenance[path]; ok {
+			return provenance, true
+		}
+
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return Provenance{}, false
+		}
+		path = path[:i]
+	}
+}
@@ -0,0 +1,381 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/openai/openai-go"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+/*
+Agent is a single model loop with its own tools and response format, run on a
+conversation buffer of its own. Execute runs one agent with every tool, while a
+pipeline runs several, each with just the tools its stage needs, so none of them
+has to keep everything in one conversation.
+*/
+type Agent struct {
+	client  *Client
+	name    string
+	tools   []models.ToolType
+	format  *openai.ResponseFormatJSONSchemaJSONSchemaParam
+	schema  map[string]any
+	err     error
+	mu      sync.Mutex
+	calls   []ToolCall
+	stopped string
+}
+
+// NewAgent creates an agent without tools, answering in plain text, named for the logs
+func NewAgent(client *Client, name string) *Agent {
+	return &Agent{client: client, name: name}
+}
+
+// WithTools sets the tools the agent may call
+func (a *Agent) WithTools(tools ...models.ToolType) *Agent {
+	a.tools = tools
+	return a
+}
+
+/*
+WithResponse makes the agent answer with JSON matching the schema. The model is
+held to a strict version of the schema, which Run turns back into the original
+shape, so the answer is guaranteed to parse.
+*/
+func (a *Agent) WithResponse(name, description string, schema any) *Agent {
+	schemaMap, err := convertSchemaToMap(schema)
+	if err != nil {
+		a.err = fmt.Errorf("error generating %s schema: %w", name, err)
+		return a
+	}
+	delete(schemaMap, "$schema")
+	delete(schemaMap, "$id")
+
+	a.schema = schemaMap
+	a.format = &openai.ResponseFormatJSONSchemaJSONSchemaParam{
+		Name:        openai.F(name),
+		Description: openai.F(description),
+		Schema:      openai.F(any(strictSchema(schemaMap))),
+		Strict:      openai.Bool(true),
+	}
+	return a
+}
+
+// ToolCalls returns the tool calls the agent made, in order
+func (a *Agent) ToolCalls() []ToolCall {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls
+}
+
+// Name returns the name the agent logs under
+func (a *Agent) Name() string {
+	return a.name
+}
+
+// Stopped returns why the agent had to stop before the model was done, empty when it finished on its own
+func (a *Agent) Stopped() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stopped
+}
+
+/*
+Run lets the model call tools until it answers, for at most maxIterations turns,
+0 for no limit. When it runs out of turns, the client's deadline passes or its
+budget is used up, the model gets one last turn without tools to answer with
+what it has so far, and Stopped says why.
+*/
+func (a *Agent) Run(buffer *Buffer, maxIterations int) (string, error) {
+	result, err := a.run(buffer, maxIterations)
+	if err == nil && a.schema != nil {
+		result, err = loosenJSON(a.schema, result)
+	}
+	if err != nil {
+		a.emit(Event{Type: EventError, Error: err.Error()})
+		return "", err
+	}
+
+	a.emit(Event{Type: EventResult, Result: result})
+	return result, nil
+}
+
+func (a *Agent) run(buffer *Buffer, maxIterations int) (string, error) {
+	if a.err != nil {
+		return "", a.err
+	}
+
+	log.Info("Starting agent", "agent", a.name, "maxIterations", maxIterations)
+
+	messages := len(buffer.Messages)
+	params := openai.ChatCompletionNewParams{
+		Model:       openai.F(openai.ChatModelGPT4oMini),
+		Messages:    openai.F(buffer.Truncate().Messages),
+		Temperature: openai.F(0.0),
+	}
+	if dropped := messages - len(buffer.Messages); dropped > 0 {
+		a.emit(Event{Type: EventTruncated, Dropped: dropped})
+	}
+
+	if len(a.tools) > 0 {
+		tools := []openai.ChatCompletionToolParam{}
+		for _, tool := range a.tools {
+			tools = append(tools, openai.ChatCompletionToolParam{
+				Type: openai.F(openai.ChatCompletionToolTypeFunction),
+				Function: openai.F(openai.FunctionDefinitionParam{
+					Name:        openai.String(tool.Name()),
+					Description: openai.String(tool.Description()),
+					Parameters:  openai.F(schemaToFunctionParameters(tool.Schema())),
+				}),
+			})
+		}
+		params.Tools = openai.F(tools)
+	}
+
+	if a.format != nil {
+		params.ResponseFormat = openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
+			openai.ResponseFormatJSONSchemaParam{
+				Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
+				JSONSchema: openai.F(*a.format),
+			},
+		)
+	}
+
+	// Iterate until the model stops requesting tool calls
+	for i := 0; ; i++ {
+		// Other agents of the client may have used up the budget in the meantime.
+		if reason := a.guard(i, maxIterations); reason != "" {
+			return a.finish(params, i+1, reason)
+		}
+
+		log.Info("Executing iteration", "agent", a.name, "iteration", i+1, "of", maxIterations)
+		a.emit(Event{Type: EventIteration, Iteration: i + 1, MaxIterations: maxIterations})
+
+		message, err := a.complete(params, i+1)
+		if err != nil {
+			return "", err
+		}
+
+		// If no tool calls are requested, return the final result
+		toolCalls := message.ToolCalls
+		if len(toolCalls) == 0 {
+			log.Info("No tool calls requested, returning final result", "agent", a.name)
+			return message.Content, nil
+		}
+
+		// Checked only now, since a final answer is worth keeping even when it went over the budget.
+		// The requested calls are dropped, since the conversation can't hold them without their results.
+		if reason := a.guard(i, maxIterations); reason != "" {
+			return a.finish(params, i+1, reason)
+		}
+
+		log.Info("Processing tool calls", "agent", a.name, "count", len(toolCalls))
+		for _, toolCall := range toolCalls {
+			call := &ToolCall{ID: toolCall.ID, Name: toolCall.Function.Name}
+			json.Unmarshal([]byte(toolCall.Function.Arguments), &call.Arguments)
+			a.emit(Event{Type: EventToolCall, Iteration: i + 1, Call: call})
+		}
+
+		// Add the assistant's message to the conversation
+		params.Messages.Value = append(params.Messages.Value, message)
+
+		// Run the tool calls, then add their results in the order the model made them
+		for _, result := range a.runToolCalls(toolCalls) {
+			if result.err != nil {
+				return "", result.err
+			}
+
+			a.mu.Lock()
+			a.calls = append(a.calls, result.call)
+			a.mu.Unlock()
+
+			// Labelled with its ID, so the model can cite it
+			params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(result.call.ID, "tool_call_id: "+result.call.ID+"\n\n"+result.call.Output))
+		}
+	}
+}
+
+// guard returns why the agent has to stop before turn i, empty while it may go on
+func (a *Agent) guard(i, maxIterations int) string {
+	if maxIterations > 0 && i >= maxIterations {
+		return fmt.Sprintf("reached the limit of %d turns", maxIterations)
+	}
+	if deadline := a.client.deadline; !deadline.IsZero() && time.Now().After(deadline) {
+		return fmt.Sprintf("ran past the deadline of %s", deadline.Format(time.RFC3339))
+	}
+	if err := a.client.meter.check(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+/*
+finish gives the model a last turn in which it can't call tools, to answer with
+the best it can make of what it gathered rather than losing it all. This turn is
+made even over the budget, it is what the budget was spent on.
+*/
+func (a *Agent) finish(params openai.ChatCompletionNewParams, iteration int, reason string) (string, error) {
+	a.mu.Lock()
+	a.stopped = reason
+	a.mu.Unlock()
+
+	log.Warn("Stopping agent early, asking for a final answer", "agent", a.name, "reason", reason)
+	a.emit(Event{Type: EventStopped, Iteration: iteration, Reason: reason})
+
+	params.Messages.Value = append(params.Messages.Value, openai.UserMessage(
+		"You have to stop now ("+reason+"). Don't call any more tools. "+
+			"Answer with the best result you can give from what you found so far, leaving out what you couldn't find rather than making it up.",
+	))
+	if len(a.tools) > 0 {
+		params.ToolChoice = openai.F[openai.ChatCompletionToolChoiceOptionUnionParam](openai.ChatCompletionToolChoiceOptionAutoNone)
+	}
+
+	message, err := a.complete(params, iteration)
+	if err != nil {
+		return "", err
+	}
+	return message.Content, nil
+}
+
+// complete asks the model for the next message of the conversation, accounting for its usage
+func (a *Agent) complete(params openai.ChatCompletionNewParams, iteration int) (openai.ChatCompletionMessage, error) {
+	completion, err := a.client.client.Chat.Completions.New(a.client.ctx, params)
+	if err != nil {
+		log.Error("OpenAI API error", "error", err)
+		return openai.ChatCompletionMessage{}, fmt.Errorf("OpenAI API error: %w", err)
+	}
+
+	usage, total := a.client.meter.record(params.Model.Value, completion.Usage)
+	a.emit(Event{Type: EventUsage, Iteration: iteration, Usage: &usage, Total: &total})
+
+	if len(completion.Choices) == 0 {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("OpenAI API error: completion %s has no choices", completion.ID)
+	}
+	return completion.Choices[0].Message, nil
+}
+
+func (a *Agent) emit(event Event) {
+	a.client.emit(a.name, event)
+}
+
+/*
+sequential is implemented by tools whose calls depend on what earlier calls did,
+like browser tools working in the same tab, keyed by what they share.
+*/
+type sequential interface {
+	Sequence() string
+}
+
+// toolResult is the outcome of a tool call
+type toolResult struct {
+	call ToolCall
+	err  error
+}
+
+/*
+runToolCalls runs the tool calls of a turn concurrently, on at most the client's
+number of tool workers. Calls of tools that share a sequence, such as clicking
+and then extracting in the same tab, run one after the other in the order the
+model made them. The results come back in that order too.
+*/
+func (a *Agent) runToolCalls(toolCalls []openai.ChatCompletionMessageToolCall) []toolResult {
+	results := make([]toolResult, len(toolCalls))
+
+	// Every group runs in order on one worker, calls without a sequence get a group of their own.
+	var groups [][]int
+	sequences := map[string]int{}
+	for i, toolCall := range toolCalls {
+		if tool, ok := a.tool(toolCall.Function.Name).(sequential); ok {
+			key := tool.Sequence()
+			if g, ok := sequences[key]; ok {
+				groups[g] = append(groups[g], i)
+				continue
+			}
+			sequences[key] = len(groups)
+		}
+		groups = append(groups, []int{i})
+	}
+
+	workers := make(chan struct{}, max(a.client.toolWorkers, 1))
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		// Taking the worker here starts the groups in order, so one worker runs them in turn.
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			for _, i := range group {
+				start := time.Now()
+				call, err := a.processToolCall(toolCalls[i])
+				results[i] = toolResult{call: call, err: err}
+
+				event := Event{Type: EventToolResult, Call: &call, Duration: time.Since(start)}
+				if err != nil {
+					event.Error = err.Error()
+				}
+				a.emit(event)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// tool returns the agent's instance of the named tool, nil when it doesn't have it
+func (a *Agent) tool(name string) models.ToolType {
+	for _, t := range a.tools {
+		if t.Name() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// processToolCall runs a tool call with the agent's own instance of the tool
+func (a *Agent) processToolCall(toolCall openai.ChatCompletionMessageToolCall) (ToolCall, error) {
+	call := ToolCall{ID: toolCall.ID, Name: toolCall.Function.Name}
+
+	// Parse the arguments
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &call.Arguments); err != nil {
+		log.Error("Error parsing arguments", "error", err)
+		return call, fmt.Errorf("error parsing arguments: %w", err)
+	}
+
+	// Check the arguments, and get what to log
+	_, logDetails, err := a.client.getToolExecutor(call.Name, call.Arguments)
+	if err != nil {
+		return call, err
+	}
+
+	tool := a.tool(call.Name)
+	if tool == nil {
+		log.Error("Tool not available to agent", "agent", a.name, "tool", call.Name)
+		return call, fmt.Errorf("tool %s is not available to the %s agent", call.Name, a.name)
+	}
+
+	// Log the action being performed
+	log.Info(logDetails["start_message"].(string), logDetails["params"].([]any)...)
+
+	// Execute the tool
+	if call.Output, err = tool.Execute(call.Arguments); err != nil {
+		log.Error("Error executing tool", "tool", call.Name, "error", err)
+		return call, fmt.Errorf("error executing tool: %w", err)
+	}
+
+	// Log success
+	log.Info(logDetails["success_message"].(string))
+
+	if page, ok := tool.(interface{ URL() string }); ok {
+		call.URL = page.URL()
+	} else {
+		call.URL, _ = call.Arguments["url"].(string)
+	}
+
+	return call, nil
+}
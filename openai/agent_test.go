@@ -0,0 +1,344 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// recorder keeps track of the order fake tools start and finish in, and how many run at once
+type recorder struct {
+	mu      sync.Mutex
+	events  []string
+	running int
+	most    int
+}
+
+func (r *recorder) start(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, "start "+id)
+	r.running++
+	r.most = max(r.most, r.running)
+}
+
+func (r *recorder) finish(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, "finish "+id)
+	r.running--
+}
+
+// index returns where an event was recorded, -1 when it wasn't
+func (r *recorder) index(event string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Index(r.events, event)
+}
+
+/*
+fakeTool stands in for a real tool under the real tool's name, since the agent
+checks the arguments by name. It sleeps for the delay given in its arguments, so
+tests decide which calls finish first.
+*/
+type fakeTool struct {
+	name     string
+	recorder *recorder
+}
+
+func (t *fakeTool) Execute(args map[string]any) (string, error) {
+	id, _ := args["id"].(string)
+	delay, _ := args["delay"].(float64)
+
+	t.recorder.start(id)
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+	t.recorder.finish(id)
+
+	return "output of " + id, nil
+}
+
+func (t *fakeTool) Name() string        { return t.name }
+func (t *fakeTool) Description() string { return t.name }
+func (t *fakeTool) Schema() any         { return map[string]any{"type": "object"} }
+
+// tabTool is a fakeTool working in a browser tab, so its calls run in order with other calls in the tab
+type tabTool struct {
+	fakeTool
+	tab string
+}
+
+func (t *tabTool) Sequence() string { return t.tab }
+
+// toolCall builds a tool call the way the model would make it
+func toolCall(id, name string, delay int) openai.ChatCompletionMessageToolCall {
+	return openai.ChatCompletionMessageToolCall{
+		ID: id,
+		Function: openai.ChatCompletionMessageToolCallFunction{
+			Name:      name,
+			Arguments: fmt.Sprintf(`{"id": %q, "delay": %d, "url": "https://docs.example.com", "selector": "a", "script": "1"}`, id, delay),
+		},
+	}
+}
+
+func newTestAgent(workers int, tools ...models.ToolType) *Agent {
+	return NewAgent(NewClient("test").WithToolWorkers(workers), "test").WithTools(tools...)
+}
+
+func TestRunToolCallsLimitsWorkers(t *testing.T) {
+	for _, workers := range []int{1, 2, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			r := &recorder{}
+			agent := newTestAgent(workers, &fakeTool{name: "http_request", recorder: r})
+
+			var calls []openai.ChatCompletionMessageToolCall
+			for i := range 8 {
+				calls = append(calls, toolCall(fmt.Sprintf("call_%d", i), "http_request", 20))
+			}
+
+			agent.runToolCalls(calls)
+
+			if r.most != workers {
+				t.Errorf("at most %d calls ran at once, want %d", r.most, workers)
+			}
+		})
+	}
+}
+
+func TestRunToolCallsKeepsTabsInOrder(t *testing.T) {
+	r := &recorder{}
+	agent := newTestAgent(4,
+		&tabTool{fakeTool: fakeTool{name: "browser_navigate", recorder: r}, tab: "tab-1"},
+		&tabTool{fakeTool: fakeTool{name: "browser_click", recorder: r}, tab: "tab-1"},
+		&tabTool{fakeTool: fakeTool{name: "browser_execute_js", recorder: r}, tab: "tab-2"},
+		&fakeTool{name: "http_request", recorder: r},
+	)
+
+	// Earlier calls in a tab take longer, so they would finish last if they ran at once.
+	agent.runToolCalls([]openai.ChatCompletionMessageToolCall{
+		toolCall("navigate_1", "browser_navigate", 40),
+		toolCall("script_2", "browser_execute_js", 30),
+		toolCall("click_1", "browser_click", 20),
+		toolCall("request", "http_request", 30),
+		toolCall("navigate_1_again", "browser_navigate", 1),
+		toolCall("script_2_again", "browser_execute_js", 1),
+	})
+
+	for _, tab := range [][]string{{"navigate_1", "click_1", "navigate_1_again"}, {"script_2", "script_2_again"}} {
+		for i := 1; i < len(tab); i++ {
+			if r.index("finish "+tab[i-1]) > r.index("start "+tab[i]) {
+				t.Errorf("%s started before %s finished in the same tab: %v", tab[i], tab[i-1], r.events)
+			}
+		}
+	}
+
+	if r.most < 2 {
+		t.Errorf("at most %d calls ran at once, want the tabs and the request to run side by side", r.most)
+	}
+}
+
+func TestRunToolCallsReturnsResultsInOrder(t *testing.T) {
+	r := &recorder{}
+	agent := newTestAgent(4,
+		&tabTool{fakeTool: fakeTool{name: "browser_navigate", recorder: r}, tab: "tab-1"},
+		&fakeTool{name: "http_request", recorder: r},
+	)
+
+	// Later calls finish first.
+	calls := []openai.ChatCompletionMessageToolCall{
+		toolCall("call_0", "http_request", 40),
+		toolCall("call_1", "browser_navigate", 30),
+		toolCall("call_2", "http_request", 20),
+		toolCall("call_3", "http_request", 1),
+	}
+
+	results := agent.runToolCalls(calls)
+
+	if len(results) != len(calls) {
+		t.Fatalf("got %d results, want %d", len(results), len(calls))
+	}
+	for i, result := range results {
+		if result.err != nil {
+			t.Errorf("call %d failed: %v", i, result.err)
+		}
+		if result.call.ID != calls[i].ID || result.call.Output != "output of "+calls[i].ID {
+			t.Errorf("result %d is %s with %q, want %s", i, result.call.ID, result.call.Output, calls[i].ID)
+		}
+	}
+
+	if r.index("finish call_3") > r.index("finish call_0") {
+		t.Errorf("the calls didn't run at the same time: %v", r.events)
+	}
+}
+
+/*
+fakeModel stands in for the OpenAI API. It keeps calling a tool until it is told
+it can't or has none, then answers, and keeps the requests it got. Every completion takes
+tokens tokens, and with empty set it answers without choices.
+*/
+type fakeModel struct {
+	*httptest.Server
+
+	tokens   int
+	empty    bool
+	mu       sync.Mutex
+	requests []map[string]any
+}
+
+func newFakeModel(t *testing.T, tokens int, empty bool) *fakeModel {
+	t.Helper()
+
+	fm := &fakeModel{tokens: tokens, empty: empty}
+	fm.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fm.mu.Lock()
+		fm.requests = append(fm.requests, params)
+		turn := len(fm.requests)
+		fm.mu.Unlock()
+
+		message := map[string]any{"role": "assistant", "content": "the final answer"}
+		if params["tools"] != nil && params["tool_choice"] != "none" {
+			id := fmt.Sprintf("call_%d", turn)
+			message = map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{
+				"id":       id,
+				"type":     "function",
+				"function": map[string]any{"name": "http_request", "arguments": fmt.Sprintf(`{"id": %q}`, id)},
+			}}}
+		}
+
+		choices := []any{map[string]any{"index": 0, "finish_reason": "stop", "message": message}}
+		if fm.empty {
+			choices = []any{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      fmt.Sprintf("chatcmpl-%d", turn),
+			"object":  "chat.completion",
+			"model":   "gpt-4o-mini",
+			"choices": choices,
+			"usage":   map[string]any{"prompt_tokens": fm.tokens - 5, "completion_tokens": 5, "total_tokens": fm.tokens},
+		})
+	}))
+	t.Cleanup(fm.Close)
+
+	return fm
+}
+
+// last returns the text of the last message of a request
+func last(request map[string]any) string {
+	messages, _ := request["messages"].([]any)
+	message, _ := messages[len(messages)-1].(map[string]any)
+	if content, ok := message["content"].(string); ok {
+		return content
+	}
+	data, _ := json.Marshal(message["content"])
+	return string(data)
+}
+
+func TestRunStopsEarly(t *testing.T) {
+	tests := []struct {
+		name       string
+		iterations int
+		client     func(*Client) *Client
+		requests   int
+		reason     string
+	}{
+		{"turn limit", 3, func(c *Client) *Client { return c }, 4, "reached the limit of 3 turns"},
+		{"deadline", 10, func(c *Client) *Client { return c.WithDeadline(time.Now().Add(-time.Minute)) }, 1, "ran past the deadline"},
+		{"token budget", 10, func(c *Client) *Client { return c.WithTokenBudget(250) }, 4, "took 300 of 250 tokens"},
+		{"dollar budget", 10, func(c *Client) *Client { return c.WithBudget(0.00015) }, 3, "cost $0.0002 of a $0.00 budget"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm := newFakeModel(t, 100, false)
+			client := tt.client(NewClient("test", option.WithBaseURL(fm.URL), option.WithMaxRetries(0)).
+				WithPrices(Prices{openai.ChatModelGPT4oMini: {Prompt: 1, Completion: 1}}))
+			agent := NewAgent(client, "test").WithTools(&fakeTool{name: "http_request", recorder: &recorder{}})
+
+			result, err := agent.Run(NewBuffer("system", "user"), tt.iterations)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			// The last turn can't call tools and asks for the best answer so far.
+			if result != "the final answer" {
+				t.Errorf("result = %q, want the answer of the last turn", result)
+			}
+			if !strings.Contains(agent.Stopped(), tt.reason) {
+				t.Errorf("Stopped() = %q, want it to contain %q", agent.Stopped(), tt.reason)
+			}
+			if len(fm.requests) != tt.requests {
+				t.Fatalf("made %d requests, want %d", len(fm.requests), tt.requests)
+			}
+
+			final := fm.requests[len(fm.requests)-1]
+			if final["tool_choice"] != "none" {
+				t.Errorf("the last turn has tool_choice %v, want none", final["tool_choice"])
+			}
+			if !strings.Contains(last(final), "You have to stop now ("+agent.Stopped()+")") {
+				t.Errorf("the last turn ends with %q, want it to say why it has to stop", last(final))
+			}
+			for i, request := range fm.requests[:len(fm.requests)-1] {
+				if request["tool_choice"] == "none" {
+					t.Errorf("turn %d couldn't call tools", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestRunKeepsAnswerOverBudget(t *testing.T) {
+	fm := newFakeModel(t, 100, false)
+	client := NewClient("test", option.WithBaseURL(fm.URL), option.WithMaxRetries(0)).WithTokenBudget(50)
+
+	// Without tools the model answers right away, which is worth keeping even over the budget.
+	agent := NewAgent(client, "test")
+	result, err := agent.Run(NewBuffer("system", "user"), 10)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result != "the final answer" || agent.Stopped() != "" {
+		t.Errorf("got %q, stopped %q, want the answer without stopping", result, agent.Stopped())
+	}
+}
+
+func TestRunFailsOnEmptyCompletion(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Time
+	}{
+		{"turn", time.Time{}},
+		{"last turn", time.Now().Add(-time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm := newFakeModel(t, 100, true)
+			client := NewClient("test", option.WithBaseURL(fm.URL), option.WithMaxRetries(0)).WithDeadline(tt.deadline)
+			agent := NewAgent(client, "test").WithTools(&fakeTool{name: "http_request", recorder: &recorder{}})
+
+			_, err := agent.Run(NewBuffer("system", "user"), 10)
+			if err == nil || !strings.Contains(err.Error(), "has no choices") {
+				t.Fatalf("Run returned %v, want an error about the missing choices", err)
+			}
+		})
+	}
+}
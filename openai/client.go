@@ -4,73 +4,133 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 	"github.com/theapemachine/idrinkyourmilkshake/browser"
+	"github.com/theapemachine/idrinkyourmilkshake/graphql"
 	"github.com/theapemachine/idrinkyourmilkshake/models"
 	"github.com/theapemachine/idrinkyourmilkshake/request"
+	"github.com/theapemachine/idrinkyourmilkshake/schema"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
 )
 
 // Client wraps the OpenAI API client with additional functionality
 type Client struct {
-	client *openai.Client
-	ctx    context.Context
+	client      *openai.Client
+	ctx         context.Context
+	toolWorkers int
+	calls       []ToolCall
+	handler     Handler
+	events      sync.Mutex
+	meter       *meter
+	deadline    time.Time
+	stopped     string
 }
 
-// NewClient creates a new OpenAI client with the given API key
-func NewClient(apiKey string) *Client {
-	client := openai.NewClient(option.WithAPIKey(apiKey))
+// defaultToolWorkers is how many tool calls of a turn run at the same time
+const defaultToolWorkers = 4
+
+/*
+ToolCall is a tool call the model made and what it returned. URL is the page the
+browser was on for browser tools, and the requested URL for the others, so what
+the model cites from a tool call can be traced back to where it was read.
+*/
+type ToolCall struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	URL       string         `json:"url,omitempty"`
+	Output    string         `json:"output"`
+}
+
+// NewClient creates a new OpenAI client with the given API key, options such as another base URL go to the API client
+func NewClient(apiKey string, options ...option.RequestOption) *Client {
+	client := openai.NewClient(append([]option.RequestOption{option.WithAPIKey(apiKey)}, options...)...)
 	return &Client{
-		client: client,
-		ctx:    context.Background(),
+		client:      client,
+		ctx:         context.Background(),
+		toolWorkers: defaultToolWorkers,
+		meter:       newMeter(),
 	}
 }
 
-// WithContext sets the context for the client
-func (c *Client) WithContext(ctx context.Context) *Client {
-	c.ctx = ctx
+// ToolCalls returns the tool calls made by the last Execute, in order
+func (c *Client) ToolCalls() []ToolCall {
+	return c.calls
+}
+
+// WithToolWorkers sets how many tool calls of a turn run at the same time, 1 to run them one by one
+func (c *Client) WithToolWorkers(workers int) *Client {
+	c.toolWorkers = workers
 	return c
 }
 
-// ToolExecutor is the interface for all tool executors
-type ToolExecutor interface {
-	Execute(args map[string]any) (string, error)
+// WithPrices sets the prices the cost of completions is worked out with
+func (c *Client) WithPrices(prices Prices) *Client {
+	c.meter.prices = prices
+	return c
 }
 
-// ProcessToolCall handles a single tool call
-func (c *Client) ProcessToolCall(toolCall openai.ChatCompletionMessageToolCall, params *openai.ChatCompletionNewParams) error {
-	// Parse the arguments
-	var args map[string]any
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		log.Error("Error parsing arguments", "error", err)
-		return fmt.Errorf("error parsing arguments: %w", err)
-	}
+// WithBudget stops the client's agents once their completions cost more than this many US dollars, 0 for no limit
+func (c *Client) WithBudget(dollars float64) *Client {
+	c.meter.budget = dollars
+	return c
+}
 
-	// Get the appropriate executor
-	executor, logDetails, err := c.getToolExecutor(toolCall.Function.Name, args)
-	if err != nil {
-		return err
-	}
+// WithTokenBudget stops the client's agents once their completions took more than this many tokens, 0 for no limit
+func (c *Client) WithTokenBudget(tokens int64) *Client {
+	c.meter.tokens = tokens
+	return c
+}
 
-	// Log the action being performed
-	log.Info(logDetails["start_message"].(string), logDetails["params"].([]any)...)
+// WithDeadline stops the client's agents once it has passed, the zero time for no limit
+func (c *Client) WithDeadline(deadline time.Time) *Client {
+	c.deadline = deadline
+	return c
+}
 
-	// Execute the tool
-	content, err := executor.Execute(args)
-	if err != nil {
-		log.Error("Error executing tool", "tool", toolCall.Function.Name, "error", err)
-		return fmt.Errorf("error executing tool: %w", err)
+// Stopped returns why the last Execute had to stop early, empty when the model finished on its own
+func (c *Client) Stopped() string {
+	return c.stopped
+}
+
+// Usage returns the tokens all completions of the client's agents took so far, and their cost
+func (c *Client) Usage() Usage {
+	return c.meter.usage()
+}
+
+// WithEvents sends the events of the client's agents to handler as they happen
+func (c *Client) WithEvents(handler Handler) *Client {
+	c.handler = handler
+	return c
+}
+
+// emit stamps an event and hands it to the handler, one at a time
+func (c *Client) emit(agent string, event Event) {
+	if c.handler == nil {
+		return
 	}
 
-	// Log success
-	log.Info(logDetails["success_message"].(string))
+	event.Agent, event.Time = agent, time.Now()
 
-	// Add the tool call result to the conversation
-	params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, content))
+	c.events.Lock()
+	defer c.events.Unlock()
+	c.handler(event)
+}
 
-	return nil
+// WithContext sets the context for the client
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c.ctx = ctx
+	return c
+}
+
+// ToolExecutor is the interface for all tool executors
+type ToolExecutor interface {
+	Execute(args map[string]any) (string, error)
 }
 
 func (c *Client) getStatusMessages(toolName string, params []any) map[string]any {
@@ -116,126 +176,51 @@ func (c *Client) getToolExecutor(toolName string, args map[string]any) (ToolExec
 		url, _ := args["url"].(string)
 		return &request.HTTPRequest{}, c.getStatusMessages(toolName, []any{"method", method, "url", url}), nil
 
+	case "graphql_introspect":
+		url, ok := args["url"].(string)
+		if !ok {
+			log.Error("URL parameter is missing")
+			return nil, nil, fmt.Errorf("url parameter is required")
+		}
+		return &graphql.GraphQLIntrospector{}, c.getStatusMessages(toolName, []any{"url", url}), nil
+
+	case "infer_schema":
+		samples, _ := args["samples"].([]any)
+		return &schema.SchemaInferrer{}, c.getStatusMessages(toolName, []any{"samples", len(samples)}), nil
+
 	default:
 		log.Error("Unknown tool called", "tool", toolName)
 		return nil, nil, fmt.Errorf("unknown tool: %s", toolName)
 	}
 }
 
+/*
+Execute runs a single agent with every tool until it returns a config, the way
+an extraction works without a pipeline.
+*/
 func (c *Client) Execute(
 	buffer *Buffer,
 	maxIterations int,
 ) (string, error) {
-	log.Info("Starting OpenAI client execution", "maxIterations", maxIterations)
-
-	// Create a simplified schema for APIConfig that follows OpenAI's expectations
-	apiConfigSchema := map[string]interface{}{
-		"type":                 "object",
-		"additionalProperties": false,
-		"properties": map[string]interface{}{
-			"integration": map[string]interface{}{
-				"type":        "string",
-				"description": "The name of the integration",
-			},
-			"account_id": map[string]interface{}{
-				"type":        "string",
-				"description": "The account ID",
-			},
-			"base_url": map[string]interface{}{
-				"type":        "string",
-				"description": "The base URL",
-			},
-			"jobs": map[string]interface{}{
-				"type": "array",
-				"items": map[string]interface{}{
-					"type":                 "object",
-					"additionalProperties": false,
-					"properties": map[string]interface{}{
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "Name of the job",
-						},
-					},
-					"required": []string{"name"},
-				},
-			},
-		},
-		"required": []string{"integration", "account_id", "base_url", "jobs"},
-	}
-
-	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-		Name:        openai.F("api_config"),
-		Description: openai.F("The API configuration"),
-		Schema:      openai.F(any(apiConfigSchema)),
-		Strict:      openai.Bool(true),
-	}
-
-	availableTools := []models.ToolType{
-		models.NewTool(browser.NewBrowserExtractor()),
-		models.NewTool(browser.NewBrowserNavigator()),
-		models.NewTool(browser.NewBrowserJavaScriptExecutor()),
-		models.NewTool(browser.NewBrowserClicker()),
-		models.NewTool(request.NewHTTPRequest()),
-	}
-
-	tools := []openai.ChatCompletionToolParam{}
-
-	for _, tool := range availableTools {
-		tools = append(tools, openai.ChatCompletionToolParam{
-			Type: openai.F(openai.ChatCompletionToolTypeFunction),
-			Function: openai.F(openai.FunctionDefinitionParam{
-				Name:        openai.String(tool.Name()),
-				Description: openai.String(tool.Description()),
-				Parameters:  openai.F(schemaToFunctionParameters(tool.Schema())),
-			}),
-		})
-	}
-
-	params := openai.ChatCompletionNewParams{
-		Model:       openai.F(openai.ChatModelGPT4oMini),
-		Messages:    openai.F(buffer.Truncate().Messages),
-		Tools:       openai.F(tools),
-		Temperature: openai.F(0.0),
-		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
-			openai.ResponseFormatJSONSchemaParam{
-				Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
-				JSONSchema: openai.F(schemaParam),
-			},
-		),
-	}
-
-	// Iterate until the model stops requesting tool calls
-	for i := range maxIterations {
-		log.Info("Executing iteration", "iteration", i+1, "of", maxIterations)
-
-		completion, err := c.client.Chat.Completions.New(c.ctx, params)
-		if err != nil {
-			log.Error("OpenAI API error", "error", err)
-			return "", fmt.Errorf("OpenAI API error: %w", err)
-		}
-
-		// If no tool calls are requested, return the final result
-		toolCalls := completion.Choices[0].Message.ToolCalls
-		if len(toolCalls) == 0 {
-			log.Info("No tool calls requested, returning final result")
-			return completion.Choices[0].Message.Content, nil
-		}
-
-		log.Info("Processing tool calls", "count", len(toolCalls))
+	// The response schema is generated from models.APIConfig, so everything a config
+	// can hold is something the model can emit.
+	agent := NewAgent(c, "extractor").
+		WithTools(Tools()...).
+		WithResponse("api_config", "The API configuration", utils.GenerateSchema[models.APIConfig]())
 
-		// Add the assistant's message to the conversation
-		params.Messages.Value = append(params.Messages.Value, completion.Choices[0].Message)
+	result, err := agent.Run(buffer, maxIterations)
+	c.calls, c.stopped = agent.ToolCalls(), agent.Stopped()
 
-		// Process each tool call
-		for _, toolCall := range toolCalls {
-			if err := c.ProcessToolCall(toolCall, &params); err != nil {
-				return "", err
-			}
-		}
-	}
+	return result, err
+}
 
-	log.Error("Maximum iterations reached without resolution", "maxIterations", maxIterations)
-	return "", fmt.Errorf("reached maximum iterations without resolution")
+// Tools returns every tool an agent can be given
+func Tools() []models.ToolType {
+	return append(browser.Tools(),
+		models.NewTool(request.NewHTTPRequest()),
+		models.NewTool(graphql.NewGraphQLIntrospector()),
+		models.NewTool(schema.NewSchemaInferrer()),
+	)
 }
 
 // schemaToFunctionParameters converts a jsonschema.Schema to the format expected by the OpenAI API
@@ -0,0 +1,66 @@
+package openai
+
+import "time"
+
+// EventType says what happened in an Event
+type EventType string
+
+const (
+	EventIteration  EventType = "iteration"
+	EventToolCall   EventType = "tool_call"
+	EventToolResult EventType = "tool_result"
+	EventUsage      EventType = "usage"
+	EventTruncated  EventType = "truncated"
+	EventStopped    EventType = "stopped"
+	EventResult     EventType = "result"
+	EventError      EventType = "error"
+)
+
+/*
+Event is something that happened while an agent ran, for programs that want to
+show progress. Which fields are set depends on the type: Iteration for every
+turn, Call when a tool call is requested and again with its output when it
+returns, Usage and the Total of the run so far after every completion, Dropped
+when the conversation had to be truncated, the Reason it was stopped before its
+last turn when it hit a limit, and Result or Error when the agent is done.
+*/
+type Event struct {
+	Type          EventType     `json:"type"`
+	Agent         string        `json:"agent"`
+	Time          time.Time     `json:"time"`
+	Iteration     int           `json:"iteration,omitempty"`
+	MaxIterations int           `json:"max_iterations,omitempty"`
+	Call          *ToolCall     `json:"call,omitempty"`
+	Duration      time.Duration `json:"duration,omitempty"`
+	Usage         *Usage        `json:"usage,omitempty"`
+	Total         *Usage        `json:"total,omitempty"`
+	Dropped       int           `json:"dropped,omitempty"`
+	Reason        string        `json:"reason,omitempty"`
+	Result        string        `json:"result,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+/*
+Handler receives the events of a client's agents. Agents running at the same
+time, like the explorers of a pipeline, share it, but it is never called by two
+of them at once.
+*/
+type Handler func(Event)
+
+// Channel returns a Handler that sends the events to ch, for consumers that prefer a channel
+func Channel(ch chan<- Event) Handler {
+	return func(event Event) {
+		ch <- event
+	}
+}
+
+// Handlers returns a Handler passing every event to each of handlers, skipping nil ones
+func Handlers(handlers ...Handler) Handler {
+	return func(event Event) {
+		for _, handler := range handlers {
+			if handler != nil {
+				handler(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// strictKeywords are the schema keywords kept in strict schemas, others are dropped
+var strictKeywords = []string{"type", "description", "enum", "properties", "required", "additionalProperties", "items"}
+
+/*
+shape says how a node of a generated schema is rewritten for strict mode: maps
+become lists of key and value pairs, and free-form values, such as request
+bodies and data model schemas, become JSON documents in a string, since strict
+mode only allows closed objects.
+*/
+func shape(node map[string]any) string {
+	_, typed := node["type"]
+	_, enum := node["enum"]
+	_, properties := node["properties"]
+	_, values := node["additionalProperties"].(map[string]any)
+
+	switch {
+	case !typed && !enum:
+		return "free"
+	case node["type"] == "object" && values && !properties:
+		return "map"
+	case node["type"] == "object" && !properties:
+		return "free"
+	case node["type"] == "object":
+		return "object"
+	case node["type"] == "array":
+		return "array"
+	}
+	return ""
+}
+
+/*
+strictSchema rewrites a generated schema into one strict structured outputs
+accept. Every property is required, and the ones that were optional may be null
+instead. What the model answers is turned back with loosen.
+*/
+func strictSchema(node map[string]any) map[string]any {
+	out := map[string]any{}
+	for _, keyword := range strictKeywords {
+		if value, ok := node[keyword]; ok {
+			out[keyword] = value
+		}
+	}
+
+	switch shape(node) {
+	case "free":
+		description, _ := node["description"].(string)
+		return map[string]any{"type": "string", "description": description + " (as a JSON document)"}
+
+	case "map":
+		return map[string]any{
+			"type":        "array",
+			"description": out["description"],
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"key":   map[string]any{"type": "string"},
+					"value": strictSchema(node["additionalProperties"].(map[string]any)),
+				},
+				"required":             []string{"key", "value"},
+				"additionalProperties": false,
+			},
+		}
+
+	case "object":
+		required := map[string]bool{}
+		if list, ok := node["required"].([]any); ok {
+			for _, name := range list {
+				required[name.(string)] = true
+			}
+		}
+
+		properties := map[string]any{}
+		var names []string
+		for name, property := range node["properties"].(map[string]any) {
+			converted := strictSchema(property.(map[string]any))
+			if !required[name] {
+				converted = nullable(converted)
+			}
+			properties[name] = converted
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		out["properties"] = properties
+		out["required"] = names
+		out["additionalProperties"] = false
+
+	case "array":
+		if items, ok := node["items"].(map[string]any); ok {
+			out["items"] = strictSchema(items)
+		}
+	}
+
+	return out
+}
+
+// nullable lets a strict schema node be null as well
+func nullable(node map[string]any) map[string]any {
+	if t, ok := node["type"].(string); ok {
+		node["type"] = []any{t, "null"}
+	}
+	if enum, ok := node["enum"].([]any); ok {
+		node["enum"] = append(enum, nil)
+	}
+	return node
+}
+
+// loosen turns a value matching the strict version of a schema back into one matching the schema
+func loosen(node map[string]any, value any) any {
+	switch shape(node) {
+	case "free":
+		if s, ok := value.(string); ok {
+			var decoded any
+			if err := json.Unmarshal([]byte(s), &decoded); err == nil {
+				return decoded
+			}
+		}
+
+	case "map":
+		if pairs, ok := value.([]any); ok {
+			out := map[string]any{}
+			for _, pair := range pairs {
+				if p, ok := pair.(map[string]any); ok {
+					key, _ := p["key"].(string)
+					out[key] = loosen(node["additionalProperties"].(map[string]any), p["value"])
+				}
+			}
+			return out
+		}
+
+	case "object":
+		if object, ok := value.(map[string]any); ok {
+			properties := node["properties"].(map[string]any)
+			for name, v := range object {
+				property, known := properties[name].(map[string]any)
+				switch {
+				case v == nil:
+					// Optional properties come back null when the model had nothing for them.
+					delete(object, name)
+				case known:
+					object[name] = loosen(property, v)
+				}
+			}
+		}
+
+	case "array":
+		if items, ok := node["items"].(map[string]any); ok {
+			if list, ok := value.([]any); ok {
+				for i, item := range list {
+					list[i] = loosen(items, item)
+				}
+			}
+		}
+	}
+
+	return value
+}
+
+// loosenJSON is loosen for an answer in JSON
+func loosenJSON(schema map[string]any, answer string) (string, error) {
+	var value any
+	if err := json.Unmarshal([]byte(answer), &value); err != nil {
+		return "", fmt.Errorf("error parsing answer: %w", err)
+	}
+
+	out, err := json.Marshal(loosen(schema, value))
+	if err != nil {
+		return "", fmt.Errorf("error encoding answer: %w", err)
+	}
+	return string(out), nil
+}
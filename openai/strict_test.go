@@ -0,0 +1,87 @@
+package openai
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+func apiConfigSchema(t *testing.T) map[string]any {
+	t.Helper()
+
+	schema, err := convertSchemaToMap(utils.GenerateSchema[models.APIConfig]())
+	if err != nil {
+		t.Fatalf("convertSchemaToMap: %v", err)
+	}
+	delete(schema, "$schema")
+	delete(schema, "$id")
+	return schema
+}
+
+// TestStrictSchemaIsClosed checks the rules strict mode enforces on every object
+func TestStrictSchemaIsClosed(t *testing.T) {
+	var walk func(path string, node map[string]any)
+	walk = func(path string, node map[string]any) {
+		if _, ok := node["type"]; !ok {
+			t.Errorf("%s has no type", path)
+		}
+
+		if properties, ok := node["properties"].(map[string]any); ok {
+			if node["additionalProperties"] != false {
+				t.Errorf("%s is not closed", path)
+			}
+			required, _ := node["required"].([]string)
+			if len(required) != len(properties) {
+				t.Errorf("%s requires %d of its %d properties", path, len(required), len(properties))
+			}
+			for name, property := range properties {
+				walk(path+"."+name, property.(map[string]any))
+			}
+		}
+		if items, ok := node["items"].(map[string]any); ok {
+			walk(path+"[]", items)
+		}
+	}
+
+	walk("api_config", strictSchema(apiConfigSchema(t)))
+}
+
+func TestLoosen(t *testing.T) {
+	answer := `{
+		"integration": "dyflexis", "account_id": "acme", "base_url": "https://api.example.com",
+		"auth": {"type": "none", "bearer": null, "basic": null, "api_key": null, "oauth2": null, "session": null, "inputs": null, "outputs": null},
+		"schemas": [{"key": "Employee", "value": "{\"type\":\"object\",\"properties\":{\"id\":{\"type\":\"integer\"}}}"}],
+		"endpoints": null,
+		"jobs": [{"name": "sync", "steps": [{
+			"name": "list", "type": "http", "endpoint": "/employees", "method": "GET", "graphql": null, "pagination": null, "input": null, "map": null,
+			"inputs": {"headers": [{"key": "Accept", "value": "application/json"}], "body": null},
+			"outputs": [{"key": "items", "value": "$.data"}]
+		}]}],
+		"citations": null
+	}`
+
+	loose, err := loosenJSON(apiConfigSchema(t), answer)
+	if err != nil {
+		t.Fatalf("loosenJSON: %v", err)
+	}
+
+	var got, want any
+	json.Unmarshal([]byte(loose), &got)
+	json.Unmarshal([]byte(`{
+		"integration": "dyflexis", "account_id": "acme", "base_url": "https://api.example.com",
+		"auth": {"type": "none"},
+		"schemas": {"Employee": {"type": "object", "properties": {"id": {"type": "integer"}}}},
+		"jobs": [{"name": "sync", "steps": [{
+			"name": "list", "type": "http", "endpoint": "/employees", "method": "GET",
+			"inputs": {"headers": {"Accept": "application/json"}},
+			"outputs": {"items": "$.data"}
+		}]}]
+	}`), &want)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loosen =\n%s", loose)
+	}
+}
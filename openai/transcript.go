@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+Transcript records the events of a run, with the tool calls and their output,
+what every completion cost and what the whole run cost, so a run can be audited
+after the fact. Record it as a client's Handler, and save it when the run is over.
+*/
+type Transcript struct {
+	mu       sync.Mutex
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Usage    Usage     `json:"usage"`
+	Events   []Event   `json:"events"`
+}
+
+// NewTranscript creates an empty transcript of a run starting now
+func NewTranscript() *Transcript {
+	return &Transcript{Started: time.Now(), Events: []Event{}}
+}
+
+// Record adds an event to the transcript
+func (t *Transcript) Record(event Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Events = append(t.Events, event)
+}
+
+// Save writes the transcript to a JSON file, with the usage of the whole run
+func (t *Transcript) Save(path string, usage Usage) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.Finished, t.Usage = time.Now(), usage
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding transcript: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing transcript: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/openai/openai-go"
+)
+
+// ErrBudgetExceeded is why agents stop once the run took more tokens or cost more than its budget
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Usage is the number of tokens completions took, and what they cost in US dollars
+type Usage struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CachedTokens     int64   `json:"cached_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (u *Usage) add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CachedTokens += other.CachedTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+}
+
+// Price is what a model charges in US dollars per million tokens, with cached prompt tokens charged separately
+type Price struct {
+	Prompt       float64 `json:"prompt"`
+	CachedPrompt float64 `json:"cached_prompt"`
+	Completion   float64 `json:"completion"`
+}
+
+// Prices are the prices of models, keyed by model name
+type Prices map[string]Price
+
+// DefaultPrices are OpenAI's list prices for the models the agents use
+var DefaultPrices = Prices{
+	openai.ChatModelGPT4oMini: {Prompt: 0.15, CachedPrompt: 0.075, Completion: 0.60},
+	openai.ChatModelGPT4o:     {Prompt: 2.50, CachedPrompt: 1.25, Completion: 10.00},
+}
+
+/*
+LoadPrices reads a price table from a JSON file, in the same shape as Prices, on
+top of the default prices, so it only has to list models that are missing or
+whose prices changed.
+*/
+func LoadPrices(path string) (Prices, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading prices: %w", err)
+	}
+
+	var prices Prices
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("error parsing prices: %w", err)
+	}
+
+	out := maps.Clone(DefaultPrices)
+	maps.Copy(out, prices)
+	return out, nil
+}
+
+/*
+meter adds up the usage of every completion a client's agents make, and tells
+them when it exceeds the budget in dollars or tokens. Budgets of zero mean there
+is none.
+*/
+type meter struct {
+	mu      sync.Mutex
+	prices  Prices
+	budget  float64
+	tokens  int64
+	total   Usage
+	unknown map[string]bool
+}
+
+func newMeter() *meter {
+	return &meter{prices: DefaultPrices, unknown: map[string]bool{}}
+}
+
+// record accounts for a completion, returning its usage and the total so far
+func (m *meter) record(model string, usage openai.CompletionUsage) (Usage, Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cached := usage.PromptTokensDetails.CachedTokens
+	u := Usage{
+		PromptTokens:     usage.PromptTokens,
+		CachedTokens:     cached,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+
+	price, ok := m.prices[model]
+	if !ok && !m.unknown[model] {
+		m.unknown[model] = true
+		log.Warn("No price known for model, its cost is counted as zero", "model", model)
+	}
+	u.Cost = (float64(usage.PromptTokens-cached)*price.Prompt +
+		float64(cached)*price.CachedPrompt +
+		float64(usage.CompletionTokens)*price.Completion) / 1e6
+
+	m.total.add(u)
+	return u, m.total
+}
+
+// check returns ErrBudgetExceeded once the total cost or number of tokens is over the budget
+func (m *meter) check() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.budget > 0 && m.total.Cost > m.budget {
+		return fmt.Errorf("%w: the run cost $%.4f of a $%.2f budget", ErrBudgetExceeded, m.total.Cost, m.budget)
+	}
+	if m.tokens > 0 && m.total.TotalTokens > m.tokens {
+		return fmt.Errorf("%w: the run took %d of %d tokens", ErrBudgetExceeded, m.total.TotalTokens, m.tokens)
+	}
+	return nil
+}
+
+func (m *meter) usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
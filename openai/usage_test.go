@@ -0,0 +1,125 @@
+package openai
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+// completionUsage builds the usage of a completion the way the API reports it
+func completionUsage(prompt, cached, completion int64) openai.CompletionUsage {
+	return openai.CompletionUsage{
+		PromptTokens:        prompt,
+		CompletionTokens:    completion,
+		TotalTokens:         prompt + completion,
+		PromptTokensDetails: openai.CompletionUsagePromptTokensDetails{CachedTokens: cached},
+	}
+}
+
+func TestMeterRecord(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		usage openai.CompletionUsage
+		cost  float64
+	}{
+		{"prompt and completion", openai.ChatModelGPT4oMini, completionUsage(1_000_000, 0, 1_000_000), 0.15 + 0.60},
+		{"cached prompt tokens at their own price", openai.ChatModelGPT4oMini, completionUsage(1_000_000, 400_000, 0), 0.6*0.15 + 0.4*0.075},
+		{"another model", openai.ChatModelGPT4o, completionUsage(2_000, 1_000, 500), (1_000*2.50 + 1_000*1.25 + 500*10.00) / 1e6},
+		{"unknown model costs nothing", "gpt-unknown", completionUsage(1_000, 0, 1_000), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, total := newMeter().record(tt.model, tt.usage)
+
+			if math.Abs(usage.Cost-tt.cost) > 1e-12 {
+				t.Errorf("cost = %v, want %v", usage.Cost, tt.cost)
+			}
+			if usage.PromptTokens != tt.usage.PromptTokens || usage.CachedTokens != tt.usage.PromptTokensDetails.CachedTokens ||
+				usage.CompletionTokens != tt.usage.CompletionTokens || usage.TotalTokens != tt.usage.TotalTokens {
+				t.Errorf("usage = %+v, want the tokens of %+v", usage, tt.usage)
+			}
+			if total != usage {
+				t.Errorf("total = %+v after one completion, want %+v", total, usage)
+			}
+		})
+	}
+}
+
+func TestMeterAddsUp(t *testing.T) {
+	m := newMeter()
+	m.record(openai.ChatModelGPT4oMini, completionUsage(1_000_000, 0, 0))
+	_, total := m.record(openai.ChatModelGPT4o, completionUsage(0, 0, 1_000_000))
+
+	want := Usage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000, TotalTokens: 2_000_000, Cost: 0.15 + 10.00}
+	if math.Abs(total.Cost-want.Cost) > 1e-12 {
+		t.Errorf("total cost = %v, want %v", total.Cost, want.Cost)
+	}
+	total.Cost = want.Cost
+	if total != want || m.usage() != total {
+		t.Errorf("total = %+v, usage() = %+v, want %+v", total, m.usage(), want)
+	}
+}
+
+func TestMeterCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   float64
+		tokens   int64
+		exceeded bool
+	}{
+		{"no budget", 0, 0, false},
+		{"under the dollar budget", 1.00, 0, false},
+		{"over the dollar budget", 0.50, 0, true},
+		{"under the token budget", 0, 2_000_000, false},
+		{"over the token budget", 0, 1_500_000, true},
+		{"over one of both", 1.00, 1_500_000, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMeter()
+			m.budget, m.tokens = tt.budget, tt.tokens
+
+			// $0.75 for 2 million tokens.
+			m.record(openai.ChatModelGPT4oMini, completionUsage(1_000_000, 0, 1_000_000))
+
+			err := m.check()
+			if exceeded := errors.Is(err, ErrBudgetExceeded); exceeded != tt.exceeded {
+				t.Errorf("check() = %v, want exceeded %v", err, tt.exceeded)
+			}
+		})
+	}
+}
+
+func TestLoadPrices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	os.WriteFile(path, []byte(`{"gpt-4o-mini": {"prompt": 0.2, "completion": 0.8}, "gpt-custom": {"prompt": 1, "completion": 2}}`), 0o644)
+
+	prices, err := LoadPrices(path)
+	if err != nil {
+		t.Fatalf("LoadPrices: %v", err)
+	}
+
+	if got := prices[openai.ChatModelGPT4oMini]; got != (Price{Prompt: 0.2, Completion: 0.8}) {
+		t.Errorf("gpt-4o-mini = %+v, want the loaded price", got)
+	}
+	if got := prices["gpt-custom"]; got != (Price{Prompt: 1, Completion: 2}) {
+		t.Errorf("gpt-custom = %+v, want the loaded price", got)
+	}
+	if got := prices[openai.ChatModelGPT4o]; got != DefaultPrices[openai.ChatModelGPT4o] {
+		t.Errorf("gpt-4o = %+v, want the default price", got)
+	}
+	if DefaultPrices[openai.ChatModelGPT4oMini].Prompt != 0.15 {
+		t.Error("loading prices changed the defaults")
+	}
+
+	if _, err := LoadPrices(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadPrices of a missing file succeeded")
+	}
+}
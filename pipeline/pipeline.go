@@ -0,0 +1,285 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/browser"
+	"github.com/theapemachine/idrinkyourmilkshake/graphql"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/openai"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+	"github.com/theapemachine/idrinkyourmilkshake/schema"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+const (
+	defaultExplorers   = 4
+	defaultIterations  = 20
+	defaultMaxSections = 20
+)
+
+// Plan is how the planner divides the docs among the explorers
+type Plan struct {
+	Sections []Section `json:"sections" jsonschema:"description=The sections of the documentation that describe the API\\, each explored on its own,required"`
+}
+
+// Section is a part of the docs for one explorer to extract
+type Section struct {
+	Title string `json:"title" jsonschema:"description=Name of the section\\, e.g. Employees,required"`
+	URL   string `json:"url" jsonschema:"description=URL of the page the section starts on,required"`
+	Focus string `json:"focus" jsonschema:"description=What to extract from the section\\, e.g. the employee endpoints and the Employee model,required"`
+}
+
+/*
+Pipeline extracts a config with several agents instead of one, for docs too big
+to navigate, read and write a config from in a single conversation. A planner
+browses the docs and divides them into sections, explorers extract a partial
+config from one section each, in parallel and in tabs of their own, and a
+writer merges the partial configs into the final one. Every agent has its own
+conversation and only the tools its stage needs: the planner browses, the
+explorers browse and call the API, and the writer only writes.
+*/
+type Pipeline struct {
+	client      *openai.Client
+	url         string
+	brief       string
+	explorers   int
+	iterations  int
+	maxSections int
+	extract     func(Section) (string, error)
+	mu          sync.Mutex
+	calls       []openai.ToolCall
+	stopped     []string
+}
+
+// New creates a Pipeline extracting the docs at url
+func New(client *openai.Client, url string) *Pipeline {
+	p := &Pipeline{
+		client:      client,
+		url:         url,
+		explorers:   defaultExplorers,
+		iterations:  defaultIterations,
+		maxSections: defaultMaxSections,
+	}
+	p.extract = p.explorer
+	return p
+}
+
+// WithBrief adds instructions for every stage, e.g. what an earlier extraction already found
+func (p *Pipeline) WithBrief(brief string) *Pipeline {
+	p.brief = brief
+	return p
+}
+
+// WithExplorers sets how many explorers run at the same time
+func (p *Pipeline) WithExplorers(explorers int) *Pipeline {
+	p.explorers = max(explorers, 1)
+	return p
+}
+
+// WithIterations sets how many turns each agent gets
+func (p *Pipeline) WithIterations(iterations int) *Pipeline {
+	p.iterations = iterations
+	return p
+}
+
+// WithMaxSections sets how many sections of the plan are explored at most
+func (p *Pipeline) WithMaxSections(sections int) *Pipeline {
+	p.maxSections = sections
+	return p
+}
+
+// ToolCalls returns the tool calls of every agent of the last run, so the writer's citations can be resolved
+func (p *Pipeline) ToolCalls() []openai.ToolCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+/*
+Run plans, explores and writes. Sections whose explorer fails are left out and
+logged, so one confusing page doesn't cost the whole extraction, but the run
+fails when none of them could be explored. When any agent had to stop early,
+the config is marked partial with the reasons.
+*/
+func (p *Pipeline) Run(ctx context.Context) (*models.APIConfig, error) {
+	p.client.WithContext(ctx)
+	p.calls, p.stopped = nil, nil
+
+	plan, err := p.plan()
+	if err != nil {
+		return nil, err
+	}
+
+	partials := p.explore(ctx, plan.Sections)
+	if len(partials) == 0 {
+		return nil, fmt.Errorf("none of the %d sections could be explored", len(plan.Sections))
+	}
+
+	config, err := p.write(partials)
+	if err != nil {
+		return nil, err
+	}
+
+	config.MarkPartial(p.stopped...)
+	return config, nil
+}
+
+// plan has the planner browse the docs and divide them into sections
+func (p *Pipeline) plan() (*Plan, error) {
+	agent := openai.NewAgent(p.client, "planner").
+		WithTools(browser.Tools()...).
+		WithResponse("plan", "The sections of the documentation to explore", utils.GenerateSchema[Plan]())
+
+	buffer := openai.NewBuffer(
+		`
+		You are an advanced API integration expert, planning the extraction of an API from its documentation.
+		Browse the documentation and divide it into sections that can each be read on their own, such as one per resource or group of endpoints, plus one for authentication.
+		Don't extract anything yourself. For every section give its title, the URL of the page it starts on, and what should be extracted from it.
+		Other agents will each explore one section, so make sure together they cover every endpoint and data model without overlapping.
+		`,
+		fmt.Sprintf("Here is the documentation URL for the API: %s\n%s", p.url, p.brief),
+	)
+
+	result, err := agent.Run(buffer, p.iterations)
+	p.record(agent)
+	if err != nil {
+		return nil, fmt.Errorf("error planning: %w", err)
+	}
+
+	var plan Plan
+	if err := json.Unmarshal([]byte(result), &plan); err != nil {
+		return nil, fmt.Errorf("error parsing plan: %w", err)
+	}
+	if len(plan.Sections) == 0 {
+		return nil, fmt.Errorf("the planner found no sections to explore")
+	}
+
+	if len(plan.Sections) > p.maxSections {
+		log.Warn("Plan has more sections than will be explored", "sections", len(plan.Sections), "max", p.maxSections)
+		plan.Sections = plan.Sections[:p.maxSections]
+	}
+
+	log.Info("Planned extraction", "sections", len(plan.Sections))
+	return &plan, nil
+}
+
+// partial is what an explorer extracted from its section
+type partial struct {
+	section Section
+	config  string
+}
+
+// explore runs an explorer per section, at most p.explorers at a time, and returns the partial configs in plan order
+func (p *Pipeline) explore(ctx context.Context, sections []Section) []partial {
+	results := make([]*partial, len(sections))
+	slots := make(chan struct{}, p.explorers)
+
+	var wg sync.WaitGroup
+	for i, section := range sections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			config, err := p.extract(section)
+			if err != nil {
+				log.Error("Explorer failed", "section", section.Title, "error", err)
+				return
+			}
+			results[i] = &partial{section: section, config: config}
+		}()
+	}
+	wg.Wait()
+
+	var out []partial
+	for _, result := range results {
+		if result != nil {
+			out = append(out, *result)
+		}
+	}
+	return out
+}
+
+// explorer extracts a partial config from one section, browsing in a tab of its own
+func (p *Pipeline) explorer(section Section) (string, error) {
+	tab := browser.NewTab()
+	defer tab.Close()
+
+	agent := openai.NewAgent(p.client, "explorer: "+section.Title).
+		WithTools(append(tab.Tools(),
+			request.NewHTTPRequest(),
+			graphql.NewGraphQLIntrospector(),
+			schema.NewSchemaInferrer(),
+		)...).
+		WithResponse("api_config", "The part of the API configuration found in this section", utils.GenerateSchema[models.APIConfig]())
+
+	buffer := openai.NewBuffer(
+		`
+		You are an advanced API integration expert, extracting one section of an API's documentation into a partial configuration file for an API Integration Engine.
+		You have access to a Chrome browser and an HTTP request tool, and only need to cover your own section: other agents extract the rest.
+		Describe every data model of the section as a JSON Schema under schemas, and list its endpoints with their request and response schemas as $ref references such as #/schemas/Employee.
+		Fill in the base URL and auth only when your section documents them.
+		When the documentation is vague about a response, fetch real samples with the HTTP request tool and pass them to the schema inference tool, together with the documented schema, to find out what the API really returns.
+		For GraphQL APIs, use the GraphQL introspection tool to discover the schema.
+		Every tool output starts with its tool_call_id. Under citations, cite where you found each endpoint, the auth and each schema field, with the tool_call_id of the output, a short quote copied verbatim from it, and a confidence from 0 to 1.
+		`,
+		fmt.Sprintf("Your section is %q, starting at %s.\nExtract: %s\n%s", section.Title, section.URL, section.Focus, p.brief),
+	)
+
+	result, err := agent.Run(buffer, p.iterations)
+	p.record(agent)
+	return result, err
+}
+
+// write has the writer merge the partial configs into the final config
+func (p *Pipeline) write(partials []partial) (*models.APIConfig, error) {
+	agent := openai.NewAgent(p.client, "writer").
+		WithResponse("api_config", "The API configuration", utils.GenerateSchema[models.APIConfig]())
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "The documentation is at %s.\n%s\n", p.url, p.brief)
+	for _, partial := range partials {
+		fmt.Fprintf(&prompt, "\n--- %s (%s)\n\n%s\n", partial.section.Title, partial.section.URL, partial.config)
+	}
+
+	buffer := openai.NewBuffer(
+		`
+		You are an advanced API integration expert, writing the configuration file for an API Integration Engine.
+		Other agents each extracted one section of an API's documentation into a partial configuration. Merge them into one complete configuration.
+		Keep every endpoint and data model, drop duplicates, give endpoints and data models consistent names, and make sure every $ref points to a schema that exists.
+		Take the base URL and auth from the sections that document them, and add jobs that use the endpoints.
+		Keep the citations of everything you keep exactly as they are, including their tool_call_id, quote and confidence.
+		`,
+		prompt.String(),
+	)
+
+	result, err := agent.Run(buffer, p.iterations)
+	p.record(agent)
+	if err != nil {
+		return nil, fmt.Errorf("error writing config: %w", err)
+	}
+
+	return models.ParseAPIConfig([]byte(result))
+}
+
+// record keeps the tool calls of an agent, and why it stopped early if it did
+func (p *Pipeline) record(agent *openai.Agent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, agent.ToolCalls()...)
+	if reason := agent.Stopped(); reason != "" {
+		p.stopped = append(p.stopped, agent.Name()+": "+reason)
+	}
+}
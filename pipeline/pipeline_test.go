@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/option"
+	"github.com/theapemachine/idrinkyourmilkshake/openai"
+)
+
+/*
+fakeModel stands in for the OpenAI API. It answers the planner with a fixed plan
+and the writer with a fixed config, telling them apart by the response format
+they ask for, and keeps the prompts the writer got.
+*/
+type fakeModel struct {
+	*httptest.Server
+
+	plan    Plan
+	mu      sync.Mutex
+	written []string
+}
+
+func newFakeModel(t *testing.T, sections ...Section) *fakeModel {
+	t.Helper()
+
+	fm := &fakeModel{plan: Plan{Sections: sections}}
+	fm.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Messages []struct {
+				Role    string          `json:"role"`
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+			ResponseFormat struct {
+				JSONSchema struct {
+					Name string `json:"name"`
+				} `json:"json_schema"`
+			} `json:"response_format"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var answer any = fm.plan
+		if params.ResponseFormat.JSONSchema.Name != "plan" {
+			fm.mu.Lock()
+			fm.written = append(fm.written, text(params.Messages[len(params.Messages)-1].Content))
+			fm.mu.Unlock()
+
+			answer = map[string]any{"integration": "staff", "base_url": "https://api.example.com", "jobs": []any{}}
+		}
+
+		content, _ := json.Marshal(answer)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion",
+			"created": time.Now().Unix(),
+			"model":   "gpt-4o-mini",
+			"choices": []any{map[string]any{
+				"index":         0,
+				"finish_reason": "stop",
+				"message":       map[string]any{"role": "assistant", "content": string(content)},
+			}},
+			"usage": map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	}))
+	t.Cleanup(fm.Close)
+
+	return fm
+}
+
+// text returns the text of a message, sent either as a string or as parts
+func text(content json.RawMessage) string {
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return s
+	}
+
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(content, &parts)
+	for _, part := range parts {
+		s += part.Text
+	}
+	return s
+}
+
+// newTestPipeline runs against the fake model, with explorers that fail for the sections in failing
+func newTestPipeline(fm *fakeModel, failing ...string) *Pipeline {
+	client := openai.NewClient("test", option.WithBaseURL(fm.URL), option.WithMaxRetries(0))
+	p := New(client, "https://docs.example.com").WithExplorers(3)
+
+	order := map[string]int{}
+	for i, section := range fm.plan.Sections {
+		order[section.Title] = i
+	}
+
+	p.extract = func(section Section) (string, error) {
+		for _, title := range failing {
+			if section.Title == title {
+				return "", fmt.Errorf("lost in the docs of %s", title)
+			}
+		}
+		// Later sections finish first, so the order can't come from finishing.
+		time.Sleep(time.Duration(len(order)-order[section.Title]) * 5 * time.Millisecond)
+		return fmt.Sprintf(`{"endpoints":[{"name":"list_%s"}]}`, strings.ToLower(section.Title)), nil
+	}
+	return p
+}
+
+func TestRunCombinesSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing []string
+		want    []string
+		missing []string
+	}{
+		{"every section", nil, []string{"Auth", "Employees", "Teams"}, nil},
+		{"a failed explorer is left out", []string{"Employees"}, []string{"Auth", "Teams"}, []string{"Employees"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm := newFakeModel(t,
+				Section{Title: "Auth", URL: "https://docs.example.com/auth", Focus: "the auth"},
+				Section{Title: "Employees", URL: "https://docs.example.com/employees", Focus: "the employee endpoints"},
+				Section{Title: "Teams", URL: "https://docs.example.com/teams", Focus: "the team endpoints"},
+			)
+
+			config, err := newTestPipeline(fm, tt.failing...).Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if config.Integration != "staff" || config.Partial {
+				t.Errorf("got config %+v, want the writer's complete config", config)
+			}
+
+			if len(fm.written) != 1 {
+				t.Fatalf("the writer was asked %d times, want once", len(fm.written))
+			}
+			prompt := fm.written[0]
+
+			// Every explored section reaches the writer under its title and URL, in plan order.
+			last := -1
+			for _, title := range tt.want {
+				header := fmt.Sprintf("--- %s (https://docs.example.com/%s)", title, strings.ToLower(title))
+				at := strings.Index(prompt, header)
+				if at < 0 {
+					t.Fatalf("the writer's prompt has no %q:\n%s", header, prompt)
+				}
+				if at < last {
+					t.Errorf("section %s is out of plan order:\n%s", title, prompt)
+				}
+				last = at
+
+				if partial := fmt.Sprintf(`{"endpoints":[{"name":"list_%s"}]}`, strings.ToLower(title)); !strings.Contains(prompt[at:], partial) {
+					t.Errorf("the writer's prompt is missing the partial config of %s", title)
+				}
+			}
+			for _, title := range tt.missing {
+				if strings.Contains(prompt, "--- "+title) {
+					t.Errorf("the writer got the failed section %s:\n%s", title, prompt)
+				}
+			}
+		})
+	}
+}
+
+func TestRunFailsWhenNoSectionIsExplored(t *testing.T) {
+	fm := newFakeModel(t,
+		Section{Title: "Auth", URL: "https://docs.example.com/auth"},
+		Section{Title: "Employees", URL: "https://docs.example.com/employees"},
+	)
+
+	_, err := newTestPipeline(fm, "Auth", "Employees").Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "none of the 2 sections") {
+		t.Fatalf("Run returned %v, want that none of the sections could be explored", err)
+	}
+	if len(fm.written) != 0 {
+		t.Error("the writer ran without any sections")
+	}
+}
+
+func TestRunExploresAtMostMaxSections(t *testing.T) {
+	fm := newFakeModel(t,
+		Section{Title: "Auth", URL: "https://docs.example.com/auth"},
+		Section{Title: "Employees", URL: "https://docs.example.com/employees"},
+		Section{Title: "Teams", URL: "https://docs.example.com/teams"},
+	)
+
+	if _, err := newTestPipeline(fm).WithMaxSections(2).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if strings.Contains(fm.written[0], "--- Teams") {
+		t.Errorf("the section past the limit was explored:\n%s", fm.written[0])
+	}
+}
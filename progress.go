@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/theapemachine/idrinkyourmilkshake/openai"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+/*
+progressFlag registers the flag that picks how the events of the agents are shown
+on stderr: as text, as JSON lines for other programs, or not at all. The returned
+function gives the handler once the flags have been parsed.
+*/
+func progressFlag(flags *flag.FlagSet) func() (openai.Handler, error) {
+	format := flags.String("progress", "text", "Show the progress of the agents on stderr as text, json or off")
+
+	return func() (openai.Handler, error) {
+		switch *format {
+		case "text":
+			return textProgress(os.Stderr), nil
+		case "json":
+			encoder := json.NewEncoder(os.Stderr)
+			return func(event openai.Event) { encoder.Encode(event) }, nil
+		case "off":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unknown progress format %q, expected text, json or off", *format)
+		}
+	}
+}
+
+// textProgress renders events as short lines
+func textProgress(w io.Writer) openai.Handler {
+	return func(event openai.Event) {
+		switch event.Type {
+		case openai.EventIteration:
+			fmt.Fprintf(w, "[%s] turn %d/%d\n", event.Agent, event.Iteration, event.MaxIterations)
+		case openai.EventToolCall:
+			fmt.Fprintf(w, "[%s]   -> %s %s\n", event.Agent, event.Call.Name, arguments(event.Call.Arguments))
+		case openai.EventToolResult:
+			if event.Error != "" {
+				fmt.Fprintf(w, "[%s]   <- %s failed after %s: %s\n", event.Agent, event.Call.Name, event.Duration.Round(time.Millisecond), event.Error)
+				return
+			}
+			fmt.Fprintf(w, "[%s]   <- %s %s, %s\n", event.Agent, event.Call.Name, event.Duration.Round(time.Millisecond), size(len(event.Call.Output)))
+		case openai.EventUsage:
+			fmt.Fprintf(w, "[%s]   %d tokens in (%d cached), %d out, $%.4f ($%.4f so far)\n",
+				event.Agent, event.Usage.PromptTokens, event.Usage.CachedTokens, event.Usage.CompletionTokens, event.Usage.Cost, event.Total.Cost)
+		case openai.EventTruncated:
+			fmt.Fprintf(w, "[%s] dropped %d messages to fit the context\n", event.Agent, event.Dropped)
+		case openai.EventStopped:
+			fmt.Fprintf(w, "[%s] stopped, %s, asking for a final answer\n", event.Agent, event.Reason)
+		case openai.EventResult:
+			fmt.Fprintf(w, "[%s] done, %s\n", event.Agent, size(len(event.Result)))
+		case openai.EventError:
+			fmt.Fprintf(w, "[%s] failed: %s\n", event.Agent, event.Error)
+		}
+	}
+}
+
+// arguments summarizes the arguments of a tool call on one line
+func arguments(args map[string]any) string {
+	for _, key := range []string{"url", "selector", "script"} {
+		if value, ok := args[key].(string); ok {
+			value = strings.Join(strings.Fields(value), " ")
+			return utils.Truncate(value, 80)
+		}
+	}
+	return ""
+}
+
+func size(bytes int) string {
+	if bytes < 1024 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.1f kB", float64(bytes)/1024)
+}
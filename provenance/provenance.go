@@ -0,0 +1,248 @@
+package provenance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/openai"
+)
+
+// pathParameter matches OpenAPI style path parameters such as {id}
+var pathParameter = regexp.MustCompile(`\{[^{}/]+\}`)
+
+/*
+Resolve turns the citations the model put in an extracted config into the
+provenance of its fields, using the tool calls of the extraction. A citation
+carries the model's confidence and gets the URL of the tool call it names. Its
+snippet is only kept when it really occurs in that tool's output, so made up
+quotes don't end up looking like evidence. Endpoints that a successful
+http_request reached are marked as verified. The auth isn't, since those
+requests were sent with the -auth credentials, if any, and VerifyAuth checks it.
+
+The account ID, base URL, auth block, every endpoint, and every schema and its
+properties get an entry, cited or not, so reviewers can see what the model
+didn't back up. The citations are dropped from the config once resolved.
+*/
+func Resolve(config *models.APIConfig, calls []openai.ToolCall) {
+	byID := map[string]openai.ToolCall{}
+	for _, call := range calls {
+		byID[call.ID] = call
+	}
+
+	config.Provenance = map[string]models.Provenance{}
+	extracted := models.Provenance{Source: models.SourceExtracted}
+
+	if config.AccountID != "" {
+		config.Provenance["account_id"] = extracted
+	}
+	if config.BaseURL != "" {
+		config.Provenance["base_url"] = extracted
+	}
+	if auth.Type(config.Auth) != models.AuthNone {
+		config.Provenance["auth"] = extracted
+	}
+	for _, endpoint := range config.Endpoints {
+		config.Provenance["endpoints."+endpoint.Name] = extracted
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.Schemas)) {
+		properties(config.Provenance, "schemas."+name, config.Schemas[name])
+	}
+
+	for _, citation := range config.Citations {
+		provenance, ok := config.Provenance[citation.Field]
+		if !ok {
+			provenance = extracted
+		}
+		if provenance.ToolCallID != "" && provenance.Snippet != "" {
+			// The first citation with a snippet that checks out wins.
+			continue
+		}
+		confidence := min(max(citation.Confidence, 0), 1)
+		provenance.Confidence = &confidence
+
+		if call, ok := byID[citation.ToolCallID]; ok {
+			provenance.URL, provenance.ToolCallID = call.URL, call.ID
+			if quotes(call.Output, citation.Snippet) {
+				provenance.Snippet = citation.Snippet
+			} else {
+				log.Warn("Cited snippet doesn't occur in the tool output", "field", citation.Field, "tool_call_id", call.ID)
+			}
+		} else if citation.ToolCallID != "" {
+			log.Warn("Citation names an unknown tool call", "field", citation.Field, "tool_call_id", citation.ToolCallID)
+		}
+
+		config.Provenance[citation.Field] = provenance
+	}
+	config.Citations = nil
+
+	for _, endpoint := range config.Endpoints {
+		call, ok := request(config, endpoint, calls)
+		if !ok {
+			continue
+		}
+
+		path := "endpoints." + endpoint.Name
+		provenance := config.Provenance[path]
+		provenance.Verified = true
+		if provenance.ToolCallID == "" {
+			provenance.URL, provenance.ToolCallID = call.URL, call.ID
+		}
+		config.Provenance[path] = provenance
+	}
+}
+
+/*
+VerifyAuth checks the extracted auth block against the live API, and marks it as
+verified when it works. It repeats the GET requests of the extraction that
+reached an endpoint, first without credentials, and for an endpoint that refuses
+those, with an authenticator built from the extracted auth. Only a success then
+proves anything, since a public endpoint answers whatever the credentials are.
+*/
+func VerifyAuth(ctx context.Context, config *models.APIConfig, calls []openai.ToolCall, client *http.Client) bool {
+	provenance, ok := config.Provenance["auth"]
+	if !ok || auth.Type(config.Auth) == models.AuthNone {
+		return false
+	}
+
+	authenticator, err := auth.New(config.Auth, config.BaseURL, client)
+	if err == nil {
+		err = authenticator.Authenticate(ctx)
+	}
+	if err != nil {
+		log.Warn("Could not authenticate with the extracted auth", "error", err)
+		return false
+	}
+
+	for _, endpoint := range config.Endpoints {
+		if method := strings.ToUpper(endpoint.Method); method != "" && method != http.MethodGet {
+			continue
+		}
+
+		call, ok := request(config, endpoint, calls)
+		if !ok {
+			continue
+		}
+
+		if status, err := send(ctx, client, call.URL, nil); err != nil || (status != http.StatusUnauthorized && status != http.StatusForbidden) {
+			continue
+		}
+
+		status, err := send(ctx, client, call.URL, authenticator)
+		if err != nil {
+			log.Warn("Could not verify the extracted auth", "endpoint", endpoint.Name, "error", err)
+			continue
+		}
+		if status >= 200 && status < 300 {
+			provenance.Verified = true
+			config.Provenance["auth"] = provenance
+			return true
+		}
+	}
+
+	return false
+}
+
+// send makes a GET request, with credentials when an authenticator is given, and returns the status
+func send(ctx context.Context, client *http.Client, target string, authenticator auth.Authenticator) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	if authenticator != nil {
+		if err := authenticator.Apply(req); err != nil {
+			return 0, fmt.Errorf("error applying auth: %w", err)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// properties adds an entry for a schema and, recursively, each of its properties
+func properties(out map[string]models.Provenance, path string, schema *models.Schema) {
+	if schema == nil {
+		return
+	}
+
+	out[path] = models.Provenance{Source: models.SourceExtracted}
+	for name, property := range schema.Properties {
+		properties(out, path+".properties."+name, property)
+	}
+}
+
+/*
+request finds an http_request tool call that reached the endpoint. Only tool calls
+that succeeded are recorded, so any match means the endpoint answered.
+*/
+func request(config *models.APIConfig, endpoint models.Endpoint, calls []openai.ToolCall) (openai.ToolCall, bool) {
+	target := endpoint.Path
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = strings.TrimRight(config.BaseURL, "/") + "/" + strings.TrimLeft(target, "/")
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return openai.ToolCall{}, false
+	}
+
+	parts := pathParameter.Split(strings.TrimRight(u.Path, "/"), -1)
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	path := regexp.MustCompile("^" + strings.Join(parts, "[^/]+") + "/?$")
+
+	method := strings.ToUpper(endpoint.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	for _, call := range calls {
+		if call.Name != "http_request" {
+			continue
+		}
+
+		callMethod, _ := call.Arguments["method"].(string)
+		if callMethod == "" {
+			callMethod = http.MethodGet
+		}
+		if !strings.EqualFold(callMethod, method) {
+			continue
+		}
+
+		reached, err := url.Parse(call.URL)
+		if err != nil || !strings.EqualFold(reached.Host, u.Host) {
+			continue
+		}
+		if path.MatchString(reached.Path) {
+			return call, true
+		}
+	}
+
+	return openai.ToolCall{}, false
+}
+
+// quotes reports whether a snippet occurs in a tool's output, ignoring differences in whitespace
+func quotes(output, snippet string) bool {
+	snippet = strings.Join(strings.Fields(snippet), " ")
+	if snippet == "" {
+		return false
+	}
+	return strings.Contains(strings.Join(strings.Fields(output), " "), snippet)
+}
@@ -0,0 +1,74 @@
+package provenance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/openai"
+)
+
+// protectedAPI only answers /employees with the right token, and /status to anyone
+func protectedAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /employees", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok": true}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVerifyAuth(t *testing.T) {
+	server := protectedAPI(t)
+
+	tests := []struct {
+		name     string
+		token    string
+		endpoint string
+		want     bool
+	}{
+		{"extracted auth works", "good", "/employees", true},
+		{"extracted auth is wrong", "bad", "/employees", false},
+		{"only a public endpoint was reached", "good", "/status", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &models.APIConfig{
+				BaseURL:   server.URL,
+				Auth:      models.Auth{Type: models.AuthBearer, Bearer: &models.BearerAuth{Token: tt.token}},
+				Endpoints: []models.Endpoint{{Name: "list", Method: "GET", Path: tt.endpoint}},
+			}
+			// The extraction reached the endpoint, with whatever -auth credentials it had.
+			calls := []openai.ToolCall{{ID: "call_1", Name: "http_request", Arguments: map[string]any{"method": "GET"}, URL: server.URL + tt.endpoint}}
+
+			Resolve(config, calls)
+			if !config.Provenance["endpoints.list"].Verified {
+				t.Fatal("the endpoint the extraction reached isn't verified")
+			}
+			if config.Provenance["auth"].Verified {
+				t.Fatal("Resolve verified the auth from the extraction's requests")
+			}
+
+			if got := VerifyAuth(context.Background(), config, calls, server.Client()); got != tt.want {
+				t.Errorf("VerifyAuth = %v, want %v", got, tt.want)
+			}
+			if got := config.Provenance["auth"].Verified; got != tt.want {
+				t.Errorf("auth verified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
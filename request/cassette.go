@@ -0,0 +1,442 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// CassetteMode determines whether a Cassette hits the network or replays from disk
+type CassetteMode string
+
+const (
+	// ModeRecord always sends requests to the network and records every interaction
+	ModeRecord CassetteMode = "record"
+	// ModeReplay only serves recorded interactions and fails on anything unknown
+	ModeReplay CassetteMode = "replay"
+	// ModeAuto replays recorded interactions and records the ones it has not seen yet
+	ModeAuto CassetteMode = "auto"
+)
+
+const redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers that never make it to disk in plain text
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// DefaultRedactedFields are the JSON and form body fields that never make it to disk in plain text
+var DefaultRedactedFields = []string{
+	"password",
+	"client_secret",
+	"access_token",
+	"refresh_token",
+	"api_key",
+	"token",
+}
+
+// RecordedRequest is the on-disk representation of an outgoing request
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the on-disk representation of a response
+type RecordedResponse struct {
+	Status     string      `json:"status"`
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Interaction is a single request/response pair stored on a cassette
+type Interaction struct {
+	Request    RecordedRequest  `json:"request"`
+	Response   RecordedResponse `json:"response"`
+	RecordedAt time.Time        `json:"recorded_at"`
+
+	replayed bool
+}
+
+/*
+Matcher decides whether an outgoing request corresponds to a recorded one. The
+request and body it gets are redacted the way they would be recorded, with JSON
+keys sorted, so they compare like for like with the recorded request.
+*/
+type Matcher func(req *http.Request, body []byte, recorded RecordedRequest) bool
+
+// MatchMethod matches requests on their HTTP method
+func MatchMethod(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+	return strings.EqualFold(req.Method, recorded.Method)
+}
+
+// MatchURL matches requests on their full URL, including the query string
+func MatchURL(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+	return req.URL.String() == recorded.URL
+}
+
+// MatchBody matches requests on their body, once redacted and canonicalized like the recorded one
+func MatchBody(_ *http.Request, body []byte, recorded RecordedRequest) bool {
+	return string(body) == recorded.Body
+}
+
+// MatchURLIgnoringQuery matches requests on their URL, disregarding the given
+// query parameters, which is useful for timestamps and nonces.
+func MatchURLIgnoringQuery(params ...string) Matcher {
+	strip := func(u *url.URL) string {
+		c := *u
+		q := c.Query()
+		for _, p := range params {
+			q.Del(p)
+		}
+		c.RawQuery = q.Encode()
+		return c.String()
+	}
+
+	return func(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+		u, err := url.Parse(recorded.URL)
+		if err != nil {
+			return false
+		}
+		return strip(req.URL) == strip(u)
+	}
+}
+
+// MatchHeader matches requests on the value of the given header
+func MatchHeader(name string) Matcher {
+	return func(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+		return req.Header.Get(name) == recorded.Headers.Get(name)
+	}
+}
+
+/*
+Cassette is an http.RoundTripper that records interactions to a file on disk and
+replays them later, so runs against third-party APIs are reproducible offline.
+Sensitive headers, query parameters and body fields are redacted before anything
+is written. Query parameters are redacted when their name is on either list.
+*/
+type Cassette struct {
+	Path           string
+	Mode           CassetteMode
+	Matchers       []Matcher
+	RedactHeaders  []string
+	RedactFields   []string
+	Transport      http.RoundTripper
+	Interactions   []*Interaction
+	mu             sync.Mutex
+	compile        sync.Once
+	fieldsPatterns []*regexp.Regexp
+}
+
+// NewCassette loads the cassette at path, or starts an empty one if it does not exist yet
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	switch mode {
+	case ModeRecord, ModeReplay, ModeAuto:
+	default:
+		return nil, fmt.Errorf("unknown cassette mode: %s", mode)
+	}
+
+	cassette := &Cassette{
+		Path:          path,
+		Mode:          mode,
+		Matchers:      []Matcher{MatchMethod, MatchURL},
+		RedactHeaders: DefaultRedactedHeaders,
+		RedactFields:  DefaultRedactedFields,
+		Transport:     http.DefaultTransport,
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		if mode == ModeReplay {
+			return nil, fmt.Errorf("cassette not found: %s", path)
+		}
+		log.Info("Starting new cassette", "path", path, "mode", mode)
+	case err != nil:
+		return nil, fmt.Errorf("error reading cassette: %w", err)
+	default:
+		if err := json.Unmarshal(data, &cassette.Interactions); err != nil {
+			return nil, fmt.Errorf("error parsing cassette: %w", err)
+		}
+		log.Info("Loaded cassette", "path", path, "mode", mode, "interactions", len(cassette.Interactions))
+	}
+
+	return cassette, nil
+}
+
+/*
+RoundTrip implements http.RoundTripper. The cassette is only locked to look up
+and add interactions, so requests that go out to the network run concurrently.
+*/
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recordedBody := c.redactBody(body)
+	recordedURL := c.redactURL(req.URL)
+
+	if c.Mode != ModeRecord {
+		// Matchers see the request the way it would be recorded.
+		recordedReq := req.Clone(req.Context())
+		recordedReq.URL, recordedReq.Header = recordedURL, c.redactHeaders(req.Header)
+
+		c.mu.Lock()
+		interaction := c.find(recordedReq, []byte(recordedBody))
+		c.mu.Unlock()
+
+		if interaction != nil {
+			log.Info("Replaying recorded interaction", "method", req.Method, "url", recordedURL)
+			return interaction.response(req), nil
+		}
+
+		if c.Mode == ModeReplay {
+			return nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, recordedURL)
+		}
+	}
+
+	resp, err := c.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	interaction := &Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     recordedURL.String(),
+			Headers: c.redactHeaders(req.Header),
+			Body:    recordedBody,
+		},
+		Response: RecordedResponse{
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+			Headers:    c.redactHeaders(withoutContentLength(resp.Header)),
+			Body:       c.redactBody(respBody),
+		},
+		RecordedAt: time.Now().UTC(),
+		replayed:   true,
+	}
+
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, interaction)
+	err = c.save()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Recorded interaction", "method", req.Method, "url", recordedURL, "status", resp.StatusCode)
+
+	// Hand the caller the real, unredacted body.
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// find returns the first matching interaction that has not been replayed yet,
+// falling back to any matching interaction so repeated calls keep working.
+func (c *Cassette) find(req *http.Request, body []byte) *Interaction {
+	var fallback *Interaction
+
+	for _, interaction := range c.Interactions {
+		if !c.matches(req, body, interaction.Request) {
+			continue
+		}
+
+		if !interaction.replayed {
+			interaction.replayed = true
+			return interaction
+		}
+
+		if fallback == nil {
+			fallback = interaction
+		}
+	}
+
+	return fallback
+}
+
+func (c *Cassette) matches(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	for _, matcher := range c.Matchers {
+		if !matcher(req, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cassette) save() error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c.Interactions); err != nil {
+		return fmt.Errorf("error encoding cassette: %w", err)
+	}
+
+	if dir := filepath.Dir(c.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("error creating cassette directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(c.Path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Cassette) redactHeaders(headers http.Header) http.Header {
+	out := headers.Clone()
+	for name := range out {
+		if slices.ContainsFunc(c.RedactHeaders, func(h string) bool {
+			return strings.EqualFold(h, name)
+		}) {
+			out[name] = []string{redacted}
+		}
+	}
+	return out
+}
+
+/*
+redactURL masks the query parameters named like a sensitive header or body field,
+such as an api_key an authenticator put there. URLs without any are left as
+they are, so cassettes recorded before keep matching.
+*/
+func (c *Cassette) redactURL(u *url.URL) *url.URL {
+	out := *u
+	if out.RawQuery == "" {
+		return &out
+	}
+
+	query := out.Query()
+	changed := false
+	for name, values := range query {
+		if !slices.ContainsFunc(slices.Concat(c.RedactHeaders, c.RedactFields), func(s string) bool {
+			return strings.EqualFold(s, name)
+		}) {
+			continue
+		}
+		for i := range values {
+			values[i] = redacted
+		}
+		changed = true
+	}
+
+	if changed {
+		out.RawQuery = query.Encode()
+	}
+	return &out
+}
+
+/*
+redactBody masks sensitive fields in JSON and form-encoded bodies. JSON bodies
+are handled structurally so nested fields are covered too; anything else falls
+back to key=value patterns.
+*/
+func (c *Cassette) redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var doc any
+	if err := json.Unmarshal(body, &doc); err == nil {
+		out, err := json.Marshal(c.redactValue(doc))
+		if err == nil {
+			return string(out)
+		}
+	}
+
+	c.compile.Do(func() {
+		for _, field := range c.RedactFields {
+			c.fieldsPatterns = append(c.fieldsPatterns, regexp.MustCompile(`(?i)(\b`+regexp.QuoteMeta(field)+`=)[^&\s]*`))
+		}
+	})
+
+	str := string(body)
+	for _, pattern := range c.fieldsPatterns {
+		str = pattern.ReplaceAllString(str, "${1}"+redacted)
+	}
+	return str
+}
+
+func (c *Cassette) redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, val := range v {
+			if slices.ContainsFunc(c.RedactFields, func(f string) bool {
+				return strings.EqualFold(f, key)
+			}) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = c.redactValue(val)
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = c.redactValue(val)
+		}
+	}
+	return value
+}
+
+func (i *Interaction) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        i.Response.Status,
+		StatusCode:    i.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Response.Headers.Clone(),
+		Body:          io.NopCloser(strings.NewReader(i.Response.Body)),
+		ContentLength: int64(len(i.Response.Body)),
+		Request:       req,
+	}
+}
+
+// withoutContentLength drops the length header, since redaction changes the body size
+func withoutContentLength(headers http.Header) http.Header {
+	out := headers.Clone()
+	out.Del("Content-Length")
+	return out
+}
+
+// readRequestBody reads the request body and puts it back so it can be sent afterwards
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
@@ -0,0 +1,197 @@
+package request
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestCassette(t *testing.T, path string, mode CassetteMode) *Cassette {
+	t.Helper()
+
+	cassette, err := NewCassette(path, mode)
+	if err != nil {
+		t.Fatalf("NewCassette: %v", err)
+	}
+	cassette.Matchers = []Matcher{MatchMethod, MatchURL, MatchBody}
+	return cassette
+}
+
+func post(t *testing.T, transport http.RoundTripper, url, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"abc","user":"ada"}`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorded := `{"username":"ada","password":"hunter2"}`
+
+	recorder := newTestCassette(t, path, ModeRecord)
+	status, body := post(t, recorder, server.URL+"/login", recorded)
+	if status != http.StatusOK || !strings.Contains(body, `"abc"`) {
+		t.Fatalf("recording got %d %s, want the real response", status, body)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading cassette: %v", err)
+	}
+	for _, secret := range []string{"hunter2", "secret-token", `"abc"`} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+
+	server.Close()
+
+	tests := []struct {
+		name  string
+		body  string
+		match bool
+	}{
+		{"same body", recorded, true},
+		{"keys in another order", `{"password":"hunter2","username":"ada"}`, true},
+		{"another secret", `{"username":"ada","password":"swordfish"}`, true},
+		{"another user", `{"username":"grace","password":"hunter2"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := newTestCassette(t, path, ModeReplay)
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/login", strings.NewReader(tt.body))
+			resp, err := player.RoundTrip(req)
+			if !tt.match {
+				if err == nil {
+					t.Fatalf("replayed a request that was never recorded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestCassetteRedactsQueryParameters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[]`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder := newTestCassette(t, path, ModeRecord)
+	if status, _ := post(t, recorder, server.URL+"/employees?page=2&api_key=s3cret&X-Api-Key=s3cret", ""); status != http.StatusOK {
+		t.Fatalf("recording got %d", status)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading cassette: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("cassette contains the API key: %s", data)
+	}
+
+	server.Close()
+
+	tests := []struct {
+		name  string
+		url   string
+		match bool
+	}{
+		{"same key", "/employees?page=2&api_key=s3cret&X-Api-Key=s3cret", true},
+		{"another key", "/employees?page=2&api_key=other&X-Api-Key=other", true},
+		{"another page", "/employees?page=3&api_key=s3cret&X-Api-Key=s3cret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := newTestCassette(t, path, ModeReplay)
+			_, err := player.RoundTrip(httptest.NewRequest(http.MethodPost, server.URL+tt.url, nil))
+			if matched := err == nil; matched != tt.match {
+				t.Errorf("matched = %v, want %v (%v)", matched, tt.match, err)
+			}
+		})
+	}
+}
+
+func TestCassetteRecordsConcurrently(t *testing.T) {
+	const requests = 4
+
+	// Every request waits until all of them arrived, which only works when none is held back.
+	var (
+		mu      sync.Mutex
+		count   int
+		all     = make(chan struct{})
+		waiting = 2 * time.Second
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if count++; count == requests {
+			close(all)
+		}
+		mu.Unlock()
+
+		select {
+		case <-all:
+			io.WriteString(w, r.URL.Path)
+		case <-time.After(waiting):
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	defer server.Close()
+
+	cassette := newTestCassette(t, filepath.Join(t.TempDir(), "cassette.json"), ModeAuto)
+
+	statuses := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], _ = post(t, cassette, server.URL+"/"+string(rune('a'+i)), "")
+		}()
+	}
+	wg.Wait()
+
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("request %d got %d, the requests were sent one at a time", i, status)
+		}
+	}
+	if len(cassette.Interactions) != requests {
+		t.Errorf("recorded %d interactions, want %d", len(cassette.Interactions), requests)
+	}
+}
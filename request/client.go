@@ -0,0 +1,326 @@
+package request
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// ClientConfig holds the knobs for the shared HTTP client
+type ClientConfig struct {
+	// Timeout bounds a whole request, including retries and the time spent backing off
+	Timeout time.Duration
+	// ResponseHeaderTimeout bounds a single attempt waiting for the server to respond
+	ResponseHeaderTimeout time.Duration
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// BaseBackoff is the initial backoff, doubled on every retry
+	BaseBackoff time.Duration
+	// MaxBackoff caps both the exponential backoff and any Retry-After the server asks for
+	MaxBackoff time.Duration
+	// RateLimit is the default number of requests per second allowed per host, 0 disables it
+	RateLimit float64
+	// Burst is the number of requests a host can receive at once before being rate limited
+	Burst int
+	// HostRateLimits overrides RateLimit for specific hosts
+	HostRateLimits map[string]float64
+}
+
+// DefaultClientConfig returns sensible defaults for talking to third-party APIs
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:               2 * time.Minute,
+		ResponseHeaderTimeout: 30 * time.Second,
+		MaxRetries:            4,
+		BaseBackoff:           500 * time.Millisecond,
+		MaxBackoff:            30 * time.Second,
+		RateLimit:             5,
+		Burst:                 5,
+	}
+}
+
+var (
+	config    = DefaultClientConfig()
+	transport = NewTransport(config)
+	client    = &http.Client{Transport: transport, Timeout: config.Timeout}
+)
+
+/*
+Configure rebuilds the shared client and transport chain from cfg. Connections
+are pooled in a single http.Transport, which is wrapped with per-host rate limiting
+and retries with backoff.
+*/
+func Configure(cfg ClientConfig) {
+	config = cfg
+	transport = NewTransport(cfg)
+	client = &http.Client{Transport: transport, Timeout: cfg.Timeout}
+}
+
+/*
+UseTransport replaces the transport of the shared client, for example to put a
+Cassette in front of it. Use Transport to get the current one to wrap.
+*/
+func UseTransport(rt http.RoundTripper) {
+	transport = rt
+	client = &http.Client{Transport: transport, Timeout: config.Timeout}
+}
+
+// Transport returns the transport currently used for outgoing requests
+func Transport() http.RoundTripper {
+	return transport
+}
+
+// Client returns the shared HTTP client used by the request tool and anything built on it
+func Client() *http.Client {
+	return client
+}
+
+// NewTransport builds a pooled, rate limited and retrying http.RoundTripper
+func NewTransport(cfg ClientConfig) http.RoundTripper {
+	base := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &retryTransport{
+		config: cfg,
+		next: &rateLimitTransport{
+			config:  cfg,
+			buckets: map[string]*tokenBucket{},
+			next:    base,
+		},
+	}
+}
+
+// retryTransport retries rate limited and failed requests with exponential backoff
+type retryTransport struct {
+	config ClientConfig
+	next   http.RoundTripper
+}
+
+/*
+RoundTrip sends the request, and a clone of it with a fresh body for every retry,
+so the caller's request is never modified. Requests with a body that can't be
+read again are only sent once.
+*/
+func (rt *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attemptReq := req
+
+	for attempt := 0; ; attempt++ {
+		resp, err := rt.next.RoundTrip(attemptReq)
+
+		if attempt >= rt.config.MaxRetries || !rt.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		next := req.Clone(req.Context())
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			next.Body = body
+		}
+
+		delay := rt.backoff(attempt, resp)
+
+		if resp != nil {
+			log.Warn("Retrying HTTP request", "url", req.URL, "status", resp.StatusCode, "attempt", attempt+1, "delay", delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			log.Warn("Retrying HTTP request", "url", req.URL, "error", err, "attempt", attempt+1, "delay", delay)
+		}
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		attemptReq = next
+	}
+}
+
+/*
+shouldRetry retries 429s for any method, since the server did not process the
+request. Server errors and network failures are only retried for idempotent
+methods, so a POST is never sent twice.
+*/
+func (rt *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	if !isIdempotent(req.Method) {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// backoff honours Retry-After when the server sends one, and otherwise uses
+// exponential backoff with full jitter.
+func (rt *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(delay, rt.config.MaxBackoff)
+		}
+	}
+
+	delay := float64(rt.config.BaseBackoff) * math.Pow(2, float64(attempt))
+	delay = min(delay, float64(rt.config.MaxBackoff))
+
+	return time.Duration(rand.Float64() * delay)
+}
+
+// rateLimitTransport holds requests back so no host receives more than its share
+type rateLimitTransport struct {
+	config  ClientConfig
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	next    http.RoundTripper
+}
+
+func (rt *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if bucket := rt.bucket(req.URL.Hostname()); bucket != nil {
+		if err := bucket.wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	return rt.next.RoundTrip(req)
+}
+
+func (rt *rateLimitTransport) bucket(host string) *tokenBucket {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if bucket, ok := rt.buckets[host]; ok {
+		return bucket
+	}
+
+	rate := rt.config.RateLimit
+	if hostRate, ok := rt.config.HostRateLimits[host]; ok {
+		rate = hostRate
+	}
+
+	if rate <= 0 {
+		rt.buckets[host] = nil
+		return nil
+	}
+
+	burst := float64(max(rt.config.Burst, 1))
+	rt.buckets[host] = &tokenBucket{
+		rate:     rate,
+		capacity: burst,
+		tokens:   burst,
+		last:     time.Now(),
+	}
+
+	return rt.buckets[host]
+}
+
+// tokenBucket refills at rate tokens per second, up to capacity
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// wait takes a token, blocking until one is available or the context is done
+func (tb *tokenBucket) wait(ctx context.Context) error {
+	tb.mu.Lock()
+
+	now := time.Now()
+	tb.tokens = min(tb.capacity, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+	tb.last = now
+	tb.tokens--
+
+	// A negative balance is a reservation: wait until it has been paid back.
+	var delay time.Duration
+	if tb.tokens < 0 {
+		delay = time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+	}
+
+	tb.mu.Unlock()
+
+	return sleep(ctx, delay)
+}
+
+// parseRetryAfter understands both forms of Retry-After: delay seconds and an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("waiting to send request: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
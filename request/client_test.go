@@ -0,0 +1,192 @@
+package request
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClientConfig retries quickly, without rate limiting
+func testClientConfig() ClientConfig {
+	return ClientConfig{
+		ResponseHeaderTimeout: 5 * time.Second,
+		MaxRetries:            3,
+		BaseBackoff:           time.Millisecond,
+		MaxBackoff:            10 * time.Millisecond,
+	}
+}
+
+// flakyServer answers with the given statuses in turn, and 200 once they run out, recording the bodies it got
+type flakyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func newFlakyServer(t *testing.T, statuses ...int) *flakyServer {
+	t.Helper()
+
+	fs := &flakyServer{statuses: statuses}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		fs.mu.Lock()
+		status := http.StatusOK
+		if len(fs.bodies) < len(fs.statuses) {
+			status = fs.statuses[len(fs.bodies)]
+		}
+		fs.bodies = append(fs.bodies, string(body))
+		fs.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(fs.Close)
+
+	return fs
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		statuses []int
+		attempts int
+		status   int
+	}{
+		{"GET retried until it succeeds", http.MethodGet, "", []int{503, 502, 504}, 4, 200},
+		{"GET gives up after the retries", http.MethodGet, "", []int{500, 500, 500, 500, 500}, 4, 500},
+		{"GET not retried on a client error", http.MethodGet, "", []int{404}, 1, 404},
+		{"PUT retried with its body", http.MethodPut, `{"name":"Ada"}`, []int{502}, 2, 200},
+		{"POST not retried on a server error", http.MethodPost, `{"name":"Ada"}`, []int{503}, 1, 503},
+		{"POST retried when rate limited", http.MethodPost, `{"name":"Ada"}`, []int{429, 429}, 3, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFlakyServer(t, tt.statuses...)
+
+			req, _ := http.NewRequest(tt.method, server.URL, strings.NewReader(tt.body))
+			original := req.Body
+
+			resp, err := NewTransport(testClientConfig()).RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if len(server.bodies) != tt.attempts {
+				t.Errorf("sent %d attempts, want %d", len(server.bodies), tt.attempts)
+			}
+			for i, body := range server.bodies {
+				if body != tt.body {
+					t.Errorf("attempt %d sent %q, want %q", i+1, body, tt.body)
+				}
+			}
+			if req.Body != original {
+				t.Error("the caller's request was modified")
+			}
+		})
+	}
+}
+
+func TestRetryTransportCapsRetryAfter(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	cfg := testClientConfig()
+	cfg.MaxBackoff = 50 * time.Millisecond
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+
+	start := time.Now()
+	resp, err := NewTransport(cfg).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < cfg.MaxBackoff || elapsed > 5*time.Second {
+		t.Errorf("took %v, want the 30s Retry-After capped at %v", elapsed, cfg.MaxBackoff)
+	}
+	if resp.StatusCode != http.StatusOK || attempts != 2 {
+		t.Errorf("got %d after %d attempts, want 200 after 2", resp.StatusCode, attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	rt := &retryTransport{config: ClientConfig{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}}
+
+	for attempt, limit := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for range 20 {
+			if delay := rt.backoff(attempt, nil); delay < 0 || delay > limit {
+				t.Fatalf("attempt %d backed off %v, want at most %v", attempt, delay, limit)
+			}
+		}
+	}
+
+	tests := []struct {
+		retryAfter string
+		want       time.Duration
+	}{
+		{"2", time.Second},
+		{"0", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), time.Second},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{"Retry-After": {tt.retryAfter}}}
+		if got := rt.backoff(0, resp); got != tt.want {
+			t.Errorf("Retry-After %q backed off %v, want %v", tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	send := func(cfg ClientConfig, requests int) time.Duration {
+		transport := NewTransport(cfg)
+
+		start := time.Now()
+		for i := range requests {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/"+strconv.Itoa(i), nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			resp.Body.Close()
+		}
+		return time.Since(start)
+	}
+
+	// A burst of 2 at 20 per second holds the other 4 requests back for 50ms each.
+	cfg := testClientConfig()
+	cfg.RateLimit, cfg.Burst = 20, 2
+	if elapsed := send(cfg, 6); elapsed < 180*time.Millisecond {
+		t.Errorf("6 requests took %v, want the rate limit to spread them over 200ms", elapsed)
+	}
+
+	// Turning the limit off for the host lets them all through at once.
+	cfg.HostRateLimits = map[string]float64{"127.0.0.1": 0}
+	if elapsed := send(cfg, 6); elapsed > 150*time.Millisecond {
+		t.Errorf("6 requests took %v without a rate limit for the host", elapsed)
+	}
+}
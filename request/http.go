@@ -1,20 +1,49 @@
 package request
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
 	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
 	"github.com/theapemachine/idrinkyourmilkshake/models"
 )
 
+// authenticator adds credentials to the tool's requests when one has been configured
+var authenticator auth.Authenticator
+
+/*
+UseAuthenticator makes the request tool authenticate its requests, so the model can
+probe endpoints that need credentials without ever seeing them.
+*/
+func UseAuthenticator(a auth.Authenticator) {
+	authenticator = a
+}
+
 type HTTPRequest struct {
 	ToolName        string           `json:"name" jsonschema:"description=The name of the tool,required"`
 	ToolDescription string           `json:"description" jsonschema:"description=The description of the tool,required"`
 	ToolParameters  models.Parameter `json:"parameters" jsonschema:"description=The parameters of the tool,required"`
 	Required        []string         `json:"required" jsonschema:"description=The required parameters of the tool,required"`
+
+	authenticator auth.Authenticator
+}
+
+// WithAuthenticator makes this tool authenticate with a, instead of the one set by UseAuthenticator
+func (h *HTTPRequest) WithAuthenticator(a auth.Authenticator) *HTTPRequest {
+	h.authenticator = a
+	return h
+}
+
+// credentials returns the tool's own authenticator, falling back to the shared one
+func (h *HTTPRequest) credentials() auth.Authenticator {
+	if h.authenticator != nil {
+		return h.authenticator
+	}
+	return authenticator
 }
 
 func NewHTTPRequest() models.ToolType {
@@ -93,13 +122,39 @@ func (h *HTTPRequest) Execute(args map[string]any) (string, error) {
 		log.Info("No request headers provided")
 	}
 
-	// Create HTTP client and request
+	resp, err := h.Do(context.Background(), method, url, headers, bodyStr)
+	if err != nil {
+		return "", err
+	}
+
+	// Check if the status code indicates success (200-299)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Error("Request failed with non-success status code", "statusCode", resp.StatusCode)
+		return "", fmt.Errorf("request failed with status code %d: %s", resp.StatusCode, string(resp.Body))
+	}
+
+	return string(resp.Body), nil
+}
+
+// Response is a response to a request made through the tool, whatever its status
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+/*
+Do sends a request the way the tool does, through the shared client and with the
+configured credentials, and returns the response without judging its status. It
+lets other parts of the application probe an API exactly like the model does.
+*/
+func (h *HTTPRequest) Do(ctx context.Context, method, url string, headers map[string]string, body string) (*Response, error) {
+	// Create the request, which is sent through the shared client
 	log.Info("Creating HTTP request", "method", method, "url", url)
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, strings.NewReader(bodyStr))
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
 	if err != nil {
 		log.Error("Error creating HTTP request", "error", err)
-		return "", err
+		return nil, err
 	}
 
 	// Set headers
@@ -107,34 +162,40 @@ func (h *HTTPRequest) Execute(args map[string]any) (string, error) {
 		req.Header.Set(key, value)
 	}
 
+	authenticator := h.credentials()
+	if authenticator != nil {
+		if err := authenticator.Apply(req); err != nil {
+			log.Error("Error authenticating HTTP request", "error", err)
+			return nil, err
+		}
+	}
+
 	// Execute request
 	log.Info("Sending HTTP request")
-	resp, err := client.Do(req)
+	resp, err := Client().Do(req)
 	if err != nil {
 		log.Error("Error executing HTTP request", "error", err)
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized && authenticator != nil {
+		// Drop cached credentials, so the model's next attempt gets fresh ones.
+		authenticator.Invalidate()
+	}
+
 	log.Info("Received HTTP response", "status", resp.Status, "statusCode", resp.StatusCode)
 
 	// Read response
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Error("Error reading response body", "error", err)
-		return "", err
+		return nil, err
 	}
 
-	responseSize := len(bodyBytes)
-	log.Info("Successfully read response body", "size", responseSize)
-
-	// Check if the status code indicates success (200-299)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Error("Request failed with non-success status code", "statusCode", resp.StatusCode)
-		return "", fmt.Errorf("request failed with status code %d: %s", resp.StatusCode, string(bodyBytes))
-	}
+	log.Info("Successfully read response body", "size", len(bodyBytes))
 
-	return string(bodyBytes), nil
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: bodyBytes}, nil
 }
 
 func (h *HTTPRequest) Schema() any {
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/review"
+)
+
+/*
+reviewCommand walks a person through the fields of a config that were flagged as
+shaky, to accept, edit or reject each, and saves the config afterwards. Quitting
+early saves the decisions made so far, the rest stays flagged for next time.
+*/
+func reviewCommand(args []string) error {
+	flags := flag.NewFlagSet("review", flag.ExitOnError)
+	threshold := flags.Float64("threshold", review.DefaultThreshold, "Flag fields with a confidence below this")
+	verified := flags.Bool("verified", true, "Flag endpoints and auth that no live request verified")
+	out := flags.String("out", "", "Save the reviewed config to this file instead of over the input")
+	list := flags.Bool("list", false, "Only list the flagged fields as JSON, without reviewing them")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: milkshake review [flags] config.json")
+	}
+
+	config, err := models.LoadAPIConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	items := review.NewFlagger().WithThreshold(*threshold).WithVerification(*verified).Flag(config)
+
+	if *list {
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding flagged fields: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(items) == 0 {
+		fmt.Println("Nothing to review.")
+		return nil
+	}
+
+	reviewed := walk(config, items)
+	if reviewed == 0 {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	path := *out
+	if path == "" {
+		path = flags.Arg(0)
+	}
+	if err := config.Save(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reviewed %d of %d flagged fields, saved to %s\n", reviewed, len(items), path)
+	return nil
+}
+
+/*
+walk asks a person about every flagged field in turn, and applies the decisions
+to the config. It returns how many fields were decided, skipped ones aside.
+*/
+func walk(config *models.APIConfig, items []review.Item) int {
+	in := bufio.NewReader(os.Stdin)
+	reviewed := 0
+
+loop:
+	for i, item := range items {
+		if _, err := review.Value(config, item.Path); err != nil {
+			// Rejecting a block earlier took this field with it.
+			continue
+		}
+
+		show(i+1, len(items), item)
+
+		for {
+			answer, err := ask(in, "Accept, edit, reject, skip or quit? [a/e/r/s/q] ")
+			if err != nil {
+				break loop
+			}
+
+			switch answer {
+			case "a", "accept":
+				review.Accept(config, item.Path)
+				for _, path := range item.Covers {
+					review.Accept(config, path)
+				}
+			case "e", "edit":
+				value, err := edit(in, item.Value)
+				if err == nil {
+					err = review.Edit(config, item.Path, value)
+				}
+				if err != nil {
+					fmt.Println("  ", err)
+					continue
+				}
+			case "r", "reject":
+				if err := review.Reject(config, item.Path); err != nil {
+					fmt.Println("  ", err)
+					continue
+				}
+			case "s", "skip":
+				continue loop
+			case "q", "quit":
+				break loop
+			default:
+				continue
+			}
+
+			reviewed++
+			continue loop
+		}
+	}
+
+	return reviewed
+}
+
+// show prints a flagged field, why it was flagged, where it came from and its value
+func show(n, total int, item review.Item) {
+	fmt.Printf("\n[%d/%d] %s\n", n, total, item.Path)
+	fmt.Printf("  flagged: %s\n", strings.Join(item.Reasons, ", "))
+	if len(item.Covers) > 0 {
+		fmt.Printf("  covers:  %s\n", strings.Join(item.Covers, ", "))
+	}
+
+	if item.Provenance.URL != "" {
+		fmt.Printf("  source:  %s\n", item.Provenance.URL)
+	}
+	if item.Provenance.Snippet != "" {
+		fmt.Printf("  quote:   %q\n", item.Provenance.Snippet)
+	}
+
+	value, _ := json.MarshalIndent(item.Value, "  ", "  ")
+	fmt.Printf("  value:   %s\n", value)
+}
+
+func ask(in *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
+
+/*
+edit gets the new value of a field as JSON. With $EDITOR set, the current value
+is opened in it, otherwise the value is typed in, ending with an empty line.
+*/
+func edit(in *bufio.Reader, current any) (json.RawMessage, error) {
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding value: %w", err)
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		fmt.Println("  Enter the new value as JSON, ending with an empty line:")
+
+		var lines []string
+		for {
+			line, err := in.ReadString('\n')
+			if strings.TrimSpace(line) == "" || err != nil {
+				break
+			}
+			lines = append(lines, line)
+		}
+		return json.RawMessage(strings.Join(lines, "")), nil
+	}
+
+	file, err := os.CreateTemp("", "milkshake-review-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error writing temporary file: %w", err)
+	}
+	file.Close()
+
+	cmd := exec.Command(editor, file.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error running %s: %w", editor, err)
+	}
+
+	return os.ReadFile(file.Name())
+}
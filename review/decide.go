@@ -0,0 +1,194 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// Accept marks a field as checked by a person, so it isn't flagged again
+func Accept(config *models.APIConfig, path string) {
+	if config.Provenance == nil {
+		config.Provenance = map[string]models.Provenance{}
+	}
+
+	provenance := config.Provenance[path]
+	provenance.Reviewed = true
+	config.Provenance[path] = provenance
+}
+
+/*
+Edit replaces the value of a field with the JSON a person gave instead. The value
+has to fit the config, an endpoint for an endpoint, a string for a path, and the
+config is left alone when it doesn't.
+*/
+func Edit(config *models.APIConfig, path string, value json.RawMessage) error {
+	var v any
+	if err := json.Unmarshal(value, &v); err != nil {
+		return fmt.Errorf("error parsing value: %w", err)
+	}
+
+	if err := update(config, path, func(parent any, key string) (any, error) {
+		return assign(parent, key, v)
+	}); err != nil {
+		return err
+	}
+
+	forget(config, path)
+	if config.Provenance == nil {
+		config.Provenance = map[string]models.Provenance{}
+	}
+	config.Provenance[path] = models.Provenance{Source: models.SourceReview, Reviewed: true}
+	return nil
+}
+
+// Reject removes a field a person found to be wrong, with the provenance of everything in it
+func Reject(config *models.APIConfig, path string) error {
+	if err := update(config, path, remove); err != nil {
+		return err
+	}
+
+	forget(config, path)
+	return nil
+}
+
+// forget drops the provenance of a field and everything in it
+func forget(config *models.APIConfig, path string) {
+	for key := range config.Provenance {
+		if key == path || strings.HasPrefix(key, path+".") {
+			delete(config.Provenance, key)
+		}
+	}
+}
+
+// Value returns the value of the field at path, the way it is written in JSON
+func Value(config *models.APIConfig, path string) (any, error) {
+	doc, err := document(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var current any = doc
+	for _, key := range strings.Split(path, ".") {
+		if current, err = child(current, key); err != nil {
+			return nil, fmt.Errorf("error finding %s: %w", path, err)
+		}
+	}
+	return current, nil
+}
+
+/*
+update applies a change to the parent of the field at path, working on the config
+as JSON, where endpoints and jobs are found by name, and decodes the result back
+into the config.
+*/
+func update(config *models.APIConfig, path string, change func(parent any, key string) (any, error)) error {
+	doc, err := document(config)
+	if err != nil {
+		return err
+	}
+
+	updated, err := apply(doc, strings.Split(path, "."), change)
+	if err != nil {
+		return fmt.Errorf("error changing %s: %w", path, err)
+	}
+
+	data, err := json.Marshal(updated)
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	var decoded models.APIConfig
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("value doesn't fit %s: %w", path, err)
+	}
+
+	*config = decoded
+	return nil
+}
+
+// apply walks down to the parent of the last key, changes it, and puts the changed values back on the way up
+func apply(node any, keys []string, change func(parent any, key string) (any, error)) (any, error) {
+	if len(keys) == 1 {
+		return change(node, keys[0])
+	}
+
+	next, err := child(node, keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := apply(next, keys[1:], change)
+	if err != nil {
+		return nil, err
+	}
+
+	return assign(node, keys[0], updated)
+}
+
+func document(config *models.APIConfig) (map[string]any, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding config: %w", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+	return doc, nil
+}
+
+// child returns the value under key, which names an element of a list by its name field
+func child(parent any, key string) (any, error) {
+	switch p := parent.(type) {
+	case map[string]any:
+		if v, ok := p[key]; ok {
+			return v, nil
+		}
+	case []any:
+		if i := index(p, key); i >= 0 {
+			return p[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no %q", key)
+}
+
+func assign(parent any, key string, value any) (any, error) {
+	switch p := parent.(type) {
+	case map[string]any:
+		p[key] = value
+		return p, nil
+	case []any:
+		if i := index(p, key); i >= 0 {
+			p[i] = value
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("no %q", key)
+}
+
+func remove(parent any, key string) (any, error) {
+	switch p := parent.(type) {
+	case map[string]any:
+		delete(p, key)
+		return p, nil
+	case []any:
+		if i := index(p, key); i >= 0 {
+			return slices.Delete(p, i, i+1), nil
+		}
+	}
+	return nil, fmt.Errorf("no %q", key)
+}
+
+func index(list []any, name string) int {
+	for i, element := range list {
+		if object, ok := element.(map[string]any); ok && object["name"] == name {
+			return i
+		}
+	}
+	return -1
+}
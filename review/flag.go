@@ -0,0 +1,122 @@
+package review
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// DefaultThreshold is the confidence below which an extracted field needs a look
+const DefaultThreshold = 0.7
+
+/*
+Item is a field of a config that a person should check, and why. Covers lists the
+fields inside it that were flagged for the same reason, and are decided with it.
+*/
+type Item struct {
+	Path       string            `json:"path"`
+	Reasons    []string          `json:"reasons"`
+	Provenance models.Provenance `json:"provenance"`
+	Value      any               `json:"value"`
+	Covers     []string          `json:"covers,omitempty"`
+}
+
+/*
+Flagger picks the fields of a config that shouldn't be trusted without a person
+looking at them. Only extracted fields are considered, since values from a seed
+or fixed in an earlier review already had one, and locked fields are hand-edited.
+*/
+type Flagger struct {
+	threshold float64
+	verified  bool
+}
+
+// NewFlagger creates a Flagger with the default threshold, which flags endpoints no live request confirmed
+func NewFlagger() *Flagger {
+	return &Flagger{threshold: DefaultThreshold, verified: true}
+}
+
+// WithThreshold sets the confidence below which a field is flagged
+func (f *Flagger) WithThreshold(threshold float64) *Flagger {
+	f.threshold = threshold
+	return f
+}
+
+// WithVerification sets whether endpoints and auth that weren't verified by a live request are flagged
+func (f *Flagger) WithVerification(verified bool) *Flagger {
+	f.verified = verified
+	return f
+}
+
+/*
+Flag returns the fields with a confidence below the threshold, the ones the model
+didn't cite or misquoted, and the endpoints and auth no live request confirmed,
+sorted by path. A field without a confidence isn't flagged for it, since the
+model didn't say. Properties of a schema that wasn't cited either are folded
+into the schema's item, unless something else is wrong with them.
+*/
+func (f *Flagger) Flag(config *models.APIConfig) []Item {
+	var items []Item
+	uncited := map[string]int{}
+
+	for _, path := range slices.Sorted(maps.Keys(config.Provenance)) {
+		provenance := config.Provenance[path]
+		if provenance.Source != models.SourceExtracted || provenance.Locked || provenance.Reviewed {
+			continue
+		}
+
+		var reasons []string
+		if provenance.Confidence != nil && *provenance.Confidence < f.threshold {
+			reasons = append(reasons, fmt.Sprintf("low confidence (%.2f)", *provenance.Confidence))
+		}
+		switch {
+		case provenance.URL == "":
+			reasons = append(reasons, notCited)
+		case provenance.Snippet == "":
+			reasons = append(reasons, "quote not found in the cited source")
+		}
+		if f.verified && !provenance.Verified && (path == "auth" || strings.HasPrefix(path, "endpoints.")) {
+			reasons = append(reasons, "not verified by a live request")
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+
+		if schema, ok := uncitedSchema(uncited, path); ok && slices.Equal(reasons, []string{notCited}) {
+			items[schema].Covers = append(items[schema].Covers, path)
+			continue
+		}
+
+		if strings.HasPrefix(path, "schemas.") && !strings.Contains(path, ".properties.") && slices.Contains(reasons, notCited) {
+			uncited[path] = len(items)
+		}
+
+		value, _ := Value(config, path)
+		items = append(items, Item{Path: path, Reasons: reasons, Provenance: provenance, Value: value})
+	}
+
+	for i, item := range items {
+		if len(item.Covers) > 0 {
+			items[i].Reasons = append(item.Reasons, fmt.Sprintf("%d of its properties not cited", len(item.Covers)))
+		}
+	}
+
+	return items
+}
+
+// notCited is the reason fields the model didn't cite are flagged for
+const notCited = "not cited"
+
+// uncitedSchema returns the index of the item of the uncited schema a property belongs to
+func uncitedSchema(uncited map[string]int, path string) (int, bool) {
+	schema, _, ok := strings.Cut(path, ".properties.")
+	if !ok {
+		return 0, false
+	}
+	i, ok := uncited[schema]
+	return i, ok
+}
@@ -0,0 +1,72 @@
+package review
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func confidence(c float64) *float64 {
+	return &c
+}
+
+func TestFlag(t *testing.T) {
+	cited := models.Provenance{Source: models.SourceExtracted, URL: "https://docs.example.com", Snippet: "GET /employees", ToolCallID: "call_1"}
+	uncited := models.Provenance{Source: models.SourceExtracted}
+	unsure := cited
+	unsure.Confidence = confidence(0.3)
+	sure := cited
+	sure.Confidence = confidence(0.9)
+	misquoted := models.Provenance{Source: models.SourceExtracted, URL: "https://docs.example.com"}
+
+	config := &models.APIConfig{
+		BaseURL: "https://api.example.com",
+		Schemas: map[string]*models.Schema{
+			"Employee": {Type: "object", Properties: map[string]*models.Schema{"id": {Type: "string"}, "name": {Type: "string"}, "email": {Type: "string"}}},
+			"Shift":    {Type: "object", Properties: map[string]*models.Schema{"start": {Type: "string"}}},
+		},
+		Provenance: map[string]models.Provenance{
+			"base_url":                          cited,
+			"account_id":                        unsure,
+			"integration":                       sure,
+			"schemas.Employee":                  uncited,
+			"schemas.Employee.properties.id":    uncited,
+			"schemas.Employee.properties.name":  uncited,
+			"schemas.Employee.properties.email": misquoted,
+			"schemas.Shift":                     cited,
+			"schemas.Shift.properties.start":    uncited,
+		},
+	}
+
+	items := NewFlagger().Flag(config)
+
+	got := map[string][]string{}
+	for _, item := range items {
+		got[item.Path] = item.Reasons
+	}
+
+	want := map[string][]string{
+		"account_id":                        {"low confidence (0.30)"},
+		"schemas.Employee":                  {"not cited", "2 of its properties not cited"},
+		"schemas.Employee.properties.email": {"quote not found in the cited source"},
+		"schemas.Shift.properties.start":    {"not cited"},
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("flagged %v, want %v", got, want)
+	}
+	for path, reasons := range want {
+		if !slices.Equal(got[path], reasons) {
+			t.Errorf("%s flagged for %v, want %v", path, got[path], reasons)
+		}
+	}
+
+	for _, item := range items {
+		if item.Path == "schemas.Employee" {
+			if want := []string{"schemas.Employee.properties.id", "schemas.Employee.properties.name"}; !slices.Equal(item.Covers, want) {
+				t.Errorf("schema covers %v, want %v", item.Covers, want)
+			}
+		}
+	}
+}
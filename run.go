@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/runner"
+	"github.com/theapemachine/idrinkyourmilkshake/store"
+)
+
+// runCommand executes the jobs in an APIConfig against the live API
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	reportPath := flags.String("report", "", "Write the per-step report as JSON to this file")
+	storePath := flags.String("store", defaultStorePath, "The store file that steps write their collections to")
+	configureHTTP := httpFlags(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: milkshake run [flags] config.json")
+	}
+
+	if err := configureHTTP(); err != nil {
+		return err
+	}
+
+	config, err := models.LoadAPIConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	s, err := store.OpenFile(*storePath)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	report, runErr := runner.New(config).WithStore(s).Run()
+
+	for _, step := range report.Steps {
+		log.Info("Step result", "job", step.Job, "step", step.Step, "status", step.Status, "http", step.HTTPStatus, "records", step.Records, "error", step.Error)
+	}
+
+	if *reportPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding report: %w", err)
+		}
+		if err := os.WriteFile(*reportPath, data, 0o644); err != nil {
+			return fmt.Errorf("error writing report: %w", err)
+		}
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+
+	if report.Failed() {
+		return fmt.Errorf("one or more steps failed")
+	}
+
+	return nil
+}
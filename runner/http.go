@@ -0,0 +1,230 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// httpCall is a rendered HTTP or GraphQL request, which pagination adjusts page by page
+type httpCall struct {
+	method  string
+	target  *url.URL
+	headers map[string]string
+	body    map[string]any
+	graphql bool
+}
+
+// response is a decoded response, with the headers kept around for pagination
+type response struct {
+	body    any
+	status  int
+	headers http.Header
+}
+
+// call sends an HTTP or GraphQL step to the API, following its pagination when it has any
+func (r *Runner) call(step models.Step) (any, int, error) {
+	c, err := r.prepare(step)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if step.Pagination != nil {
+		return r.paginate(c, *step.Pagination)
+	}
+
+	resp, err := r.send(c)
+	if err != nil {
+		return nil, resp.status, err
+	}
+
+	return resp.body, resp.status, nil
+}
+
+// prepare renders the step's endpoint, headers and body against the run's variables
+func (r *Runner) prepare(step models.Step) (*httpCall, error) {
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = http.MethodGet
+		if step.GraphQL != nil {
+			method = http.MethodPost
+		}
+	}
+
+	target, err := r.resolveURL(utils.RenderString(step.Endpoint, r.vars))
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{}
+	for key, value := range step.Inputs.Headers {
+		headers[key] = utils.RenderString(value, r.vars)
+	}
+
+	body, _ := utils.Render(step.Inputs.Body, r.vars).(map[string]any)
+	if step.GraphQL != nil {
+		body = map[string]any{
+			"query":     step.GraphQL.Query,
+			"variables": utils.Render(step.GraphQL.Variables, r.vars),
+		}
+		if step.GraphQL.OperationName != "" {
+			body["operationName"] = step.GraphQL.OperationName
+		}
+	}
+
+	return &httpCall{
+		method:  method,
+		target:  target,
+		headers: headers,
+		body:    body,
+		graphql: step.GraphQL != nil,
+	}, nil
+}
+
+// clone copies a call, so pagination can change it without affecting the next page
+func (c *httpCall) clone() *httpCall {
+	target := *c.target
+	return &httpCall{
+		method:  c.method,
+		target:  &target,
+		headers: maps.Clone(c.headers),
+		body:    maps.Clone(c.body),
+		graphql: c.graphql,
+	}
+}
+
+// hasBody reports whether the call's parameters travel in the body rather than the query string
+func (c *httpCall) hasBody() bool {
+	return c.method != http.MethodGet && c.method != http.MethodDelete
+}
+
+/*
+send sends the call and decodes the response. For GET and DELETE requests the
+body inputs are sent as query parameters, since those methods have no body.
+*/
+func (r *Runner) send(c *httpCall) (*response, error) {
+	target := *c.target
+	headers := maps.Clone(c.headers)
+
+	var (
+		payload []byte
+		err     error
+	)
+
+	if len(c.body) > 0 {
+		switch {
+		case !c.hasBody():
+			query := target.Query()
+			for key, value := range c.body {
+				query.Set(key, utils.Stringify(value))
+			}
+			target.RawQuery = query.Encode()
+
+		case strings.Contains(headerValue(headers, "Content-Type"), "x-www-form-urlencoded"):
+			form := url.Values{}
+			for key, value := range c.body {
+				form.Set(key, utils.Stringify(value))
+			}
+			payload = []byte(form.Encode())
+
+		default:
+			if payload, err = json.Marshal(c.body); err != nil {
+				return &response{}, fmt.Errorf("error encoding body: %w", err)
+			}
+			if headerValue(headers, "Content-Type") == "" {
+				headers["Content-Type"] = "application/json"
+			}
+		}
+	}
+
+	do := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(r.ctx, c.method, target.String(), bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		if req.Header.Get("Accept") == "" {
+			req.Header.Set("Accept", "application/json")
+		}
+
+		if r.authenticator != nil {
+			if err := r.authenticator.Apply(req); err != nil {
+				return nil, fmt.Errorf("error authenticating request: %w", err)
+			}
+		}
+
+		return r.client.Do(req)
+	}
+
+	resp, err := do()
+
+	// Credentials can be revoked before they expire, so get fresh ones and try once more.
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && r.authenticator != nil {
+		resp.Body.Close()
+		r.authenticator.Invalidate()
+		resp, err = do()
+	}
+
+	if err != nil {
+		return &response{}, err
+	}
+	defer resp.Body.Close()
+
+	out := &response{status: resp.StatusCode, headers: resp.Header}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return out, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return out, fmt.Errorf("%s %s failed with status code %d: %s", c.method, &target, resp.StatusCode, utils.Truncate(string(data), 500))
+	}
+
+	if err := json.Unmarshal(data, &out.body); err != nil {
+		// Not every endpoint returns JSON, keep the raw body around instead.
+		out.body = string(data)
+		return out, nil
+	}
+
+	if c.graphql {
+		if errs, ok := utils.Lookup(out.body, "errors"); ok && countRecords(errs) > 0 {
+			return out, fmt.Errorf("graphql errors: %s", utils.Truncate(string(data), 500))
+		}
+		if payload, ok := utils.Lookup(out.body, "data"); ok {
+			out.body = payload
+		}
+	}
+
+	return out, nil
+}
+
+// resolveURL joins relative endpoints onto the config's base URL
+func (r *Runner) resolveURL(endpoint string) (*url.URL, error) {
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+
+	if target.IsAbs() {
+		return target, nil
+	}
+
+	base, err := url.Parse(r.config.BaseURL)
+	if err != nil || !base.IsAbs() {
+		return nil, fmt.Errorf("endpoint %q is relative but base URL %q is not absolute", endpoint, r.config.BaseURL)
+	}
+
+	return base.JoinPath(target.Path).ResolveReference(&url.URL{RawQuery: target.RawQuery}), nil
+}
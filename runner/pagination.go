@@ -0,0 +1,202 @@
+package runner
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// defaultMaxPages stops runaway pagination when an API never signals the last page
+const defaultMaxPages = 100
+
+/*
+paginate sends the call page by page, following the step's pagination, and returns
+the items of all pages concatenated. It stops on an empty or short page, when the
+API stops handing out a cursor or next link, when the reported total is reached,
+or after MaxPages pages.
+*/
+func (r *Runner) paginate(c *httpCall, p models.Pagination) (any, int, error) {
+	maxPages := p.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+
+	page := p.StartPage
+	if p.Type == models.PaginationPage && page == 0 {
+		page = 1
+	}
+
+	var (
+		items  = []any{}
+		status int
+		cursor string
+		next   *url.URL
+	)
+
+	for n := 0; ; n++ {
+		if n == maxPages {
+			log.Warn("Stopped paginating at the page limit", "url", c.target, "pages", maxPages)
+			break
+		}
+
+		pc := c.clone()
+
+		if p.Limit > 0 && p.LimitParam != "" {
+			pc.setParam(p.LimitParam, p.Limit)
+		}
+
+		switch p.Type {
+		case models.PaginationPage:
+			pc.setParam(defaultString(p.PageParam, "page"), page)
+		case models.PaginationOffset:
+			pc.setParam(defaultString(p.OffsetParam, "offset"), len(items))
+		case models.PaginationCursor:
+			if p.CursorParam == "" || p.CursorPath == "" {
+				return nil, 0, fmt.Errorf("cursor pagination requires cursor_param and cursor_path")
+			}
+			if cursor != "" {
+				pc.setParam(p.CursorParam, cursor)
+			}
+		case models.PaginationLinkHeader, models.PaginationNextURL:
+			if next != nil {
+				pc.target = next
+				if !pc.hasBody() {
+					// The next URL carries the query parameters from here on.
+					pc.body = nil
+				}
+			}
+		default:
+			return nil, 0, fmt.Errorf("unknown pagination type %q", p.Type)
+		}
+
+		resp, err := r.send(pc)
+		status = resp.status
+		if err != nil {
+			return nil, status, err
+		}
+
+		found, err := pageItems(resp.body, p.ItemsPath)
+		if err != nil {
+			return nil, status, err
+		}
+		items = append(items, found...)
+
+		if len(found) == 0 || (p.Limit > 0 && len(found) < p.Limit) {
+			break
+		}
+
+		if p.TotalPath != "" {
+			if total, ok := utils.Lookup(resp.body, p.TotalPath); ok {
+				if want, err := strconv.Atoi(utils.Stringify(total)); err == nil && len(items) >= want {
+					break
+				}
+			}
+		}
+
+		switch p.Type {
+		case models.PaginationPage:
+			page++
+		case models.PaginationCursor:
+			value, _ := utils.Lookup(resp.body, p.CursorPath)
+			if value == nil || utils.Stringify(value) == "" || utils.Stringify(value) == cursor {
+				return items, status, nil
+			}
+			cursor = utils.Stringify(value)
+		case models.PaginationLinkHeader:
+			if next = nextLink(pc.target, resp.headers.Values("Link")); next == nil {
+				return items, status, nil
+			}
+		case models.PaginationNextURL:
+			value, _ := utils.Lookup(resp.body, p.NextURLPath)
+			if value == nil || utils.Stringify(value) == "" {
+				return items, status, nil
+			}
+			if next, err = pc.target.Parse(utils.Stringify(value)); err != nil {
+				return nil, status, fmt.Errorf("invalid next page URL %v: %w", value, err)
+			}
+		}
+	}
+
+	return items, status, nil
+}
+
+/*
+setParam sets a pagination parameter. GraphQL calls take it as a variable, other
+calls in their body, which send turns into query parameters for GET requests.
+*/
+func (c *httpCall) setParam(name string, value any) {
+	if c.body == nil {
+		c.body = map[string]any{}
+	}
+
+	if !c.graphql {
+		c.body[name] = value
+		return
+	}
+
+	variables, _ := c.body["variables"].(map[string]any)
+	variables = maps.Clone(variables)
+	if variables == nil {
+		variables = map[string]any{}
+	}
+	variables[name] = value
+	c.body["variables"] = variables
+}
+
+// pageItems returns the items in a page, found at the items path or as the page itself
+func pageItems(body any, path string) ([]any, error) {
+	if path != "" {
+		value, ok := utils.Lookup(body, path)
+		if !ok || value == nil {
+			return nil, nil
+		}
+		body = value
+	}
+
+	items, ok := body.([]any)
+	if !ok {
+		return nil, fmt.Errorf("page has no list of items at %q, set items_path on the pagination", defaultString(path, "$"))
+	}
+
+	return items, nil
+}
+
+// nextLink finds the rel="next" URL in RFC 8288 Link headers
+func nextLink(current *url.URL, headers []string) *url.URL {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") {
+					continue
+				}
+
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						if next, err := current.Parse(target); err == nil {
+							return next
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
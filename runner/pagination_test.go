@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// pagedItems are what the paginated test endpoints serve, two at a time
+var pagedItems = []any{"a", "b", "c", "d", "e"}
+
+const pageSize = 2
+
+// pagedAPI serves pagedItems in every pagination style, counting the requests it gets
+func pagedAPI(t *testing.T) (*httptest.Server, func() int) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+
+	slice := func(from int) []any {
+		from = min(max(from, 0), len(pagedItems))
+		return pagedItems[from:min(from+pageSize, len(pagedItems))]
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		json.NewEncoder(w).Encode(map[string]any{"data": slice((page - 1) * pageSize)})
+	})
+	mux.HandleFunc("/offset", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		json.NewEncoder(w).Encode(map[string]any{"items": slice(offset), "total": len(pagedItems)})
+	})
+	mux.HandleFunc("/cursor", func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		body := map[string]any{"data": slice(from)}
+		if next := from + pageSize; next < len(pagedItems) {
+			body["next"] = strconv.Itoa(next)
+		}
+		json.NewEncoder(w).Encode(body)
+	})
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		if next := from + pageSize; next < len(pagedItems) {
+			w.Header().Add("Link", fmt.Sprintf(`</link?from=%d>; rel="next", </link?from=0>; rel="first"`, next))
+		}
+		json.NewEncoder(w).Encode(slice(from))
+	})
+	mux.HandleFunc("/next", func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		body := map[string]any{"results": slice(from), "next": nil}
+		if next := from + pageSize; next < len(pagedItems) {
+			body["next"] = fmt.Sprintf("/next?from=%d", next)
+		}
+		json.NewEncoder(w).Encode(body)
+	})
+	mux.HandleFunc("/endless", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"data": []any{r.URL.Query().Get("page")}})
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   string
+		pagination models.Pagination
+		items      int
+		requests   int
+	}{
+		{
+			name:       "page stops on an empty last page",
+			endpoint:   "/page",
+			pagination: models.Pagination{Type: models.PaginationPage, ItemsPath: "$.data"},
+			items:      5,
+			requests:   4,
+		},
+		{
+			name:       "page stops on a short page",
+			endpoint:   "/page",
+			pagination: models.Pagination{Type: models.PaginationPage, ItemsPath: "$.data", LimitParam: "per_page", Limit: pageSize},
+			items:      5,
+			requests:   3,
+		},
+		{
+			name:       "offset stops at the reported total",
+			endpoint:   "/offset",
+			pagination: models.Pagination{Type: models.PaginationOffset, ItemsPath: "$.items", TotalPath: "$.total"},
+			items:      5,
+			requests:   3,
+		},
+		{
+			name:       "cursor stops without a next cursor",
+			endpoint:   "/cursor",
+			pagination: models.Pagination{Type: models.PaginationCursor, ItemsPath: "$.data", CursorParam: "after", CursorPath: "$.next"},
+			items:      5,
+			requests:   3,
+		},
+		{
+			name:       "link header stops without a next link",
+			endpoint:   "/link",
+			pagination: models.Pagination{Type: models.PaginationLinkHeader},
+			items:      5,
+			requests:   3,
+		},
+		{
+			name:       "next URL stops on a null next",
+			endpoint:   "/next",
+			pagination: models.Pagination{Type: models.PaginationNextURL, ItemsPath: "$.results", NextURLPath: "$.next"},
+			items:      5,
+			requests:   3,
+		},
+		{
+			name:       "max pages stops an endless API",
+			endpoint:   "/endless",
+			pagination: models.Pagination{Type: models.PaginationPage, ItemsPath: "$.data", MaxPages: 7},
+			items:      7,
+			requests:   7,
+		},
+		{
+			name:       "the default max pages applies without one",
+			endpoint:   "/endless",
+			pagination: models.Pagination{Type: models.PaginationPage, ItemsPath: "$.data"},
+			items:      defaultMaxPages,
+			requests:   defaultMaxPages,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := pagedAPI(t)
+			r := New(&models.APIConfig{BaseURL: server.URL}).WithClient(server.Client())
+
+			out, status, err := r.call(models.Step{Name: "list", Endpoint: tt.endpoint, Pagination: &tt.pagination})
+			if err != nil {
+				t.Fatalf("call: %v", err)
+			}
+			if status != http.StatusOK {
+				t.Errorf("status = %d, want %d", status, http.StatusOK)
+			}
+
+			items, _ := out.([]any)
+			if len(items) != tt.items {
+				t.Errorf("got %d items %v, want %d", len(items), items, tt.items)
+			}
+			if tt.items == len(pagedItems) && fmt.Sprint(items) != fmt.Sprint(pagedItems) {
+				t.Errorf("items = %v, want %v in order", items, pagedItems)
+			}
+			if got := requests(); got != tt.requests {
+				t.Errorf("sent %d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestPaginateErrors(t *testing.T) {
+	server, _ := pagedAPI(t)
+	r := New(&models.APIConfig{BaseURL: server.URL}).WithClient(server.Client())
+
+	tests := []struct {
+		name       string
+		endpoint   string
+		pagination models.Pagination
+	}{
+		{"cursor without a cursor path", "/cursor", models.Pagination{Type: models.PaginationCursor, CursorParam: "after"}},
+		{"unknown type", "/page", models.Pagination{Type: "scroll"}},
+		{"items that aren't a list", "/page", models.Pagination{Type: models.PaginationPage}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := r.call(models.Step{Name: "list", Endpoint: tt.endpoint, Pagination: &tt.pagination}); err == nil {
+				t.Error("call succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"time"
+)
+
+// Status is the outcome of a single step
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+// StepResult records what happened when a step was executed
+type StepResult struct {
+	Job        string        `json:"job"`
+	Step       string        `json:"step"`
+	Type       string        `json:"type"`
+	Status     Status        `json:"status"`
+	HTTPStatus int           `json:"http_status,omitempty"`
+	Records    int           `json:"records,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Report is the outcome of a complete run
+type Report struct {
+	Integration string       `json:"integration"`
+	StartedAt   time.Time    `json:"started_at"`
+	FinishedAt  time.Time    `json:"finished_at"`
+	Auth        *StepResult  `json:"auth,omitempty"`
+	Steps       []StepResult `json:"steps"`
+}
+
+// Failed reports whether authentication or any step failed
+func (report *Report) Failed() bool {
+	if report.Auth != nil && report.Auth.Status == StatusFailed {
+		return true
+	}
+
+	for _, step := range report.Steps {
+		if step.Status == StatusFailed {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+	"github.com/theapemachine/idrinkyourmilkshake/store"
+)
+
+/*
+Runner executes the jobs of an APIConfig against the real API, so an extracted
+config can be tested end to end. Every step stores its result under its name,
+or just its declared outputs when it has any, and later steps reference earlier
+results with {{steps.<name>.<path>}}.
+*/
+type Runner struct {
+	config        *models.APIConfig
+	client        *http.Client
+	authenticator auth.Authenticator
+	store         store.Store
+	ctx           context.Context
+	vars          map[string]any
+}
+
+// New creates a Runner for the given config, using the shared HTTP client and an in-memory store
+func New(config *models.APIConfig) *Runner {
+	return &Runner{
+		config: config,
+		client: request.Client(),
+		store:  store.NewMemory(),
+		ctx:    context.Background(),
+		vars: map[string]any{
+			"integration": config.Integration,
+			"account_id":  config.AccountID,
+			"base_url":    config.BaseURL,
+			"steps":       map[string]any{},
+		},
+	}
+}
+
+// WithContext sets the context for the runner
+func (r *Runner) WithContext(ctx context.Context) *Runner {
+	r.ctx = ctx
+	return r
+}
+
+// WithClient sets the HTTP client used for every call the runner makes
+func (r *Runner) WithClient(client *http.Client) *Runner {
+	r.client = client
+	return r
+}
+
+// WithStore sets the store that steps write their collections to
+func (r *Runner) WithStore(s store.Store) *Runner {
+	r.store = s
+	return r
+}
+
+/*
+Run authenticates and then executes each job's steps in order. A failing step
+skips the rest of its job, since later steps depend on its output, but other
+jobs still run. The error is only set when the run could not start at all.
+*/
+func (r *Runner) Run() (*Report, error) {
+	log.Info("Starting integration run", "integration", r.config.Integration, "jobs", len(r.config.Jobs))
+
+	report := &Report{
+		Integration: r.config.Integration,
+		StartedAt:   time.Now(),
+	}
+
+	if auth.Type(r.config.Auth) != models.AuthNone {
+		report.Auth = r.authenticate()
+		if report.Auth.Status == StatusFailed {
+			report.FinishedAt = time.Now()
+			return report, fmt.Errorf("authentication failed: %s", report.Auth.Error)
+		}
+	}
+
+	for _, job := range r.config.Jobs {
+		log.Info("Running job", "job", job.Name, "steps", len(job.Steps))
+		failed := false
+
+		for _, step := range job.Steps {
+			if failed {
+				report.Steps = append(report.Steps, StepResult{
+					Job:    job.Name,
+					Step:   step.Name,
+					Type:   step.Type,
+					Status: StatusSkipped,
+				})
+				continue
+			}
+
+			result := r.executeStep(job, step)
+			report.Steps = append(report.Steps, result)
+
+			if result.Status == StatusFailed {
+				log.Error("Step failed", "job", job.Name, "step", step.Name, "error", result.Error)
+				failed = true
+				continue
+			}
+
+			log.Info("Step completed", "job", job.Name, "step", step.Name, "records", result.Records, "duration", result.Duration)
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	log.Info("Integration run finished", "integration", r.config.Integration, "failed", report.Failed())
+
+	return report, nil
+}
+
+/*
+authenticate obtains credentials up front, so a broken auth setup fails the run
+before any step does. Values obtained at runtime, like a session login response,
+are exposed as {{auth.<path>}}.
+*/
+func (r *Runner) authenticate() *StepResult {
+	authType := auth.Type(r.config.Auth)
+	log.Info("Authenticating", "type", authType)
+
+	started := time.Now()
+	result := &StepResult{Step: "auth", Type: authType, Status: StatusOK}
+
+	authenticator, err := auth.New(r.config.Auth, r.config.BaseURL, r.client)
+	if err == nil {
+		err = authenticator.Authenticate(r.ctx)
+	}
+
+	result.Duration = time.Since(started)
+
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	r.authenticator = authenticator
+	if valuer, ok := authenticator.(auth.Valuer); ok {
+		r.vars["auth"] = valuer.Values()
+	}
+
+	return result
+}
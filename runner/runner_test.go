@@ -0,0 +1,242 @@
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/store"
+)
+
+// testAPI serves the endpoints the runner tests call
+func testAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /employees", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data": [{"id": 1, "first": "Ada", "last": "Lovelace"}, {"id": 2, "first": "Grace", "last": "Hopper"}]}`)
+	})
+	mux.HandleFunc("GET /employees/{id}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"id": `+r.PathValue("id")+`, "department": "engineering"}`)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]any{
+			"method":       r.Method,
+			"query":        r.URL.RawQuery,
+			"content_type": r.Header.Get("Content-Type"),
+			"tenant":       r.Header.Get("X-Tenant"),
+			"body":         string(body),
+		})
+	})
+	mux.HandleFunc("POST /graphql", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if strings.Contains(request.Query, "broken") {
+			io.WriteString(w, `{"errors": [{"message": "Cannot query field broken"}]}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"employee": request.Variables}})
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRun(t *testing.T) {
+	server := testAPI(t)
+
+	tests := []struct {
+		name     string
+		jobs     string
+		statuses []Status
+		steps    map[string]any
+		records  []store.Record
+	}{
+		{
+			name: "later steps use earlier results",
+			jobs: `[{"name": "sync", "steps": [
+				{"name": "list", "type": "http", "endpoint": "/employees", "outputs": {"employees": "$.data"}},
+				{"name": "first", "type": "http", "endpoint": "/employees/{{steps.list.employees.0.id}}", "outputs": {"department": "$.department"}}
+			]}]`,
+			statuses: []Status{StatusOK, StatusOK},
+			steps:    map[string]any{"first": map[string]any{"department": "engineering"}},
+		},
+		{
+			name: "records are mapped and stored",
+			jobs: `[{"name": "sync", "steps": [
+				{"name": "list", "type": "http", "endpoint": "/employees", "outputs": {"employees": "$.data"}},
+				{"name": "map", "type": "map", "input": "list.employees", "map": [
+					{"target": "external_id", "source": "$.id", "type": "string"},
+					{"target": "name", "concat": ["$.first", "$.last"]}
+				]},
+				{"name": "save", "type": "store", "input": "map", "collection": "employees", "match_field": "external_id"}
+			]}]`,
+			statuses: []Status{StatusOK, StatusOK, StatusOK},
+			records: []store.Record{
+				{"external_id": "1", "name": "Ada Lovelace"},
+				{"external_id": "2", "name": "Grace Hopper"},
+			},
+		},
+		{
+			name: "GET bodies are sent as query parameters",
+			jobs: `[{"name": "sync", "steps": [
+				{"name": "echo", "type": "http", "endpoint": "/echo", "method": "GET", "inputs": {"headers": {"X-Tenant": "{{account_id}}"}, "body": {"since": 2024}}}
+			]}]`,
+			statuses: []Status{StatusOK},
+			steps: map[string]any{"echo": map[string]any{
+				"method": "GET", "query": "since=2024", "content_type": "", "tenant": "acme", "body": "",
+			}},
+		},
+		{
+			name: "POST bodies are sent as JSON",
+			jobs: `[{"name": "sync", "steps": [
+				{"name": "echo", "type": "http", "endpoint": "/echo", "method": "POST", "inputs": {"body": {"account": "{{account_id}}"}}}
+			]}]`,
+			statuses: []Status{StatusOK},
+			steps: map[string]any{"echo": map[string]any{
+				"method": "POST", "query": "", "content_type": "application/json", "tenant": "", "body": `{"account":"acme"}`,
+			}},
+		},
+		{
+			name: "form bodies are encoded as forms",
+			jobs: `[{"name": "sync", "steps": [
+				{"name": "echo", "type": "http", "endpoint": "/echo", "method": "POST", "inputs": {
+					"headers": {"Content-Type": "application/x-www-form-urlencoded"}, "body": {"account": "{{account_id}}"}
+				}}
+			]}]`,
+			statuses: []Status{StatusOK},
+			steps: map[string]any{"echo": map[string]any{
+				"method": "POST", "query": "", "content_type": "application/x-www-form-urlencoded", "tenant": "", "body": "account=acme",
+			}},
+		},
+		{
+			name: "GraphQL data is unwrapped",
+			jobs: `[{"name": "sync", "steps": [
+				{"name": "employee", "type": "graphql", "endpoint": "/graphql", "graphql": {"query": "query ($id: ID!) { employee(id: $id) { id } }", "variables": {"id": "{{account_id}}"}}}
+			]}]`,
+			statuses: []Status{StatusOK},
+			steps:    map[string]any{"employee": map[string]any{"employee": map[string]any{"id": "acme"}}},
+		},
+		{
+			name: "GraphQL errors fail the step",
+			jobs: `[{"name": "sync", "steps": [
+				{"name": "employee", "type": "graphql", "endpoint": "/graphql", "graphql": {"query": "{ broken }"}}
+			]}]`,
+			statuses: []Status{StatusFailed},
+		},
+		{
+			name: "a failed step skips the rest of its job only",
+			jobs: `[
+				{"name": "broken", "steps": [
+					{"name": "missing", "type": "http", "endpoint": "/missing"},
+					{"name": "after", "type": "http", "endpoint": "/employees"}
+				]},
+				{"name": "fine", "steps": [
+					{"name": "list", "type": "http", "endpoint": "/employees"}
+				]}
+			]`,
+			statuses: []Status{StatusFailed, StatusSkipped, StatusOK},
+		},
+		{
+			name: "step types are inferred from their fields",
+			jobs: `[{"name": "sync", "steps": [
+				{"name": "list", "type": "fetch", "endpoint": "/employees", "outputs": {"employees": "$.data"}},
+				{"name": "save", "type": "", "input": "list.employees", "collection": "employees", "operation": "insert"},
+				{"name": "dance", "type": "dance"}
+			]}]`,
+			statuses: []Status{StatusOK, StatusOK, StatusFailed},
+			records: []store.Record{
+				{"id": 1.0, "first": "Ada", "last": "Lovelace"},
+				{"id": 2.0, "first": "Grace", "last": "Hopper"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &models.APIConfig{Integration: "test", AccountID: "acme", BaseURL: server.URL, Auth: models.Auth{Type: models.AuthNone}}
+			if err := json.Unmarshal([]byte(tt.jobs), &config.Jobs); err != nil {
+				t.Fatalf("parsing jobs: %v", err)
+			}
+
+			memory := store.NewMemory()
+			r := New(config).WithClient(server.Client()).WithStore(memory)
+
+			report, err := r.Run()
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			var statuses []Status
+			for _, step := range report.Steps {
+				statuses = append(statuses, step.Status)
+			}
+			if !reflect.DeepEqual(statuses, tt.statuses) {
+				t.Errorf("statuses = %v, want %v", statuses, tt.statuses)
+			}
+
+			for name, want := range tt.steps {
+				if got := r.vars["steps"].(map[string]any)[name]; !reflect.DeepEqual(got, want) {
+					t.Errorf("step %s = %#v, want %#v", name, got, want)
+				}
+			}
+
+			if tt.records != nil {
+				got, err := memory.Query("employees", nil)
+				if err != nil {
+					t.Fatalf("Query: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.records) {
+					t.Errorf("stored %v, want %v", got, tt.records)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		base     string
+		endpoint string
+		want     string
+		fails    bool
+	}{
+		{base: "https://api.example.com/v3", endpoint: "/employees", want: "https://api.example.com/v3/employees"},
+		{base: "https://api.example.com/v3/", endpoint: "employees?page=2", want: "https://api.example.com/v3/employees?page=2"},
+		{base: "https://api.example.com/v3", endpoint: "https://other.example.com/x", want: "https://other.example.com/x"},
+		{base: "api.example.com", endpoint: "/employees", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.base+" "+tt.endpoint, func(t *testing.T) {
+			r := New(&models.APIConfig{BaseURL: tt.base})
+
+			got, err := r.resolveURL(tt.endpoint)
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("resolveURL = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveURL: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("resolveURL = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
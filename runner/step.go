@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/theapemachine/idrinkyourmilkshake/mapping"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// The step types the runner understands
+const (
+	StepHTTP    = "http"
+	StepGraphQL = "graphql"
+	StepMap     = "map"
+	StepStore   = "store"
+)
+
+// stepAliases maps the names the model tends to come up with onto the step types
+var stepAliases = map[string]string{
+	"http":       StepHTTP,
+	"request":    StepHTTP,
+	"api":        StepHTTP,
+	"api_call":   StepHTTP,
+	"fetch":      StepHTTP,
+	"graphql":    StepGraphQL,
+	"map":        StepMap,
+	"mapping":    StepMap,
+	"transform":  StepMap,
+	"store":      StepStore,
+	"database":   StepStore,
+	"db":         StepStore,
+	"collection": StepStore,
+	"save":       StepStore,
+	"upsert":     StepStore,
+}
+
+// stepType normalizes the step's type, falling back to what the step's fields imply
+func stepType(step models.Step) string {
+	if t, ok := stepAliases[strings.ToLower(step.Type)]; ok {
+		return t
+	}
+
+	switch {
+	case step.GraphQL != nil:
+		return StepGraphQL
+	case step.Collection != "":
+		return StepStore
+	case step.Endpoint != "":
+		return StepHTTP
+	}
+
+	return step.Type
+}
+
+func (r *Runner) executeStep(job models.Job, step models.Step) StepResult {
+	started := time.Now()
+	result := StepResult{
+		Job:    job.Name,
+		Step:   step.Name,
+		Type:   stepType(step),
+		Status: StatusOK,
+	}
+
+	var (
+		out any
+		err error
+	)
+
+	switch result.Type {
+	case StepHTTP, StepGraphQL:
+		out, result.HTTPStatus, err = r.call(step)
+		if err == nil && len(step.Outputs) > 0 {
+			out = outputs(out, step.Outputs)
+		}
+	case StepMap:
+		out, err = r.mapRecords(step)
+	case StepStore:
+		out, err = r.storeRecords(step)
+	default:
+		err = fmt.Errorf("unknown step type: %s", step.Type)
+	}
+
+	result.Duration = time.Since(started)
+
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	r.vars["steps"].(map[string]any)[step.Name] = out
+	result.Records = countRecords(out)
+
+	return result
+}
+
+/*
+input resolves a step's input reference, either a full path like
+steps.list_employees.data or just the name of an earlier step.
+*/
+func (r *Runner) input(step models.Step) (any, error) {
+	if step.Input == "" {
+		return nil, fmt.Errorf("step %s has no input", step.Name)
+	}
+
+	reference := strings.Trim(step.Input, "{} ")
+
+	if value, ok := utils.Resolve(reference, r.vars); ok {
+		return value, nil
+	}
+
+	if value, ok := utils.Resolve("steps."+reference, r.vars); ok {
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("input %s of step %s not found", step.Input, step.Name)
+}
+
+// records turns a step's input into a list of records
+func (r *Runner) records(step models.Step) ([]map[string]any, error) {
+	value, err := r.input(step)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return []map[string]any{v}, nil
+	case []map[string]any:
+		return v, nil
+	case []any:
+		out := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			record, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("input %s of step %s contains %T, not records", step.Input, step.Name, item)
+			}
+			out = append(out, record)
+		}
+		return out, nil
+	}
+
+	return nil, fmt.Errorf("input %s of step %s is %T, not records", step.Input, step.Name, value)
+}
+
+// mapRecords evaluates the step's mapping against every input record
+func (r *Runner) mapRecords(step models.Step) (any, error) {
+	records, err := r.records(step)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]any, 0, len(records))
+	for i, record := range records {
+		mapped, err := mapping.Apply(step.Map, record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		out = append(out, mapped)
+	}
+
+	return out, nil
+}
+
+// storeRecords applies the step's collection operation to its input records
+func (r *Runner) storeRecords(step models.Step) (any, error) {
+	if step.Collection == "" {
+		return nil, fmt.Errorf("step %s has no collection", step.Name)
+	}
+
+	records, err := r.records(step)
+	if err != nil {
+		return nil, err
+	}
+
+	operation := strings.ToLower(step.Operation)
+	if operation == "" {
+		operation = "upsert"
+	}
+
+	switch operation {
+	case "insert", "create":
+		err = r.store.Insert(step.Collection, records...)
+	case "upsert", "update":
+		err = r.store.Upsert(step.Collection, step.MatchField, records...)
+	case "delete", "remove":
+		_, err = r.store.Delete(step.Collection, step.MatchField, records...)
+	default:
+		err = fmt.Errorf("unknown operation %s on collection %s", step.Operation, step.Collection)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]any, len(records))
+	for i, record := range records {
+		out[i] = record
+	}
+	return out, nil
+}
+
+// outputs keeps only the named values from a response, when a step declares any
+func outputs(response any, declared ...models.Output) map[string]any {
+	out := map[string]any{}
+	for _, output := range declared {
+		for name, path := range output {
+			if value, ok := utils.Lookup(response, path); ok {
+				out[name] = value
+			}
+		}
+	}
+	return out
+}
+
+func countRecords(value any) int {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case []any:
+		return len(v)
+	case []map[string]any:
+		return len(v)
+	}
+	return 1
+}
+
+func headerValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
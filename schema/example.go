@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+/*
+maxExampleDepth stops examples of self-referencing schemas from growing forever.
+Beyond it only required properties are filled in and arrays stay empty, so the
+values still conform. Required properties that reference their own schema can't
+ever end, and give up at maxRequiredDepth.
+*/
+const (
+	maxExampleDepth  = 8
+	maxRequiredDepth = 2 * maxExampleDepth
+)
+
+// formatExamples are the example values for strings of the well-known formats
+var formatExamples = map[string]string{
+	"date-time": "2024-01-01T09:00:00Z",
+	"date":      "2024-01-01",
+	"time":      "09:00:00",
+	"email":     "user@example.com",
+	"uuid":      "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+	"uri":       "https://example.com",
+}
+
+/*
+Example builds a value that conforms to the schema, for request bodies in exports
+and the like. Declared examples and the first enum value are used where present,
+otherwise a placeholder value of the right type and format. It is deterministic,
+so exports don't change between runs.
+*/
+func Example(config *models.APIConfig, schema *models.Schema) any {
+	return example(config, schema, 0)
+}
+
+func example(config *models.APIConfig, schema *models.Schema, depth int) any {
+	schema, err := config.ResolveSchema(schema)
+	if err != nil || schema == nil || depth > maxRequiredDepth {
+		return nil
+	}
+
+	if schema.Example != nil {
+		return schema.Example
+	}
+	if len(schema.Enum) > 0 {
+		return schema.Enum[0]
+	}
+
+	switch schema.Type {
+	case "object":
+		out := map[string]any{}
+		for _, name := range properties(schema, depth) {
+			if value := example(config, schema.Properties[name], depth+1); value != nil {
+				out[name] = value
+			}
+		}
+		return out
+	case "array":
+		if depth >= maxExampleDepth {
+			return []any{}
+		}
+		if item := example(config, schema.Items, depth+1); item != nil {
+			return []any{item}
+		}
+		return []any{}
+	case "string":
+		if value, ok := formatExamples[schema.Format]; ok {
+			return value
+		}
+		return "string"
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	}
+
+	if len(schema.Properties) > 0 {
+		return example(config, &models.Schema{Type: "object", Properties: schema.Properties}, depth)
+	}
+
+	return nil
+}
+
+// properties returns the names of the properties to fill in, only the required ones past maxExampleDepth
+func properties(schema *models.Schema, depth int) []string {
+	names := slices.Sorted(maps.Keys(schema.Properties))
+	if depth < maxExampleDepth {
+		return names
+	}
+	return slices.DeleteFunc(names, func(name string) bool {
+		return !slices.Contains(schema.Required, name)
+	})
+}
@@ -0,0 +1,111 @@
+package schema
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// fakeWords are what fake strings are made of
+var fakeWords = []string{
+	"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel",
+	"india", "juliet", "kilo", "lima", "mike", "november", "oscar", "papa",
+}
+
+/*
+Faker builds random values that conform to a schema, for mock servers that need
+more variety than Example gives, e.g. a list of distinct items. Declared enums
+are respected and strings of the well-known formats are valid for that format.
+Fakers with the same seed produce the same values.
+*/
+type Faker struct {
+	config *models.APIConfig
+	rand   *rand.Rand
+}
+
+// NewFaker creates a Faker for the schemas of config
+func NewFaker(config *models.APIConfig, seed uint64) *Faker {
+	return &Faker{config: config, rand: rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))}
+}
+
+// Fake builds a random value that conforms to the schema
+func (f *Faker) Fake(schema *models.Schema) any {
+	return f.fake(schema, 0)
+}
+
+func (f *Faker) fake(schema *models.Schema, depth int) any {
+	schema, err := f.config.ResolveSchema(schema)
+	if err != nil || schema == nil || depth > maxRequiredDepth {
+		return nil
+	}
+
+	if len(schema.Enum) > 0 {
+		return schema.Enum[f.rand.IntN(len(schema.Enum))]
+	}
+
+	switch schema.Type {
+	case "object":
+		out := map[string]any{}
+		for _, name := range properties(schema, depth) {
+			if value := f.fake(schema.Properties[name], depth+1); value != nil {
+				out[name] = value
+			}
+		}
+		return out
+	case "array":
+		out := []any{}
+		if depth >= maxExampleDepth {
+			return out
+		}
+		for range 1 + f.rand.IntN(3) {
+			if item := f.fake(schema.Items, depth+1); item != nil {
+				out = append(out, item)
+			}
+		}
+		return out
+	case "string":
+		return f.fakeString(schema.Format)
+	case "integer":
+		return 1 + f.rand.IntN(1000)
+	case "number":
+		return float64(f.rand.IntN(100000)) / 100
+	case "boolean":
+		return f.rand.IntN(2) == 1
+	}
+
+	if len(schema.Properties) > 0 {
+		return f.fake(&models.Schema{Type: "object", Properties: schema.Properties}, depth)
+	}
+
+	if schema.Example != nil {
+		return schema.Example
+	}
+
+	return nil
+}
+
+// fakeString builds a random string, valid for the format when it is a well-known one
+func (f *Faker) fakeString(format string) string {
+	moment := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(f.rand.IntN(365*24*60)) * time.Minute)
+	word := fakeWords[f.rand.IntN(len(fakeWords))]
+
+	switch format {
+	case "date-time":
+		return moment.Format(time.RFC3339)
+	case "date":
+		return moment.Format(time.DateOnly)
+	case "time":
+		return moment.Format(time.TimeOnly)
+	case "email":
+		return fmt.Sprintf("%s%d@example.com", word, f.rand.IntN(100))
+	case "uuid":
+		return fmt.Sprintf("%08x-%04x-4%03x-%04x-%012x",
+			f.rand.Uint32(), f.rand.Uint32()&0xffff, f.rand.Uint32()&0xfff, 0x8000|f.rand.Uint32()&0x3fff, f.rand.Uint64()&0xffffffffffff)
+	case "uri":
+		return fmt.Sprintf("https://example.com/%s", word)
+	}
+
+	return fmt.Sprintf("%s %s", word, fakeWords[f.rand.IntN(len(fakeWords))])
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// selfReferencing is a config whose Employee has an optional manager and reports of the same type
+var selfReferencing = &models.APIConfig{Schemas: map[string]*models.Schema{
+	"Employee": {
+		Type:     "object",
+		Required: []string{"id", "name", "team"},
+		Properties: map[string]*models.Schema{
+			"id":      {Type: "integer"},
+			"name":    {Type: "string"},
+			"email":   {Type: "string", Format: "email"},
+			"team":    models.SchemaRef("Team"),
+			"manager": models.SchemaRef("Employee"),
+			"reports": {Type: "array", Items: models.SchemaRef("Employee")},
+		},
+	},
+	"Team": {
+		Type:       "object",
+		Required:   []string{"name"},
+		Properties: map[string]*models.Schema{"name": {Type: "string"}, "lead": models.SchemaRef("Employee")},
+	},
+}}
+
+func TestValuesOfSelfReferencingSchemas(t *testing.T) {
+	values := map[string]func() any{
+		"Example": func() any { return Example(selfReferencing, models.SchemaRef("Employee")) },
+		"Fake":    func() any { return NewFaker(selfReferencing, 1).Fake(models.SchemaRef("Employee")) },
+	}
+
+	for name, value := range values {
+		t.Run(name, func(t *testing.T) {
+			// Validate checks values the way they come back from JSON.
+			var v any
+			data, _ := json.Marshal(value())
+			json.Unmarshal(data, &v)
+
+			if mismatches := Validate(selfReferencing, models.SchemaRef("Employee"), v); len(mismatches) > 0 {
+				t.Errorf("%s doesn't conform: %v\n%s", name, mismatches, data)
+			}
+
+			depth := 0
+			for employee, ok := v.(map[string]any); ok; employee, ok = employee["manager"].(map[string]any) {
+				if employee["id"] == nil || employee["name"] == nil {
+					t.Fatalf("manager at depth %d lacks its required fields: %v", depth, employee)
+				}
+				depth++
+			}
+			if depth < 2 || depth > maxExampleDepth+1 {
+				t.Errorf("the chain of managers is %d deep, want it to end at the depth limit", depth)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package schema
+
+import (
+	"encoding/json"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+/*
+MaxEnumValues is the most distinct values a string field may take before it stops
+being treated as an enum. Fields only become enums when every value repeats, and
+there are at least enumRatio times as many values as distinct ones, so fetching a
+page twice or a surname that happens to repeat never turns names or IDs into one.
+*/
+var MaxEnumValues = 10
+
+const enumRatio = 3
+
+var (
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+/*
+node accumulates what the samples show about one position in the documents, so
+every sample contributes before a schema is decided on.
+*/
+type node struct {
+	types      map[string]int
+	nullable   bool
+	objects    int
+	properties map[string]*node
+	presence   map[string]int
+	items      *node
+	strings    map[string]int
+	formats    map[string]int
+}
+
+func newNode() *node {
+	return &node{
+		types:      map[string]int{},
+		properties: map[string]*node{},
+		presence:   map[string]int{},
+		strings:    map[string]int{},
+		formats:    map[string]int{},
+	}
+}
+
+/*
+Infer derives a JSON Schema that all the samples conform to, such as responses
+obtained through http_request. Fields present in every object are required, the
+others optional, and null values make a field nullable. Strings get a format when
+every value has it, or an enum when they come from a small, repeating set.
+*/
+func Infer(samples ...any) *models.Schema {
+	root := newNode()
+	for _, sample := range samples {
+		root.add(sample)
+	}
+	return root.schema()
+}
+
+func (n *node) add(value any) {
+	switch v := value.(type) {
+	case nil:
+		n.nullable = true
+
+	case map[string]any:
+		n.types["object"]++
+		n.objects++
+		for key, child := range v {
+			if n.properties[key] == nil {
+				n.properties[key] = newNode()
+			}
+			n.presence[key]++
+			n.properties[key].add(child)
+		}
+
+	case []any:
+		n.types["array"]++
+		if n.items == nil {
+			n.items = newNode()
+		}
+		for _, item := range v {
+			n.items.add(item)
+		}
+
+	case string:
+		n.types["string"]++
+		n.strings[v]++
+		n.formats[format(v)]++
+
+	case bool:
+		n.types["boolean"]++
+
+	case float64:
+		if v == float64(int64(v)) {
+			n.types["integer"]++
+		} else {
+			n.types["number"]++
+		}
+
+	default:
+		// Values that didn't come from encoding/json, e.g. typed Go values, are added the way JSON would decode them.
+		var decoded any
+		data, err := json.Marshal(v)
+		if err != nil || json.Unmarshal(data, &decoded) != nil {
+			// Leaves the type open, since there is no JSON type for channels or functions.
+			n.types[""]++
+			return
+		}
+		n.add(decoded)
+	}
+}
+
+func (n *node) schema() *models.Schema {
+	schema := &models.Schema{Type: n.typeName(), Nullable: n.nullable}
+
+	switch schema.Type {
+	case "object":
+		schema.Properties = map[string]*models.Schema{}
+		for key, child := range n.properties {
+			schema.Properties[key] = child.schema()
+			if n.presence[key] == n.objects {
+				schema.Required = append(schema.Required, key)
+			}
+		}
+		sort.Strings(schema.Required)
+
+	case "array":
+		if n.items != nil && (len(n.items.types) > 0 || n.items.nullable) {
+			schema.Items = n.items.schema()
+		}
+
+	case "string":
+		schema.Format = n.format()
+		if schema.Format == "" {
+			schema.Enum = n.enum()
+		}
+	}
+
+	return schema
+}
+
+// typeName picks the JSON Schema type, widening integers to numbers and leaving mixed types open
+func (n *node) typeName() string {
+	types := slices.Collect(maps.Keys(n.types))
+
+	switch {
+	case len(types) == 1:
+		return types[0]
+	case len(types) == 2 && n.types["integer"] > 0 && n.types["number"] > 0:
+		return "number"
+	}
+
+	return ""
+}
+
+// format returns the format every string value has, if they share one
+func (n *node) format() string {
+	if len(n.formats) != 1 {
+		return ""
+	}
+	for f := range n.formats {
+		return f
+	}
+	return ""
+}
+
+// enum returns the string values when they come from a small set that repeats across the samples
+func (n *node) enum() []any {
+	total := 0
+	for _, count := range n.strings {
+		if count < 2 {
+			return nil
+		}
+		total += count
+	}
+
+	if len(n.strings) == 0 || len(n.strings) > MaxEnumValues || total < enumRatio*len(n.strings) {
+		return nil
+	}
+
+	values := slices.Sorted(maps.Keys(n.strings))
+	enum := make([]any, len(values))
+	for i, value := range values {
+		enum[i] = value
+	}
+
+	return enum
+}
+
+// format detects the well-known JSON Schema formats of a string value
+func format(value string) string {
+	if _, err := time.Parse(time.RFC3339, value); err == nil {
+		return "date-time"
+	}
+	if _, err := time.Parse(time.DateOnly, value); err == nil {
+		return "date"
+	}
+	if uuidPattern.MatchString(value) {
+		return "uuid"
+	}
+	if emailPattern.MatchString(value) {
+		return "email"
+	}
+	if u, err := url.Parse(value); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return "uri"
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// decode parses JSON samples the way responses arrive
+func decode(t *testing.T, samples ...string) []any {
+	t.Helper()
+
+	out := make([]any, len(samples))
+	for i, sample := range samples {
+		if err := json.Unmarshal([]byte(sample), &out[i]); err != nil {
+			t.Fatalf("decoding %s: %v", sample, err)
+		}
+	}
+	return out
+}
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []string
+		want    *models.Schema
+	}{
+		{
+			name:    "fields in every object are required",
+			samples: []string{`{"id": 1, "name": "Ada"}`, `{"id": 2, "name": "Grace", "nickname": "Amazing"}`},
+			want: &models.Schema{Type: "object", Required: []string{"id", "name"}, Properties: map[string]*models.Schema{
+				"id":       {Type: "integer"},
+				"name":     {Type: "string"},
+				"nickname": {Type: "string"},
+			}},
+		},
+		{
+			name:    "null makes a field nullable",
+			samples: []string{`{"manager": null}`, `{"manager": "Ada"}`},
+			want: &models.Schema{Type: "object", Required: []string{"manager"}, Properties: map[string]*models.Schema{
+				"manager": {Type: "string", Nullable: true},
+			}},
+		},
+		{
+			name:    "integers widen to numbers",
+			samples: []string{`1`, `1.5`},
+			want:    &models.Schema{Type: "number"},
+		},
+		{
+			name:    "mixed types stay open",
+			samples: []string{`1`, `"one"`},
+			want:    &models.Schema{},
+		},
+		{
+			name:    "items of arrays",
+			samples: []string{`[1, 2]`, `[]`},
+			want:    &models.Schema{Type: "array", Items: &models.Schema{Type: "integer"}},
+		},
+		{
+			name:    "empty arrays leave the items open",
+			samples: []string{`[]`},
+			want:    &models.Schema{Type: "array"},
+		},
+		{
+			name:    "formats every value has",
+			samples: []string{`["2024-01-02T15:04:05Z", "2024-03-04T00:00:00+02:00"]`},
+			want:    &models.Schema{Type: "array", Items: &models.Schema{Type: "string", Format: "date-time"}},
+		},
+		{
+			name:    "formats",
+			samples: []string{`{"day": "2024-01-02", "id": "123e4567-e89b-12d3-a456-426614174000", "email": "ada@example.com", "url": "https://example.com/ada"}`},
+			want: &models.Schema{Type: "object", Required: []string{"day", "email", "id", "url"}, Properties: map[string]*models.Schema{
+				"day":   {Type: "string", Format: "date"},
+				"id":    {Type: "string", Format: "uuid"},
+				"email": {Type: "string", Format: "email"},
+				"url":   {Type: "string", Format: "uri"},
+			}},
+		},
+		{
+			name:    "no format when only some values have it",
+			samples: []string{`["2024-01-02", "tomorrow"]`},
+			want:    &models.Schema{Type: "array", Items: &models.Schema{Type: "string"}},
+		},
+		{
+			name:    "values that keep repeating are an enum",
+			samples: []string{`["active", "inactive", "active", "active", "inactive", "inactive"]`},
+			want:    &models.Schema{Type: "array", Items: &models.Schema{Type: "string", Enum: []any{"active", "inactive"}}},
+		},
+		{
+			name:    "the same page twice is no enum",
+			samples: []string{`["Ada", "Grace"]`, `["Ada", "Grace"]`},
+			want:    &models.Schema{Type: "array", Items: &models.Schema{Type: "string"}},
+		},
+		{
+			name:    "one repeated name is no enum",
+			samples: []string{`["Smith", "Smith", "Smith", "Jones"]`},
+			want:    &models.Schema{Type: "array", Items: &models.Schema{Type: "string"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Infer(decode(t, tt.samples...)...)
+			if !reflect.DeepEqual(got, tt.want) {
+				g, _ := json.Marshal(got)
+				w, _ := json.Marshal(tt.want)
+				t.Errorf("Infer = %s, want %s", g, w)
+			}
+		})
+	}
+}
+
+func TestInferGoValues(t *testing.T) {
+	type employee struct {
+		ID      int      `json:"id"`
+		Salary  float32  `json:"salary"`
+		Tags    []string `json:"tags"`
+		Manager *string  `json:"manager"`
+	}
+
+	got := Infer(employee{ID: 1, Salary: 1.5, Tags: []string{"a"}}, map[string]any{"id": int64(2), "salary": uint8(3), "tags": []string{}, "manager": "Ada"})
+
+	want := &models.Schema{Type: "object", Required: []string{"id", "manager", "salary", "tags"}, Properties: map[string]*models.Schema{
+		"id":      {Type: "integer"},
+		"salary":  {Type: "number"},
+		"tags":    {Type: "array", Items: &models.Schema{Type: "string"}},
+		"manager": {Type: "string", Nullable: true},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		g, _ := json.Marshal(got)
+		w, _ := json.Marshal(want)
+		t.Errorf("Infer = %s, want %s", g, w)
+	}
+
+	if got := Infer(make(chan int)); got.Type != "" {
+		t.Errorf("Infer of a channel has type %q, want it left open", got.Type)
+	}
+}
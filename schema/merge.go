@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+// Contradiction is a place where the documented schema and the observed responses disagree
+type Contradiction struct {
+	Path       string `json:"path"`
+	Documented string `json:"documented"`
+	Observed   string `json:"observed"`
+}
+
+func (c Contradiction) String() string {
+	return fmt.Sprintf("%s: documented %s, observed %s", c.Path, c.Documented, c.Observed)
+}
+
+/*
+Merge combines a documented schema with one inferred from real responses. The
+documentation keeps its descriptions, enums and fields that weren't observed, but
+where the two disagree the observed behaviour wins, since that is what the API
+really does, and the disagreement is reported as a contradiction. References are
+not followed, so resolve documented schemas against their config first.
+*/
+func Merge(documented, observed *models.Schema) (*models.Schema, []Contradiction) {
+	var contradictions []Contradiction
+	merged := merge("$", documented, observed, &contradictions)
+	return merged, contradictions
+}
+
+func merge(path string, documented, observed *models.Schema, contradictions *[]Contradiction) *models.Schema {
+	if documented == nil {
+		return observed
+	}
+	if observed == nil {
+		return documented
+	}
+
+	report := func(documented, observed string) {
+		*contradictions = append(*contradictions, Contradiction{Path: path, Documented: documented, Observed: observed})
+	}
+
+	merged := *documented
+
+	switch {
+	case observed.Type == "" || observed.Type == documented.Type:
+	case documented.Type == "":
+		merged.Type = observed.Type
+	case documented.Type == "number" && observed.Type == "integer":
+		// Whole numbers are valid numbers.
+	default:
+		report("type "+documented.Type, "type "+observed.Type)
+		merged.Type = observed.Type
+	}
+
+	if observed.Nullable && !documented.Nullable {
+		report("not nullable", "null")
+		merged.Nullable = true
+	}
+
+	if documented.Format != "" && observed.Format != "" && documented.Format != observed.Format {
+		report("format "+documented.Format, "format "+observed.Format)
+		merged.Format = observed.Format
+	} else if documented.Format == "" {
+		merged.Format = observed.Format
+	}
+
+	if len(documented.Enum) > 0 {
+		merged.Enum = slices.Clone(documented.Enum)
+		for _, value := range observed.Enum {
+			if !slices.ContainsFunc(documented.Enum, func(v any) bool { return fmt.Sprint(v) == fmt.Sprint(value) }) {
+				report(fmt.Sprintf("enum %v", documented.Enum), fmt.Sprintf("value %v", value))
+				merged.Enum = append(merged.Enum, value)
+			}
+		}
+	}
+
+	if merged.Type == "object" {
+		merged.Properties, merged.Required = mergeProperties(path, documented, observed, contradictions)
+	}
+
+	if documented.Items != nil || observed.Items != nil {
+		merged.Items = merge(path+"[*]", documented.Items, observed.Items, contradictions)
+	}
+
+	return &merged
+}
+
+/*
+mergeProperties takes the union of both sides' properties. Documented fields that
+never showed up stay, unless the docs claim they're required. Undocumented fields
+are added, and are only required when the docs say nothing about fields at all.
+*/
+func mergeProperties(path string, documented, observed *models.Schema, contradictions *[]Contradiction) (map[string]*models.Schema, []string) {
+	properties := map[string]*models.Schema{}
+	required := map[string]bool{}
+
+	for _, key := range documented.Required {
+		required[key] = true
+	}
+
+	keys := slices.Collect(maps.Keys(documented.Properties))
+	for key := range observed.Properties {
+		if _, ok := documented.Properties[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := path + "." + key
+		doc, isDocumented := documented.Properties[key]
+		obs, isObserved := observed.Properties[key]
+
+		switch {
+		case isDocumented && !isObserved && observed.Type == "object":
+			if required[key] {
+				*contradictions = append(*contradictions, Contradiction{Path: child, Documented: "required", Observed: "missing"})
+				delete(required, key)
+			}
+		case isDocumented && required[key] && !slices.Contains(observed.Required, key):
+			*contradictions = append(*contradictions, Contradiction{Path: child, Documented: "required", Observed: "sometimes missing"})
+			delete(required, key)
+		case !isDocumented && len(documented.Properties) > 0:
+			*contradictions = append(*contradictions, Contradiction{Path: child, Documented: "absent", Observed: "present"})
+		case !isDocumented && slices.Contains(observed.Required, key):
+			required[key] = true
+		}
+
+		properties[key] = merge(child, doc, obs, contradictions)
+	}
+
+	return properties, slices.Sorted(maps.Keys(required))
+}
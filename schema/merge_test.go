@@ -0,0 +1,135 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name           string
+		documented     *models.Schema
+		observed       *models.Schema
+		want           *models.Schema
+		contradictions []Contradiction
+	}{
+		{
+			name:       "documentation keeps its descriptions and enums",
+			documented: &models.Schema{Type: "string", Description: "Status", Enum: []any{"active", "inactive"}},
+			observed:   &models.Schema{Type: "string", Enum: []any{"active"}},
+			want:       &models.Schema{Type: "string", Description: "Status", Enum: []any{"active", "inactive"}},
+		},
+		{
+			name:           "observed enum values are added",
+			documented:     &models.Schema{Type: "string", Enum: []any{"active"}},
+			observed:       &models.Schema{Type: "string", Enum: []any{"active", "archived"}},
+			want:           &models.Schema{Type: "string", Enum: []any{"active", "archived"}},
+			contradictions: []Contradiction{{Path: "$", Documented: "enum [active]", Observed: "value archived"}},
+		},
+		{
+			name:           "observed types win",
+			documented:     &models.Schema{Type: "integer"},
+			observed:       &models.Schema{Type: "string"},
+			want:           &models.Schema{Type: "string"},
+			contradictions: []Contradiction{{Path: "$", Documented: "type integer", Observed: "type string"}},
+		},
+		{
+			name:       "whole numbers are numbers",
+			documented: &models.Schema{Type: "number"},
+			observed:   &models.Schema{Type: "integer"},
+			want:       &models.Schema{Type: "number"},
+		},
+		{
+			name:           "observed nulls",
+			documented:     &models.Schema{Type: "string", Format: "date"},
+			observed:       &models.Schema{Type: "string", Nullable: true},
+			want:           &models.Schema{Type: "string", Format: "date", Nullable: true},
+			contradictions: []Contradiction{{Path: "$", Documented: "not nullable", Observed: "null"}},
+		},
+		{
+			name:       "observed formats fill in",
+			documented: &models.Schema{Type: "string"},
+			observed:   &models.Schema{Type: "string", Format: "uuid"},
+			want:       &models.Schema{Type: "string", Format: "uuid"},
+		},
+		{
+			name: "properties",
+			documented: &models.Schema{Type: "object", Required: []string{"id", "name", "team"}, Properties: map[string]*models.Schema{
+				"id":    {Type: "integer", Description: "ID"},
+				"name":  {Type: "string"},
+				"team":  {Type: "string"},
+				"email": {Type: "string"},
+			}},
+			observed: &models.Schema{Type: "object", Required: []string{"id", "name", "extra"}, Properties: map[string]*models.Schema{
+				"id":    {Type: "integer"},
+				"name":  {Type: "string"},
+				"extra": {Type: "boolean"},
+			}},
+			want: &models.Schema{Type: "object", Required: []string{"id", "name"}, Properties: map[string]*models.Schema{
+				"id":    {Type: "integer", Description: "ID"},
+				"name":  {Type: "string"},
+				"team":  {Type: "string"},
+				"email": {Type: "string"},
+				"extra": {Type: "boolean"},
+			}},
+			contradictions: []Contradiction{
+				{Path: "$.extra", Documented: "absent", Observed: "present"},
+				{Path: "$.team", Documented: "required", Observed: "missing"},
+			},
+		},
+		{
+			name: "documented required fields that are sometimes missing",
+			documented: &models.Schema{Type: "object", Required: []string{"id"}, Properties: map[string]*models.Schema{
+				"id": {Type: "integer"},
+			}},
+			observed: &models.Schema{Type: "object", Properties: map[string]*models.Schema{
+				"id": {Type: "integer"},
+			}},
+			want: &models.Schema{Type: "object", Properties: map[string]*models.Schema{
+				"id": {Type: "integer"},
+			}},
+			contradictions: []Contradiction{{Path: "$.id", Documented: "required", Observed: "sometimes missing"}},
+		},
+		{
+			name:       "undocumented fields are required when the docs list none",
+			documented: &models.Schema{Type: "object"},
+			observed: &models.Schema{Type: "object", Required: []string{"id"}, Properties: map[string]*models.Schema{
+				"id":   {Type: "integer"},
+				"name": {Type: "string"},
+			}},
+			want: &models.Schema{Type: "object", Required: []string{"id"}, Properties: map[string]*models.Schema{
+				"id":   {Type: "integer"},
+				"name": {Type: "string"},
+			}},
+		},
+		{
+			name:           "items",
+			documented:     &models.Schema{Type: "array", Items: &models.Schema{Type: "integer"}},
+			observed:       &models.Schema{Type: "array", Items: &models.Schema{Type: "string"}},
+			want:           &models.Schema{Type: "array", Items: &models.Schema{Type: "string"}},
+			contradictions: []Contradiction{{Path: "$[*]", Documented: "type integer", Observed: "type string"}},
+		},
+		{
+			name:     "nothing documented",
+			observed: &models.Schema{Type: "string"},
+			want:     &models.Schema{Type: "string"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, contradictions := Merge(tt.documented, tt.observed)
+			if !reflect.DeepEqual(got, tt.want) {
+				g, _ := json.Marshal(got)
+				w, _ := json.Marshal(tt.want)
+				t.Errorf("Merge = %s, want %s", g, w)
+			}
+			if !reflect.DeepEqual(contradictions, tt.contradictions) {
+				t.Errorf("contradictions = %v, want %v", contradictions, tt.contradictions)
+			}
+		})
+	}
+}
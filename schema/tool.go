@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+type SchemaInferrer struct {
+	ToolName        string           `json:"name" jsonschema:"description=The name of the tool,required"`
+	ToolDescription string           `json:"description" jsonschema:"description=The description of the tool,required"`
+	ToolParameters  models.Parameter `json:"parameters" jsonschema:"description=The parameters of the tool,required"`
+	Required        []string         `json:"required" jsonschema:"description=The required parameters of the tool,required"`
+}
+
+func NewSchemaInferrer() models.ToolType {
+	return &SchemaInferrer{
+		ToolName:        "infer_schema",
+		ToolDescription: "Infers a JSON Schema from sample JSON responses, optionally merging it with the documented schema and reporting where the documentation and the responses contradict each other",
+		ToolParameters: models.Parameter{
+			Type: "object",
+			Properties: []models.Property{
+				{
+					Name:        "samples",
+					Type:        "array",
+					Description: "One or more JSON responses of the same endpoint, as JSON values or strings",
+				},
+				{
+					Name:        "documented",
+					Type:        "object",
+					Description: "The schema the documentation describes, to merge the inferred schema with",
+				},
+			},
+			Required: true,
+		},
+		Required: []string{"samples"},
+	}
+}
+
+func (si *SchemaInferrer) Name() string {
+	return si.ToolName
+}
+
+func (si *SchemaInferrer) Description() string {
+	return si.ToolDescription
+}
+
+func (si *SchemaInferrer) Execute(args map[string]any) (string, error) {
+	raw, ok := args["samples"].([]any)
+	if !ok || len(raw) == 0 {
+		log.Error("Samples are required but not provided")
+		return "", fmt.Errorf("samples are required")
+	}
+
+	samples := make([]any, 0, len(raw))
+	for _, sample := range raw {
+		// Responses are usually passed on as the raw text http_request returned.
+		if text, ok := sample.(string); ok {
+			var decoded any
+			if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+				sample = decoded
+			}
+		}
+		samples = append(samples, sample)
+	}
+
+	result := struct {
+		Schema         *models.Schema  `json:"schema"`
+		Contradictions []Contradiction `json:"contradictions,omitempty"`
+	}{
+		Schema: Infer(samples...),
+	}
+
+	if documented, ok := args["documented"].(map[string]any); ok && len(documented) > 0 {
+		data, err := json.Marshal(documented)
+		if err != nil {
+			return "", fmt.Errorf("error encoding documented schema: %w", err)
+		}
+
+		var doc models.Schema
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", fmt.Errorf("error decoding documented schema: %w", err)
+		}
+
+		result.Schema, result.Contradictions = Merge(&doc, result.Schema)
+	}
+
+	log.Info("Schema inferred", "samples", len(samples), "contradictions", len(result.Contradictions))
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding schema: %w", err)
+	}
+
+	return string(out), nil
+}
+
+func (si *SchemaInferrer) Schema() any {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"samples": map[string]interface{}{
+				"type":        "array",
+				"description": "One or more JSON responses of the same endpoint, as JSON values or strings",
+				"items":       map[string]interface{}{},
+			},
+			"documented": map[string]interface{}{
+				"type":        "object",
+				"description": "The schema the documentation describes, to merge the inferred schema with",
+			},
+		},
+		"required": []string{"samples"},
+	}
+}
@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func TestSchemaInferrer(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           map[string]any
+		required       []string
+		contradictions int
+		fails          bool
+	}{
+		{
+			name:     "samples as text",
+			args:     map[string]any{"samples": []any{`{"id": 1, "name": "Ada"}`, `{"id": 2}`}},
+			required: []string{"id"},
+		},
+		{
+			name:     "samples as JSON values",
+			args:     map[string]any{"samples": []any{map[string]any{"id": float64(1), "name": "Ada"}}},
+			required: []string{"id", "name"},
+		},
+		{
+			name: "merged with the documented schema",
+			args: map[string]any{
+				"samples":    []any{`{"id": "1"}`},
+				"documented": map[string]any{"type": "object", "required": []any{"id"}, "properties": map[string]any{"id": map[string]any{"type": "integer"}}},
+			},
+			required:       []string{"id"},
+			contradictions: 1,
+		},
+		{
+			name:  "without samples",
+			args:  map[string]any{"samples": []any{}},
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := NewSchemaInferrer().Execute(tt.args)
+			if (err != nil) != tt.fails {
+				t.Fatalf("Execute error = %v, want failure %v", err, tt.fails)
+			}
+			if tt.fails {
+				return
+			}
+
+			var result struct {
+				Schema         models.Schema   `json:"schema"`
+				Contradictions []Contradiction `json:"contradictions"`
+			}
+			if err := json.Unmarshal([]byte(out), &result); err != nil {
+				t.Fatalf("decoding %s: %v", out, err)
+			}
+
+			if result.Schema.Type != "object" || len(result.Schema.Required) != len(tt.required) {
+				t.Errorf("schema = %s, want an object requiring %v", out, tt.required)
+			}
+			if len(result.Contradictions) != tt.contradictions {
+				t.Errorf("got %d contradictions, want %d: %s", len(result.Contradictions), tt.contradictions, out)
+			}
+		})
+	}
+}
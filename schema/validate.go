@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+/*
+Validate checks a decoded JSON value against a schema and returns every mismatch,
+each prefixed with the JSONPath of the offending value. References are resolved
+against the config's schemas. Undeclared fields are allowed, since documentation
+rarely lists every field a response carries.
+*/
+func Validate(config *models.APIConfig, schema *models.Schema, value any) []string {
+	var mismatches []string
+	validate(config, "$", schema, value, &mismatches)
+	return mismatches
+}
+
+func validate(config *models.APIConfig, path string, schema *models.Schema, value any, mismatches *[]string) {
+	report := func(format string, args ...any) {
+		*mismatches = append(*mismatches, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	schema, err := config.ResolveSchema(schema)
+	if err != nil {
+		report("%v", err)
+		return
+	}
+	if schema == nil {
+		return
+	}
+
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			report("expected %s, got null", schema.Type)
+		}
+		return
+	}
+
+	if schema.Type != "" && !hasType(value, schema.Type) {
+		report("expected %s, got %s", schema.Type, typeOf(value))
+		return
+	}
+
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(v any) bool { return fmt.Sprint(v) == fmt.Sprint(value) }) {
+		report("value %v is not one of %v", value, schema.Enum)
+	}
+
+	switch v := value.(type) {
+	case string:
+		if schema.Format != "" && formats[schema.Format] && format(v) != schema.Format {
+			report("%q is not a valid %s", v, schema.Format)
+		}
+
+	case map[string]any:
+		for _, key := range schema.Required {
+			if _, ok := v[key]; !ok {
+				report("missing required field %q", key)
+			}
+		}
+		for key, property := range schema.Properties {
+			if child, ok := v[key]; ok {
+				validate(config, path+"."+key, property, child, mismatches)
+			}
+		}
+
+	case []any:
+		if schema.Items != nil {
+			for i, item := range v {
+				validate(config, fmt.Sprintf("%s[%d]", path, i), schema.Items, item, mismatches)
+			}
+		}
+	}
+}
+
+// formats are the formats Validate checks, others are taken on trust
+var formats = map[string]bool{
+	"date-time": true,
+	"date":      true,
+	"uuid":      true,
+	"email":     true,
+	"uri":       true,
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == float64(int64(n))
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	}
+	return typeOf(value) == t
+}
+
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
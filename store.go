@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/store"
+)
+
+// defaultStorePath is where runs keep their collections unless told otherwise
+const defaultStorePath = "milkshake.db"
+
+// storeCommand inspects the collections written by integration runs
+func storeCommand(args []string) error {
+	flags := flag.NewFlagSet("store", flag.ExitOnError)
+	storePath := flags.String("store", defaultStorePath, "The store file to inspect")
+	flags.Parse(args)
+
+	usage := fmt.Errorf("usage: milkshake store [flags] collections | query <collection> [field=value ...]")
+
+	if flags.NArg() == 0 {
+		return usage
+	}
+
+	s, err := store.OpenFile(*storePath)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var out any
+
+	switch flags.Arg(0) {
+	case "collections":
+		if out, err = s.Collections(); err != nil {
+			return err
+		}
+
+	case "query":
+		if flags.NArg() < 2 {
+			return usage
+		}
+
+		filter := store.Filter{}
+		for _, arg := range flags.Args()[2:] {
+			field, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return fmt.Errorf("invalid filter %q, expected field=value", arg)
+			}
+			filter[field] = value
+		}
+
+		if out, err = s.Query(flags.Arg(1), filter); err != nil {
+			return err
+		}
+
+	default:
+		return usage
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
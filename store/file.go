@@ -0,0 +1,131 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+/*
+File is a Store embedded in a single JSON file, so runs persist without an
+external database. Everything is kept in memory and the whole file is rewritten
+atomically after every change, which is plenty for integration test runs. Only
+changes write the file, so reading a store never creates or rewrites it.
+*/
+type File struct {
+	*Memory
+	path    string
+	writing sync.Mutex
+	dirty   bool
+}
+
+// OpenFile opens the store at path, creating it on the first write if it does not exist
+func OpenFile(path string) (*File, error) {
+	file := &File{
+		Memory: NewMemory(),
+		path:   path,
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		log.Info("Starting new store", "path", path)
+	case err != nil:
+		return nil, fmt.Errorf("error reading store: %w", err)
+	default:
+		if err := json.Unmarshal(data, &file.collections); err != nil {
+			return nil, fmt.Errorf("error parsing store: %w", err)
+		}
+		if file.collections == nil {
+			file.collections = map[string][]Record{}
+		}
+		log.Info("Opened store", "path", path, "collections", len(file.collections))
+	}
+
+	return file, nil
+}
+
+func (f *File) Insert(collection string, records ...Record) error {
+	if err := f.Memory.Insert(collection, records...); err != nil {
+		return err
+	}
+	return f.changed()
+}
+
+func (f *File) Upsert(collection, matchField string, records ...Record) error {
+	if err := f.Memory.Upsert(collection, matchField, records...); err != nil {
+		return err
+	}
+	return f.changed()
+}
+
+func (f *File) Delete(collection, matchField string, records ...Record) (int, error) {
+	deleted, err := f.Memory.Delete(collection, matchField, records...)
+	if err != nil || deleted == 0 {
+		return deleted, err
+	}
+	return deleted, f.changed()
+}
+
+// Close writes what a failed flush left unwritten, if anything
+func (f *File) Close() error {
+	return f.flush()
+}
+
+// changed marks the store as changed and writes it
+func (f *File) changed() error {
+	f.writing.Lock()
+	f.dirty = true
+	f.writing.Unlock()
+
+	return f.flush()
+}
+
+// flush writes to a temporary file first and renames it, so a crash never leaves a half-written store
+func (f *File) flush() error {
+	f.writing.Lock()
+	defer f.writing.Unlock()
+
+	if !f.dirty {
+		return nil
+	}
+
+	f.mu.RLock()
+	data, err := json.MarshalIndent(f.collections, "", "  ")
+	f.mu.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("error encoding store: %w", err)
+	}
+
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating store directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error writing store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing store: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing store: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("error writing store: %w", err)
+	}
+
+	f.dirty = false
+	return nil
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileReadsDontWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "none.db")
+
+	file, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := file.Collections(); err != nil {
+		t.Fatalf("Collections: %v", err)
+	}
+	if _, err := file.Query("employees", nil); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if _, err := file.Delete("employees", "id", Record{"id": 1}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("reading a missing store created it: %v", err)
+	}
+}
+
+func TestFileWritesPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs", "milkshake.db")
+
+	file, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if err := file.Upsert("employees", "id", Record{"id": "1", "name": "Ada"}, Record{"id": "2", "name": "Grace"}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := file.Upsert("employees", "id", Record{"id": "2", "name": "Grace Hopper"}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Backdate the file, so a rewrite by the read-only open below would show.
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	got, err := reopened.Query("employees", Filter{"id": "2"})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if want := []Record{{"id": "2", "name": "Grace Hopper"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Query = %v, want %v", got, want)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("closing a store that was only read rewrote it")
+	}
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// Record is a single item in a collection
+type Record = map[string]any
+
+// Filter selects records whose fields equal the given values, an empty filter selects everything
+type Filter map[string]any
+
+/*
+Store holds collections of records for the integration runner. Upserts and
+deletes identify records by the value of a match field, the way Step.MatchField
+describes them.
+*/
+type Store interface {
+	Insert(collection string, records ...Record) error
+	Upsert(collection, matchField string, records ...Record) error
+	Delete(collection, matchField string, records ...Record) (int, error)
+	Query(collection string, filter Filter) ([]Record, error)
+	Collections() ([]string, error)
+	Close() error
+}
+
+// Memory is a Store that keeps everything in memory, for runs that don't need to persist
+type Memory struct {
+	mu          sync.RWMutex
+	collections map[string][]Record
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{collections: map[string][]Record{}}
+}
+
+func (m *Memory) Insert(collection string, records ...Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.collections[collection] = append(m.collections[collection], records...)
+	return nil
+}
+
+func (m *Memory) Upsert(collection, matchField string, records ...Record) error {
+	if matchField == "" {
+		return fmt.Errorf("upsert on collection %s requires a match field", collection)
+	}
+	if err := identified(collection, matchField, records); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := m.collections[collection]
+	for _, record := range records {
+		if index := indexOf(existing, matchField, record[matchField]); index >= 0 {
+			existing[index] = record
+			continue
+		}
+		existing = append(existing, record)
+	}
+	m.collections[collection] = existing
+
+	return nil
+}
+
+func (m *Memory) Delete(collection, matchField string, records ...Record) (int, error) {
+	if matchField == "" {
+		return 0, fmt.Errorf("delete on collection %s requires a match field", collection)
+	}
+	if err := identified(collection, matchField, records); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	existing := m.collections[collection]
+	for _, record := range records {
+		if index := indexOf(existing, matchField, record[matchField]); index >= 0 {
+			existing = slices.Delete(existing, index, index+1)
+			deleted++
+		}
+	}
+	m.collections[collection] = existing
+
+	return deleted, nil
+}
+
+func (m *Memory) Query(collection string, filter Filter) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := []Record{}
+	for _, record := range m.collections[collection] {
+		if filter.Matches(record) {
+			out = append(out, record)
+		}
+	}
+
+	return out, nil
+}
+
+func (m *Memory) Collections() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.collections))
+	for name := range m.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Matches reports whether the record has every field in the filter
+func (filter Filter) Matches(record Record) bool {
+	for field, value := range filter {
+		if !equal(record[field], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// identified checks every record has a value for the match field, records without one would all match each other
+func identified(collection, matchField string, records []Record) error {
+	for i, record := range records {
+		if utils.Stringify(record[matchField]) == "" {
+			return fmt.Errorf("error matching record %d in collection %s: no value for %s", i, collection, matchField)
+		}
+	}
+	return nil
+}
+
+func indexOf(records []Record, matchField string, value any) int {
+	for i, record := range records {
+		if equal(record[matchField], value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// equal compares values loosely, since an ID can come back as 42 from one API and "42" from another
+func equal(a, b any) bool {
+	return utils.Stringify(a) == utils.Stringify(b)
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemoryUpsert(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []Record
+		want    []Record
+		fails   bool
+	}{
+		{
+			name:    "replaces a record with the same value",
+			records: []Record{{"id": "1", "name": "Ada"}, {"id": "1", "name": "Ada Lovelace"}},
+			want:    []Record{{"id": "1", "name": "Ada Lovelace"}},
+		},
+		{
+			name:    "matches a number to the same string",
+			records: []Record{{"id": float64(1000000), "name": "Ada"}, {"id": "1000000", "name": "Ada Lovelace"}},
+			want:    []Record{{"id": "1000000", "name": "Ada Lovelace"}},
+		},
+		{
+			name:    "keeps records with other values",
+			records: []Record{{"id": 1, "name": "Ada"}, {"id": 2, "name": "Grace"}},
+			want:    []Record{{"id": 1, "name": "Ada"}, {"id": 2, "name": "Grace"}},
+		},
+		{
+			name:    "rejects records without the match field",
+			records: []Record{{"id": 1, "name": "Ada"}, {"name": "Grace"}},
+			want:    []Record{},
+			fails:   true,
+		},
+		{
+			name:    "rejects records with a null match field",
+			records: []Record{{"id": nil, "name": "Ada"}},
+			want:    []Record{},
+			fails:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory()
+
+			// Nothing is written when any record in the batch lacks a value.
+			if err := m.Upsert("employees", "id", tt.records...); (err != nil) != tt.fails {
+				t.Fatalf("Upsert error = %v, want failure %v", err, tt.fails)
+			}
+
+			got, _ := m.Query("employees", nil)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stored %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryDelete(t *testing.T) {
+	m := NewMemory()
+	m.Insert("employees", Record{"id": float64(1000000), "name": "Ada"}, Record{"id": "2", "name": "Grace"})
+
+	if _, err := m.Delete("employees", "id", Record{"name": "Ada"}); err == nil {
+		t.Error("deleted a record without the match field, want an error")
+	}
+
+	deleted, err := m.Delete("employees", "id", Record{"id": "1000000"}, Record{"id": "3"})
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted %d records, want 1", deleted)
+	}
+
+	got, _ := m.Query("employees", nil)
+	if want := []Record{{"id": "2", "name": "Grace"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("left %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+Lookup resolves a JSONPath-style expression against decoded JSON. It supports the
+subset that shows up in API documentation: dotted keys, array indexes (negative
+ones count from the end), quoted keys and the * wildcard, e.g. $.data[*].name
+or items.0['first name']. A wildcard collects the results into a slice, in key
+order for objects.
+*/
+func Lookup(data any, path string) (any, bool) {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	return lookup(data, segments)
+}
+
+func lookup(data any, segments []string) (any, bool) {
+	if len(segments) == 0 {
+		return data, true
+	}
+
+	segment, rest := segments[0], segments[1:]
+
+	switch value := data.(type) {
+	case map[string]any:
+		if segment == "*" {
+			// Sorted by key, since the order of a map is random.
+			out := []any{}
+			for _, key := range slices.Sorted(maps.Keys(value)) {
+				if found, ok := lookup(value[key], rest); ok {
+					out = append(out, found)
+				}
+			}
+			return out, true
+		}
+
+		item, ok := value[segment]
+		if !ok {
+			return nil, false
+		}
+		return lookup(item, rest)
+
+	case []any:
+		if segment == "*" {
+			out := []any{}
+			for _, item := range value {
+				if found, ok := lookup(item, rest); ok {
+					out = append(out, found)
+				}
+			}
+			return out, true
+		}
+
+		index, err := strconv.Atoi(segment)
+		if err != nil {
+			return nil, false
+		}
+		if index < 0 {
+			index += len(value)
+		}
+		if index < 0 || index >= len(value) {
+			return nil, false
+		}
+		return lookup(value[index], rest)
+	}
+
+	return nil, false
+}
+
+// ParsePath splits a path expression into its segments
+func ParsePath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+
+	var (
+		segments []string
+		current  strings.Builder
+	)
+
+	flush := func() {
+		if current.Len() > 0 {
+			segments = append(segments, current.String())
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '.':
+			flush()
+		case '[':
+			flush()
+
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket in path: %s", path)
+			}
+
+			segment := strings.TrimSpace(path[i+1 : i+end])
+			segment = strings.Trim(segment, `'"`)
+			segments = append(segments, segment)
+			i += end
+		default:
+			current.WriteByte(path[i])
+		}
+	}
+	flush()
+
+	return segments, nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLookupWildcardOverObjectIsSorted(t *testing.T) {
+	data := map[string]any{"teams": map[string]any{
+		"ops":   map[string]any{"lead": "Grace"},
+		"data":  map[string]any{"lead": "Ada"},
+		"infra": map[string]any{"lead": "Linus"},
+		"web":   map[string]any{"lead": "Tim"},
+	}}
+
+	want := []any{"Ada", "Linus", "Grace", "Tim"}
+	for range 20 {
+		got, ok := Lookup(data, "$.teams.*.lead")
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Fatalf("Lookup = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// placeholder matches {{ path }} references inside config values
+var placeholder = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+/*
+Render resolves {{ path }} placeholders in strings, maps and slices against vars.
+A string that is nothing but a single placeholder is replaced by the raw value,
+so numbers, objects and lists keep their type. {{env.NAME}} reads from the
+environment, so secrets never have to be written into a config.
+*/
+func Render(value any, vars map[string]any) any {
+	switch v := value.(type) {
+	case string:
+		if match := placeholder.FindStringSubmatch(v); match != nil && match[0] == v {
+			if resolved, ok := Resolve(match[1], vars); ok {
+				return resolved
+			}
+			return v
+		}
+
+		return placeholder.ReplaceAllStringFunc(v, func(m string) string {
+			resolved, ok := Resolve(placeholder.FindStringSubmatch(m)[1], vars)
+			if !ok {
+				return m
+			}
+			return Stringify(resolved)
+		})
+
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, val := range v {
+			out[key] = Render(val, vars)
+		}
+		return out
+
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = Render(val, vars)
+		}
+		return out
+	}
+
+	return value
+}
+
+// RenderString renders a string value, always returning a string
+func RenderString(value string, vars map[string]any) string {
+	return Stringify(Render(value, vars))
+}
+
+// Unresolved lists the placeholder paths in strings, maps and slices that don't resolve against vars
+func Unresolved(value any, vars map[string]any) []string {
+	var missing []string
+
+	switch v := value.(type) {
+	case string:
+		for _, match := range placeholder.FindAllStringSubmatch(v, -1) {
+			if _, ok := Resolve(match[1], vars); !ok {
+				missing = append(missing, match[1])
+			}
+		}
+	case map[string]any:
+		for _, val := range v {
+			missing = append(missing, Unresolved(val, vars)...)
+		}
+	case []any:
+		for _, val := range v {
+			missing = append(missing, Unresolved(val, vars)...)
+		}
+	}
+
+	return missing
+}
+
+// Resolve looks up a placeholder path in vars, or in the environment for env.NAME
+func Resolve(path string, vars map[string]any) (any, bool) {
+	if name, ok := strings.CutPrefix(path, "env."); ok {
+		return os.LookupEnv(name)
+	}
+
+	return Lookup(vars, path)
+}
+
+// Stringify formats a decoded JSON value for use in headers, URLs and query strings
+func Stringify(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		// JSON numbers decode as float64, but IDs should not turn into 1.2e+06.
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v))
+		}
+	}
+	return fmt.Sprint(value)
+}
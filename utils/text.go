@@ -0,0 +1,13 @@
+package utils
+
+/*
+Truncate shortens s to at most n characters, ending in ... when it was cut. It
+cuts on runes, so text in another script doesn't end halfway a character.
+*/
+func Truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:max(n-3, 0)]) + "..."
+}
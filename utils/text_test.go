@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"a bit too long", 10, "a bit t..."},
+		{"überprüfung der daten", 10, "überprü..."},
+		{"社員の一覧を取得します", 6, "社員の..."},
+		{"🙂🙂🙂🙂🙂", 4, "🙂..."},
+	}
+
+	for _, tt := range tests {
+		got := Truncate(tt.in, tt.n)
+		if got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("Truncate(%q, %d) split a character: %q", tt.in, tt.n, got)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/verify"
+)
+
+// verifyCommand probes the endpoints in an APIConfig against the live API and reports what it finds
+func verifyCommand(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	reportPath := flags.String("report", "", "Write the JSON report to this file instead of stdout")
+	allow := flags.String("allow", "", "Comma-separated unsafe methods that may be sent, e.g. POST,PUT")
+	configureHTTP := httpFlags(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: milkshake verify [flags] config.json")
+	}
+
+	if err := configureHTTP(); err != nil {
+		return err
+	}
+
+	config, err := models.LoadAPIConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	report, verifyErr := verify.New(config).AllowMethods(strings.Split(*allow, ",")...).Verify()
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report: %w", err)
+	}
+
+	if *reportPath == "" {
+		fmt.Println(string(data))
+	} else if err := os.WriteFile(*reportPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+
+	if verifyErr != nil {
+		return verifyErr
+	}
+
+	if report.Failed() {
+		return fmt.Errorf("%d endpoints unreachable, %d not matching their schema", report.Summary.Unreachable, report.Summary.SchemaMismatches)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package verify
+
+import (
+	"time"
+)
+
+// Result records what probing a single endpoint showed
+type Result struct {
+	Endpoint      string        `json:"endpoint"`
+	Method        string        `json:"method"`
+	URL           string        `json:"url,omitempty"`
+	Probe         string        `json:"probe,omitempty"`
+	Reachable     bool          `json:"reachable"`
+	HTTPStatus    int           `json:"http_status,omitempty"`
+	MethodAllowed *bool         `json:"method_allowed,omitempty"`
+	SchemaMatch   *bool         `json:"schema_match,omitempty"`
+	Mismatches    []string      `json:"mismatches,omitempty"`
+	Skipped       string        `json:"skipped,omitempty"`
+	Duration      time.Duration `json:"duration"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// Summary counts the results of a verification
+type Summary struct {
+	Endpoints        int `json:"endpoints"`
+	Reachable        int `json:"reachable"`
+	Unreachable      int `json:"unreachable"`
+	SchemaMatches    int `json:"schema_matches"`
+	SchemaMismatches int `json:"schema_mismatches"`
+	Skipped          int `json:"skipped"`
+}
+
+// Report is the outcome of verifying a config against the live API
+type Report struct {
+	Integration string    `json:"integration"`
+	BaseURL     string    `json:"base_url"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	AuthError   string    `json:"auth_error,omitempty"`
+	Summary     Summary   `json:"summary"`
+	Results     []Result  `json:"results"`
+}
+
+// Failed reports whether authentication failed, or any probed endpoint was unreachable or didn't match its schema
+func (report *Report) Failed() bool {
+	return report.AuthError != "" || report.Summary.Unreachable > 0 || report.Summary.SchemaMismatches > 0
+}
+
+func (report *Report) summarize() {
+	report.Summary = Summary{Endpoints: len(report.Results)}
+
+	for _, result := range report.Results {
+		switch {
+		case result.Skipped != "":
+			report.Summary.Skipped++
+			continue
+		case result.Reachable:
+			report.Summary.Reachable++
+		default:
+			report.Summary.Unreachable++
+		}
+
+		if result.SchemaMatch != nil {
+			if *result.SchemaMatch {
+				report.Summary.SchemaMatches++
+			} else {
+				report.Summary.SchemaMismatches++
+			}
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/auth"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+	"github.com/theapemachine/idrinkyourmilkshake/schema"
+	"github.com/theapemachine/idrinkyourmilkshake/utils"
+)
+
+// safeMethods can be sent to a live API without changing anything
+var safeMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+// pathParameter matches OpenAPI style path parameters such as {id}
+var pathParameter = regexp.MustCompile(`\{([^{}]+)\}`)
+
+/*
+Verifier probes the endpoints of an APIConfig against the live API, to catch
+wrong paths, methods and schemas in an extracted config. It only sends safe
+methods, unless others are explicitly allowed. Endpoints with an unsafe method
+are probed with OPTIONS instead, which shows whether the path exists.
+*/
+type Verifier struct {
+	config  *models.APIConfig
+	tool    *request.HTTPRequest
+	allowed []string
+	ctx     context.Context
+	vars    map[string]any
+}
+
+// target is one endpoint to probe, from the config's endpoints or its HTTP steps
+type target struct {
+	name       string
+	method     string
+	path       string
+	parameters []models.EndpointParameter
+	headers    map[string]string
+	body       any
+	response   *models.Schema
+}
+
+// New creates a Verifier for the given config
+func New(config *models.APIConfig) *Verifier {
+	return &Verifier{
+		config:  config,
+		tool:    &request.HTTPRequest{},
+		allowed: slices.Clone(safeMethods),
+		ctx:     context.Background(),
+		vars: map[string]any{
+			"integration": config.Integration,
+			"account_id":  config.AccountID,
+			"base_url":    config.BaseURL,
+		},
+	}
+}
+
+// WithContext sets the context for the verifier
+func (v *Verifier) WithContext(ctx context.Context) *Verifier {
+	v.ctx = ctx
+	return v
+}
+
+// AllowMethods allows probing endpoints with unsafe methods like POST, which may change data on the live API
+func (v *Verifier) AllowMethods(methods ...string) *Verifier {
+	for _, method := range methods {
+		if method = strings.ToUpper(strings.TrimSpace(method)); method != "" && !slices.Contains(v.allowed, method) {
+			v.allowed = append(v.allowed, method)
+		}
+	}
+	return v
+}
+
+/*
+Verify authenticates through the config's auth block, has its request tool use
+those credentials, and probes every endpoint in turn. Failing probes end up in the
+report rather than as an error, so one bad endpoint doesn't hide the others.
+*/
+func (v *Verifier) Verify() (*Report, error) {
+	report := &Report{
+		Integration: v.config.Integration,
+		BaseURL:     v.config.BaseURL,
+		StartedAt:   time.Now(),
+	}
+
+	if auth.Type(v.config.Auth) != models.AuthNone {
+		authenticator, err := auth.New(v.config.Auth, v.config.BaseURL, request.Client())
+		if err == nil {
+			err = authenticator.Authenticate(v.ctx)
+		}
+		if err != nil {
+			report.AuthError = err.Error()
+			report.FinishedAt = time.Now()
+			return report, fmt.Errorf("authentication failed: %w", err)
+		}
+
+		v.tool.WithAuthenticator(authenticator)
+		if valuer, ok := authenticator.(auth.Valuer); ok {
+			v.vars["auth"] = valuer.Values()
+		}
+	}
+
+	targets := v.targets()
+	log.Info("Verifying endpoints", "integration", v.config.Integration, "endpoints", len(targets))
+
+	for _, t := range targets {
+		result := v.probe(t)
+		report.Results = append(report.Results, result)
+
+		switch {
+		case result.Skipped != "":
+			log.Warn("Endpoint skipped", "endpoint", result.Endpoint, "reason", result.Skipped)
+		case !result.Reachable || (result.SchemaMatch != nil && !*result.SchemaMatch):
+			log.Error("Endpoint failed verification", "endpoint", result.Endpoint, "status", result.HTTPStatus, "error", result.Error)
+		default:
+			log.Info("Endpoint verified", "endpoint", result.Endpoint, "status", result.HTTPStatus)
+		}
+	}
+
+	report.summarize()
+	report.FinishedAt = time.Now()
+
+	return report, nil
+}
+
+/*
+targets collects the endpoints to probe. The config's endpoints come first, then
+the HTTP steps of its jobs that call something the endpoints don't already cover.
+*/
+func (v *Verifier) targets() []target {
+	var targets []target
+	seen := map[string]bool{}
+
+	for _, endpoint := range v.config.Endpoints {
+		method := strings.ToUpper(endpoint.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		var body any
+		if req, err := v.config.ResolveSchema(endpoint.Request); err == nil && req != nil {
+			body = req.Example
+		}
+
+		targets = append(targets, target{
+			name:       endpoint.Name,
+			method:     method,
+			path:       endpoint.Path,
+			parameters: endpoint.Parameters,
+			body:       body,
+			response:   endpoint.Response,
+		})
+		seen[method+" "+endpoint.Path] = true
+	}
+
+	for _, job := range v.config.Jobs {
+		for _, step := range job.Steps {
+			if step.Endpoint == "" || step.GraphQL != nil || step.Collection != "" {
+				continue
+			}
+
+			method := strings.ToUpper(step.Method)
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			if seen[method+" "+step.Endpoint] {
+				continue
+			}
+			seen[method+" "+step.Endpoint] = true
+
+			targets = append(targets, target{
+				name:    job.Name + "/" + step.Name,
+				method:  method,
+				path:    step.Endpoint,
+				headers: step.Inputs.Headers,
+				body:    step.Inputs.Body,
+			})
+		}
+	}
+
+	return targets
+}
+
+func (v *Verifier) probe(t target) (result Result) {
+	started := time.Now()
+	result = Result{Endpoint: t.name, Method: t.method, Probe: t.method}
+	defer func() { result.Duration = time.Since(started) }()
+
+	u, err := v.url(t)
+	if err != nil {
+		result.Skipped = err.Error()
+		return result
+	}
+	result.URL = u.String()
+
+	headers := map[string]string{"Accept": "application/json"}
+	for key, value := range t.headers {
+		headers[key] = utils.RenderString(value, v.vars)
+	}
+	for _, parameter := range t.parameters {
+		if value, ok := example(parameter); ok && parameter.In == "header" {
+			headers[parameter.Name] = value
+		}
+	}
+
+	var body string
+	if !slices.Contains(v.allowed, t.method) {
+		result.Probe = http.MethodOptions
+	} else if t.method != http.MethodGet && t.method != http.MethodHead && t.body != nil {
+		data, err := json.Marshal(utils.Render(t.body, v.vars))
+		if err != nil {
+			result.Error = fmt.Sprintf("error encoding body: %v", err)
+			return result
+		}
+		body = string(data)
+		headers["Content-Type"] = "application/json"
+	}
+
+	if strings.Contains(body, "{{") {
+		result.Skipped = "needs values from earlier steps"
+		return result
+	}
+
+	resp, err := v.tool.Do(v.ctx, result.Probe, result.URL, headers, body)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.HTTPStatus = resp.StatusCode
+	result.Reachable = reachable(result.Probe, t.method, resp.StatusCode)
+
+	if result.Probe != t.method {
+		// An OPTIONS probe can still tell whether the declared method is supported.
+		if allow := resp.Header.Get("Allow"); allow != "" {
+			allowed := slices.ContainsFunc(strings.Split(allow, ","), func(m string) bool {
+				return strings.EqualFold(strings.TrimSpace(m), t.method)
+			})
+			result.MethodAllowed = &allowed
+		}
+		return result
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("status code %d: %s", resp.StatusCode, utils.Truncate(string(resp.Body), 200))
+		return result
+	}
+
+	if t.response == nil || t.method == http.MethodHead || len(resp.Body) == 0 {
+		return result
+	}
+
+	var decoded any
+	if err := json.Unmarshal(resp.Body, &decoded); err != nil {
+		match := false
+		result.SchemaMatch = &match
+		result.Mismatches = []string{"response is not JSON"}
+		return result
+	}
+
+	result.Mismatches = schema.Validate(v.config, t.response, decoded)
+	match := len(result.Mismatches) == 0
+	result.SchemaMatch = &match
+
+	return result
+}
+
+// url renders the target's path against the base URL, filling in path and query parameters from their examples
+func (v *Verifier) url(t target) (*url.URL, error) {
+	path := utils.RenderString(t.path, v.vars)
+	if strings.Contains(path, "{{") {
+		return nil, fmt.Errorf("needs values from earlier steps")
+	}
+
+	var missing []string
+	path = pathParameter.ReplaceAllStringFunc(path, func(match string) string {
+		name := strings.Trim(match, "{}")
+		for _, parameter := range t.parameters {
+			if parameter.Name == name {
+				if value, ok := example(parameter); ok {
+					return url.PathEscape(value)
+				}
+			}
+		}
+		missing = append(missing, name)
+		return match
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no example value for path parameters %s", strings.Join(missing, ", "))
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", path, err)
+	}
+
+	if !u.IsAbs() {
+		base, err := url.Parse(v.config.BaseURL)
+		if err != nil || !base.IsAbs() {
+			return nil, fmt.Errorf("path %q is relative but base URL %q is not absolute", path, v.config.BaseURL)
+		}
+		u = base.JoinPath(u.Path).ResolveReference(&url.URL{RawQuery: u.RawQuery})
+	}
+
+	query := u.Query()
+	for _, parameter := range t.parameters {
+		if parameter.In != "query" || !parameter.Required {
+			continue
+		}
+		if value, ok := example(parameter); ok {
+			query.Set(parameter.Name, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u, nil
+}
+
+// example returns a value to send for a parameter, from its schema's example or first enum value
+func example(parameter models.EndpointParameter) (string, bool) {
+	if parameter.Schema == nil {
+		return "", false
+	}
+	if parameter.Schema.Example != nil {
+		return utils.Stringify(parameter.Schema.Example), true
+	}
+	if len(parameter.Schema.Enum) > 0 {
+		return utils.Stringify(parameter.Schema.Enum[0]), true
+	}
+	return "", false
+}
+
+/*
+reachable decides whether a response shows the endpoint exists. Any answer but
+404 or 410 does, except that probing with the declared method also requires it
+not to be rejected. OPTIONS probes may get 405 from servers that don't implement
+OPTIONS, which still means the path is there.
+*/
+func reachable(probe, method string, status int) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusGone:
+		return false
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return probe != method
+	}
+	return true
+}
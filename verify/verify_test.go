@@ -0,0 +1,157 @@
+package verify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+)
+
+// liveAPI is a stand-in API that requires a bearer token and records the requests it got
+type liveAPI struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newLiveAPI(t *testing.T) *liveAPI {
+	t.Helper()
+
+	api := &liveAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+		api.mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodOptions && r.URL.Path == "/employees":
+			w.Header().Set("Allow", "GET, POST")
+		case r.Method == http.MethodOptions:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/employees":
+			io.WriteString(w, `[{"id": 1, "name": "Ada"}]`)
+		case r.URL.Path == "/teams":
+			io.WriteString(w, `[{"id": "one"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	return api
+}
+
+func TestVerify(t *testing.T) {
+	t.Setenv("TEST_TOKEN", "s3cret")
+	api := newLiveAPI(t)
+
+	withID := &models.Schema{Type: "array", Items: &models.Schema{
+		Type:       "object",
+		Required:   []string{"id"},
+		Properties: map[string]*models.Schema{"id": {Type: "integer"}},
+	}}
+
+	config := &models.APIConfig{
+		Integration: "test",
+		BaseURL:     api.URL,
+		Auth:        models.Auth{Bearer: &models.BearerAuth{Token: "{{env.TEST_TOKEN}}"}},
+		Endpoints: []models.Endpoint{
+			{Name: "list_employees", Method: "GET", Path: "/employees", Response: withID},
+			{Name: "list_teams", Method: "GET", Path: "/teams", Response: withID},
+			{Name: "create_employee", Method: "POST", Path: "/employees"},
+			{Name: "delete_employee", Method: "DELETE", Path: "/employees/{id}", Parameters: []models.EndpointParameter{
+				{Name: "id", In: "path", Required: true, Schema: &models.Schema{Type: "integer", Example: 1}},
+			}},
+			{Name: "list_missing", Method: "GET", Path: "/missing"},
+		},
+	}
+
+	report, err := New(config).WithContext(context.Background()).Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	results := map[string]Result{}
+	for _, result := range report.Results {
+		results[result.Endpoint] = result
+	}
+
+	tests := []struct {
+		endpoint    string
+		probe       string
+		reachable   bool
+		schemaMatch *bool
+		allowed     *bool
+	}{
+		{"list_employees", "GET", true, ptr(true), nil},
+		{"list_teams", "GET", true, ptr(false), nil},
+		{"create_employee", "OPTIONS", true, nil, ptr(true)},
+		{"delete_employee", "OPTIONS", true, nil, nil},
+		{"list_missing", "GET", false, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			result := results[tt.endpoint]
+			if result.Probe != tt.probe {
+				t.Errorf("probed with %s, want %s", result.Probe, tt.probe)
+			}
+			if result.Reachable != tt.reachable {
+				t.Errorf("reachable = %v, want %v (status %d)", result.Reachable, tt.reachable, result.HTTPStatus)
+			}
+			if !equalPtr(result.SchemaMatch, tt.schemaMatch) {
+				t.Errorf("schema match = %v, want %v (mismatches %v)", deref(result.SchemaMatch), deref(tt.schemaMatch), result.Mismatches)
+			}
+			if !equalPtr(result.MethodAllowed, tt.allowed) {
+				t.Errorf("method allowed = %v, want %v", deref(result.MethodAllowed), deref(tt.allowed))
+			}
+		})
+	}
+
+	if mismatches := results["list_teams"].Mismatches; len(mismatches) == 0 || !strings.Contains(mismatches[0], "id") {
+		t.Errorf("mismatches = %v, want the id's type reported", mismatches)
+	}
+	if !report.Failed() || report.Summary.SchemaMismatches != 1 || report.Summary.Unreachable != 1 {
+		t.Errorf("summary = %+v, want one mismatch and one unreachable endpoint", report.Summary)
+	}
+
+	for _, req := range api.requests {
+		if method, _, _ := strings.Cut(req, " "); method != http.MethodGet && method != http.MethodOptions {
+			t.Errorf("sent %s to the live API, want only safe methods", req)
+		}
+	}
+
+	// The credentials stay with the verifier, rather than going to every request tool.
+	resp, err := (&request.HTTPRequest{}).Do(context.Background(), http.MethodGet, api.URL+"/employees", nil, "")
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("another request tool got %d, want it unauthenticated", resp.StatusCode)
+	}
+}
+
+func ptr(b bool) *bool { return &b }
+
+func deref(b *bool) any {
+	if b == nil {
+		return nil
+	}
+	return *b
+}
+
+func equalPtr(a, b *bool) bool {
+	return deref(a) == deref(b)
+}
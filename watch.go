@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/openai"
+	"github.com/theapemachine/idrinkyourmilkshake/watch"
+)
+
+// watchCommand monitors an API's docs and reports when they drift from a baseline config
+func watchCommand(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	docs := flags.String("url", "", "URL of the API documentation to watch")
+	interval := flags.Duration("interval", 24*time.Hour, "Time between checks")
+	once := flags.Bool("once", false, "Check once and exit, e.g. when run from cron")
+	statePath := flags.String("state", "watch-state.json", "File to keep section hashes and the latest extraction in")
+	maxPages := flags.Int("max-pages", 50, "Maximum number of docs pages to crawl")
+	reportPath := flags.String("report", "", "Write the drift report to this file")
+	webhook := flags.String("webhook", "", "POST the drift report as JSON to this URL")
+	iterations := flags.Int("max-iterations", 10, "Maximum number of model iterations per re-extraction")
+	configureHTTP := httpFlags(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *docs == "" {
+		return fmt.Errorf("usage: milkshake watch -url docs-url [flags] baseline.json")
+	}
+
+	if err := configureHTTP(); err != nil {
+		return err
+	}
+
+	baseline, err := models.LoadAPIConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return fmt.Errorf("OPENAI_API_KEY environment variable is not set")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	watcher := watch.New(*docs, baseline).
+		WithState(*statePath).
+		WithMaxPages(*maxPages).
+		WithExtractor(reextract(openai.NewClient(apiKey).WithContext(ctx), *iterations)).
+		WithReportFile(*reportPath).
+		WithWebhook(*webhook)
+
+	if !*once {
+		log.Info("Watching docs", "url", *docs, "interval", *interval)
+		if err := watcher.Run(ctx, *interval); err != nil && ctx.Err() == nil {
+			return err
+		}
+		return nil
+	}
+
+	report, err := watcher.Check(ctx)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		fmt.Print(report.Diff.Text())
+	}
+
+	return nil
+}
+
+/*
+reextract has the model update a config from the docs sections that changed,
+rather than extracting the whole API again. It gets the current config and the
+changed sections in full, and the headings of the removed ones.
+*/
+func reextract(client *openai.Client, iterations int) watch.Extractor {
+	return func(ctx context.Context, current *models.APIConfig, changed, removed []watch.Section) (*models.APIConfig, error) {
+		config, err := json.MarshalIndent(current, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error encoding config: %w", err)
+		}
+
+		var prompt strings.Builder
+		fmt.Fprintf(&prompt, "Here is the current configuration:\n\n%s\n", config)
+
+		if len(changed) > 0 {
+			prompt.WriteString("\nThese sections of the documentation are new or changed:\n")
+			for _, s := range changed {
+				fmt.Fprintf(&prompt, "\n--- %s (%s)\n\n%s\n", s.Heading, s.Page, s.Content)
+			}
+		}
+
+		if len(removed) > 0 {
+			prompt.WriteString("\nThese sections were removed from the documentation:\n")
+			for _, s := range removed {
+				fmt.Fprintf(&prompt, "- %s (%s)\n", s.Heading, s.Page)
+			}
+		}
+
+		buffer := openai.NewBuffer(
+			`
+		You are an advanced API integration expert.
+		You maintain a configuration file that drives an API Integration Engine, extracted earlier from the API's documentation.
+		The documentation has changed since, and you will be given the current configuration and only the sections of the documentation that changed.
+		Update the endpoints, data models and auth of the configuration to match the changed sections, and drop what removed sections described.
+		Keep everything the changes don't affect exactly as it is, including names, so the old and new configuration can be compared.
+		Return the complete updated configuration.
+		`,
+			prompt.String(),
+		)
+
+		log.Info("Re-extracting changed sections", "changed", len(changed), "removed", len(removed))
+		result, err := client.WithContext(ctx).Execute(buffer, iterations)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := models.ParseAPIConfig([]byte(result))
+		if err != nil {
+			return nil, err
+		}
+
+		updated.MarkPartial(client.Stopped())
+		return updated, nil
+	}
+}
@@ -0,0 +1,52 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/charmbracelet/log"
+)
+
+// notify writes the report to the report file and posts it to the webhook, whichever are set
+func (w *Watcher) notify(ctx context.Context, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report: %w", err)
+	}
+
+	if w.reportPath != "" {
+		if err := os.WriteFile(w.reportPath, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("error writing report: %w", err)
+		}
+		log.Info("Wrote drift report", "path", w.reportPath)
+	}
+
+	if w.webhook == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.webhook, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting report to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook failed with status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	log.Info("Posted drift report", "webhook", w.webhook, "status", resp.StatusCode)
+	return nil
+}
@@ -0,0 +1,65 @@
+package watch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Section is a part of a docs page, from one heading up to the next
+type Section struct {
+	Page    string `json:"page"`
+	Heading string `json:"heading"`
+	Hash    string `json:"hash"`
+	Content string `json:"-"`
+}
+
+/*
+sections splits a page's markdown at its headings and hashes every section, so
+a change shows up in the sections it touches rather than the whole page. Lines
+in code blocks that look like headings don't split. Whitespace is normalized
+before hashing, so reflowed text doesn't count as a change. Headings that occur
+more than once are numbered to keep them apart.
+*/
+func sections(page, markdown string) []Section {
+	var (
+		out     []Section
+		heading = "(top)"
+		lines   []string
+		fenced  bool
+		seen    = map[string]int{}
+	)
+
+	flush := func() {
+		content := strings.TrimSpace(strings.Join(lines, "\n"))
+		lines = nil
+		if content == "" {
+			return
+		}
+
+		key := heading
+		if seen[heading]++; seen[heading] > 1 {
+			key = fmt.Sprintf("%s (%d)", heading, seen[heading])
+		}
+
+		sum := sha256.Sum256([]byte(strings.Join(strings.Fields(content), " ")))
+		out = append(out, Section{Page: page, Heading: key, Hash: hex.EncodeToString(sum[:]), Content: content})
+	}
+
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+
+		if !fenced && strings.HasPrefix(trimmed, "#") {
+			flush()
+			heading = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	return out
+}
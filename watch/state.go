@@ -0,0 +1,54 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+/*
+state is what the watcher remembers between checks: the hash of every section
+of every page, the config the latest re-extraction produced, which the next
+re-extraction builds on, and a report that couldn't be sent out yet.
+*/
+type state struct {
+	URL       string               `json:"url"`
+	CheckedAt time.Time            `json:"checked_at"`
+	Sections  map[string][]Section `json:"sections"`
+	Candidate *models.APIConfig    `json:"candidate,omitempty"`
+	Pending   *Report              `json:"pending,omitempty"`
+}
+
+// loadState reads the state file, starting empty when there is none yet
+func loadState(path string) (*state, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &state{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading watch state: %w", err)
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("error parsing watch state: %w", err)
+	}
+
+	return &st, nil
+}
+
+func (st *state) save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding watch state: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing watch state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/theapemachine/idrinkyourmilkshake/browser"
+	"github.com/theapemachine/idrinkyourmilkshake/diff"
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+	"github.com/theapemachine/idrinkyourmilkshake/request"
+)
+
+// defaultMaxPages stops a crawl of huge docs sites, the entry page and its neighbours are what matter
+const defaultMaxPages = 50
+
+// Fetcher loads a page, returning its content as markdown and the absolute URLs it links to
+type Fetcher func(url string) (string, []string, error)
+
+/*
+Extractor updates a config from the sections of the docs that changed since it
+was extracted, and those that were removed, returning the whole updated config.
+*/
+type Extractor func(ctx context.Context, current *models.APIConfig, changed, removed []Section) (*models.APIConfig, error)
+
+// SectionChange is a section of the docs that was added, changed or removed since the last check
+type SectionChange struct {
+	Page    string `json:"page"`
+	Heading string `json:"heading"`
+	Kind    string `json:"kind"`
+}
+
+// Report is the outcome of a check that found the docs changed
+type Report struct {
+	URL         string          `json:"url"`
+	Integration string          `json:"integration"`
+	CheckedAt   time.Time       `json:"checked_at"`
+	Pages       int             `json:"pages"`
+	Sections    []SectionChange `json:"sections"`
+	Diff        *diff.Report    `json:"diff"`
+}
+
+/*
+Watcher monitors the docs of an API for drift from a baseline config. Every check
+crawls the docs, hashes their sections, and re-extracts only the sections that
+changed since the previous check. The re-extracted config is compared with the
+baseline, and a report goes to a file or webhook when endpoints or models
+differ. What it saw is kept in a state file, so checks continue across runs.
+*/
+type Watcher struct {
+	url        string
+	baseline   *models.APIConfig
+	statePath  string
+	maxPages   int
+	fetch      Fetcher
+	extract    Extractor
+	reportPath string
+	webhook    string
+	client     *http.Client
+}
+
+// New creates a Watcher for the docs at url, watching for drift from baseline
+func New(url string, baseline *models.APIConfig) *Watcher {
+	return &Watcher{
+		url:       url,
+		baseline:  baseline,
+		statePath: "watch-state.json",
+		maxPages:  defaultMaxPages,
+		fetch:     browser.Visit,
+		client:    request.Client(),
+	}
+}
+
+// WithState sets the file the watcher keeps the section hashes and latest extraction in
+func (w *Watcher) WithState(path string) *Watcher {
+	w.statePath = path
+	return w
+}
+
+// WithMaxPages sets how many pages a crawl visits at most
+func (w *Watcher) WithMaxPages(pages int) *Watcher {
+	w.maxPages = pages
+	return w
+}
+
+// WithFetcher replaces the browser the docs are loaded with
+func (w *Watcher) WithFetcher(fetch Fetcher) *Watcher {
+	w.fetch = fetch
+	return w
+}
+
+// WithExtractor sets what re-extracts the config from changed sections
+func (w *Watcher) WithExtractor(extract Extractor) *Watcher {
+	w.extract = extract
+	return w
+}
+
+// WithReportFile writes the report of every check that finds drift to this file
+func (w *Watcher) WithReportFile(path string) *Watcher {
+	w.reportPath = path
+	return w
+}
+
+// WithWebhook posts the report of every check that finds drift to this URL as JSON
+func (w *Watcher) WithWebhook(url string) *Watcher {
+	w.webhook = url
+	return w
+}
+
+// Run checks the docs every interval until the context is done, logging failed checks rather than giving up
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Check(ctx); err != nil {
+			log.Error("Check failed", "url", w.url, "error", err)
+		}
+
+		log.Info("Next check", "at", time.Now().Add(interval).Format(time.RFC3339))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Check crawls the docs once and compares their sections with the previous check.
+The first check only records the sections. When sections changed, they are
+re-extracted on top of the latest extraction, and the result is compared with
+the baseline. The report is returned and sent out when the configs differ, nil
+when nothing changed. A failed extraction leaves the state alone, so the next
+check tries the same changes again. A report that couldn't be sent is kept in the
+state, and sent before anything else on the next check.
+*/
+func (w *Watcher) Check(ctx context.Context) (*Report, error) {
+	st, err := loadState(w.statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if st.Pending != nil {
+		if err := w.notify(ctx, st.Pending); err != nil {
+			return nil, fmt.Errorf("error resending the report of the check at %s: %w", st.Pending.CheckedAt.Format(time.RFC3339), err)
+		}
+		log.Info("Resent drift report", "checked_at", st.Pending.CheckedAt.Format(time.RFC3339))
+
+		st.Pending = nil
+		if err := st.save(w.statePath); err != nil {
+			return nil, err
+		}
+	}
+
+	current := w.crawl(st.Sections)
+	if len(current) == 0 {
+		return nil, fmt.Errorf("no pages could be loaded from %s", w.url)
+	}
+
+	report := &Report{URL: w.url, Integration: w.baseline.Integration, CheckedAt: time.Now(), Pages: len(current)}
+
+	if st.Sections == nil {
+		st.URL, st.CheckedAt, st.Sections = w.url, report.CheckedAt, current
+		log.Info("Recorded initial snapshot of the docs", "url", w.url, "pages", len(current))
+		return nil, st.save(w.statePath)
+	}
+
+	changed, removed := compare(st.Sections, current)
+	for _, s := range changed {
+		kind := diff.Changed
+		if !slices.ContainsFunc(st.Sections[s.Page], func(old Section) bool { return old.Heading == s.Heading }) {
+			kind = diff.Added
+		}
+		report.Sections = append(report.Sections, SectionChange{Page: s.Page, Heading: s.Heading, Kind: kind})
+	}
+	for _, s := range removed {
+		report.Sections = append(report.Sections, SectionChange{Page: s.Page, Heading: s.Heading, Kind: diff.Removed})
+	}
+
+	if len(report.Sections) == 0 {
+		st.CheckedAt = report.CheckedAt
+		log.Info("Docs unchanged", "url", w.url, "pages", len(current))
+		return nil, st.save(w.statePath)
+	}
+
+	log.Info("Docs changed", "url", w.url, "changed", len(changed), "removed", len(removed))
+
+	if w.extract == nil {
+		return nil, fmt.Errorf("docs changed but there is no extractor to re-extract them")
+	}
+
+	latest := st.Candidate
+	if latest == nil {
+		latest = w.baseline
+	}
+
+	updated, err := w.extract(ctx, latest, changed, removed)
+	if err != nil {
+		return nil, fmt.Errorf("error re-extracting changed sections: %w", err)
+	}
+
+	report.Diff = diff.Compare(w.baseline, updated)
+
+	// The report is pending until it went out, so the drift is never lost along with the changed sections.
+	st.CheckedAt, st.Sections, st.Candidate = report.CheckedAt, current, updated
+	if report.Diff.Summary.Changes > 0 {
+		st.Pending = report
+	}
+	if err := st.save(w.statePath); err != nil {
+		return nil, err
+	}
+
+	if report.Diff.Summary.Changes == 0 {
+		log.Info("Re-extracted config matches the baseline", "url", w.url)
+		return nil, nil
+	}
+
+	log.Warn("Config drifted from the baseline", "breaking", report.Diff.Summary.Breaking, "non_breaking", report.Diff.Summary.NonBreaking)
+	if err := w.notify(ctx, report); err != nil {
+		return report, err
+	}
+
+	st.Pending = nil
+	return report, st.save(w.statePath)
+}
+
+/*
+crawl visits the docs page and the pages it links to under the same path, breadth
+first, and returns the sections of each. Pages that fail to load keep their
+sections from the previous check, so a flaky page doesn't count as removed.
+*/
+func (w *Watcher) crawl(previous map[string][]Section) map[string][]Section {
+	start, err := url.Parse(w.url)
+	if err != nil {
+		log.Error("Invalid docs URL", "url", w.url, "error", err)
+		return nil
+	}
+
+	scope := start.Path
+	if strings.Contains(path.Base(scope), ".") {
+		scope = path.Dir(scope)
+	}
+	scope = strings.TrimSuffix(scope, "/") + "/"
+
+	out := map[string][]Section{}
+	queue := []string{normalize(start)}
+	seen := map[string]bool{queue[0]: true}
+
+	for len(queue) > 0 {
+		page := queue[0]
+		queue = queue[1:]
+
+		markdown, links, err := w.fetch(page)
+		if err != nil {
+			log.Warn("Could not load page", "url", page, "error", err)
+			if old, ok := previous[page]; ok {
+				out[page] = old
+			}
+			continue
+		}
+		out[page] = sections(page, markdown)
+
+		for _, link := range links {
+			u, err := url.Parse(link)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.EqualFold(u.Host, start.Host) {
+				continue
+			}
+			if u.Path != start.Path && !strings.HasPrefix(u.Path, scope) {
+				continue
+			}
+
+			next := normalize(u)
+			if !seen[next] && len(seen) < w.maxPages {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return out
+}
+
+// compare returns the sections that are new or changed in current, and the ones current no longer has
+func compare(previous, current map[string][]Section) ([]Section, []Section) {
+	var changed, removed []Section
+
+	for _, page := range slices.Sorted(maps.Keys(current)) {
+		old := map[string]string{}
+		for _, s := range previous[page] {
+			old[s.Heading] = s.Hash
+		}
+		for _, s := range current[page] {
+			if old[s.Heading] != s.Hash {
+				changed = append(changed, s)
+			}
+		}
+	}
+
+	for _, page := range slices.Sorted(maps.Keys(previous)) {
+		now := map[string]bool{}
+		for _, s := range current[page] {
+			now[s.Heading] = true
+		}
+		for _, s := range previous[page] {
+			if !now[s.Heading] {
+				removed = append(removed, s)
+			}
+		}
+	}
+
+	return changed, removed
+}
+
+// normalize drops the fragment of a URL, since anchors on a page don't make it another page
+func normalize(u *url.URL) string {
+	c := *u
+	c.Fragment = ""
+	c.RawFragment = ""
+	return c.String()
+}
@@ -0,0 +1,271 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/theapemachine/idrinkyourmilkshake/models"
+)
+
+func TestSections(t *testing.T) {
+	markdown := "Intro text\n\n# Employees\nList them.\n\n```sh\n# not a heading\ncurl /employees\n```\n\n## Errors\nOops\n\n## Errors\nMore oops\n\n#\n"
+
+	var headings []string
+	for _, s := range sections("https://docs.example.com", markdown) {
+		headings = append(headings, s.Heading)
+		if s.Page != "https://docs.example.com" || len(s.Hash) != 64 {
+			t.Errorf("section %q has page %q and hash %q", s.Heading, s.Page, s.Hash)
+		}
+	}
+
+	if want := []string{"(top)", "Employees", "Errors", "Errors (2)", ""}; !reflect.DeepEqual(headings, want) {
+		t.Errorf("headings = %q, want %q", headings, want)
+	}
+
+	hash := func(markdown string) string { return sections("page", markdown)[0].Hash }
+
+	if hash("# A\nSome   text\nwrapped") != hash("# A\n\nSome text wrapped  ") {
+		t.Error("reflowing a section changed its hash")
+	}
+	if hash("# A\nSome text") == hash("# A\nOther text") {
+		t.Error("changing a section kept its hash")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	section := func(page, heading, hash string) Section {
+		return Section{Page: page, Heading: heading, Hash: hash}
+	}
+
+	previous := map[string][]Section{
+		"a": {section("a", "Intro", "1"), section("a", "Auth", "2"), section("a", "Old", "3")},
+		"b": {section("b", "Gone", "4")},
+	}
+	current := map[string][]Section{
+		"a": {section("a", "Intro", "1"), section("a", "Auth", "changed"), section("a", "New", "5")},
+		"c": {section("c", "Page", "6")},
+	}
+
+	changed, removed := compare(previous, current)
+
+	if want := []Section{section("a", "Auth", "changed"), section("a", "New", "5"), section("c", "Page", "6")}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if want := []Section{section("a", "Old", "3"), section("b", "Gone", "4")}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+
+	if changed, removed := compare(current, current); len(changed)+len(removed) > 0 {
+		t.Errorf("comparing with itself found %v and %v", changed, removed)
+	}
+}
+
+// docs is a fake docs site of linked pages, whose content tests change between checks
+type docs struct {
+	mu    sync.Mutex
+	pages map[string]string
+	links map[string][]string
+}
+
+func (d *docs) fetch(url string) (string, []string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	content, ok := d.pages[url]
+	if !ok {
+		return "", nil, fmt.Errorf("no page at %s", url)
+	}
+	return content, d.links[url], nil
+}
+
+func (d *docs) set(url, content string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pages[url] = content
+}
+
+// extractor adds an endpoint for every changed section, and records what it was asked to re-extract
+type extractor struct {
+	calls   int
+	changed []Section
+	removed []Section
+	err     error
+}
+
+func (e *extractor) extract(ctx context.Context, current *models.APIConfig, changed, removed []Section) (*models.APIConfig, error) {
+	e.calls++
+	e.changed, e.removed = changed, removed
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	updated := *current
+	updated.Endpoints = append([]models.Endpoint{}, current.Endpoints...)
+	for _, s := range changed {
+		updated.Endpoints = append(updated.Endpoints, models.Endpoint{Name: "list_" + s.Heading, Method: "GET", Path: "/" + s.Heading})
+	}
+	return &updated, nil
+}
+
+// webhook receives the reports, and fails while down is set
+type webhook struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	down    bool
+	reports []string
+}
+
+func newWebhook(t *testing.T) *webhook {
+	t.Helper()
+
+	h := &webhook{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if h.down {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		h.reports = append(h.reports, string(body))
+	}))
+	t.Cleanup(h.Close)
+
+	return h
+}
+
+func (h *webhook) received() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.reports)
+}
+
+func (h *webhook) setDown(down bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.down = down
+}
+
+func TestCheck(t *testing.T) {
+	const (
+		index     = "https://docs.example.com/api/"
+		employees = "https://docs.example.com/api/employees"
+	)
+
+	site := &docs{
+		pages: map[string]string{index: "# Overview\nWelcome", employees: "# employees\nGET /employees"},
+		links: map[string][]string{index: {employees, "https://docs.example.com/blog", "https://other.example.com/api/x"}},
+	}
+	ex := &extractor{}
+	hook := newWebhook(t)
+
+	baseline := &models.APIConfig{Integration: "test", Endpoints: []models.Endpoint{{Name: "list_employees", Method: "GET", Path: "/employees"}}}
+	watcher := New(index, baseline).
+		WithState(filepath.Join(t.TempDir(), "state.json")).
+		WithFetcher(site.fetch).
+		WithExtractor(ex.extract).
+		WithWebhook(hook.URL)
+	watcher.client = hook.Client()
+
+	check := func(t *testing.T) *Report {
+		t.Helper()
+		report, err := watcher.Check(context.Background())
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		return report
+	}
+
+	t.Run("the first check records the docs", func(t *testing.T) {
+		if report := check(t); report != nil || ex.calls != 0 {
+			t.Errorf("got %v after %d extractions, want nothing", report, ex.calls)
+		}
+	})
+
+	t.Run("unchanged docs", func(t *testing.T) {
+		if report := check(t); report != nil || ex.calls != 0 {
+			t.Errorf("got %v after %d extractions, want nothing", report, ex.calls)
+		}
+	})
+
+	t.Run("a failed extraction is retried", func(t *testing.T) {
+		site.set(employees, "# employees\nGET /employees\n\n# teams\nGET /teams")
+		ex.err = errors.New("model unavailable")
+
+		if _, err := watcher.Check(context.Background()); err == nil {
+			t.Fatal("Check succeeded, want the extraction error")
+		}
+		ex.err = nil
+	})
+
+	t.Run("changed sections are re-extracted and reported", func(t *testing.T) {
+		report := check(t)
+		if report == nil {
+			t.Fatal("no report, want the drift reported")
+		}
+
+		if want := []SectionChange{{Page: employees, Heading: "teams", Kind: "added"}}; !reflect.DeepEqual(report.Sections, want) {
+			t.Errorf("sections = %v, want %v", report.Sections, want)
+		}
+		if len(ex.changed) != 1 || ex.changed[0].Heading != "teams" {
+			t.Errorf("re-extracted %v, want only the new section", ex.changed)
+		}
+		if report.Pages != 2 || report.Diff.Summary.Changes != 1 {
+			t.Errorf("report covers %d pages and %d changes, want 2 and 1", report.Pages, report.Diff.Summary.Changes)
+		}
+		if hook.received() != 1 {
+			t.Errorf("webhook got %d reports, want 1", hook.received())
+		}
+	})
+
+	t.Run("a report that can't be sent is kept", func(t *testing.T) {
+		site.set(index, "# Overview\nWelcome\n\n# shifts\nGET /shifts")
+		hook.setDown(true)
+
+		if _, err := watcher.Check(context.Background()); err == nil {
+			t.Fatal("Check succeeded, want the webhook error")
+		}
+		// The docs didn't change since, but the report still has to go out.
+		if _, err := watcher.Check(context.Background()); err == nil {
+			t.Fatal("Check succeeded with the webhook still down")
+		}
+
+		hook.setDown(false)
+		calls := ex.calls
+		if report := check(t); report != nil {
+			t.Errorf("got %v, want only the pending report resent", report)
+		}
+		if ex.calls != calls {
+			t.Error("re-extracted the sections the pending report covers")
+		}
+		if hook.received() != 2 {
+			t.Errorf("webhook got %d reports, want 2", hook.received())
+		}
+
+		if report := check(t); report != nil || hook.received() != 2 {
+			t.Errorf("got %v and %d reports, want the pending report sent only once", report, hook.received())
+		}
+	})
+
+	t.Run("removed sections", func(t *testing.T) {
+		site.set(employees, "# employees\nGET /employees")
+
+		report := check(t)
+		if report == nil {
+			t.Fatal("no report, want the drift reported")
+		}
+		if len(ex.removed) != 1 || ex.removed[0].Heading != "teams" {
+			t.Errorf("removed = %v, want the teams section", ex.removed)
+		}
+	})
+}